@@ -2,13 +2,14 @@ package api
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
-	"math/big"
 	"net/http"
 	"strings"
-	"sync"
 	"tinypay-server/config"
+	"tinypay-server/locks"
+	"tinypay-server/store"
 	"tinypay-server/utils"
 
 	"tinypay-server/client"
@@ -18,105 +19,145 @@ import (
 
 // APIServer implements the ServerInterface generated by oapi-codegen
 type APIServer struct {
-	aptosClient   *client.AptosClient
-	evmClients    map[string]*client.EVMClient    // Map of network name to EVM client
-	solanaClients map[string]*client.SolanaClient // Map of network name to Solana client
-	payerLocks    map[string]*sync.Mutex
-	locksMutex    sync.RWMutex
-	config        *config.Config
+	backends    *client.BackendRegistry // Map of network name to chain backend
+	ledger      *store.Store            // Persistent record of every payment request
+	submissions *submissionQueue        // Worker pools for asynchronous payments
+	tails       *client.TailCache       // On-chain tails for OTP pre-verification
+	payerLocks  locks.Manager           // Serializes submissions per payer, possibly across replicas
+	paymasters  *paymasterMonitor       // Samples paymaster balances and reports networks that ran dry
+	webhooks    *webhookDispatcher      // Delivers payment events to merchant webhooks
+	config      *config.Config
 }
 
 // NewAPIServer creates a new API server instance
-func NewAPIServer(aptosClient *client.AptosClient, evmClients map[string]*client.EVMClient, solanaClients map[string]*client.SolanaClient, cfg *config.Config) *APIServer {
-	return &APIServer{
-		aptosClient:   aptosClient,
-		evmClients:    evmClients,
-		solanaClients: solanaClients,
-		payerLocks:    make(map[string]*sync.Mutex),
-		locksMutex:    sync.RWMutex{},
-		config:        cfg,
+func NewAPIServer(backends *client.BackendRegistry, ledger *store.Store, payerLocks locks.Manager, cfg *config.Config) *APIServer {
+	s := &APIServer{
+		backends:   backends,
+		ledger:     ledger,
+		tails:      client.NewTailCache(cfg.OTPTailCacheTTL),
+		payerLocks: payerLocks,
+		config:     cfg,
 	}
+	s.submissions = newSubmissionQueue(s, backends.Networks())
+	s.paymasters = newPaymasterMonitor(backends, cfg)
+	s.paymasters.start()
+	s.webhooks = newWebhookDispatcher(ledger, cfg)
+	s.webhooks.start()
+
+	// Stuck transactions that are sped up or cancelled move their payment to the new hash
+	for _, network := range backends.Networks() {
+		if replacer, ok := backends.Get(network).(client.TransactionReplacer); ok {
+			network := network
+			replacer.OnReplaced(func(replacement client.TransactionReplacement) {
+				s.recordReplacement(network, replacement)
+			})
+		}
+	}
+	return s
 }
 
-// getEVMClient returns the EVM client for the specified network
-func (s *APIServer) getEVMClient(network string) *client.EVMClient {
-	if s.evmClients == nil {
-		return nil
-	}
-	return s.evmClients[network]
+// Close stops the submission workers, the paymaster monitor and the webhook dispatcher; queued
+// payments that were not picked up stay in the received state, and webhook deliveries stay queued
+func (s *APIServer) Close() {
+	s.submissions.stop()
+	s.paymasters.stop()
+	s.webhooks.stop()
 }
 
-// getSolanaClient returns the Solana client for the specified network
-func (s *APIServer) getSolanaClient(network string) *client.SolanaClient {
-	if s.solanaClients == nil {
-		return nil
+// submitPayment sends a payment to the chain while holding the payer lock and records the outcome in the ledger
+func (s *APIServer) submitPayment(ctx context.Context, paymentID, network string, payment *client.Payment) (*client.Submission, error) {
+	// Only one payment per payer is in flight, since each spends the payer's current tail
+	lease, err := s.payerLocks.Acquire(ctx, s.payerLockKey(network, payment.PayerAddr))
+	if err != nil {
+		log.Printf("Failed to lock payer %s on %s: %v", payment.PayerAddr, network, err)
+		s.markPaymentFailed(paymentID, paymentErrorCode(err), err.Error())
+		return nil, err
+	}
+	defer func() {
+		if err := lease.Release(); err != nil {
+			log.Printf("Failed to release lock of payer %s on %s: %v", payment.PayerAddr, network, err)
+		}
+	}()
+
+	// The payment moves the payer's tail, or shows the cached one was stale
+	defer s.tails.Invalidate(s.getBackend(network), payment.PayerAddr)
+
+	// From here on the transaction may reach the chain, so a restart must not simply fail it
+	if _, err := s.ledger.MarkSubmitting(paymentID); err != nil {
+		log.Printf("Failed to mark payment %s as submitting: %v", paymentID, err)
+		return nil, fmt.Errorf("failed to record payment %s: %w", paymentID, err)
 	}
-	return s.solanaClients[network]
+
+	submission, err := s.getBackend(network).SendPayment(ctx, payment)
+	if err != nil {
+		log.Printf("Failed to complete payment on %s: %v", network, err)
+		s.markPaymentFailed(paymentID, paymentErrorCode(err), err.Error())
+		return nil, err
+	}
+	s.markPaymentSubmitted(paymentID, submission)
+	return submission, nil
+}
+
+// getBackend returns the chain backend for the specified network
+func (s *APIServer) getBackend(network string) client.ChainBackend {
+	return s.backends.Get(network)
 }
 
-// isNetworkAvailable checks if a network is properly configured and available
+// isNetworkAvailable checks if a network is properly configured and available. A network whose
+// paymasters all ran below the unavailable threshold is reported unavailable before its payments
+// start failing on chain.
 func (s *APIServer) isNetworkAvailable(network string) (bool, error) {
-    switch network {
-    case "aptos-testnet":
-        if s.aptosClient == nil {
-            return false, fmt.Errorf("aptos client not initialized")
-        }
-        // Check basic configuration
-        if s.config.ContractAddress == "" {
-            return false, fmt.Errorf("aptos contract address not configured")
-        }
-        return true, nil
-    default:
-        // Check if it's a Solana network first
-        solanaClient := s.getSolanaClient(network)
-        if solanaClient != nil {
-            // Solana network found and initialized
-            return true, nil
-        }
-        
-        // Treat as EVM network configured via array
-        evmClient := s.getEVMClient(network)
-        if evmClient == nil {
-            return false, fmt.Errorf("evm client not initialized for %s", network)
-        }
-        if netCfg := utils.GetEVMNetworkConfig(s.config, network); netCfg != nil {
-            if strings.TrimSpace(netCfg.RPCURL) == "" || strings.TrimSpace(netCfg.ContractAddress) == "" || strings.TrimSpace(netCfg.PrivateKey) == "" {
-                return false, fmt.Errorf("evm network %s not properly configured", network)
-            }
-            return true, nil
-        }
-        return false, fmt.Errorf("unsupported network: %s", network)
-    }
+	if available, err := s.isNetworkConfigured(network); !available {
+		return false, err
+	}
+	if s.paymasters != nil {
+		return s.paymasters.available(network)
+	}
+	return true, nil
+}
+
+// isNetworkConfigured checks that a backend is registered for a network, whether or not it can
+// take payments right now
+func (s *APIServer) isNetworkConfigured(network string) (bool, error) {
+	backend := s.getBackend(network)
+	if backend == nil {
+		return false, fmt.Errorf("client not initialized for %s", network)
+	}
+	if checker, ok := backend.(client.ConfigChecker); ok {
+		if err := checker.CheckConfig(); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
 }
 
 // validateNetworkAndCurrency performs comprehensive network and currency validation
-func (s *APIServer) validateNetworkAndCurrency(network, currency string) error {
-    // Use the comprehensive validation from utils package (dynamic)
-    if err := utils.ValidateNetworkCurrencyCombination(s.config, network, currency); err != nil {
-        return err
-    }
+// and returns the coin type the backend resolved for the currency
+func (s *APIServer) validateNetworkAndCurrency(network, currency string) (string, error) {
+	// Use the comprehensive validation from utils package (dynamic)
+	if err := utils.ValidateNetworkCurrencyCombination(s.config, network, currency); err != nil {
+		return "", err
+	}
 
 	// Check if network is available
 	available, err := s.isNetworkAvailable(network)
 	if !available {
 		log.Printf("Network %s is not available: %v", network, err)
-		return fmt.Errorf("network %s is not available: %w", network, err)
+		return "", fmt.Errorf("network %s is not available: %w", network, err)
 	}
 
-    // Additional network-specific configuration validation
-    switch strings.ToLower(network) {
-    case "aptos-testnet":
-        if currency == "USDC" && strings.TrimSpace(s.config.USDCMetadataAddress) == "" {
-            return fmt.Errorf("aptos USDC metadata address not configured")
-        }
-    }
+	// Network-specific currency configuration validation
+	coinType, err := s.getBackend(network).ResolveCoinType(currency)
+	if err != nil {
+		return "", err
+	}
 
-	return nil
+	return coinType, nil
 }
 
 // getDetailedValidationError returns a detailed error message for validation failures
 func (s *APIServer) getDetailedValidationError(network, currency string) map[string]interface{} {
-    matrix := utils.NewNetworkCurrencyValidationMatrix(s.config)
+	matrix := utils.NewNetworkCurrencyValidationMatrix(s.config)
 
 	data := map[string]interface{}{
 		"error":              "Invalid network-currency combination",
@@ -131,36 +172,107 @@ func (s *APIServer) getDetailedValidationError(network, currency string) map[str
 	}
 
 	// Add suggestion for default currency if network is valid
-    if defaultCurrency := utils.GetDefaultCurrencyForNetwork(s.config, network); defaultCurrency != "" {
-        data["suggested_currency"] = defaultCurrency
-    }
+	if defaultCurrency := utils.GetDefaultCurrencyForNetwork(s.config, network); defaultCurrency != "" {
+		data["suggested_currency"] = defaultCurrency
+	}
 
 	return data
 }
 
-// getPayerLock returns the mutex for a specific payer address, creating one if it doesn't exist
-func (s *APIServer) getPayerLock(payerAddr string) *sync.Mutex {
-	// First try to get the lock with read lock
-	s.locksMutex.RLock()
-	if lock, exists := s.payerLocks[payerAddr]; exists {
-		s.locksMutex.RUnlock()
-		return lock
+// payerLockKey identifies a payer on a network, whatever spelling of the address was submitted
+func (s *APIServer) payerLockKey(network, payerAddr string) string {
+	return strings.ToLower(network) + "/" + client.NormalizeAddress(s.getBackend(network), payerAddr)
+}
+
+// requestNetwork returns the network named by the query parameters, defaulting to aptos-testnet
+func requestNetwork(c *gin.Context, network *string) string {
+	if network != nil {
+		return *network
 	}
-	s.locksMutex.RUnlock()
+	if qn := c.Query("network"); qn != "" {
+		return qn
+	}
+	return client.AptosNetworkName
+}
 
-	// If lock doesn't exist, acquire write lock and create it
-	s.locksMutex.Lock()
-	defer s.locksMutex.Unlock()
+// failureReasonCodes maps decoded on-chain rejections to business status codes
+var failureReasonCodes = map[client.FailureReason]int{
+	client.FailureInvalidOTP:          CodeInvalidOpt,
+	client.FailureLimitExceeded:       CodeAmountExceedsLimit,
+	client.FailureInsufficientBalance: CodeInsufficientBalance,
+	client.FailureInvalidAmount:       CodeAmountMustBePositive,
+	client.FailureUnsupportedCurrency: CodeInvalidNetworkCurrency,
+	client.FailurePrecommit:           CodePrecommitUnavailable,
+}
 
-	// Double-check in case another goroutine created it while we were waiting
-	if lock, exists := s.payerLocks[payerAddr]; exists {
-		return lock
+// chainErrorCode maps a decoded on-chain rejection to a business status code
+func chainErrorCode(chainErr *client.ChainError) int {
+	if code, ok := failureReasonCodes[chainErr.Reason]; ok {
+		return code
 	}
+	return CodeTransactionRejected
+}
 
-	// Create new lock for this payer
-	lock := &sync.Mutex{}
-	s.payerLocks[payerAddr] = lock
-	return lock
+// paymentErrorResponse builds the error response of a failed submission. Rejections decoded
+// from the chain carry their original reason; other errors stay opaque to the caller.
+func paymentErrorResponse(err error) ApiResponse {
+	var chainErr *client.ChainError
+	if errors.As(err, &chainErr) {
+		data := map[string]interface{}{
+			"reason":      chainErr.Message,
+			"chain_error": chainErr.Code,
+		}
+		return CreateApiResponseWithMap(chainErrorCode(chainErr), data)
+	}
+	return CreateApiResponseWithNullData(paymentErrorCode(err))
+}
+
+// paymentErrorCode maps a payment submission error to a business status code
+func paymentErrorCode(err error) int {
+	var chainErr *client.ChainError
+	if errors.As(err, &chainErr) {
+		return chainErrorCode(chainErr)
+	}
+	if errors.Is(err, locks.ErrBusy) {
+		return CodePayerBusy
+	}
+	if errors.Is(err, client.ErrNoPaymasterAvailable) {
+		return CodePaymasterUnavailable
+	}
+
+	// Errors that were not decoded from the chain are classified by their text
+	errorMsg := strings.ToLower(err.Error())
+
+	switch {
+	case strings.Contains(errorMsg, "insufficient") || strings.Contains(errorMsg, "balance"):
+		return CodeInsufficientBalance
+	case strings.Contains(errorMsg, "amount") && (strings.Contains(errorMsg, "invalid") || strings.Contains(errorMsg, "zero")):
+		return CodeAmountMustBePositive
+	case strings.Contains(errorMsg, "limit") || strings.Contains(errorMsg, "exceed"):
+		return CodeAmountExceedsLimit
+	case strings.Contains(errorMsg, "connection") || strings.Contains(errorMsg, "rpc"):
+		return CodeNetworkConnectionError
+	case strings.Contains(errorMsg, "config"):
+		return CodeNetworkConfigError
+	default:
+		return CodeInvalidOpt
+	}
+}
+
+// lookupErrorCode maps a transaction or user lookup error to a business status code
+func lookupErrorCode(err error, fallback int) int {
+	errorMsg := strings.ToLower(err.Error())
+
+	switch {
+	case strings.Contains(errorMsg, "not found"):
+		return fallback
+	case strings.Contains(errorMsg, "connection") || strings.Contains(errorMsg, "rpc"):
+		return CodeNetworkConnectionError
+	case strings.Contains(errorMsg, "config"):
+		return CodeNetworkConfigError
+	default:
+		return fallback
+	}
 }
 
 // HealthCheck implements the health check endpoint
@@ -173,37 +285,151 @@ func (s *APIServer) HealthCheck(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
-// CreatePayment implements the payment creation endpoint
-func (s *APIServer) CreatePayment(c *gin.Context) {
-	var req PaymentRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		// Invalid request body format
-		response := CreateApiResponseWithNullData(CodeInvalidOpt)
-		c.JSON(http.StatusBadRequest, response)
+// createPayment validates a payment request and submits it to the chain, or queues it when async is set
+func (s *APIServer) createPayment(c *gin.Context, async bool, apiKeyID string) {
+	vp, ok := s.bindPaymentRequest(c)
+	if !ok {
 		return
 	}
+	req, network, currency, coinType, amount := vp.req, vp.network, vp.currency, vp.coinType, vp.amount
+
+	log.Printf("Processing payment on network: %s with currency: %s, coin type: %s", network, currency, coinType)
+
+	// Completing a merchant precommit requires the request to match what was committed
+	var precommit *store.Precommit
+	if req.PrecommitId != nil && *req.PrecommitId != "" {
+		if precommit, ok = s.precommitForPayment(c, *req.PrecommitId, vp); !ok {
+			return
+		}
+	}
+
+	// Payments the contract would reject are turned away before anything is signed
+	if !s.preflightPayment(c, vp) {
+		return
+	}
+
+	// Record the request before anything is sent to the chain
+	record := &store.Payment{
+		PayerAddr: req.PayerAddr,
+		PayeeAddr: req.PayeeAddr,
+		Amount:    amount,
+		Network:   network,
+		Currency:  currency,
+		CoinType:  coinType,
+		Async:     async,
+		APIKeyID:  apiKeyID,
+	}
+	if precommit != nil {
+		record.PrecommitID = precommit.ID
+	}
+	if err := s.ledger.CreatePayment(record); err != nil {
+		log.Printf("Failed to record payment in ledger: %v", err)
+		response := CreateApiResponseWithNullData(CodeStorageError)
+		c.JSON(http.StatusInternalServerError, response)
+		return
+	}
+
+	payment := &client.Payment{
+		PayerAddr: req.PayerAddr,
+		PayeeAddr: req.PayeeAddr,
+		Amount:    amount,
+		Otp:       req.Otp,
+		Currency:  currency,
+	}
+	if precommit != nil {
+		commitHash, err := s.claimPrecommit(precommit, record.ID)
+		if err != nil {
+			log.Printf("Failed to claim precommit %s for payment %s: %v", precommit.ID, record.ID, err)
+			s.markPaymentFailed(record.ID, CodePrecommitUnavailable, err.Error())
+			response := CreateApiResponseWithNullData(CodePrecommitUnavailable)
+			c.JSON(http.StatusBadRequest, response)
+			return
+		}
+		payment.CommitHash = commitHash
+		// The precommit belongs to the key that made it
+		payment.Sender = precommit.Paymaster
+	}
+
+	if async {
+		if !s.submissions.enqueue(&paymentJob{paymentID: record.ID, network: network, payment: payment}) {
+			log.Printf("Submission queue for %s is full, rejecting payment %s", network, record.ID)
+			s.markPaymentFailed(record.ID, CodeSubmissionQueueFull, "submission queue full")
+			response := CreateApiResponseWithNullData(CodeSubmissionQueueFull)
+			c.JSON(http.StatusServiceUnavailable, response)
+			return
+		}
 
-	// Early validation to get payer address for locking
-	if req.PayerAddr == "" {
-		missingFields := []string{"payer_addr"}
 		data := map[string]interface{}{
-			"missing_fields": missingFields,
+			"status":     string(store.StatusReceived),
+			"payment_id": record.ID,
+			"currency":   currency,
+			"network":    network,
+			"coin_type":  coinType,
 		}
-		response := CreateApiResponseWithMap(CodeMissingFields, data)
-		c.JSON(http.StatusBadRequest, response)
+		response := CreateApiResponseWithMap(CodeTransactionPending, data)
+		c.JSON(http.StatusAccepted, response)
+		return
+	}
+
+	submission, err := s.submitPayment(c.Request.Context(), record.ID, network, payment)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, locks.ErrBusy) {
+			status = http.StatusConflict
+		}
+		response := paymentErrorResponse(err)
+		c.JSON(status, response)
 		return
 	}
 
-	// Acquire lock for this specific payer to prevent concurrent payments
-	payerLock := s.getPayerLock(req.PayerAddr)
-	payerLock.Lock()
-	defer payerLock.Unlock()
+	// Webhooks hear about the outcome without anyone polling for it
+	if s.webhookSubscribed(record) {
+		s.submissions.follow(record.ID, network, submission.TxHash)
+	}
+
+	data := map[string]interface{}{
+		"status":           "submitted",
+		"payment_id":       record.ID,
+		"transaction_hash": submission.TxHash,
+		"currency":         currency,
+		"network":          network,
+		"coin_type":        coinType,
+	}
+	if fee := ledgerFee(submission.Fee); fee != nil {
+		data["fee"] = fee
+	}
+	response := CreateApiResponseWithMap(CodeTransactionCreated, data)
+	c.JSON(http.StatusOK, response)
+}
+
+// validatedPayment is a payment request that passed field, network and currency validation
+type validatedPayment struct {
+	req      PaymentRequest
+	network  string
+	currency string
+	coinType string
+	amount   uint64
+}
 
-	// Check for missing fields after successful JSON binding (PayerAddr already validated above)
-    missingFields := []string{}
-    if req.Otp == "" {
-        missingFields = append(missingFields, "otp")
-    }
+// bindPaymentRequest decodes and validates a payment request body. On failure it writes
+// the error response and returns false.
+func (s *APIServer) bindPaymentRequest(c *gin.Context) (*validatedPayment, bool) {
+	var req PaymentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		// Invalid request body format
+		response := CreateApiResponseWithNullData(CodeInvalidOpt)
+		c.JSON(http.StatusBadRequest, response)
+		return nil, false
+	}
+
+	// Check for missing fields after successful JSON binding
+	missingFields := []string{}
+	if req.PayerAddr == "" {
+		missingFields = append(missingFields, "payer_addr")
+	}
+	if req.Otp == "" {
+		missingFields = append(missingFields, "otp")
+	}
 	if req.PayeeAddr == "" {
 		missingFields = append(missingFields, "payee_addr")
 	}
@@ -217,11 +443,11 @@ func (s *APIServer) CreatePayment(c *gin.Context) {
 		}
 		response := CreateApiResponseWithMap(CodeMissingFields, data)
 		c.JSON(http.StatusBadRequest, response)
-		return
+		return nil, false
 	}
 
 	// Handle network type - default to aptos-testnet if not specified
-	network := "aptos-testnet"
+	network := client.AptosNetworkName
 	if req.Network != nil {
 		network = string(*req.Network)
 	}
@@ -230,18 +456,13 @@ func (s *APIServer) CreatePayment(c *gin.Context) {
 	currency := "APT"
 	if req.Currency != nil {
 		currency = string(*req.Currency)
-	} else {
-		if strings.ToLower(network) == "aptos-testnet" {
-			currency = "APT"
-		} else if netCfg := utils.GetEVMNetworkConfig(s.config, network); netCfg != nil {
-			currency = strings.ToUpper(netCfg.NativeToken.Symbol)
-		} else if netCfg := utils.GetSolanaNetworkConfig(s.config, network); netCfg != nil {
-			currency = strings.ToUpper(netCfg.NativeToken.Symbol)
-		}
+	} else if backend := s.getBackend(network); backend != nil {
+		currency = backend.DefaultCurrency()
 	}
 
 	// Validate network and currency combination with enhanced error handling
-	if err := s.validateNetworkAndCurrency(network, currency); err != nil {
+	coinType, err := s.validateNetworkAndCurrency(network, currency)
+	if err != nil {
 		log.Printf("Network/currency validation failed: %v", err)
 
 		// Determine appropriate error code based on the error type
@@ -257,6 +478,8 @@ func (s *APIServer) CreatePayment(c *gin.Context) {
 		} else if strings.Contains(errorMsg, "not supported on network") {
 			errorCode = CodeInvalidNetworkCurrency
 			responseData = s.getDetailedValidationError(network, currency)
+		} else if strings.Contains(errorMsg, "unsupported") {
+			errorCode = CodeInvalidNetworkCurrency
 		} else {
 			errorCode = CodeNetworkConfigError
 		}
@@ -268,206 +491,26 @@ func (s *APIServer) CreatePayment(c *gin.Context) {
 			response := CreateApiResponseWithNullData(errorCode)
 			c.JSON(http.StatusBadRequest, response)
 		}
-		return
-	}
-
-	var coinType string
-	var err error
-	
-	// Determine if this is a Solana network
-	isSolanaNetwork := s.getSolanaClient(network) != nil
-	
-	switch {
-	case network == "aptos-testnet":
-		// Get coin type from currency mapping
-		coinType, err = utils.GetCoinType(s.aptosClient.GetConfig(), currency)
-		if err != nil {
-			log.Printf("Unsupported currency: %s", currency)
-			response := CreateApiResponseWithNullData(CodeInvalidOpt)
-			c.JSON(http.StatusBadRequest, response)
-			return
-		}
-	case isSolanaNetwork:
-		// For Solana networks, use the currency as the coin type
-		coinType = currency
-		// No additional validation needed here, will be validated later
-	default:
-		// For EVM networks, we'll use the currency directly as the token identifier
-		coinType = currency
-		// Validate that we have an EVM client for this network
-		evmClient := s.getEVMClient(network)
-		if evmClient == nil {
-			log.Printf("EVM client not initialized for network %s", network)
-			response := CreateApiResponseWithNullData(CodeNetworkUnavailable)
-			c.JSON(http.StatusInternalServerError, response)
-			return
-		}
+		return nil, false
 	}
 
-	log.Printf("Processing payment on network: %s with currency: %s, coin type: %s", network, currency, coinType)
-
-    // Convert hex strings to bytes
-    optBytes := utils.HexToASCIIBytes(req.Otp)
-	log.Printf("\nAptos CLI format for otp:\n")
-	fmt.Printf("u8:[")
-	for i, b := range optBytes {
-		if i > 0 {
-			fmt.Printf(",")
-		}
-		fmt.Printf("%d", b)
-	}
-	fmt.Printf("]\n")
-
 	// Convert amount to uint64
 	amount := uint64(req.Amount)
 
 	// Check amount validation
-	if amount <= 0 {
+	if req.Amount <= 0 {
 		response := CreateApiResponseWithNullData(CodeAmountMustBePositive)
 		c.JSON(http.StatusBadRequest, response)
-		return
+		return nil, false
 	}
 
-	// Compute payment hash for the transaction
-	// note: 暂时不用商家自行处理 precommit
-	//commitHash, err := s.aptosClient.ComputePaymentHash(req.PayerAddr, req.PayeeAddr, amount, optBytes)
-	//if err != nil {
-	//	log.Printf("Failed to compute payment hash: %v", err)
-	//	response := CreateApiResponseWithNullData(CodeInvalidOpt)
-	//	c.JSON(http.StatusBadRequest, response)
-	//	return
-	//}
-
-	var txHash string
-	switch network {
-	case "aptos-testnet":
-		// Submit the transaction with FA support
-    txHash, err = s.aptosClient.CompletePaymentWithFA(optBytes, req.PayerAddr, req.PayeeAddr, amount, []byte(""), currency)
-		if err != nil {
-			log.Printf("Failed to complete Aptos payment: %v", err)
-			// todo:
-			// Randomly return one of the validation error codes (2000-2003)
-			errorCodes := []int{CodeAmountMustBePositive, CodeAmountExceedsLimit, CodeInsufficientBalance, CodeInvalidOpt}
-			randomCode := errorCodes[len(err.Error())%len(errorCodes)]
-			response := CreateApiResponseWithNullData(randomCode)
-			c.JSON(http.StatusBadRequest, response)
-			return
-		}
-	default:
-		// Check if it's a Solana network first
-		solanaClient := s.getSolanaClient(network)
-		if solanaClient != nil {
-			// Process Solana payment
-			payerPubkey, err := utils.ParseSolanaPublicKey(req.PayerAddr)
-			if err != nil {
-				log.Printf("Invalid Solana payer address: %v", err)
-				response := CreateApiResponseWithNullData(CodeInvalidOpt)
-				c.JSON(http.StatusBadRequest, response)
-				return
-			}
-
-			recipientPubkey, err := utils.ParseSolanaPublicKey(req.PayeeAddr)
-			if err != nil {
-				log.Printf("Invalid Solana recipient address: %v", err)
-				response := CreateApiResponseWithNullData(CodeInvalidOpt)
-				c.JSON(http.StatusBadRequest, response)
-				return
-			}
-
-			sig, err := solanaClient.CompletePayment(context.Background(), payerPubkey, recipientPubkey, req.Otp, amount)
-			if err != nil {
-				log.Printf("Failed to complete Solana payment on %s: %v", network, err)
-				errorMsg := strings.ToLower(err.Error())
-				var errorCode int
-
-				if strings.Contains(errorMsg, "insufficient") || strings.Contains(errorMsg, "balance") {
-					errorCode = CodeInsufficientBalance
-				} else if strings.Contains(errorMsg, "limit") || strings.Contains(errorMsg, "exceed") {
-					errorCode = CodeAmountExceedsLimit
-				} else if strings.Contains(errorMsg, "connection") || strings.Contains(errorMsg, "rpc") {
-					errorCode = CodeNetworkConnectionError
-				} else {
-					errorCode = CodeInvalidOpt
-				}
-
-				response := CreateApiResponseWithNullData(errorCode)
-				c.JSON(http.StatusBadRequest, response)
-				return
-			}
-			txHash = sig.String()
-		} else {
-			// Get the network-specific EVM client
-			evmClient := s.getEVMClient(network)
-			if evmClient == nil {
-				log.Printf("EVM client not initialized for network %s", network)
-				response := CreateApiResponseWithNullData(CodeNetworkUnavailable)
-				c.JSON(http.StatusInternalServerError, response)
-				return
-			}
-
-		// For EVM networks, we need to convert the amount to *big.Int
-		amountBig := new(big.Int).SetUint64(amount)
-
-		// For EVM, we'll use a fixed commit hash for now (32 bytes of zeros)
-		// In a real implementation, this would need to be computed properly
-		commitHash := "0x0000000000000000000000000000000000000000000000000000000000000000"
-
-		// Resolve token address dynamically from configuration
-		tokenAddress, err := utils.GetEVMTokenAddressByNetwork(evmClient.GetConfig(), currency, network)
-		if err != nil || strings.TrimSpace(tokenAddress) == "" {
-			log.Printf("Invalid currency %s for network %s: %v", currency, network, err)
-			response := CreateApiResponseWithNullData(CodeInvalidNetworkCurrency)
-			c.JSON(http.StatusBadRequest, response)
-			return
-		}
-
-		if tokenAddress == "" {
-			log.Printf("Invalid currency %s for network %s", currency, network)
-			response := CreateApiResponseWithNullData(CodeInvalidNetworkCurrency)
-			c.JSON(http.StatusBadRequest, response)
-			return
-		}
-
-		// Call the network-specific EVM client's CompletePayment method with enhanced error handling
-    tx, err := evmClient.CompletePayment(context.Background(), tokenAddress, req.PayerAddr, req.PayeeAddr, amountBig, req.Otp, commitHash)
-		if err != nil {
-			log.Printf("Failed to complete EVM payment on %s: %v", network, err)
-
-			// Enhanced error handling based on error type
-			errorMsg := strings.ToLower(err.Error())
-			var errorCode int
-
-			if strings.Contains(errorMsg, "insufficient") || strings.Contains(errorMsg, "balance") {
-				errorCode = CodeInsufficientBalance
-			} else if strings.Contains(errorMsg, "amount") && (strings.Contains(errorMsg, "invalid") || strings.Contains(errorMsg, "zero")) {
-				errorCode = CodeAmountMustBePositive
-			} else if strings.Contains(errorMsg, "limit") || strings.Contains(errorMsg, "exceed") {
-				errorCode = CodeAmountExceedsLimit
-			} else if strings.Contains(errorMsg, "connection") || strings.Contains(errorMsg, "rpc") || strings.Contains(errorMsg, "network") {
-				errorCode = CodeNetworkConnectionError
-			} else if strings.Contains(errorMsg, "config") || strings.Contains(errorMsg, "address") {
-				errorCode = CodeNetworkConfigError
-			} else {
-				errorCode = CodeInvalidOpt
-			}
-
-			response := CreateApiResponseWithNullData(errorCode)
-			c.JSON(http.StatusBadRequest, response)
-			return
-		}
-		txHash = tx.Hex()
-		}
-	}
-
-	data := map[string]interface{}{
-		"status":           "submitted",
-		"transaction_hash": txHash,
-		"currency":         currency,
-		"network":          network,
-		"coin_type":        coinType,
-	}
-	response := CreateApiResponseWithMap(CodeTransactionCreated, data)
-	c.JSON(http.StatusOK, response)
+	return &validatedPayment{
+		req:      req,
+		network:  network,
+		currency: currency,
+		coinType: coinType,
+		amount:   amount,
+	}, true
 }
 
 // GetTransactionStatus implements the transaction status query endpoint
@@ -478,197 +521,76 @@ func (s *APIServer) GetTransactionStatus(c *gin.Context, transactionHash string,
 		return
 	}
 
-	// Determine network from parsed params (OpenAPI), fallback to query param; default to aptos-testnet
-	network := "aptos-testnet"
-	if params.Network != nil {
-		network = string(*params.Network)
-	} else {
-		if qn := c.Query("network"); qn != "" {
-			network = qn
-		}
+	// Payment IDs returned by POST /api/payments are answered from the ledger
+	if payment, err := s.ledger.GetPayment(transactionHash); err == nil {
+		s.respondWithPayment(c, payment)
+		return
+	} else if !errors.Is(err, store.ErrNotFound) {
+		log.Printf("Failed to look up payment %s: %v", transactionHash, err)
 	}
 
-	switch network {
-	case "aptos-testnet":
-		// Get detailed transaction information from Aptos
-		txInfo, err := s.aptosClient.GetTransactionDetails(transactionHash)
-		if err != nil {
-			response := CreateApiResponseWithNullData(CodeTransactionNotFound)
-			c.JSON(http.StatusNotFound, response)
-			return
-		}
-
-		if !txInfo.Confirmed {
-			data := map[string]interface{}{
-				"status":  "pending",
-				"network": network,
-			}
-			response := CreateApiResponseWithMap(CodeTransactionPending, data)
-			c.JSON(http.StatusOK, response)
-			return
-		}
-
-		if txInfo.Success {
-			data := map[string]interface{}{
-				"status":          "confirmed",
-				"received_amount": txInfo.Amount,
-				"currency":        txInfo.CoinType,
-				"network":         network,
-			}
-			response := CreateApiResponseWithMap(CodeTransactionConfirmed, data)
-			c.JSON(http.StatusOK, response)
-		} else {
-			data := map[string]interface{}{
-				"status":  "failed",
-				"error":   txInfo.Error,
-				"network": network,
-			}
-			response := CreateApiResponseWithMap(CodeTransactionConfirmed, data)
-			c.JSON(http.StatusOK, response)
-		}
-	default:
-		// Check network availability first
-		if available, err := s.isNetworkAvailable(network); !available {
-			log.Printf("Network %s not available for transaction status query: %v", network, err)
-			response := CreateApiResponseWithNullData(CodeNetworkUnavailable)
-			c.JSON(http.StatusServiceUnavailable, response)
-			return
-		}
-
-		// Check if it's a Solana network first
-		solanaClient := s.getSolanaClient(network)
-		if solanaClient != nil {
-			// Fetch Solana transaction details
-			txInfo, err := solanaClient.GetTransactionDetails(c.Request.Context(), transactionHash)
-			if err != nil {
-				log.Printf("Failed to get Solana transaction details for %s on %s: %v", transactionHash, network, err)
-				errorMsg := strings.ToLower(err.Error())
-				var errorCode int
-
-				if strings.Contains(errorMsg, "not found") {
-					errorCode = CodeTransactionNotFound
-				} else if strings.Contains(errorMsg, "connection") || strings.Contains(errorMsg, "rpc") {
-					errorCode = CodeNetworkConnectionError
-				} else {
-					errorCode = CodeTransactionNotFound
-				}
-
-				response := CreateApiResponseWithNullData(errorCode)
-				c.JSON(http.StatusNotFound, response)
-				return
-			}
-
-			if !txInfo.Confirmed {
-				data := map[string]interface{}{
-					"status":  "pending",
-					"network": network,
-				}
-				response := CreateApiResponseWithMap(CodeTransactionPending, data)
-				c.JSON(http.StatusOK, response)
-				return
-			}
-
-			if txInfo.Success {
-				data := map[string]interface{}{
-					"status":          "confirmed",
-					"received_amount": txInfo.Amount,
-					"currency":        txInfo.CoinType,
-					"network":         network,
-				}
-				response := CreateApiResponseWithMap(CodeTransactionConfirmed, data)
-				c.JSON(http.StatusOK, response)
-			} else {
-				data := map[string]interface{}{
-					"status":  "failed",
-					"error":   txInfo.Error,
-					"network": network,
-				}
-				response := CreateApiResponseWithMap(CodeTransactionConfirmed, data)
-				c.JSON(http.StatusOK, response)
-			}
-			return
-		}
+	// Determine network from parsed params (OpenAPI), fallback to query param; default to aptos-testnet
+	network := requestNetwork(c, params.Network)
 
-		// Get the network-specific EVM client
-		evmClient := s.getEVMClient(network)
-		if evmClient == nil {
-			log.Printf("EVM client not initialized for network %s", network)
-			response := CreateApiResponseWithNullData(CodeNetworkUnavailable)
-			c.JSON(http.StatusInternalServerError, response)
-			return
-		}
+	// Check network availability first
+	if available, err := s.isNetworkAvailable(network); !available {
+		log.Printf("Network %s not available for transaction status query: %v", network, err)
+		response := CreateApiResponseWithNullData(CodeNetworkUnavailable)
+		c.JSON(http.StatusServiceUnavailable, response)
+		return
+	}
 
-		// Fetch EVM transaction details with enhanced error handling
-		txInfo, err := evmClient.GetTransactionDetails(c.Request.Context(), transactionHash)
-		if err != nil {
-			log.Printf("Failed to get transaction details for %s on %s: %v", transactionHash, network, err)
+	// A payment transaction that was sped up or cancelled is followed to its replacement
+	lookupHash := transactionHash
+	if payment, err := s.ledger.FindPaymentByTxHash(network, transactionHash); err == nil && !strings.EqualFold(payment.TxHash, transactionHash) {
+		lookupHash = payment.TxHash
+	}
 
-			// Enhanced error handling based on error type
-			errorMsg := strings.ToLower(err.Error())
-			var errorCode int
+	txInfo, err := s.getBackend(network).GetTransactionDetails(c.Request.Context(), lookupHash)
+	if err != nil {
+		log.Printf("Failed to get transaction details for %s on %s: %v", transactionHash, network, err)
+		response := CreateApiResponseWithNullData(lookupErrorCode(err, CodeTransactionNotFound))
+		c.JSON(http.StatusNotFound, response)
+		return
+	}
 
-			if strings.Contains(errorMsg, "not found") {
-				errorCode = CodeTransactionNotFound
-			} else if strings.Contains(errorMsg, "connection") || strings.Contains(errorMsg, "rpc") || strings.Contains(errorMsg, "network") {
-				errorCode = CodeNetworkConnectionError
-			} else {
-				errorCode = CodeTransactionNotFound
-			}
+	s.recordTransactionOutcome(network, lookupHash, txInfo)
 
-			response := CreateApiResponseWithNullData(errorCode)
-			c.JSON(http.StatusNotFound, response)
-			return
+	var code int
+	var data map[string]interface{}
+	switch {
+	case !txInfo.Confirmed:
+		code = CodeTransactionPending
+		data = map[string]interface{}{
+			"status":  "pending",
+			"network": network,
 		}
-		if !txInfo.Confirmed {
-			data := map[string]interface{}{
-				"status":  "pending",
-				"network": network,
-			}
-			response := CreateApiResponseWithMap(CodeTransactionPending, data)
-			c.JSON(http.StatusOK, response)
-			return
+	case txInfo.Success:
+		code = CodeTransactionConfirmed
+		data = map[string]interface{}{
+			"status":          "confirmed",
+			"received_amount": txInfo.Amount,
+			"currency":        txInfo.CoinType,
+			"network":         network,
 		}
-		if txInfo.Success {
-			// Determine currency from token address based on network
-			var currency string
-			if txInfo.TokenAddress != "" && txInfo.TokenAddress != "0x0000000000000000000000000000000000000000" {
-				// Try to get currency from token address using network-specific mapping
-				currency = utils.GetCurrencyFromEVMTokenAddressByNetwork(evmClient.GetConfig(), txInfo.TokenAddress, network)
-				if currency == "UNKNOWN" {
-					// Fallback to network's native currency if mapping fails
-					if netCfg := utils.GetEVMNetworkConfig(evmClient.GetConfig(), network); netCfg != nil {
-						currency = strings.ToUpper(netCfg.NativeToken.Symbol)
-					} else {
-						currency = "UNKNOWN"
-					}
-				}
-			} else {
-				// Zero address means native token
-				if netCfg := utils.GetEVMNetworkConfig(evmClient.GetConfig(), network); netCfg != nil {
-					currency = strings.ToUpper(netCfg.NativeToken.Symbol)
-				} else {
-					currency = "UNKNOWN"
-				}
-			}
-
-			data := map[string]interface{}{
-				"status":          "confirmed",
-				"received_amount": txInfo.Amount,
-				"currency":        currency,
-				"network":         network,
-			}
-			response := CreateApiResponseWithMap(CodeTransactionConfirmed, data)
-			c.JSON(http.StatusOK, response)
-		} else {
-			data := map[string]interface{}{
-				"status":  "failed",
-				"error":   txInfo.Error,
-				"network": network,
-			}
-			response := CreateApiResponseWithMap(CodeTransactionConfirmed, data)
-			c.JSON(http.StatusOK, response)
+	default:
+		code = CodeTransactionConfirmed
+		data = map[string]interface{}{
+			"status":  "failed",
+			"error":   txInfo.Error,
+			"network": network,
 		}
-    }
+	}
+	// Chains with commitment levels report how final the transaction is
+	if txInfo.Commitment != "" {
+		data["commitment"] = txInfo.Commitment
+	}
+	if lookupHash != transactionHash {
+		data["replaced_by"] = lookupHash
+	}
+
+	response := CreateApiResponseWithMap(code, data)
+	c.JSON(http.StatusOK, response)
 }
 
 // GetUserLimits implements the GET /api/users/{user_address}/limits endpoint
@@ -683,113 +605,28 @@ func (s *APIServer) GetUserLimits(c *gin.Context, userAddress string, params Get
 	}
 
 	// Determine network from parsed params (OpenAPI), fallback to query param; default to aptos-testnet
-	network := "aptos-testnet"
-	if params.Network != nil {
-		network = string(*params.Network)
-	} else {
-		if qn := c.Query("network"); qn != "" {
-			network = qn
-		}
-	}
-
-	switch network {
-	case "aptos-testnet":
-		userLimits, err := s.aptosClient.GetUserLimits(userAddress)
-		if err != nil {
-			log.Printf("Failed to get user limits: %v", err)
-			response := CreateApiResponseWithNullData(CodeInvalidOpt)
-			c.JSON(http.StatusBadRequest, response)
-			return
-		}
-		data := map[string]interface{}{
-			"user_limits": userLimits,
-			"network":     network,
-		}
-		response := CreateApiResponseWithMap(CodeServerHealthy, data)
-		c.JSON(http.StatusOK, response)
-	default:
-		// Check network availability first
-		if available, err := s.isNetworkAvailable(network); !available {
-			log.Printf("Network %s not available for user limits query: %v", network, err)
-			response := CreateApiResponseWithNullData(CodeNetworkUnavailable)
-			c.JSON(http.StatusServiceUnavailable, response)
-			return
-		}
+	network := requestNetwork(c, params.Network)
 
-		// Check if it's a Solana network first
-		solanaClient := s.getSolanaClient(network)
-		if solanaClient != nil {
-			// Parse Solana public key
-			userPubkey, err := utils.ParseSolanaPublicKey(userAddress)
-			if err != nil {
-				log.Printf("Invalid Solana user address: %v", err)
-				response := CreateApiResponseWithNullData(CodeInvalidOpt)
-				c.JSON(http.StatusBadRequest, response)
-				return
-			}
-
-			userLimits, err := solanaClient.GetUserLimits(c.Request.Context(), userPubkey)
-			if err != nil {
-				log.Printf("Failed to get Solana user limits for network %s: %v", network, err)
-				errorMsg := strings.ToLower(err.Error())
-				var errorCode int
-
-				if strings.Contains(errorMsg, "connection") || strings.Contains(errorMsg, "rpc") {
-					errorCode = CodeNetworkConnectionError
-				} else if strings.Contains(errorMsg, "not found") {
-					errorCode = CodeInvalidOpt
-				} else {
-					errorCode = CodeInvalidOpt
-				}
-
-				response := CreateApiResponseWithNullData(errorCode)
-				c.JSON(http.StatusBadRequest, response)
-				return
-			}
-
-			data := map[string]interface{}{
-				"user_limits": userLimits,
-				"network":     network,
-			}
-			response := CreateApiResponseWithMap(CodeServerHealthy, data)
-			c.JSON(http.StatusOK, response)
-			return
-		}
-
-		// Get the network-specific EVM client
-		evmClient := s.getEVMClient(network)
-		if evmClient == nil {
-			log.Printf("EVM client not initialized for network %s", network)
-			response := CreateApiResponseWithNullData(CodeNetworkUnavailable)
-			c.JSON(http.StatusInternalServerError, response)
-			return
-		}
-
-		userLimits, err := evmClient.GetUserLimits(c.Request.Context(), userAddress)
-		if err != nil {
-			log.Printf("Failed to get EVM user limits for network %s: %v", network, err)
-
-			// Enhanced error handling based on error type
-			errorMsg := strings.ToLower(err.Error())
-			var errorCode int
+	// Check network availability first
+	if available, err := s.isNetworkAvailable(network); !available {
+		log.Printf("Network %s not available for user limits query: %v", network, err)
+		response := CreateApiResponseWithNullData(CodeNetworkUnavailable)
+		c.JSON(http.StatusServiceUnavailable, response)
+		return
+	}
 
-			if strings.Contains(errorMsg, "connection") || strings.Contains(errorMsg, "rpc") || strings.Contains(errorMsg, "network") {
-				errorCode = CodeNetworkConnectionError
-			} else if strings.Contains(errorMsg, "config") || strings.Contains(errorMsg, "address") {
-				errorCode = CodeNetworkConfigError
-			} else {
-				errorCode = CodeInvalidOpt
-			}
+	userLimits, err := s.getBackend(network).GetUserLimits(c.Request.Context(), userAddress)
+	if err != nil {
+		log.Printf("Failed to get user limits for network %s: %v", network, err)
+		response := CreateApiResponseWithNullData(lookupErrorCode(err, CodeInvalidOpt))
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
 
-			response := CreateApiResponseWithNullData(errorCode)
-			c.JSON(http.StatusBadRequest, response)
-			return
-		}
-		data := map[string]interface{}{
-			"user_limits": userLimits,
-			"network":     network,
-		}
-		response := CreateApiResponseWithMap(CodeServerHealthy, data)
-		c.JSON(http.StatusOK, response)
-    }
+	data := map[string]interface{}{
+		"user_limits": userLimits,
+		"network":     network,
+	}
+	response := CreateApiResponseWithMap(CodeServerHealthy, data)
+	c.JSON(http.StatusOK, response)
 }
@@ -0,0 +1,518 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"tinypay-server/client"
+	"tinypay-server/config"
+	"tinypay-server/locks"
+	"tinypay-server/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+// fakeBackend is an in-memory ChainBackend used to exercise the handlers
+type fakeBackend struct {
+	mu        sync.Mutex
+	network   string
+	cfg       *config.Config
+	payments  []*client.Payment
+	sendErr   error
+	fee       *client.Fee // reported with every submission
+	txInfo    *client.TransactionInfo
+	lookupErr error
+	looked    []string // transaction hashes looked up, in order
+	limits    *client.UserLimits
+	tail      []byte
+	balances  map[string]uint64 // deposited balance per currency; nil when balances are not tracked
+	entered   chan struct{}     // when set, SendPayment reports here and then waits for sendGate
+	sendGate  chan struct{}
+
+	onReplaced func(client.TransactionReplacement)
+	cancelErr  error
+	draining   bool // state of the backend's only paymaster key, fakePaymaster
+
+	paymasterBalance *big.Int             // native balance of fakePaymaster
+	paymasterPool    config.PaymasterPool // alert thresholds of the paymaster pool
+	configErr        error                // returned by CheckConfig
+}
+
+// fakePaymaster is the paymaster key that sends every transaction of the fake backend
+const fakePaymaster = "0xpaymaster"
+
+func (f *fakeBackend) GetNetwork() string            { return f.network }
+func (f *fakeBackend) GetConfig() *config.Config     { return f.cfg }
+func (f *fakeBackend) SupportedCurrencies() []string { return []string{"ETH", "USDC"} }
+func (f *fakeBackend) DefaultCurrency() string       { return "ETH" }
+
+func (f *fakeBackend) ResolveCoinType(currency string) (string, error) {
+	return strings.ToUpper(currency), nil
+}
+
+func (f *fakeBackend) SendPayment(ctx context.Context, payment *client.Payment) (*client.Submission, error) {
+	if f.entered != nil {
+		f.entered <- struct{}{}
+		<-f.sendGate
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.sendErr != nil {
+		return nil, f.sendErr
+	}
+	f.payments = append(f.payments, payment)
+	return &client.Submission{TxHash: "0xabc", Fee: f.fee, Sender: fakePaymaster}, nil
+}
+
+func (f *fakeBackend) GetTransactionDetails(ctx context.Context, txHash string) (*client.TransactionInfo, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.looked = append(f.looked, txHash)
+	return f.txInfo, f.lookupErr
+}
+
+func (f *fakeBackend) OnReplaced(fn func(client.TransactionReplacement)) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.onReplaced = fn
+}
+
+// CancelTransaction replaces txHash with 0xcancel and reports it like the stuck transaction monitor
+func (f *fakeBackend) CancelTransaction(ctx context.Context, txHash string) (*client.TransactionReplacement, error) {
+	f.mu.Lock()
+	cancelErr := f.cancelErr
+	f.mu.Unlock()
+	if cancelErr != nil {
+		return nil, cancelErr
+	}
+	replacement := client.TransactionReplacement{Original: txHash, Replacement: "0xcancel", Cancelled: true}
+	f.replace(replacement)
+	return &replacement, nil
+}
+
+// replace reports a replacement through the registered callback
+func (f *fakeBackend) replace(replacement client.TransactionReplacement) {
+	f.mu.Lock()
+	onReplaced := f.onReplaced
+	f.mu.Unlock()
+	onReplaced(replacement)
+}
+
+func (f *fakeBackend) Precommit(ctx context.Context, payment *client.Payment) (*client.Precommit, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.sendErr != nil {
+		return nil, f.sendErr
+	}
+	return &client.Precommit{CommitHash: []byte{0xc0, 0xff, 0xee}, TxHash: "0xpre", Sender: fakePaymaster}, nil
+}
+
+func (f *fakeBackend) DrainPaymaster(address string) (*client.PaymasterKey, error) {
+	return f.setDraining(address, true)
+}
+
+func (f *fakeBackend) ResumePaymaster(address string) (*client.PaymasterKey, error) {
+	return f.setDraining(address, false)
+}
+
+func (f *fakeBackend) setDraining(address string, draining bool) (*client.PaymasterKey, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if address != fakePaymaster {
+		return nil, client.ErrPaymasterNotFound
+	}
+	f.draining = draining
+	return &client.PaymasterKey{Address: fakePaymaster, Draining: draining, Balance: big.NewInt(1000)}, nil
+}
+
+func (f *fakeBackend) Paymasters() []client.PaymasterKey {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return []client.PaymasterKey{{Address: fakePaymaster, Draining: f.draining, Balance: f.paymasterBalance}}
+}
+
+func (f *fakeBackend) PaymasterBalance(ctx context.Context, address string) (*big.Int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if address != fakePaymaster {
+		return nil, client.ErrPaymasterNotFound
+	}
+	return f.paymasterBalance, nil
+}
+
+func (f *fakeBackend) NativeUnit() string { return "wei" }
+
+func (f *fakeBackend) PaymasterPool() config.PaymasterPool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.paymasterPool
+}
+
+// setPaymasterBalance changes the native balance of fakePaymaster
+func (f *fakeBackend) setPaymasterBalance(balance *big.Int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.paymasterBalance = balance
+}
+
+// submitted returns the payments the backend has sent so far
+func (f *fakeBackend) submitted() []*client.Payment {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]*client.Payment(nil), f.payments...)
+}
+
+func (f *fakeBackend) GetUserLimits(ctx context.Context, userAddress string) (*client.UserLimits, error) {
+	return f.limits, f.lookupErr
+}
+
+func (f *fakeBackend) CheckConfig() error { return f.configErr }
+
+func (f *fakeBackend) GetUserTail(ctx context.Context, userAddress string) ([]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.tail, nil
+}
+
+func (f *fakeBackend) GetBalance(ctx context.Context, userAddress, currency string) (uint64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.balances == nil {
+		return 0, client.ErrBalanceUnavailable
+	}
+	return f.balances[currency], nil
+}
+
+func newTestServer(t *testing.T) (*gin.Engine, *fakeBackend) {
+	router, backend, _ := newTestServerWithLedger(t)
+	return router, backend
+}
+
+func newTestServerWithLedger(t *testing.T) (*gin.Engine, *fakeBackend, *store.Store) {
+	t.Helper()
+	server, backend, ledger := newTestAPIServer(t)
+	router := gin.New()
+	RegisterHandlers(router, server)
+	return router, backend, ledger
+}
+
+func newTestAPIServer(t *testing.T) (*APIServer, *fakeBackend, *store.Store) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	cfg := &config.Config{
+		EVMNetworks: []config.EVMNetwork{{
+			Name:        "fake-evm",
+			NativeToken: config.EVMNativeToken{Symbol: "ETH"},
+			Tokens:      []config.EVMToken{{Symbol: "USDC", Address: "0x01"}},
+		}},
+	}
+	backend := &fakeBackend{network: "fake-evm", cfg: cfg}
+	backends := client.NewBackendRegistry()
+	backends.Register(backend)
+
+	ledger, err := store.Open(filepath.Join(t.TempDir(), "ledger.db"))
+	if err != nil {
+		t.Fatalf("open ledger: %v", err)
+	}
+	t.Cleanup(func() { ledger.Close() })
+
+	payerLocks := locks.NewMemoryManager(locks.Options{LeaseTTL: time.Minute, AcquireTimeout: 200 * time.Millisecond})
+	t.Cleanup(func() { payerLocks.Close() })
+
+	server := NewAPIServer(backends, ledger, payerLocks, cfg)
+	t.Cleanup(server.Close) // runs before the ledger is closed
+	return server, backend, ledger
+}
+
+func doRequest(t *testing.T, router *gin.Engine, method, path string, body interface{}) (int, ApiResponse) {
+	t.Helper()
+	rec := doRawRequest(t, router, method, path, body, nil)
+
+	var resp ApiResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response %q: %v", rec.Body.String(), err)
+	}
+	return rec.Code, resp
+}
+
+func doRawRequest(t *testing.T, router *gin.Engine, method, path string, body interface{}, headers map[string]string) *httptest.ResponseRecorder {
+	t.Helper()
+	var reader *bytes.Reader
+	if body != nil {
+		raw, err := json.Marshal(body)
+		if err != nil {
+			t.Fatalf("marshal body: %v", err)
+		}
+		reader = bytes.NewReader(raw)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestCreatePayment_RoutesToBackend(t *testing.T) {
+	router, backend := newTestServer(t)
+
+	status, resp := doRequest(t, router, http.MethodPost, "/api/payments", map[string]interface{}{
+		"payer_addr": "0x1111",
+		"payee_addr": "0x2222",
+		"otp":        "deadbeef",
+		"amount":     100,
+		"network":    "fake-evm",
+	})
+
+	if status != http.StatusOK || resp.Code != CodeTransactionCreated {
+		t.Fatalf("expected 200/%d, got %d/%d", CodeTransactionCreated, status, resp.Code)
+	}
+	if len(backend.payments) != 1 {
+		t.Fatalf("expected one payment, got %d", len(backend.payments))
+	}
+	if got := backend.payments[0]; got.Currency != "ETH" || got.Amount != 100 || got.Otp != "deadbeef" {
+		t.Errorf("unexpected payment forwarded to backend: %+v", got)
+	}
+	if (*resp.Data)["transaction_hash"] != "0xabc" {
+		t.Errorf("expected transaction hash 0xabc, got %v", (*resp.Data)["transaction_hash"])
+	}
+}
+
+func TestCreatePayment_MapsBackendErrors(t *testing.T) {
+	router, backend := newTestServer(t)
+	backend.sendErr = errors.New("execution reverted: insufficient balance")
+
+	status, resp := doRequest(t, router, http.MethodPost, "/api/payments", map[string]interface{}{
+		"payer_addr": "0x1111",
+		"payee_addr": "0x2222",
+		"otp":        "deadbeef",
+		"amount":     100,
+		"network":    "fake-evm",
+	})
+
+	if status != http.StatusBadRequest || resp.Code != CodeInsufficientBalance {
+		t.Fatalf("expected 400/%d, got %d/%d", CodeInsufficientBalance, status, resp.Code)
+	}
+}
+
+func TestCreatePayment_RejectsBusyPayer(t *testing.T) {
+	router, backend, ledger := newTestServerWithLedger(t)
+	backend.entered = make(chan struct{})
+	backend.sendGate = make(chan struct{})
+
+	request := map[string]interface{}{
+		"payer_addr": "0xABCDEF",
+		"payee_addr": "0x2222",
+		"otp":        "deadbeef",
+		"amount":     100,
+		"network":    "fake-evm",
+	}
+	first := make(chan int)
+	go func() {
+		rec := doRawRequest(t, router, http.MethodPost, "/api/payments", request, nil)
+		first <- rec.Code
+	}()
+	<-backend.entered
+
+	// The same payer spelled differently waits for the lock and gives up
+	request["payer_addr"] = "0xabcdef"
+	status, resp := doRequest(t, router, http.MethodPost, "/api/payments", request)
+	if status != http.StatusConflict || resp.Code != CodePayerBusy {
+		t.Fatalf("expected 409/%d, got %d/%d", CodePayerBusy, status, resp.Code)
+	}
+
+	close(backend.sendGate)
+	if code := <-first; code != http.StatusOK {
+		t.Fatalf("expected the first payment to succeed, got %d", code)
+	}
+
+	payments, _, err := ledger.ListPayments(store.PaymentFilter{Status: store.StatusFailed})
+	if err != nil || len(payments) != 1 || payments[0].ErrorCode != CodePayerBusy {
+		t.Fatalf("expected the busy payment to be recorded as failed with %d, got %d err=%v", CodePayerBusy, len(payments), err)
+	}
+}
+
+func TestCreatePayment_MapsDecodedChainErrors(t *testing.T) {
+	cases := []struct {
+		reason client.FailureReason
+		code   int
+	}{
+		{client.FailureInvalidOTP, CodeInvalidOpt},
+		{client.FailureLimitExceeded, CodeAmountExceedsLimit},
+		{client.FailureInsufficientBalance, CodeInsufficientBalance},
+		{client.FailureUnknown, CodeTransactionRejected},
+	}
+
+	for _, tc := range cases {
+		router, backend := newTestServer(t)
+		backend.sendErr = fmt.Errorf("simulation failed: %w", &client.ChainError{
+			Reason:  tc.reason,
+			Code:    "E_TEST",
+			Message: "rejected by the contract",
+		})
+
+		status, resp := doRequest(t, router, http.MethodPost, "/api/payments", map[string]interface{}{
+			"payer_addr": "0x1111",
+			"payee_addr": "0x2222",
+			"otp":        "deadbeef",
+			"amount":     100,
+			"network":    "fake-evm",
+		})
+		if status != http.StatusBadRequest || resp.Code != tc.code {
+			t.Errorf("%s: expected 400/%d, got %d/%d", tc.reason, tc.code, status, resp.Code)
+			continue
+		}
+		if resp.Data == nil || (*resp.Data)["reason"] != "rejected by the contract" || (*resp.Data)["chain_error"] != "E_TEST" {
+			t.Errorf("%s: expected the original reason in the response, got %v", tc.reason, resp.Data)
+		}
+	}
+}
+
+func TestCreatePayment_UnregisteredNetwork(t *testing.T) {
+	router, _ := newTestServer(t)
+
+	status, resp := doRequest(t, router, http.MethodPost, "/api/payments", map[string]interface{}{
+		"payer_addr": "0x1111",
+		"payee_addr": "0x2222",
+		"otp":        "deadbeef",
+		"amount":     100,
+		"network":    "aptos-testnet",
+	})
+
+	if status != http.StatusBadRequest || resp.Code != CodeNetworkUnavailable {
+		t.Fatalf("expected 400/%d, got %d/%d", CodeNetworkUnavailable, status, resp.Code)
+	}
+}
+
+func TestCreatePayment_MisconfiguredNetwork(t *testing.T) {
+	router, backend := newTestServer(t)
+	backend.configErr = errors.New("contract address not configured")
+
+	status, resp := doRequest(t, router, http.MethodPost, "/api/payments", map[string]interface{}{
+		"payer_addr": "0x1111",
+		"payee_addr": "0x2222",
+		"otp":        "deadbeef",
+		"amount":     100,
+		"network":    "fake-evm",
+	})
+
+	if status != http.StatusBadRequest || resp.Code != CodeNetworkUnavailable {
+		t.Fatalf("expected 400/%d, got %d/%d", CodeNetworkUnavailable, status, resp.Code)
+	}
+	if len(backend.submitted()) != 0 {
+		t.Error("expected no payment to reach the backend")
+	}
+}
+
+func TestGetTransactionStatus(t *testing.T) {
+	router, backend := newTestServer(t)
+
+	backend.txInfo = &client.TransactionInfo{Confirmed: false}
+	_, resp := doRequest(t, router, http.MethodGet, "/api/payments/0xabc?network=fake-evm", nil)
+	if resp.Code != CodeTransactionPending {
+		t.Errorf("expected pending code %d, got %d", CodeTransactionPending, resp.Code)
+	}
+
+	backend.txInfo = &client.TransactionInfo{Confirmed: true, Success: true, Amount: 42, CoinType: "USDC"}
+	_, resp = doRequest(t, router, http.MethodGet, "/api/payments/0xabc?network=fake-evm", nil)
+	if resp.Code != CodeTransactionConfirmed || (*resp.Data)["currency"] != "USDC" {
+		t.Errorf("expected confirmed USDC payment, got %d %v", resp.Code, resp.Data)
+	}
+
+	if _, ok := (*resp.Data)["commitment"]; ok {
+		t.Errorf("expected no commitment for a chain without commitment levels, got %v", resp.Data)
+	}
+
+	backend.txInfo = &client.TransactionInfo{Confirmed: false, Commitment: "processed"}
+	_, resp = doRequest(t, router, http.MethodGet, "/api/payments/0xabc?network=fake-evm", nil)
+	if resp.Code != CodeTransactionPending || (*resp.Data)["commitment"] != "processed" {
+		t.Errorf("expected pending payment at processed, got %d %v", resp.Code, resp.Data)
+	}
+
+	backend.txInfo, backend.lookupErr = nil, errors.New("transaction not found")
+	status, resp := doRequest(t, router, http.MethodGet, "/api/payments/0xabc?network=fake-evm", nil)
+	if status != http.StatusNotFound || resp.Code != CodeTransactionNotFound {
+		t.Errorf("expected 404/%d, got %d/%d", CodeTransactionNotFound, status, resp.Code)
+	}
+}
+
+func TestGetUserLimits(t *testing.T) {
+	router, backend := newTestServer(t)
+	backend.limits = &client.UserLimits{PaymentLimit: 500, TailUpdateCount: 1, MaxTailUpdates: 3}
+
+	status, resp := doRequest(t, router, http.MethodGet, "/api/users/0x1111/limits?network=fake-evm", nil)
+	if status != http.StatusOK || resp.Code != CodeServerHealthy {
+		t.Fatalf("expected 200/%d, got %d/%d", CodeServerHealthy, status, resp.Code)
+	}
+
+	status, resp = doRequest(t, router, http.MethodGet, "/api/users/0x1111/limits?network=unknown", nil)
+	if status != http.StatusServiceUnavailable || resp.Code != CodeNetworkUnavailable {
+		t.Errorf("expected 503/%d, got %d/%d", CodeNetworkUnavailable, status, resp.Code)
+	}
+}
+
+func TestCreatePayment_RecordsLedgerEntries(t *testing.T) {
+	router, backend, ledger := newTestServerWithLedger(t)
+	backend.fee = &client.Fee{Mode: "estimate", Unit: client.FeeUnitWeiPerGas, Price: 30, PriorityFee: 2, Limit: 90000}
+
+	body := map[string]interface{}{
+		"payer_addr": "0x1111",
+		"payee_addr": "0x2222",
+		"otp":        "deadbeef",
+		"amount":     100,
+		"network":    "fake-evm",
+	}
+	if _, resp := doRequest(t, router, http.MethodPost, "/api/payments", body); resp.Code != CodeTransactionCreated {
+		t.Fatalf("expected payment to be created, got %d", resp.Code)
+	}
+
+	backend.sendErr = errors.New("invalid otp")
+	doRequest(t, router, http.MethodPost, "/api/payments", body)
+
+	// A confirmed lookup moves the submitted payment to its final state
+	backend.txInfo = &client.TransactionInfo{Confirmed: true, Success: true, Amount: 100, CoinType: "ETH"}
+	doRequest(t, router, http.MethodGet, "/api/payments/0xabc?network=fake-evm", nil)
+
+	payments, _, err := ledger.ListPayments(store.PaymentFilter{})
+	if err != nil {
+		t.Fatalf("list payments: %v", err)
+	}
+	if len(payments) != 2 {
+		t.Fatalf("expected 2 ledger entries, got %d", len(payments))
+	}
+	if payments[0].Status != store.StatusFailed || payments[0].Error != "invalid otp" {
+		t.Errorf("expected newest payment to be failed, got %+v", payments[0])
+	}
+	if payments[1].Status != store.StatusConfirmed || payments[1].TxHash != "0xabc" {
+		t.Errorf("expected oldest payment to be confirmed, got %+v", payments[1])
+	}
+	if fee := payments[1].Fee; fee == nil || fee.Price != 30 || fee.PriorityFee != 2 || fee.Mode != "estimate" {
+		t.Errorf("expected the submission fee to be recorded, got %+v", fee)
+	}
+
+	status, resp := doRequest(t, router, http.MethodGet, "/api/payments?status=confirmed&payer=0x1111", nil)
+	if status != http.StatusOK {
+		t.Fatalf("expected 200 listing payments, got %d", status)
+	}
+	if listed := (*resp.Data)["payments"].([]interface{}); len(listed) != 1 {
+		t.Errorf("expected 1 confirmed payment, got %d", len(listed))
+	}
+}
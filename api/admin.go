@@ -0,0 +1,183 @@
+package api
+
+import (
+	"crypto/subtle"
+	"errors"
+	"log"
+	"math/big"
+	"net/http"
+	"strings"
+
+	"tinypay-server/client"
+	"tinypay-server/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+// authorizeAdmin checks the bearer token of an admin request. Admin endpoints are disabled
+// when no token is configured. On failure it writes the error response and returns false.
+func (s *APIServer) authorizeAdmin(c *gin.Context) bool {
+	token := ""
+	if s.config != nil {
+		token = s.config.AdminToken
+	}
+	provided, found := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if token == "" || !found || subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+		response := CreateApiResponseWithNullData(CodeAdminUnauthorized)
+		c.JSON(http.StatusUnauthorized, response)
+		return false
+	}
+	return true
+}
+
+// CancelTransaction implements the POST /api/admin/transactions/{transaction_hash}/cancel endpoint
+func (s *APIServer) CancelTransaction(c *gin.Context, transactionHash string, params CancelTransactionParams) {
+	if !s.authorizeAdmin(c) {
+		return
+	}
+
+	network := params.Network
+	if available, err := s.isNetworkConfigured(network); !available {
+		log.Printf("Network %s not available for transaction cancel: %v", network, err)
+		response := CreateApiResponseWithNullData(CodeNetworkUnavailable)
+		c.JSON(http.StatusServiceUnavailable, response)
+		return
+	}
+	replacer, ok := s.getBackend(network).(client.TransactionReplacer)
+	if !ok {
+		response := CreateApiResponseWithNullData(CodeReplaceNotSupported)
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	// A payment is cancelled through whatever transaction currently stands for it
+	txHash := transactionHash
+	payment, err := s.ledger.FindPaymentByTxHash(network, transactionHash)
+	if err == nil {
+		txHash = payment.TxHash
+	} else if !errors.Is(err, store.ErrNotFound) {
+		log.Printf("Failed to look up payment for %s on %s: %v", transactionHash, network, err)
+	}
+
+	log.Printf("Cancelling transaction %s on %s", txHash, network)
+	replacement, err := replacer.CancelTransaction(c.Request.Context(), txHash)
+	if err != nil {
+		log.Printf("Failed to cancel %s on %s: %v", txHash, network, err)
+		switch {
+		case errors.Is(err, client.ErrTransactionNotFound):
+			response := CreateApiResponseWithNullData(CodeTransactionNotFound)
+			c.JSON(http.StatusNotFound, response)
+		case errors.Is(err, client.ErrTransactionNotPending):
+			response := CreateApiResponseWithNullData(CodeTransactionNotPending)
+			c.JSON(http.StatusBadRequest, response)
+		default:
+			response := CreateApiResponseWithNullData(lookupErrorCode(err, CodeTransactionRejected))
+			c.JSON(http.StatusBadRequest, response)
+		}
+		return
+	}
+
+	data := map[string]interface{}{
+		"original_transaction_hash": replacement.Original,
+		"cancel_transaction_hash":   replacement.Replacement,
+		"network":                   network,
+	}
+	if payment != nil {
+		data["payment_id"] = payment.ID
+	}
+	if fee := ledgerFee(replacement.Fee); fee != nil {
+		data["fee"] = fee
+	}
+	response := CreateApiResponseWithMap(CodeCancelSubmitted, data)
+	c.JSON(http.StatusOK, response)
+}
+
+// ListPaymasters implements the GET /api/admin/paymasters endpoint
+func (s *APIServer) ListPaymasters(c *gin.Context, params ListPaymastersParams) {
+	if !s.authorizeAdmin(c) {
+		return
+	}
+
+	network := ""
+	if params.Network != nil {
+		network = *params.Network
+		if available, err := s.isNetworkConfigured(network); !available {
+			log.Printf("Network %s not available for paymaster listing: %v", network, err)
+			response := CreateApiResponseWithNullData(CodeNetworkUnavailable)
+			c.JSON(http.StatusServiceUnavailable, response)
+			return
+		}
+	}
+
+	paymasters := s.paymasters.report(network)
+	if paymasters == nil {
+		paymasters = []map[string]interface{}{}
+	}
+	response := CreateApiResponseWithMap(CodeServerHealthy, map[string]interface{}{
+		"paymasters": paymasters,
+	})
+	c.JSON(http.StatusOK, response)
+}
+
+// DrainPaymaster implements the POST /api/admin/paymasters/{address}/drain endpoint
+func (s *APIServer) DrainPaymaster(c *gin.Context, address string, params DrainPaymasterParams) {
+	s.setPaymasterDraining(c, params.Network, address, true)
+}
+
+// ResumePaymaster implements the POST /api/admin/paymasters/{address}/resume endpoint
+func (s *APIServer) ResumePaymaster(c *gin.Context, address string, params ResumePaymasterParams) {
+	s.setPaymasterDraining(c, params.Network, address, false)
+}
+
+// setPaymasterDraining takes a paymaster key out of rotation or puts it back
+func (s *APIServer) setPaymasterDraining(c *gin.Context, network, address string, draining bool) {
+	if !s.authorizeAdmin(c) {
+		return
+	}
+
+	if available, err := s.isNetworkConfigured(network); !available {
+		log.Printf("Network %s not available for paymaster update: %v", network, err)
+		response := CreateApiResponseWithNullData(CodeNetworkUnavailable)
+		c.JSON(http.StatusServiceUnavailable, response)
+		return
+	}
+	rotator, ok := s.getBackend(network).(client.PaymasterRotator)
+	if !ok {
+		response := CreateApiResponseWithNullData(CodePaymasterNotFound)
+		c.JSON(http.StatusNotFound, response)
+		return
+	}
+
+	var key *client.PaymasterKey
+	var err error
+	if draining {
+		key, err = rotator.DrainPaymaster(address)
+	} else {
+		key, err = rotator.ResumePaymaster(address)
+	}
+	if err != nil {
+		log.Printf("Failed to update paymaster %s on %s: %v", address, network, err)
+		response := CreateApiResponseWithNullData(CodePaymasterNotFound)
+		c.JSON(http.StatusNotFound, response)
+		return
+	}
+	log.Printf("Paymaster %s on %s draining=%t (%d in flight)", key.Address, network, key.Draining, key.InFlight)
+
+	response := CreateApiResponseWithMap(CodePaymasterUpdated, map[string]interface{}{
+		"address":   key.Address,
+		"network":   network,
+		"draining":  key.Draining,
+		"in_flight": key.InFlight,
+		"balance":   balanceString(key.Balance),
+		"low_funds": key.LowFunds,
+	})
+	c.JSON(http.StatusOK, response)
+}
+
+// balanceString formats a paymaster balance as a decimal string, nil when it was never read
+func balanceString(balance *big.Int) interface{} {
+	if balance == nil {
+		return nil
+	}
+	return balance.String()
+}
@@ -0,0 +1,147 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"tinypay-server/client"
+	"tinypay-server/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+// createSubmittedPayment sends a payment through the fake backend and returns its ID
+func createSubmittedPayment(t *testing.T, router *gin.Engine) string {
+	t.Helper()
+	_, resp := doRequest(t, router, http.MethodPost, "/api/payments", map[string]interface{}{
+		"payer_addr": "0x1111",
+		"payee_addr": "0x2222",
+		"otp":        "deadbeef",
+		"amount":     100,
+		"network":    "fake-evm",
+	})
+	if resp.Code != CodeTransactionCreated {
+		t.Fatalf("expected the payment to be submitted, got %d", resp.Code)
+	}
+	return (*resp.Data)["payment_id"].(string)
+}
+
+func TestCancelTransaction_RequiresAdminToken(t *testing.T) {
+	router, backend := newTestServer(t)
+	path := "/api/admin/transactions/0xabc/cancel?network=fake-evm"
+
+	// Admin endpoints are disabled without a configured token
+	if status, resp := doRequest(t, router, http.MethodPost, path, nil); status != http.StatusUnauthorized || resp.Code != CodeAdminUnauthorized {
+		t.Errorf("expected 401/%d without a configured token, got %d/%d", CodeAdminUnauthorized, status, resp.Code)
+	}
+
+	backend.cfg.AdminToken = "secret"
+	rec := doRawRequest(t, router, http.MethodPost, path, nil, map[string]string{"Authorization": "Bearer wrong"})
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 for a wrong token, got %d", rec.Code)
+	}
+	rec = doRawRequest(t, router, http.MethodPost, path, nil, map[string]string{"Authorization": "Bearer secret"})
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected 200 for the configured token, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestCancelTransaction_FailsPaymentOnceMined(t *testing.T) {
+	router, backend := newTestServer(t)
+	backend.cfg.AdminToken = "secret"
+	paymentID := createSubmittedPayment(t, router)
+
+	backend.cancelErr = client.ErrTransactionNotPending
+	rec := doRawRequest(t, router, http.MethodPost, "/api/admin/transactions/0xabc/cancel?network=fake-evm", nil, map[string]string{"Authorization": "Bearer secret"})
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a mined transaction, got %d", rec.Code)
+	}
+
+	backend.cancelErr = nil
+	rec = doRawRequest(t, router, http.MethodPost, "/api/admin/transactions/0xabc/cancel?network=fake-evm", nil, map[string]string{"Authorization": "Bearer secret"})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected the cancel to be submitted, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	backend.txInfo = &client.TransactionInfo{Confirmed: true, Success: true}
+	_, resp := doRequest(t, router, http.MethodGet, "/api/payments/"+paymentID, nil)
+	data := *resp.Data
+	if data["status"] != string(store.StatusFailed) || data["error_code"] != float64(CodeTransactionCancelled) {
+		t.Errorf("expected the cancelled payment to fail with %d, got %v", CodeTransactionCancelled, data)
+	}
+	if data["transaction_hash"] != "0xcancel" {
+		t.Errorf("expected the cancellation to stand for the payment, got %v", data["transaction_hash"])
+	}
+	if replaced, _ := data["replaced_transactions"].([]interface{}); len(replaced) != 1 || replaced[0] != "0xabc" {
+		t.Errorf("expected the original transaction to be listed as replaced, got %v", data["replaced_transactions"])
+	}
+}
+
+func TestGetTransactionStatus_FollowsReplacement(t *testing.T) {
+	router, backend, ledger := newTestServerWithLedger(t)
+	paymentID := createSubmittedPayment(t, router)
+
+	backend.replace(client.TransactionReplacement{Original: "0xabc", Replacement: "0xdef", Fee: &client.Fee{Price: 12}})
+	backend.txInfo = &client.TransactionInfo{Confirmed: true, Success: true, Amount: 100, CoinType: "ETH"}
+
+	status, resp := doRequest(t, router, http.MethodGet, "/api/payments/0xabc?network=fake-evm", nil)
+	if status != http.StatusOK || (*resp.Data)["replaced_by"] != "0xdef" {
+		t.Fatalf("expected the lookup to follow the replacement, got %d %v", status, resp.Data)
+	}
+	if looked := backend.looked; len(looked) == 0 || looked[len(looked)-1] != "0xdef" {
+		t.Errorf("expected the replacement to be looked up, got %v", looked)
+	}
+
+	payment, err := ledger.GetPayment(paymentID)
+	if err != nil {
+		t.Fatalf("get payment: %v", err)
+	}
+	if payment.Status != store.StatusConfirmed || payment.TxHash != "0xdef" || payment.Fee.Price != 12 {
+		t.Errorf("expected the payment confirmed through its replacement, got %+v", payment)
+	}
+
+	// Replacements of payments the ledger does not know are ignored
+	backend.replace(client.TransactionReplacement{Original: "0x999", Replacement: "0x998"})
+	if _, err := ledger.FindPaymentByTxHash("fake-evm", "0x998"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("expected no payment for an unknown replacement, got %v", err)
+	}
+}
+
+func TestDrainPaymaster(t *testing.T) {
+	router, backend := newTestServer(t)
+	backend.cfg.AdminToken = "secret"
+	auth := map[string]string{"Authorization": "Bearer secret"}
+
+	rec := doRawRequest(t, router, http.MethodPost, "/api/admin/paymasters/0xpaymaster/drain?network=fake-evm", nil, auth)
+	if rec.Code != http.StatusOK || !backend.draining {
+		t.Fatalf("expected the paymaster to be drained, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = doRawRequest(t, router, http.MethodPost, "/api/admin/paymasters/0xpaymaster/resume?network=fake-evm", nil, auth)
+	if rec.Code != http.StatusOK || backend.draining {
+		t.Fatalf("expected the paymaster to be resumed, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = doRawRequest(t, router, http.MethodPost, "/api/admin/paymasters/0xother/drain?network=fake-evm", nil, auth)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown paymaster, got %d", rec.Code)
+	}
+}
+
+func TestCreatePayment_NoPaymasterAvailable(t *testing.T) {
+	router, backend := newTestServer(t)
+	backend.sendErr = fmt.Errorf("%w on fake-evm", client.ErrNoPaymasterAvailable)
+
+	_, resp := doRequest(t, router, http.MethodPost, "/api/payments", map[string]interface{}{
+		"payer_addr": "0x1111",
+		"payee_addr": "0x2222",
+		"otp":        "deadbeef",
+		"amount":     100,
+		"network":    "fake-evm",
+	})
+	if resp.Code != CodePaymasterUnavailable {
+		t.Errorf("expected %d when every paymaster is unavailable, got %d", CodePaymasterUnavailable, resp.Code)
+	}
+}
@@ -7,6 +7,11 @@ const (
 	CodeTransactionCreated   = 1001 // 交易创建成功
 	CodeTransactionPending   = 1002 // 交易处理中
 	CodeTransactionConfirmed = 1003 // 交易确认成功
+	CodePrecommitCreated     = 1004 // 预提交成功
+	CodeCancelSubmitted      = 1005 // 取消交易已提交
+	CodePaymasterUpdated     = 1006 // paymaster 密钥状态已更新
+	CodeWebhookCreated       = 1007 // webhook 订阅已创建
+	CodeWebhookQueued        = 1008 // webhook 已重新加入投递队列
 
 	// 错误状态码 (2000-2999)
 	CodeAmountMustBePositive   = 2000 // 金额必须大于0
@@ -16,11 +21,33 @@ const (
 	CodeMissingFields          = 2004 // 缺少必需字段
 	CodeTransactionNotFound    = 2005 // 交易不存在
 	CodeInvalidNetworkCurrency = 2006 // 无效的货币种类
+	CodeIdempotencyKeyReused   = 2007 // 幂等键已用于不同的请求
+	CodeRequestInProgress      = 2008 // 相同幂等键的请求正在处理中
+	CodePrecommitNotFound      = 2009 // 预提交记录不存在
+	CodePrecommitMismatch      = 2010 // 支付参数与预提交不一致
+	CodePrecommitUnavailable   = 2011 // 预提交已使用或已过期
+	CodePrecommitNotSupported  = 2012 // 该网络不支持预提交
+	CodeTransactionRejected    = 2013 // 交易被链上合约拒绝（原因见 data.reason）
+	CodePayerBusy              = 2014 // 该付款方有支付正在处理中，请稍后重试
+	CodeTransactionCancelled   = 2015 // 交易已被管理员取消
+	CodeAdminUnauthorized      = 2016 // 管理接口认证失败或未启用
+	CodeReplaceNotSupported    = 2017 // 该网络不支持替换交易
+	CodeTransactionNotPending  = 2018 // 交易已上链或已不在交易池中，无法取消
+	CodePaymasterNotFound      = 2019 // 该网络没有此 paymaster 密钥
+	CodeWebhookNotFound        = 2020 // webhook 订阅或投递记录不存在
+	CodeInvalidWebhook         = 2021 // webhook 订阅参数无效（原因见 data.reason）
+	CodeInvalidRequestBody     = 2022 // 请求体无法读取
 
 	// 网络特定错误状态码 (2100-2199)
 	CodeNetworkUnavailable     = 2100 // 网络不可用
 	CodeNetworkConfigError     = 2101 // 网络配置错误
 	CodeNetworkConnectionError = 2102 // 网络连接错误
+	CodePaymasterUnavailable   = 2103 // 该网络没有可用的 paymaster 密钥
+
+	// 服务端错误状态码 (2200-2299)
+	CodeStorageError          = 2200 // 存储错误
+	CodeSubmissionQueueFull   = 2201 // 提交队列已满，请稍后重试
+	CodeSubmissionInterrupted = 2202 // 服务重启导致提交中断
 )
 
 // CreateApiResponse 创建统一的API响应
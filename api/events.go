@@ -0,0 +1,62 @@
+package api
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"tinypay-server/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListChainEvents implements the GET /api/events endpoint
+func (s *APIServer) ListChainEvents(c *gin.Context, params ListChainEventsParams) {
+	filter := store.ChainEventFilter{Network: params.Network}
+	if params.Type != nil {
+		filter.Type = *params.Type
+	}
+	if params.Account != nil {
+		filter.Account = *params.Account
+	}
+	if params.TransactionHash != nil {
+		filter.TxHash = *params.TransactionHash
+	}
+	if params.Cursor != nil {
+		filter.Cursor = *params.Cursor
+	}
+	if params.Limit != nil {
+		filter.Limit = *params.Limit
+	}
+
+	events, nextCursor, err := s.ledger.ListChainEvents(filter)
+	if err != nil {
+		log.Printf("Failed to list chain events: %v", err)
+		response := CreateApiResponseWithNullData(CodeStorageError)
+		c.JSON(http.StatusInternalServerError, response)
+		return
+	}
+
+	data := map[string]interface{}{
+		"events":      events,
+		"next_cursor": nextCursor,
+	}
+	// Without a checkpoint the network is not indexed, or its indexer has not finished a pass yet
+	cp, err := s.ledger.GetIndexerCheckpoint(params.Network)
+	switch {
+	case err == nil:
+		checkpoint := map[string]interface{}{
+			"next":       cp.Next,
+			"updated_at": cp.UpdatedAt,
+		}
+		// An Aptos page may end inside a transaction
+		if cp.NextIndex > 0 {
+			checkpoint["next_index"] = cp.NextIndex
+		}
+		data["checkpoint"] = checkpoint
+	case !errors.Is(err, store.ErrNotFound):
+		log.Printf("Failed to load indexer checkpoint of %s: %v", params.Network, err)
+	}
+	response := CreateApiResponseWithMap(CodeServerHealthy, data)
+	c.JSON(http.StatusOK, response)
+}
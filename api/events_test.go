@@ -0,0 +1,41 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+
+	"tinypay-server/store"
+)
+
+func TestListChainEvents(t *testing.T) {
+	router, _, ledger := newTestServerWithLedger(t)
+
+	status, resp := doRequest(t, router, http.MethodGet, "/api/events?network=fake-evm", nil)
+	if status != http.StatusOK || len((*resp.Data)["events"].([]interface{})) != 0 || (*resp.Data)["checkpoint"] != nil {
+		t.Fatalf("expected no events and no checkpoint before indexing, got %d: %+v", status, resp.Data)
+	}
+
+	err := ledger.SaveChainEvents(&store.IndexerCheckpoint{Network: "fake-evm", Next: 11}, []*store.ChainEvent{
+		{Type: "PaymentCompleted", BlockNumber: 5, TxHash: "0xaaa", Account: "0x1111", Recipient: "0x2222", Amount: "100"},
+		{Type: "DepositMade", BlockNumber: 9, TxHash: "0xbbb", Account: "0x3333", Amount: "500"},
+	})
+	if err != nil {
+		t.Fatalf("save events: %v", err)
+	}
+
+	status, resp = doRequest(t, router, http.MethodGet, "/api/events?network=fake-evm&account=0x2222", nil)
+	if status != http.StatusOK {
+		t.Fatalf("expected 200 listing events, got %d", status)
+	}
+	listed := (*resp.Data)["events"].([]interface{})
+	if len(listed) != 1 || listed[0].(map[string]interface{})["transaction_hash"] != "0xaaa" {
+		t.Errorf("expected the payment to the recipient, got %+v", listed)
+	}
+	if checkpoint, _ := (*resp.Data)["checkpoint"].(map[string]interface{}); checkpoint == nil || checkpoint["next"] != float64(11) {
+		t.Errorf("expected the indexer checkpoint, got %+v", (*resp.Data)["checkpoint"])
+	}
+
+	if rec := doRawRequest(t, router, http.MethodGet, "/api/events", nil, nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 without a network, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,128 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"tinypay-server/config"
+	"tinypay-server/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+// idempotentReplayHeader marks responses served from the idempotency cache
+const idempotentReplayHeader = "Idempotent-Replayed"
+
+// responseCapture tees everything written to the client so it can be stored for replay
+type responseCapture struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseCapture) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *responseCapture) WriteString(data string) (int, error) {
+	w.body.WriteString(data)
+	return w.ResponseWriter.WriteString(data)
+}
+
+// CreatePayment implements the payment creation endpoint
+func (s *APIServer) CreatePayment(c *gin.Context, params CreatePaymentParams) {
+	async := params.Async != nil && *params.Async
+
+	merchant := apiKeyID(params.ApiKey)
+	if params.IdempotencyKey == nil || *params.IdempotencyKey == "" {
+		s.createPayment(c, async, merchant)
+		return
+	}
+	// Keys are chosen by the merchants, so each API key gets its own namespace
+	key := merchant + ":" + *params.IdempotencyKey
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		response := CreateApiResponseWithNullData(CodeInvalidRequestBody)
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	requestHash := requestFingerprint(body)
+
+	record, claimed, err := s.ledger.BeginIdempotentRequest(key, requestHash, s.idempotencyWindowDuration())
+	if err != nil {
+		log.Printf("Failed to claim idempotency key %s: %v", key, err)
+		response := CreateApiResponseWithNullData(CodeStorageError)
+		c.JSON(http.StatusInternalServerError, response)
+		return
+	}
+
+	if !claimed {
+		switch {
+		case record.RequestHash != requestHash:
+			log.Printf("Idempotency key %s reused with a different request", key)
+			response := CreateApiResponseWithNullData(CodeIdempotencyKeyReused)
+			c.JSON(http.StatusUnprocessableEntity, response)
+		case record.State != store.IdempotencyCompleted:
+			response := CreateApiResponseWithNullData(CodeRequestInProgress)
+			c.JSON(http.StatusConflict, response)
+		default:
+			log.Printf("Replaying stored response for idempotency key %s", key)
+			c.Header(idempotentReplayHeader, "true")
+			c.Data(record.StatusCode, "application/json; charset=utf-8", record.Body)
+		}
+		return
+	}
+
+	// Unless a final response is stored, the key is released, also when the handler panics, so
+	// it is not left in progress until its window expires
+	completed := false
+	defer func() {
+		if completed {
+			return
+		}
+		if err := s.ledger.ReleaseIdempotentRequest(key); err != nil {
+			log.Printf("Failed to release idempotency key %s: %v", key, err)
+		}
+	}()
+
+	capture := &responseCapture{ResponseWriter: c.Writer}
+	c.Writer = capture
+	s.createPayment(c, async, merchant)
+
+	// A busy payer, a full queue or a storage failure did not reach the chain; the retry may succeed
+	if retryableStatus(capture.Status()) {
+		return
+	}
+	if err := s.ledger.CompleteIdempotentRequest(key, capture.Status(), capture.body.Bytes()); err != nil {
+		log.Printf("Failed to store response for idempotency key %s: %v", key, err)
+		return
+	}
+	completed = true
+}
+
+// retryableStatus reports whether a payment response is temporary rather than final: 409 for a
+// busy payer and 5xx for a full submission queue, an unavailable network or a storage error
+func retryableStatus(status int) bool {
+	return status == http.StatusConflict || status >= http.StatusInternalServerError
+}
+
+// idempotencyWindowDuration returns how long idempotency keys are remembered
+func (s *APIServer) idempotencyWindowDuration() time.Duration {
+	if s.config != nil && s.config.IdempotencyWindow > 0 {
+		return s.config.IdempotencyWindow
+	}
+	return config.DefaultIdempotencyWindow
+}
+
+// requestFingerprint identifies a request body so a key reused for a different request can be detected
+func requestFingerprint(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,152 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCreatePayment_IdempotencyKeyReplaysResponse(t *testing.T) {
+	router, backend := newTestServer(t)
+	headers := map[string]string{"Idempotency-Key": "retry-1"}
+	body := map[string]interface{}{
+		"payer_addr": "0x1111",
+		"payee_addr": "0x2222",
+		"otp":        "deadbeef",
+		"amount":     100,
+		"network":    "fake-evm",
+	}
+
+	first := doRawRequest(t, router, http.MethodPost, "/api/payments", body, headers)
+	if first.Code != http.StatusOK {
+		t.Fatalf("expected first request to succeed, got %d: %s", first.Code, first.Body.String())
+	}
+
+	// A retry must not reach the chain again, even if it would now fail there
+	backend.sendErr = errors.New("invalid otp")
+	second := doRawRequest(t, router, http.MethodPost, "/api/payments", body, headers)
+	if second.Code != http.StatusOK || second.Body.String() != first.Body.String() {
+		t.Fatalf("expected replayed response %q, got %d %q", first.Body.String(), second.Code, second.Body.String())
+	}
+	if second.Header().Get(idempotentReplayHeader) != "true" {
+		t.Errorf("expected %s header on replay", idempotentReplayHeader)
+	}
+	if len(backend.payments) != 1 {
+		t.Errorf("expected one submission, got %d", len(backend.payments))
+	}
+
+	body["amount"] = 200
+	reused := doRawRequest(t, router, http.MethodPost, "/api/payments", body, headers)
+	if reused.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected 422 for a reused key, got %d", reused.Code)
+	}
+}
+
+func TestCreatePayment_IdempotencyKeyInProgress(t *testing.T) {
+	router, backend, ledger := newTestServerWithLedger(t)
+	body := map[string]interface{}{
+		"payer_addr": "0x1111",
+		"payee_addr": "0x2222",
+		"otp":        "deadbeef",
+		"amount":     100,
+		"network":    "fake-evm",
+	}
+	raw, err := json.Marshal(body)
+	if err != nil {
+		t.Fatalf("marshal body: %v", err)
+	}
+
+	// Claim the key as if the first request were still being processed; requests without an
+	// API key share the empty namespace
+	if _, _, err := ledger.BeginIdempotentRequest(":busy", requestFingerprint(raw), time.Minute); err != nil {
+		t.Fatalf("claim key: %v", err)
+	}
+
+	status, resp := doRequestWithHeaders(t, router, body, map[string]string{"Idempotency-Key": "busy"})
+	if status != http.StatusConflict || resp.Code != CodeRequestInProgress {
+		t.Fatalf("expected 409/%d, got %d/%d", CodeRequestInProgress, status, resp.Code)
+	}
+	if len(backend.payments) != 0 {
+		t.Errorf("expected no submission while the key is in progress, got %d", len(backend.payments))
+	}
+}
+
+func TestCreatePayment_IdempotencyKeyScopedToAPIKey(t *testing.T) {
+	router, backend := newTestServer(t)
+	body := map[string]interface{}{
+		"payer_addr": "0x1111",
+		"payee_addr": "0x2222",
+		"otp":        "deadbeef",
+		"amount":     100,
+		"network":    "fake-evm",
+	}
+
+	first := doRawRequest(t, router, http.MethodPost, "/api/payments", body, map[string]string{"Idempotency-Key": "order-1", "Api-Key": "merchant-a"})
+	if first.Code != http.StatusOK {
+		t.Fatalf("expected first merchant to succeed, got %d: %s", first.Code, first.Body.String())
+	}
+
+	// Another merchant picking the same key gets its own payment, not the first one's response
+	body["amount"] = 200
+	second := doRawRequest(t, router, http.MethodPost, "/api/payments", body, map[string]string{"Idempotency-Key": "order-1", "Api-Key": "merchant-b"})
+	if second.Code != http.StatusOK || second.Header().Get(idempotentReplayHeader) != "" {
+		t.Fatalf("expected the second merchant's payment to be processed, got %d %q", second.Code, second.Body.String())
+	}
+	if len(backend.payments) != 2 {
+		t.Errorf("expected two submissions, got %d", len(backend.payments))
+	}
+}
+
+func TestCreatePayment_IdempotencyKeyReleasedOnRetryableFailure(t *testing.T) {
+	router, backend := newTestServer(t)
+	backend.entered = make(chan struct{})
+	backend.sendGate = make(chan struct{})
+
+	body := map[string]interface{}{
+		"payer_addr": "0x1111",
+		"payee_addr": "0x2222",
+		"otp":        "deadbeef",
+		"amount":     100,
+		"network":    "fake-evm",
+	}
+	first := make(chan int)
+	go func() {
+		first <- doRawRequest(t, router, http.MethodPost, "/api/payments", body, nil).Code
+	}()
+	<-backend.entered
+
+	// The payer is busy with another payment, which is not a final answer for this key
+	headers := map[string]string{"Idempotency-Key": "busy-payer"}
+	status, resp := doRequestWithHeaders(t, router, body, headers)
+	if status != http.StatusConflict || resp.Code != CodePayerBusy {
+		t.Fatalf("expected 409/%d, got %d/%d", CodePayerBusy, status, resp.Code)
+	}
+	close(backend.sendGate)
+	if code := <-first; code != http.StatusOK {
+		t.Fatalf("expected the first payment to succeed, got %d", code)
+	}
+	backend.entered = nil
+
+	retry := doRawRequest(t, router, http.MethodPost, "/api/payments", body, headers)
+	if retry.Code != http.StatusOK || retry.Header().Get(idempotentReplayHeader) != "" {
+		t.Fatalf("expected the retry to be processed, got %d %q", retry.Code, retry.Body.String())
+	}
+	if len(backend.payments) != 2 {
+		t.Errorf("expected the retry to reach the chain, got %d submissions", len(backend.payments))
+	}
+}
+
+func doRequestWithHeaders(t *testing.T, router *gin.Engine, body interface{}, headers map[string]string) (int, ApiResponse) {
+	t.Helper()
+	rec := doRawRequest(t, router, http.MethodPost, "/api/payments", body, headers)
+
+	var resp ApiResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response %q: %v", rec.Body.String(), err)
+	}
+	return rec.Code, resp
+}
@@ -0,0 +1,167 @@
+package api
+
+import (
+	"context"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"tinypay-server/client"
+	"tinypay-server/config"
+	"tinypay-server/store"
+)
+
+const (
+	// confirmPollInterval is how often a worker checks whether an async payment executed
+	confirmPollInterval = 3 * time.Second
+	// confirmTimeout bounds how long an async payment is followed after submission
+	confirmTimeout = 10 * time.Minute
+	// lookupTimeout bounds a single transaction lookup while following a payment
+	lookupTimeout = 30 * time.Second
+)
+
+// paymentJob is a validated payment waiting for a submission worker.
+// The OTP only lives here; it is never written to the ledger.
+type paymentJob struct {
+	paymentID string
+	network   string
+	payment   *client.Payment
+}
+
+// submissionQueue feeds queued payments to a fixed pool of workers per network
+type submissionQueue struct {
+	server          *APIServer
+	ctx             context.Context
+	cancel          context.CancelFunc
+	wg              sync.WaitGroup
+	mu              sync.Mutex // orders follow's wg.Add before stop's wg.Wait
+	queues          map[string]chan *paymentJob
+	confirmInterval time.Duration
+	confirmTimeout  time.Duration
+}
+
+// newSubmissionQueue starts the configured number of workers for every network
+func newSubmissionQueue(s *APIServer, networks []string) *submissionQueue {
+	workers, size := config.DefaultAsyncWorkersPerNetwork, config.DefaultAsyncQueueSize
+	if s.config != nil {
+		if s.config.AsyncWorkersPerNetwork > 0 {
+			workers = s.config.AsyncWorkersPerNetwork
+		}
+		if s.config.AsyncQueueSize > 0 {
+			size = s.config.AsyncQueueSize
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	q := &submissionQueue{
+		server:          s,
+		ctx:             ctx,
+		cancel:          cancel,
+		queues:          make(map[string]chan *paymentJob),
+		confirmInterval: confirmPollInterval,
+		confirmTimeout:  confirmTimeout,
+	}
+
+	for _, network := range networks {
+		jobs := make(chan *paymentJob, size)
+		q.queues[strings.ToLower(network)] = jobs
+		for i := 0; i < workers; i++ {
+			q.wg.Add(1)
+			go q.worker(jobs)
+		}
+		log.Printf("Started %d submission workers for %s (queue size %d)", workers, network, size)
+	}
+	return q
+}
+
+// enqueue hands a job to its network's workers without blocking; it reports false when the queue is full
+func (q *submissionQueue) enqueue(job *paymentJob) bool {
+	jobs, ok := q.queues[strings.ToLower(job.network)]
+	if !ok {
+		return false
+	}
+	select {
+	case jobs <- job:
+		return true
+	default:
+		return false
+	}
+}
+
+// stop cancels the workers and waits for them to return
+func (q *submissionQueue) stop() {
+	q.mu.Lock()
+	q.cancel()
+	q.mu.Unlock()
+	q.wg.Wait()
+}
+
+func (q *submissionQueue) worker(jobs <-chan *paymentJob) {
+	defer q.wg.Done()
+	for {
+		select {
+		case <-q.ctx.Done():
+			return
+		case job := <-jobs:
+			q.process(job)
+		}
+	}
+}
+
+// process submits one job and, once it is on chain, follows it until it executes
+func (q *submissionQueue) process(job *paymentJob) {
+	s := q.server
+	submission, err := s.submitPayment(q.ctx, job.paymentID, job.network, job.payment)
+	if err != nil {
+		return
+	}
+	log.Printf("Async payment %s submitted on %s: %s", job.paymentID, job.network, submission.TxHash)
+	q.follow(job.paymentID, job.network, submission.TxHash)
+}
+
+// follow records the outcome of a submitted payment in the background
+func (q *submissionQueue) follow(paymentID, network, txHash string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.ctx.Err() != nil {
+		return
+	}
+	q.wg.Add(1)
+	go q.awaitOutcome(paymentID, network, txHash)
+}
+
+// awaitOutcome polls the chain until the payment's transaction executed and records the result
+// in the ledger. The hash is reloaded on every poll, since stuck transactions may be replaced.
+func (q *submissionQueue) awaitOutcome(paymentID, network, txHash string) {
+	defer q.wg.Done()
+
+	backend := q.server.getBackend(network)
+	deadline := time.Now().Add(q.confirmTimeout)
+	ticker := time.NewTicker(q.confirmInterval)
+	defer ticker.Stop()
+
+	for time.Now().Before(deadline) {
+		if payment, err := q.server.ledger.GetPayment(paymentID); err == nil {
+			if payment.Status != store.StatusSubmitted {
+				return
+			}
+			txHash = payment.TxHash
+		}
+
+		ctx, cancel := context.WithTimeout(q.ctx, lookupTimeout)
+		txInfo, err := backend.GetTransactionDetails(ctx, txHash)
+		cancel()
+		if err == nil && txInfo != nil && txInfo.Confirmed {
+			q.server.recordTransactionOutcome(network, txHash, txInfo)
+			return
+		}
+
+		select {
+		case <-q.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+	log.Printf("Gave up following %s on %s after %s; it stays submitted", txHash, network, q.confirmTimeout)
+}
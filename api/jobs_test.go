@@ -0,0 +1,94 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"tinypay-server/client"
+
+	"github.com/gin-gonic/gin"
+)
+
+// waitForPaymentStatus polls GET /api/payments/{id} until the job reaches status
+func waitForPaymentStatus(t *testing.T, router *gin.Engine, paymentID, status string) map[string]interface{} {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		_, resp := doRequest(t, router, http.MethodGet, "/api/payments/"+paymentID, nil)
+		if resp.Data != nil && (*resp.Data)["status"] == status {
+			return *resp.Data
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("payment %s did not reach %s, last response %d %v", paymentID, status, resp.Code, resp.Data)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestCreatePayment_AsyncReturnsJobID(t *testing.T) {
+	router, backend := newTestServer(t)
+	backend.txInfo = &client.TransactionInfo{Confirmed: true, Success: true, Amount: 100, CoinType: "ETH"}
+
+	status, resp := doRequest(t, router, http.MethodPost, "/api/payments?async=true", map[string]interface{}{
+		"payer_addr": "0x1111",
+		"payee_addr": "0x2222",
+		"otp":        "deadbeef",
+		"amount":     100,
+		"network":    "fake-evm",
+	})
+	if status != http.StatusAccepted || resp.Code != CodeTransactionPending {
+		t.Fatalf("expected 202/%d, got %d/%d", CodeTransactionPending, status, resp.Code)
+	}
+	paymentID, _ := (*resp.Data)["payment_id"].(string)
+	if paymentID == "" {
+		t.Fatalf("expected a payment id, got %v", resp.Data)
+	}
+
+	data := waitForPaymentStatus(t, router, paymentID, "confirmed")
+	if data["transaction_hash"] != "0xabc" {
+		t.Errorf("expected transaction hash 0xabc, got %v", data["transaction_hash"])
+	}
+	if got := backend.submitted(); len(got) != 1 || got[0].Otp != "deadbeef" {
+		t.Errorf("expected the queued payment to reach the backend, got %+v", got)
+	}
+}
+
+func TestCreatePayment_AsyncFailureIsReported(t *testing.T) {
+	router, backend := newTestServer(t)
+	backend.sendErr = errors.New("invalid otp")
+
+	_, resp := doRequest(t, router, http.MethodPost, "/api/payments?async=true", map[string]interface{}{
+		"payer_addr": "0x1111",
+		"payee_addr": "0x2222",
+		"otp":        "deadbeef",
+		"amount":     100,
+		"network":    "fake-evm",
+	})
+	paymentID, _ := (*resp.Data)["payment_id"].(string)
+
+	data := waitForPaymentStatus(t, router, paymentID, "failed")
+	if data["error"] != "invalid otp" || data["error_code"] != float64(CodeInvalidOpt) {
+		t.Errorf("unexpected failure details: %v", data)
+	}
+}
+
+func TestSubmissionQueue_FollowRacingStop(t *testing.T) {
+	server, _, _ := newTestAPIServer(t)
+	q := server.submissions
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			q.follow("pay", "fake-evm", "0xhash")
+		}
+	}()
+	q.stop()
+	<-done
+
+	// Nothing may be followed once the queue has stopped
+	q.follow("pay", "fake-evm", "0xhash")
+	q.wg.Wait()
+}
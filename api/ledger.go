@@ -0,0 +1,200 @@
+package api
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"tinypay-server/client"
+	"tinypay-server/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+// markPaymentSubmitted records the transaction hash, paymaster and fee of a payment in the ledger
+func (s *APIServer) markPaymentSubmitted(paymentID string, submission *client.Submission) {
+	payment, err := s.ledger.MarkSubmitted(paymentID, submission.TxHash, submission.Sender, ledgerFee(submission.Fee))
+	if err != nil {
+		log.Printf("Failed to mark payment %s as submitted: %v", paymentID, err)
+		return
+	}
+	s.emitPaymentEvent(store.EventPaymentSubmitted, payment)
+}
+
+// ledgerFee converts the fee a chain client reported into its ledger form
+func ledgerFee(fee *client.Fee) *store.Fee {
+	if fee == nil {
+		return nil
+	}
+	return &store.Fee{
+		Mode:        fee.Mode,
+		Unit:        fee.Unit,
+		Price:       fee.Price,
+		PriorityFee: fee.PriorityFee,
+		Limit:       fee.Limit,
+		Capped:      fee.Capped,
+	}
+}
+
+// recordReplacement points the payment of a replaced transaction at its replacement
+func (s *APIServer) recordReplacement(network string, replacement client.TransactionReplacement) {
+	_, err := s.ledger.ReplaceTransaction(network, replacement.Original, replacement.Replacement, ledgerFee(replacement.Fee), replacement.Cancelled)
+	if err != nil {
+		if !errors.Is(err, store.ErrNotFound) {
+			log.Printf("Failed to record replacement %s of %s on %s: %v", replacement.Replacement, replacement.Original, network, err)
+		}
+		return
+	}
+	log.Printf("Payment transaction %s on %s replaced by %s (cancelled %v)", replacement.Original, network, replacement.Replacement, replacement.Cancelled)
+}
+
+// markPaymentFailed records a submission failure and its business status code in the ledger
+func (s *APIServer) markPaymentFailed(paymentID string, code int, reason string) {
+	payment, err := s.ledger.MarkFailed(paymentID, code, reason)
+	if err != nil {
+		log.Printf("Failed to mark payment %s as failed: %v", paymentID, err)
+		return
+	}
+	s.releasePrecommit(payment)
+	s.emitPaymentEvent(store.EventPaymentFailed, payment)
+}
+
+// recordTransactionOutcome moves the ledger entry of a transaction to its final state once it executed
+func (s *APIServer) recordTransactionOutcome(network, txHash string, txInfo *client.TransactionInfo) {
+	if txInfo == nil || !txInfo.Confirmed {
+		return
+	}
+
+	payment, err := s.ledger.FindPaymentByTxHash(network, txHash)
+	if err != nil {
+		if !errors.Is(err, store.ErrNotFound) {
+			log.Printf("Failed to look up payment for %s on %s: %v", txHash, network, err)
+		}
+		return
+	}
+	if payment.Status != store.StatusSubmitted || !strings.EqualFold(payment.TxHash, txHash) {
+		// A replaced transaction that executed anyway is reported through its replacement
+		return
+	}
+
+	if payment.Cancelled {
+		s.markPaymentFailed(payment.ID, CodeTransactionCancelled, "transaction cancelled by an administrator")
+		return
+	}
+	if !txInfo.Success {
+		code := 0
+		if txInfo.Failure != nil {
+			code = chainErrorCode(txInfo.Failure)
+		}
+		s.markPaymentFailed(payment.ID, code, txInfo.Error)
+		return
+	}
+	confirmed, err := s.ledger.MarkConfirmed(payment.ID)
+	if err != nil {
+		log.Printf("Failed to record outcome of payment %s: %v", payment.ID, err)
+		return
+	}
+	s.emitPaymentEvent(store.EventPaymentConfirmed, confirmed)
+}
+
+// respondWithPayment reports the job state of a ledger entry, refreshing submitted payments from the chain
+func (s *APIServer) respondWithPayment(c *gin.Context, payment *store.Payment) {
+	commitment := ""
+	if payment.Status == store.StatusSubmitted {
+		if backend := s.getBackend(payment.Network); backend != nil {
+			txInfo, err := backend.GetTransactionDetails(c.Request.Context(), payment.TxHash)
+			if err == nil {
+				commitment = txInfo.Commitment
+				s.recordTransactionOutcome(payment.Network, payment.TxHash, txInfo)
+				if refreshed, err := s.ledger.GetPayment(payment.ID); err == nil {
+					payment = refreshed
+				}
+			}
+		}
+	}
+
+	data := map[string]interface{}{
+		"payment_id": payment.ID,
+		"status":     string(payment.Status),
+		"network":    payment.Network,
+		"currency":   payment.Currency,
+		"amount":     payment.Amount,
+	}
+	if payment.TxHash != "" {
+		data["transaction_hash"] = payment.TxHash
+	}
+	if payment.Paymaster != "" {
+		data["paymaster"] = payment.Paymaster
+	}
+	if payment.Fee != nil {
+		data["fee"] = payment.Fee
+	}
+	if len(payment.Replaced) > 0 {
+		replaced := make([]string, len(payment.Replaced))
+		for i, r := range payment.Replaced {
+			replaced[i] = r.TxHash
+		}
+		data["replaced_transactions"] = replaced
+	}
+	if commitment != "" {
+		data["commitment"] = commitment
+	}
+	if payment.Status == store.StatusFailed || payment.Status == store.StatusNeedsReconciliation {
+		data["error"] = payment.Error
+		if payment.ErrorCode != 0 {
+			data["error_code"] = payment.ErrorCode
+		}
+	}
+
+	code := CodeTransactionPending
+	if payment.Status == store.StatusConfirmed || payment.Status == store.StatusFailed {
+		code = CodeTransactionConfirmed
+	}
+	response := CreateApiResponseWithMap(code, data)
+	c.JSON(http.StatusOK, response)
+}
+
+// ListPayments implements the GET /api/payments endpoint
+func (s *APIServer) ListPayments(c *gin.Context, params ListPaymentsParams) {
+	filter := store.PaymentFilter{}
+	if params.Payee != nil {
+		filter.PayeeAddr = *params.Payee
+	}
+	if params.Payer != nil {
+		filter.PayerAddr = *params.Payer
+	}
+	if params.Network != nil {
+		filter.Network = *params.Network
+	}
+	if params.Status != nil {
+		filter.Status = store.PaymentStatus(*params.Status)
+	}
+	if params.From != nil {
+		filter.From = *params.From
+	}
+	if params.To != nil {
+		filter.To = *params.To
+	}
+	if params.Cursor != nil {
+		filter.Cursor = *params.Cursor
+	}
+	if params.Limit != nil {
+		filter.Limit = *params.Limit
+	}
+
+	payments, nextCursor, err := s.ledger.ListPayments(filter)
+	if err != nil {
+		log.Printf("Failed to list payments: %v", err)
+		response := CreateApiResponseWithNullData(CodeStorageError)
+		c.JSON(http.StatusInternalServerError, response)
+		return
+	}
+
+	data := map[string]interface{}{
+		"payments":    payments,
+		"next_cursor": nextCursor,
+	}
+	response := CreateApiResponseWithMap(CodeServerHealthy, data)
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,367 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"tinypay-server/client"
+	"tinypay-server/config"
+)
+
+const (
+	// paymasterSampleTimeout bounds one balance read of the paymaster monitor
+	paymasterSampleTimeout = 10 * time.Second
+	// paymasterAlertTimeout bounds the delivery of one low-balance alert
+	paymasterAlertTimeout = 10 * time.Second
+)
+
+// Events posted to the paymaster alert webhook
+const (
+	alertLowBalance       = "paymaster.low_balance"
+	alertBalanceRecovered = "paymaster.balance_recovered"
+)
+
+// balanceSample is one reading of a paymaster key's native balance
+type balanceSample struct {
+	at      time.Time
+	balance *big.Int
+}
+
+// monitoredPaymaster is what the monitor knows about one paymaster key
+type monitoredPaymaster struct {
+	network  string
+	address  string
+	samples  []balanceSample // readings within the burn rate window, oldest first
+	lastErr  error           // the last read failed; samples keep the readings before it
+	errorAt  time.Time
+	alerting bool // a low-balance alert was sent and the balance has not recovered since
+}
+
+// paymasterAlert is the JSON body posted to the alert webhook. Balances are decimal strings, as
+// wei amounts do not fit JSON numbers.
+type paymasterAlert struct {
+	Event           string    `json:"event"`
+	Network         string    `json:"network"`
+	Address         string    `json:"address"`
+	Balance         string    `json:"balance"`
+	Unit            string    `json:"unit"`
+	Threshold       uint64    `json:"threshold"`
+	BurnRatePerHour string    `json:"burn_rate_per_hour"`
+	HoursLeft       *float64  `json:"hours_left"`
+	Timestamp       time.Time `json:"timestamp"`
+}
+
+// paymasterMonitor samples the native balance of every paymaster key on a schedule. It keeps the
+// readings of a sliding window to estimate each key's burn rate, posts alerts when a key drops
+// below its network's alert threshold, and reports networks whose keys are all below the
+// unavailable threshold.
+type paymasterMonitor struct {
+	backends   *client.BackendRegistry
+	interval   time.Duration
+	window     time.Duration
+	webhookURL string
+	httpClient *http.Client
+	now        func() time.Time
+
+	mu   sync.Mutex
+	keys map[string]*monitoredPaymaster // by network and address
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// newPaymasterMonitor creates a monitor of the registered backends; start begins sampling
+func newPaymasterMonitor(backends *client.BackendRegistry, cfg *config.Config) *paymasterMonitor {
+	interval, window := config.DefaultPaymasterMonitorInterval, config.DefaultPaymasterBurnRateWindow
+	webhookURL := ""
+	if cfg != nil {
+		if cfg.PaymasterMonitorInterval > 0 {
+			interval = cfg.PaymasterMonitorInterval
+		}
+		if cfg.PaymasterBurnRateWindow > 0 {
+			window = cfg.PaymasterBurnRateWindow
+		}
+		webhookURL = cfg.PaymasterAlertWebhookURL
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &paymasterMonitor{
+		backends:   backends,
+		interval:   interval,
+		window:     window,
+		webhookURL: webhookURL,
+		httpClient: &http.Client{Timeout: paymasterAlertTimeout},
+		now:        time.Now,
+		keys:       make(map[string]*monitoredPaymaster),
+		ctx:        ctx,
+		cancel:     cancel,
+	}
+}
+
+// start samples right away and then every interval, when any backend has paymaster keys
+func (m *paymasterMonitor) start() {
+	if len(m.funded()) == 0 {
+		return
+	}
+	log.Printf("Sampling paymaster balances every %s", m.interval)
+
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		ticker := time.NewTicker(m.interval)
+		defer ticker.Stop()
+		for {
+			m.sample(m.ctx)
+			select {
+			case <-m.ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// stop ends sampling and waits for a sample in progress
+func (m *paymasterMonitor) stop() {
+	m.cancel()
+	m.wg.Wait()
+}
+
+// funded returns the networks whose backends pay gas from paymaster keys
+func (m *paymasterMonitor) funded() []string {
+	var networks []string
+	for _, network := range m.backends.Networks() {
+		if funds, ok := m.backends.Get(network).(client.PaymasterFunds); ok && len(funds.Paymasters()) > 0 {
+			networks = append(networks, network)
+		}
+	}
+	return networks
+}
+
+// sample reads the balance of every paymaster key once and sends the alerts it calls for
+func (m *paymasterMonitor) sample(ctx context.Context) {
+	for _, network := range m.funded() {
+		funds := m.backends.Get(network).(client.PaymasterFunds)
+		pool := funds.PaymasterPool()
+		for _, key := range funds.Paymasters() {
+			readCtx, cancel := context.WithTimeout(ctx, paymasterSampleTimeout)
+			balance, err := funds.PaymasterBalance(readCtx, key.Address)
+			cancel()
+			if ctx.Err() != nil {
+				return
+			}
+			if alert := m.record(network, key.Address, balance, err, pool, funds.NativeUnit()); alert != nil {
+				m.sendAlert(ctx, alert)
+			}
+		}
+	}
+}
+
+// record stores a reading and returns the alert it calls for, if any
+func (m *paymasterMonitor) record(network, address string, balance *big.Int, err error, pool config.PaymasterPool, unit string) *paymasterAlert {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	key := m.key(network, address)
+	if err != nil {
+		log.Printf("Failed to sample the balance of paymaster %s on %s: %v", address, network, err)
+		key.lastErr, key.errorAt = err, now
+		return nil
+	}
+	key.lastErr = nil
+	key.samples = append(key.samples, balanceSample{at: now, balance: balance})
+	for len(key.samples) > 1 && now.Sub(key.samples[0].at) > m.window {
+		key.samples = key.samples[1:]
+	}
+
+	if pool.AlertBelow == 0 {
+		key.alerting = false
+		return nil
+	}
+	low := below(balance, pool.AlertBelow)
+	if low == key.alerting {
+		return nil
+	}
+	key.alerting = low
+	event := alertLowBalance
+	if !low {
+		event = alertBalanceRecovered
+	}
+	burnRate := key.burnRate()
+	return &paymasterAlert{
+		Event:           event,
+		Network:         network,
+		Address:         address,
+		Balance:         balance.String(),
+		Unit:            unit,
+		Threshold:       pool.AlertBelow,
+		BurnRatePerHour: burnRate.String(),
+		HoursLeft:       hoursLeft(balance, burnRate),
+		Timestamp:       now.UTC(),
+	}
+}
+
+// key returns the state of a paymaster key, creating it on first use; callers hold mu
+func (m *paymasterMonitor) key(network, address string) *monitoredPaymaster {
+	id := strings.ToLower(network) + "/" + address
+	key, ok := m.keys[id]
+	if !ok {
+		key = &monitoredPaymaster{network: network, address: address}
+		m.keys[id] = key
+	}
+	return key
+}
+
+// sendAlert logs an alert and posts it to the webhook when one is configured. A failed delivery
+// is only logged; the alert is not sent again until the balance crosses the threshold again.
+func (m *paymasterMonitor) sendAlert(ctx context.Context, alert *paymasterAlert) {
+	log.Printf("Paymaster alert %s: %s on %s has %s %s (threshold %d, burning %s per hour)",
+		alert.Event, alert.Address, alert.Network, alert.Balance, alert.Unit, alert.Threshold, alert.BurnRatePerHour)
+	if m.webhookURL == "" {
+		return
+	}
+
+	body, err := json.Marshal(alert)
+	if err != nil {
+		log.Printf("Failed to encode paymaster alert: %v", err)
+		return
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.webhookURL, bytes.NewReader(body))
+	if err != nil {
+		log.Printf("Failed to create paymaster alert request: %v", err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := m.httpClient.Do(req)
+	if err != nil {
+		log.Printf("Failed to deliver paymaster alert: %v", err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		log.Printf("Paymaster alert webhook answered %d", resp.StatusCode)
+	}
+}
+
+// available reports whether network's paymasters can still pay. A network is unavailable while
+// every key in rotation has a sampled balance below its pool's unavailable threshold; keys that
+// were never sampled, or whose last read failed, count as funded.
+func (m *paymasterMonitor) available(network string) (bool, error) {
+	funds, ok := m.backends.Get(network).(client.PaymasterFunds)
+	if !ok {
+		return true, nil
+	}
+	threshold := funds.PaymasterPool().UnavailableBelow
+	if threshold == 0 {
+		return true, nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	inRotation := 0
+	for _, paymaster := range funds.Paymasters() {
+		if paymaster.Draining {
+			continue
+		}
+		inRotation++
+		key, ok := m.keys[strings.ToLower(network)+"/"+paymaster.Address]
+		if !ok || key.lastErr != nil || len(key.samples) == 0 || !below(key.latest().balance, threshold) {
+			return true, nil
+		}
+	}
+	if inRotation == 0 {
+		return true, nil
+	}
+	return false, fmt.Errorf("every paymaster of %s is below %d %s", network, threshold, funds.NativeUnit())
+}
+
+// report describes the paymaster keys of network, or of every network when network is empty
+func (m *paymasterMonitor) report(network string) []map[string]interface{} {
+	var paymasters []map[string]interface{}
+	for _, name := range m.funded() {
+		if network != "" && !strings.EqualFold(name, network) {
+			continue
+		}
+		funds := m.backends.Get(name).(client.PaymasterFunds)
+		pool := funds.PaymasterPool()
+		available, _ := m.available(name)
+
+		m.mu.Lock()
+		for _, paymaster := range funds.Paymasters() {
+			entry := map[string]interface{}{
+				"network":           name,
+				"address":           paymaster.Address,
+				"unit":              funds.NativeUnit(),
+				"draining":          paymaster.Draining,
+				"in_flight":         paymaster.InFlight,
+				"alert_below":       pool.AlertBelow,
+				"unavailable_below": pool.UnavailableBelow,
+				"network_available": available,
+			}
+			key, ok := m.keys[strings.ToLower(name)+"/"+paymaster.Address]
+			if ok && len(key.samples) > 0 {
+				latest := key.latest()
+				burnRate := key.burnRate()
+				entry["balance"] = latest.balance.String()
+				entry["sampled_at"] = latest.at.UTC()
+				entry["burn_rate_per_hour"] = burnRate.String()
+				entry["hours_left"] = hoursLeft(latest.balance, burnRate)
+				entry["low_balance"] = pool.AlertBelow > 0 && below(latest.balance, pool.AlertBelow)
+			}
+			if ok && key.lastErr != nil {
+				entry["error"] = key.lastErr.Error()
+			}
+			paymasters = append(paymasters, entry)
+		}
+		m.mu.Unlock()
+	}
+	return paymasters
+}
+
+func (k *monitoredPaymaster) latest() balanceSample {
+	return k.samples[len(k.samples)-1]
+}
+
+// burnRate returns how much the key spent per hour over its samples. Increases are top-ups and
+// are left out, so a refill does not hide what the key is spending.
+func (k *monitoredPaymaster) burnRate() *big.Int {
+	spent := new(big.Int)
+	if len(k.samples) < 2 {
+		return spent
+	}
+	elapsed := k.latest().at.Sub(k.samples[0].at)
+	if elapsed <= 0 {
+		return spent
+	}
+	for i := 1; i < len(k.samples); i++ {
+		if previous, current := k.samples[i-1].balance, k.samples[i].balance; current.Cmp(previous) < 0 {
+			spent.Add(spent, new(big.Int).Sub(previous, current))
+		}
+	}
+	spent.Mul(spent, big.NewInt(int64(time.Hour)))
+	return spent.Quo(spent, big.NewInt(int64(elapsed)))
+}
+
+// hoursLeft estimates how long balance lasts at burnRate; nil when nothing is being spent
+func hoursLeft(balance, burnRate *big.Int) *float64 {
+	if burnRate.Sign() == 0 {
+		return nil
+	}
+	hours, _ := new(big.Float).Quo(new(big.Float).SetInt(balance), new(big.Float).SetInt(burnRate)).Float64()
+	return &hours
+}
+
+// below reports whether balance is less than threshold
+func below(balance *big.Int, threshold uint64) bool {
+	return balance.Cmp(new(big.Int).SetUint64(threshold)) < 0
+}
@@ -0,0 +1,165 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"tinypay-server/client"
+	"tinypay-server/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// alertRecorder is a webhook that keeps the alerts posted to it
+type alertRecorder struct {
+	mu     sync.Mutex
+	alerts []paymasterAlert
+}
+
+func (r *alertRecorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	var alert paymasterAlert
+	json.NewDecoder(req.Body).Decode(&alert)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.alerts = append(r.alerts, alert)
+}
+
+func (r *alertRecorder) received() []paymasterAlert {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]paymasterAlert(nil), r.alerts...)
+}
+
+func TestPaymasterMonitor_BurnRateAndAlerts(t *testing.T) {
+	recorder := &alertRecorder{}
+	webhook := httptest.NewServer(recorder)
+	defer webhook.Close()
+
+	backend := &fakeBackend{network: "fake-evm", paymasterPool: config.PaymasterPool{AlertBelow: 700}}
+	backends := client.NewBackendRegistry()
+	backends.Register(backend)
+	m := newPaymasterMonitor(backends, &config.Config{PaymasterBurnRateWindow: time.Hour, PaymasterAlertWebhookURL: webhook.URL})
+	now := time.Unix(1700000000, 0)
+	m.now = func() time.Time { return now }
+
+	// 1000 -> 800 -> 600 over an hour burns 400 per hour and crosses the alert threshold once
+	for _, balance := range []int64{1000, 800, 600} {
+		backend.setPaymasterBalance(big.NewInt(balance))
+		m.sample(context.Background())
+		now = now.Add(30 * time.Minute)
+	}
+	alerts := recorder.received()
+	if len(alerts) != 1 || alerts[0].Event != alertLowBalance {
+		t.Fatalf("expected one low-balance alert, got %+v", alerts)
+	}
+	if alert := alerts[0]; alert.Balance != "600" || alert.BurnRatePerHour != "400" || alert.HoursLeft == nil || *alert.HoursLeft != 1.5 {
+		t.Errorf("expected 600 wei burning 400 per hour with 1.5 hours left, got %+v", alert)
+	}
+
+	// Still low: no repeated alert. A top-up recovers the key without counting as negative burn.
+	backend.setPaymasterBalance(big.NewInt(500))
+	m.sample(context.Background())
+	now = now.Add(30 * time.Minute)
+	backend.setPaymasterBalance(big.NewInt(5000))
+	m.sample(context.Background())
+
+	alerts = recorder.received()
+	if len(alerts) != 2 || alerts[1].Event != alertBalanceRecovered {
+		t.Fatalf("expected a recovery alert after the top-up, got %+v", alerts)
+	}
+	// Samples older than the window are dropped: only 600 -> 500 and the top-up remain
+	if alerts[1].BurnRatePerHour != "100" {
+		t.Errorf("expected a burn rate of 100 per hour over the window, got %s", alerts[1].BurnRatePerHour)
+	}
+}
+
+func TestPaymasterMonitor_MarksNetworkUnavailable(t *testing.T) {
+	server, backend, _ := newTestAPIServer(t)
+	router := gin.New()
+	RegisterHandlers(router, server)
+	backend.cfg.AdminToken = "secret"
+	backend.mu.Lock()
+	backend.paymasterPool = config.PaymasterPool{AlertBelow: 1000, UnavailableBelow: 100}
+	backend.mu.Unlock()
+	backend.setPaymasterBalance(big.NewInt(50))
+	server.paymasters.sample(context.Background())
+
+	status, resp := doRequest(t, router, http.MethodPost, "/api/payments", map[string]interface{}{
+		"payer_addr": "0x1111",
+		"payee_addr": "0x2222",
+		"otp":        "deadbeef",
+		"amount":     100,
+		"network":    "fake-evm",
+	})
+	if status != http.StatusBadRequest || resp.Code != CodeNetworkUnavailable {
+		t.Fatalf("expected 400/%d while the paymaster is nearly empty, got %d/%d", CodeNetworkUnavailable, status, resp.Code)
+	}
+	if len(backend.submitted()) != 0 {
+		t.Error("expected no payment to reach the backend")
+	}
+
+	backend.setPaymasterBalance(big.NewInt(5000))
+	server.paymasters.sample(context.Background())
+	if available, err := server.isNetworkAvailable("fake-evm"); !available {
+		t.Errorf("expected the network to be available after a top-up, got %v", err)
+	}
+}
+
+func TestListPaymasters(t *testing.T) {
+	server, backend, _ := newTestAPIServer(t)
+	router := gin.New()
+	RegisterHandlers(router, server)
+	backend.cfg.AdminToken = "secret"
+	backend.mu.Lock()
+	backend.paymasterPool = config.PaymasterPool{AlertBelow: 1000}
+	backend.mu.Unlock()
+	backend.setPaymasterBalance(big.NewInt(500))
+	server.paymasters.sample(context.Background())
+
+	rec := doRawRequest(t, router, http.MethodGet, "/api/admin/paymasters", nil, nil)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 without the admin token, got %d", rec.Code)
+	}
+
+	auth := map[string]string{"Authorization": "Bearer secret"}
+	rec = doRawRequest(t, router, http.MethodGet, "/api/admin/paymasters?network=fake-evm", nil, auth)
+	var resp ApiResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response %q: %v", rec.Body.String(), err)
+	}
+	if rec.Code != http.StatusOK || resp.Code != CodeServerHealthy {
+		t.Fatalf("expected 200/%d, got %d/%d", CodeServerHealthy, rec.Code, resp.Code)
+	}
+	paymasters, _ := (*resp.Data)["paymasters"].([]interface{})
+	if len(paymasters) != 1 {
+		t.Fatalf("expected one paymaster, got %v", resp.Data)
+	}
+	entry := paymasters[0].(map[string]interface{})
+	if entry["address"] != fakePaymaster || entry["balance"] != "500" || entry["unit"] != "wei" || entry["low_balance"] != true {
+		t.Errorf("expected %s low at 500 wei, got %v", fakePaymaster, entry)
+	}
+
+	// Wei balances beyond uint64 are reported in full
+	balance, _ := new(big.Int).SetString("100000000000000000000", 10)
+	backend.setPaymasterBalance(balance)
+	server.paymasters.sample(context.Background())
+	rec = doRawRequest(t, router, http.MethodGet, "/api/admin/paymasters?network=fake-evm", nil, auth)
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response %q: %v", rec.Body.String(), err)
+	}
+	entry = (*resp.Data)["paymasters"].([]interface{})[0].(map[string]interface{})
+	if entry["balance"] != balance.String() || entry["low_balance"] != false {
+		t.Errorf("expected a balance of %s wei, got %v", balance, entry)
+	}
+
+	rec = doRawRequest(t, router, http.MethodGet, "/api/admin/paymasters?network=unknown", nil, auth)
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 for an unknown network, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,153 @@
+package api
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"tinypay-server/client"
+	"tinypay-server/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreatePrecommit implements the POST /api/payments/precommit endpoint
+func (s *APIServer) CreatePrecommit(c *gin.Context) {
+	vp, ok := s.bindPaymentRequest(c)
+	if !ok {
+		return
+	}
+
+	precommitter, ok := s.getBackend(vp.network).(client.Precommitter)
+	if !ok {
+		response := CreateApiResponseWithNullData(CodePrecommitNotSupported)
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	log.Printf("Processing merchant precommit on network: %s with currency: %s", vp.network, vp.currency)
+
+	result, err := precommitter.Precommit(c.Request.Context(), &client.Payment{
+		PayerAddr: vp.req.PayerAddr,
+		PayeeAddr: vp.req.PayeeAddr,
+		Amount:    vp.amount,
+		Otp:       vp.req.Otp,
+		Currency:  vp.currency,
+	})
+	if err != nil {
+		log.Printf("Failed to submit precommit on %s: %v", vp.network, err)
+		response := paymentErrorResponse(err)
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	record := &store.Precommit{
+		PayerAddr:  vp.req.PayerAddr,
+		PayeeAddr:  vp.req.PayeeAddr,
+		Amount:     vp.amount,
+		Network:    vp.network,
+		Currency:   vp.currency,
+		CoinType:   vp.coinType,
+		CommitHash: "0x" + hex.EncodeToString(result.CommitHash),
+		TxHash:     result.TxHash,
+		Paymaster:  result.Sender,
+		ExpiresAt:  result.ExpiresAt,
+	}
+	if err := s.ledger.CreatePrecommit(record); err != nil {
+		// The precommit is on chain already; report the hash so it is not lost
+		log.Printf("Failed to record precommit %s (tx %s) in ledger: %v", record.CommitHash, result.TxHash, err)
+		response := CreateApiResponseWithNullData(CodeStorageError)
+		c.JSON(http.StatusInternalServerError, response)
+		return
+	}
+
+	data := map[string]interface{}{
+		"precommit_id":     record.ID,
+		"commit_hash":      record.CommitHash,
+		"transaction_hash": record.TxHash,
+		"network":          record.Network,
+		"currency":         record.Currency,
+	}
+	if !record.ExpiresAt.IsZero() {
+		data["expires_at"] = record.ExpiresAt
+	}
+	response := CreateApiResponseWithMap(CodePrecommitCreated, data)
+	c.JSON(http.StatusOK, response)
+}
+
+// precommitForPayment loads a precommit and checks that the payment request matches it.
+// On failure it writes the error response and returns false.
+func (s *APIServer) precommitForPayment(c *gin.Context, precommitID string, vp *validatedPayment) (*store.Precommit, bool) {
+	precommit, err := s.ledger.GetPrecommit(precommitID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			response := CreateApiResponseWithNullData(CodePrecommitNotFound)
+			c.JSON(http.StatusNotFound, response)
+		} else {
+			log.Printf("Failed to load precommit %s: %v", precommitID, err)
+			response := CreateApiResponseWithNullData(CodeStorageError)
+			c.JSON(http.StatusInternalServerError, response)
+		}
+		return nil, false
+	}
+
+	mismatched := []string{}
+	if !strings.EqualFold(precommit.PayerAddr, vp.req.PayerAddr) {
+		mismatched = append(mismatched, "payer_addr")
+	}
+	if !strings.EqualFold(precommit.PayeeAddr, vp.req.PayeeAddr) {
+		mismatched = append(mismatched, "payee_addr")
+	}
+	if precommit.Amount != vp.amount {
+		mismatched = append(mismatched, "amount")
+	}
+	if !strings.EqualFold(precommit.Currency, vp.currency) {
+		mismatched = append(mismatched, "currency")
+	}
+	if !strings.EqualFold(precommit.Network, vp.network) {
+		mismatched = append(mismatched, "network")
+	}
+	if len(mismatched) > 0 {
+		data := map[string]interface{}{
+			"mismatched_fields": mismatched,
+		}
+		response := CreateApiResponseWithMap(CodePrecommitMismatch, data)
+		c.JSON(http.StatusBadRequest, response)
+		return nil, false
+	}
+
+	if precommit.Status != store.PrecommitCommitted || precommit.Expired(time.Now()) {
+		response := CreateApiResponseWithNullData(CodePrecommitUnavailable)
+		c.JSON(http.StatusBadRequest, response)
+		return nil, false
+	}
+
+	return precommit, true
+}
+
+// claimPrecommit assigns the precommit to a payment and returns its commit hash bytes
+func (s *APIServer) claimPrecommit(precommit *store.Precommit, paymentID string) ([]byte, error) {
+	commitHash, err := hex.DecodeString(strings.TrimPrefix(precommit.CommitHash, "0x"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid stored commit hash: %w", err)
+	}
+	if _, err := s.ledger.ClaimPrecommit(precommit.ID, paymentID); err != nil {
+		return nil, err
+	}
+	return commitHash, nil
+}
+
+// releasePrecommit makes the precommit of a failed payment available for another attempt
+func (s *APIServer) releasePrecommit(payment *store.Payment) {
+	if payment.PrecommitID == "" {
+		return
+	}
+	_, err := s.ledger.ReleasePrecommit(payment.PrecommitID, payment.ID)
+	if err != nil && !errors.Is(err, store.ErrPrecommitUnavailable) {
+		log.Printf("Failed to release precommit %s of payment %s: %v", payment.PrecommitID, payment.ID, err)
+	}
+}
@@ -0,0 +1,63 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+)
+
+func TestPrecommitFlow(t *testing.T) {
+	router, backend := newTestServer(t)
+	body := map[string]interface{}{
+		"payer_addr": "0x1111",
+		"payee_addr": "0x2222",
+		"otp":        "deadbeef",
+		"amount":     100,
+		"network":    "fake-evm",
+	}
+
+	status, resp := doRequest(t, router, http.MethodPost, "/api/payments/precommit", body)
+	if status != http.StatusOK || resp.Code != CodePrecommitCreated {
+		t.Fatalf("expected 200/%d, got %d/%d", CodePrecommitCreated, status, resp.Code)
+	}
+	if (*resp.Data)["commit_hash"] != "0xc0ffee" {
+		t.Errorf("expected commit hash 0xc0ffee, got %v", (*resp.Data)["commit_hash"])
+	}
+	precommitID := (*resp.Data)["precommit_id"].(string)
+
+	// The completion has to match the precommitted payment
+	mismatched := map[string]interface{}{}
+	for k, v := range body {
+		mismatched[k] = v
+	}
+	mismatched["amount"] = 200
+	mismatched["precommit_id"] = precommitID
+	status, resp = doRequest(t, router, http.MethodPost, "/api/payments", mismatched)
+	if status != http.StatusBadRequest || resp.Code != CodePrecommitMismatch {
+		t.Fatalf("expected 400/%d, got %d/%d", CodePrecommitMismatch, status, resp.Code)
+	}
+
+	body["precommit_id"] = precommitID
+	status, resp = doRequest(t, router, http.MethodPost, "/api/payments", body)
+	if status != http.StatusOK || resp.Code != CodeTransactionCreated {
+		t.Fatalf("expected 200/%d, got %d/%d", CodeTransactionCreated, status, resp.Code)
+	}
+	if got := backend.submitted(); len(got) != 1 || !bytes.Equal(got[0].CommitHash, []byte{0xc0, 0xff, 0xee}) {
+		t.Fatalf("expected the commit hash to reach the backend, got %+v", got)
+	}
+	if got := backend.submitted()[0].Sender; got != fakePaymaster {
+		t.Errorf("expected the precommit's paymaster to complete it, got %q", got)
+	}
+
+	// A precommit completes exactly one payment
+	status, resp = doRequest(t, router, http.MethodPost, "/api/payments", body)
+	if status != http.StatusBadRequest || resp.Code != CodePrecommitUnavailable {
+		t.Errorf("expected 400/%d for a used precommit, got %d/%d", CodePrecommitUnavailable, status, resp.Code)
+	}
+
+	body["precommit_id"] = "unknown"
+	status, resp = doRequest(t, router, http.MethodPost, "/api/payments", body)
+	if status != http.StatusNotFound || resp.Code != CodePrecommitNotFound {
+		t.Errorf("expected 404/%d, got %d/%d", CodePrecommitNotFound, status, resp.Code)
+	}
+}
@@ -0,0 +1,60 @@
+package api
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"tinypay-server/client"
+
+	"github.com/gin-gonic/gin"
+)
+
+// preflightPayment checks a payment against the payer's on-chain state before anything is recorded
+// or signed: the OTP has to match the payer's tail, and the amount has to be within the payment
+// limit and covered by the deposited balance. On failure it writes the error response and returns
+// false. Lookups that fail are skipped, since the contract enforces the same rules on submission.
+func (s *APIServer) preflightPayment(c *gin.Context, vp *validatedPayment) bool {
+	ctx := c.Request.Context()
+	backend := s.getBackend(vp.network)
+	payer := vp.req.PayerAddr
+
+	if err := s.tails.VerifyOTP(ctx, backend, payer, vp.req.Otp); err != nil {
+		log.Printf("Rejecting payment from %s on %s: %v", payer, vp.network, err)
+		response := CreateApiResponseWithNullData(CodeInvalidOpt)
+		c.JSON(http.StatusBadRequest, response)
+		return false
+	}
+
+	// A payment limit of zero means the payer has not set one
+	limits, err := backend.GetUserLimits(ctx, payer)
+	if err != nil {
+		log.Printf("Skipping payment limit check for %s on %s: %v", payer, vp.network, err)
+	} else if limits != nil && limits.PaymentLimit > 0 && vp.amount > limits.PaymentLimit {
+		data := map[string]interface{}{
+			"payment_limit": limits.PaymentLimit,
+		}
+		response := CreateApiResponseWithMap(CodeAmountExceedsLimit, data)
+		c.JSON(http.StatusBadRequest, response)
+		return false
+	}
+
+	reader, ok := backend.(client.BalanceReader)
+	if !ok {
+		return true
+	}
+	balance, err := reader.GetBalance(ctx, payer, vp.currency)
+	switch {
+	case errors.Is(err, client.ErrBalanceUnavailable):
+	case err != nil:
+		log.Printf("Skipping balance check for %s on %s: %v", payer, vp.network, err)
+	case vp.amount > balance:
+		data := map[string]interface{}{
+			"available_balance": balance,
+		}
+		response := CreateApiResponseWithMap(CodeInsufficientBalance, data)
+		c.JSON(http.StatusBadRequest, response)
+		return false
+	}
+	return true
+}
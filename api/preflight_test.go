@@ -0,0 +1,108 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"tinypay-server/client"
+	"tinypay-server/store"
+)
+
+func TestPreflight_RejectsOTPNotMatchingTail(t *testing.T) {
+	router, backend, ledger := newTestServerWithLedger(t)
+	// sha256("deadbeef")
+	backend.tail = []byte("2baf1f40105d9501fe319a8ec463fdf4325a2a5df445adf3f572f626253678c9")
+
+	request := map[string]interface{}{
+		"payer_addr": "0x1111",
+		"payee_addr": "0x2222",
+		"otp":        "cafebabe",
+		"amount":     100,
+		"network":    "fake-evm",
+	}
+	status, resp := doRequest(t, router, http.MethodPost, "/api/payments", request)
+	if status != http.StatusBadRequest || resp.Code != CodeInvalidOpt {
+		t.Fatalf("expected 400/%d, got %d/%d", CodeInvalidOpt, status, resp.Code)
+	}
+	if len(backend.submitted()) != 0 {
+		t.Fatal("a payment with a mismatching OTP must not reach the backend")
+	}
+	if payments, _, err := ledger.ListPayments(store.PaymentFilter{}); err != nil || len(payments) != 0 {
+		t.Fatalf("expected no ledger record, got %d err=%v", len(payments), err)
+	}
+
+	request["otp"] = "DEADBEEF"
+	status, resp = doRequest(t, router, http.MethodPost, "/api/payments", request)
+	if status != http.StatusOK || resp.Code != CodeTransactionCreated {
+		t.Fatalf("expected 200/%d, got %d/%d", CodeTransactionCreated, status, resp.Code)
+	}
+}
+
+func TestPreflight_RejectsAmountAboveLimit(t *testing.T) {
+	router, backend := newTestServer(t)
+	backend.limits = &client.UserLimits{PaymentLimit: 50}
+
+	status, resp := doRequest(t, router, http.MethodPost, "/api/payments", map[string]interface{}{
+		"payer_addr": "0x1111",
+		"payee_addr": "0x2222",
+		"otp":        "deadbeef",
+		"amount":     100,
+		"network":    "fake-evm",
+	})
+
+	if status != http.StatusBadRequest || resp.Code != CodeAmountExceedsLimit {
+		t.Fatalf("expected 400/%d, got %d/%d", CodeAmountExceedsLimit, status, resp.Code)
+	}
+	if limit := (*resp.Data)["payment_limit"]; limit != float64(50) {
+		t.Errorf("expected payment_limit 50, got %v", limit)
+	}
+	if len(backend.submitted()) != 0 {
+		t.Error("a payment above the limit must not reach the backend")
+	}
+}
+
+func TestPreflight_RejectsInsufficientBalance(t *testing.T) {
+	router, backend := newTestServer(t)
+	backend.limits = &client.UserLimits{PaymentLimit: 0} // no limit set
+	backend.balances = map[string]uint64{"ETH": 1000, "USDC": 40}
+
+	request := map[string]interface{}{
+		"payer_addr": "0x1111",
+		"payee_addr": "0x2222",
+		"otp":        "deadbeef",
+		"amount":     100,
+		"network":    "fake-evm",
+		"currency":   "USDC",
+	}
+	status, resp := doRequest(t, router, http.MethodPost, "/api/payments", request)
+	if status != http.StatusBadRequest || resp.Code != CodeInsufficientBalance {
+		t.Fatalf("expected 400/%d, got %d/%d", CodeInsufficientBalance, status, resp.Code)
+	}
+	if available := (*resp.Data)["available_balance"]; available != float64(40) {
+		t.Errorf("expected available_balance 40, got %v", available)
+	}
+
+	request["currency"] = "ETH"
+	status, resp = doRequest(t, router, http.MethodPost, "/api/payments", request)
+	if status != http.StatusOK || resp.Code != CodeTransactionCreated {
+		t.Fatalf("expected 200/%d, got %d/%d", CodeTransactionCreated, status, resp.Code)
+	}
+}
+
+func TestPreflight_SkipsFailedLookups(t *testing.T) {
+	router, backend := newTestServer(t)
+	backend.lookupErr = errors.New("rpc unavailable")
+
+	status, resp := doRequest(t, router, http.MethodPost, "/api/payments", map[string]interface{}{
+		"payer_addr": "0x1111",
+		"payee_addr": "0x2222",
+		"otp":        "deadbeef",
+		"amount":     100,
+		"network":    "fake-evm",
+	})
+
+	if status != http.StatusOK || resp.Code != CodeTransactionCreated {
+		t.Fatalf("expected 200/%d, got %d/%d", CodeTransactionCreated, status, resp.Code)
+	}
+}
@@ -16,9 +16,45 @@ type ServerInterface interface {
 	// 健康检查
 	// (GET /api)
 	HealthCheck(c *gin.Context)
+	// 查询 paymaster 余额
+	// (GET /api/admin/paymasters)
+	ListPaymasters(c *gin.Context, params ListPaymastersParams)
+	// 停用 paymaster 密钥
+	// (POST /api/admin/paymasters/{address}/drain)
+	DrainPaymaster(c *gin.Context, address string, params DrainPaymasterParams)
+	// 恢复 paymaster 密钥
+	// (POST /api/admin/paymasters/{address}/resume)
+	ResumePaymaster(c *gin.Context, address string, params ResumePaymasterParams)
+	// 取消待确认交易
+	// (POST /api/admin/transactions/{transaction_hash}/cancel)
+	CancelTransaction(c *gin.Context, transactionHash string, params CancelTransactionParams)
+	// 查询 webhook 订阅
+	// (GET /api/admin/webhooks)
+	ListWebhooks(c *gin.Context)
+	// 创建 webhook 订阅
+	// (POST /api/admin/webhooks)
+	CreateWebhook(c *gin.Context)
+	// 查询 webhook 投递日志
+	// (GET /api/admin/webhooks/deliveries)
+	ListWebhookDeliveries(c *gin.Context, params ListWebhookDeliveriesParams)
+	// 重新投递 webhook
+	// (POST /api/admin/webhooks/deliveries/{delivery_id}/redeliver)
+	RedeliverWebhook(c *gin.Context, deliveryId string)
+	// 删除 webhook 订阅
+	// (DELETE /api/admin/webhooks/{subscription_id})
+	DeleteWebhook(c *gin.Context, subscriptionId string)
+	// 查询链上合约事件
+	// (GET /api/events)
+	ListChainEvents(c *gin.Context, params ListChainEventsParams)
+	// 查询支付记录
+	// (GET /api/payments)
+	ListPayments(c *gin.Context, params ListPaymentsParams)
 	// 创建支付交易
 	// (POST /api/payments)
-	CreatePayment(c *gin.Context)
+	CreatePayment(c *gin.Context, params CreatePaymentParams)
+	// 创建商户预提交
+	// (POST /api/payments/precommit)
+	CreatePrecommit(c *gin.Context)
 	// 查询交易状态
 	// (GET /api/payments/{transaction_hash})
 	GetTransactionStatus(c *gin.Context, transactionHash string, params GetTransactionStatusParams)
@@ -49,9 +85,532 @@ func (siw *ServerInterfaceWrapper) HealthCheck(c *gin.Context) {
 	siw.Handler.HealthCheck(c)
 }
 
+// ListPaymasters operation middleware
+func (siw *ServerInterfaceWrapper) ListPaymasters(c *gin.Context) {
+
+	var err error
+
+	c.Set(AdminTokenScopes, []string{})
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params ListPaymastersParams
+
+	// ------------- Optional query parameter "network" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "network", c.Request.URL.Query(), &params.Network)
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter network: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.ListPaymasters(c, params)
+}
+
+// DrainPaymaster operation middleware
+func (siw *ServerInterfaceWrapper) DrainPaymaster(c *gin.Context) {
+
+	var err error
+
+	// ------------- Path parameter "address" -------------
+	var address string
+
+	err = runtime.BindStyledParameterWithOptions("simple", "address", c.Param("address"), &address, runtime.BindStyledParameterOptions{Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter address: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	c.Set(AdminTokenScopes, []string{})
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params DrainPaymasterParams
+
+	// ------------- Required query parameter "network" -------------
+
+	if paramValue := c.Query("network"); paramValue != "" {
+
+	} else {
+		siw.ErrorHandler(c, fmt.Errorf("Query argument network is required, but not found"), http.StatusBadRequest)
+		return
+	}
+
+	err = runtime.BindQueryParameter("form", true, true, "network", c.Request.URL.Query(), &params.Network)
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter network: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.DrainPaymaster(c, address, params)
+}
+
+// ResumePaymaster operation middleware
+func (siw *ServerInterfaceWrapper) ResumePaymaster(c *gin.Context) {
+
+	var err error
+
+	// ------------- Path parameter "address" -------------
+	var address string
+
+	err = runtime.BindStyledParameterWithOptions("simple", "address", c.Param("address"), &address, runtime.BindStyledParameterOptions{Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter address: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	c.Set(AdminTokenScopes, []string{})
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params ResumePaymasterParams
+
+	// ------------- Required query parameter "network" -------------
+
+	if paramValue := c.Query("network"); paramValue != "" {
+
+	} else {
+		siw.ErrorHandler(c, fmt.Errorf("Query argument network is required, but not found"), http.StatusBadRequest)
+		return
+	}
+
+	err = runtime.BindQueryParameter("form", true, true, "network", c.Request.URL.Query(), &params.Network)
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter network: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.ResumePaymaster(c, address, params)
+}
+
+// CancelTransaction operation middleware
+func (siw *ServerInterfaceWrapper) CancelTransaction(c *gin.Context) {
+
+	var err error
+
+	// ------------- Path parameter "transaction_hash" -------------
+	var transactionHash string
+
+	err = runtime.BindStyledParameterWithOptions("simple", "transaction_hash", c.Param("transaction_hash"), &transactionHash, runtime.BindStyledParameterOptions{Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter transaction_hash: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	c.Set(AdminTokenScopes, []string{})
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params CancelTransactionParams
+
+	// ------------- Required query parameter "network" -------------
+
+	if paramValue := c.Query("network"); paramValue != "" {
+
+	} else {
+		siw.ErrorHandler(c, fmt.Errorf("Query argument network is required, but not found"), http.StatusBadRequest)
+		return
+	}
+
+	err = runtime.BindQueryParameter("form", true, true, "network", c.Request.URL.Query(), &params.Network)
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter network: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.CancelTransaction(c, transactionHash, params)
+}
+
+// ListWebhooks operation middleware
+func (siw *ServerInterfaceWrapper) ListWebhooks(c *gin.Context) {
+
+	c.Set(AdminTokenScopes, []string{})
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.ListWebhooks(c)
+}
+
+// CreateWebhook operation middleware
+func (siw *ServerInterfaceWrapper) CreateWebhook(c *gin.Context) {
+
+	c.Set(AdminTokenScopes, []string{})
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.CreateWebhook(c)
+}
+
+// ListWebhookDeliveries operation middleware
+func (siw *ServerInterfaceWrapper) ListWebhookDeliveries(c *gin.Context) {
+
+	var err error
+
+	c.Set(AdminTokenScopes, []string{})
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params ListWebhookDeliveriesParams
+
+	// ------------- Optional query parameter "subscription_id" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "subscription_id", c.Request.URL.Query(), &params.SubscriptionId)
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter subscription_id: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	// ------------- Optional query parameter "payment_id" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "payment_id", c.Request.URL.Query(), &params.PaymentId)
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter payment_id: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	// ------------- Optional query parameter "status" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "status", c.Request.URL.Query(), &params.Status)
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter status: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	// ------------- Optional query parameter "cursor" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "cursor", c.Request.URL.Query(), &params.Cursor)
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter cursor: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	// ------------- Optional query parameter "limit" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "limit", c.Request.URL.Query(), &params.Limit)
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter limit: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.ListWebhookDeliveries(c, params)
+}
+
+// RedeliverWebhook operation middleware
+func (siw *ServerInterfaceWrapper) RedeliverWebhook(c *gin.Context) {
+
+	var err error
+
+	// ------------- Path parameter "delivery_id" -------------
+	var deliveryId string
+
+	err = runtime.BindStyledParameterWithOptions("simple", "delivery_id", c.Param("delivery_id"), &deliveryId, runtime.BindStyledParameterOptions{Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter delivery_id: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	c.Set(AdminTokenScopes, []string{})
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.RedeliverWebhook(c, deliveryId)
+}
+
+// DeleteWebhook operation middleware
+func (siw *ServerInterfaceWrapper) DeleteWebhook(c *gin.Context) {
+
+	var err error
+
+	// ------------- Path parameter "subscription_id" -------------
+	var subscriptionId string
+
+	err = runtime.BindStyledParameterWithOptions("simple", "subscription_id", c.Param("subscription_id"), &subscriptionId, runtime.BindStyledParameterOptions{Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter subscription_id: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	c.Set(AdminTokenScopes, []string{})
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.DeleteWebhook(c, subscriptionId)
+}
+
+// ListChainEvents operation middleware
+func (siw *ServerInterfaceWrapper) ListChainEvents(c *gin.Context) {
+
+	var err error
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params ListChainEventsParams
+
+	// ------------- Required query parameter "network" -------------
+
+	if paramValue := c.Query("network"); paramValue != "" {
+
+	} else {
+		siw.ErrorHandler(c, fmt.Errorf("Query argument network is required, but not found"), http.StatusBadRequest)
+		return
+	}
+
+	err = runtime.BindQueryParameter("form", true, true, "network", c.Request.URL.Query(), &params.Network)
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter network: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	// ------------- Optional query parameter "type" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "type", c.Request.URL.Query(), &params.Type)
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter type: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	// ------------- Optional query parameter "account" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "account", c.Request.URL.Query(), &params.Account)
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter account: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	// ------------- Optional query parameter "transaction_hash" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "transaction_hash", c.Request.URL.Query(), &params.TransactionHash)
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter transaction_hash: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	// ------------- Optional query parameter "cursor" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "cursor", c.Request.URL.Query(), &params.Cursor)
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter cursor: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	// ------------- Optional query parameter "limit" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "limit", c.Request.URL.Query(), &params.Limit)
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter limit: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.ListChainEvents(c, params)
+}
+
+// ListPayments operation middleware
+func (siw *ServerInterfaceWrapper) ListPayments(c *gin.Context) {
+
+	var err error
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params ListPaymentsParams
+
+	// ------------- Optional query parameter "payee" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "payee", c.Request.URL.Query(), &params.Payee)
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter payee: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	// ------------- Optional query parameter "payer" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "payer", c.Request.URL.Query(), &params.Payer)
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter payer: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	// ------------- Optional query parameter "network" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "network", c.Request.URL.Query(), &params.Network)
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter network: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	// ------------- Optional query parameter "status" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "status", c.Request.URL.Query(), &params.Status)
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter status: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	// ------------- Optional query parameter "from" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "from", c.Request.URL.Query(), &params.From)
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter from: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	// ------------- Optional query parameter "to" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "to", c.Request.URL.Query(), &params.To)
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter to: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	// ------------- Optional query parameter "cursor" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "cursor", c.Request.URL.Query(), &params.Cursor)
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter cursor: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	// ------------- Optional query parameter "limit" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "limit", c.Request.URL.Query(), &params.Limit)
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter limit: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.ListPayments(c, params)
+}
+
 // CreatePayment operation middleware
 func (siw *ServerInterfaceWrapper) CreatePayment(c *gin.Context) {
 
+	var err error
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params CreatePaymentParams
+
+	// ------------- Optional query parameter "async" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "async", c.Request.URL.Query(), &params.Async)
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter async: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	headers := c.Request.Header
+
+	// ------------- Optional header parameter "Idempotency-Key" -------------
+	if valueList, found := headers[http.CanonicalHeaderKey("Idempotency-Key")]; found {
+		var IdempotencyKey string
+		n := len(valueList)
+		if n != 1 {
+			siw.ErrorHandler(c, fmt.Errorf("Expected one value for Idempotency-Key, got %d", n), http.StatusBadRequest)
+			return
+		}
+
+		err = runtime.BindStyledParameterWithOptions("simple", "Idempotency-Key", valueList[0], &IdempotencyKey, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationHeader, Explode: false, Required: false})
+		if err != nil {
+			siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter Idempotency-Key: %w", err), http.StatusBadRequest)
+			return
+		}
+
+		params.IdempotencyKey = &IdempotencyKey
+
+	}
+
+	// ------------- Optional header parameter "Api-Key" -------------
+	if valueList, found := headers[http.CanonicalHeaderKey("Api-Key")]; found {
+		var ApiKey string
+		n := len(valueList)
+		if n != 1 {
+			siw.ErrorHandler(c, fmt.Errorf("Expected one value for Api-Key, got %d", n), http.StatusBadRequest)
+			return
+		}
+
+		err = runtime.BindStyledParameterWithOptions("simple", "Api-Key", valueList[0], &ApiKey, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationHeader, Explode: false, Required: false})
+		if err != nil {
+			siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter Api-Key: %w", err), http.StatusBadRequest)
+			return
+		}
+
+		params.ApiKey = &ApiKey
+
+	}
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.CreatePayment(c, params)
+}
+
+// CreatePrecommit operation middleware
+func (siw *ServerInterfaceWrapper) CreatePrecommit(c *gin.Context) {
+
 	for _, middleware := range siw.HandlerMiddlewares {
 		middleware(c)
 		if c.IsAborted() {
@@ -59,7 +618,7 @@ func (siw *ServerInterfaceWrapper) CreatePayment(c *gin.Context) {
 		}
 	}
 
-	siw.Handler.CreatePayment(c)
+	siw.Handler.CreatePrecommit(c)
 }
 
 // GetTransactionStatus operation middleware
@@ -160,7 +719,19 @@ func RegisterHandlersWithOptions(router gin.IRouter, si ServerInterface, options
 	}
 
 	router.GET(options.BaseURL+"/api", wrapper.HealthCheck)
+	router.GET(options.BaseURL+"/api/admin/paymasters", wrapper.ListPaymasters)
+	router.POST(options.BaseURL+"/api/admin/paymasters/:address/drain", wrapper.DrainPaymaster)
+	router.POST(options.BaseURL+"/api/admin/paymasters/:address/resume", wrapper.ResumePaymaster)
+	router.POST(options.BaseURL+"/api/admin/transactions/:transaction_hash/cancel", wrapper.CancelTransaction)
+	router.GET(options.BaseURL+"/api/admin/webhooks", wrapper.ListWebhooks)
+	router.POST(options.BaseURL+"/api/admin/webhooks", wrapper.CreateWebhook)
+	router.GET(options.BaseURL+"/api/admin/webhooks/deliveries", wrapper.ListWebhookDeliveries)
+	router.POST(options.BaseURL+"/api/admin/webhooks/deliveries/:delivery_id/redeliver", wrapper.RedeliverWebhook)
+	router.DELETE(options.BaseURL+"/api/admin/webhooks/:subscription_id", wrapper.DeleteWebhook)
+	router.GET(options.BaseURL+"/api/events", wrapper.ListChainEvents)
+	router.GET(options.BaseURL+"/api/payments", wrapper.ListPayments)
 	router.POST(options.BaseURL+"/api/payments", wrapper.CreatePayment)
+	router.POST(options.BaseURL+"/api/payments/precommit", wrapper.CreatePrecommit)
 	router.GET(options.BaseURL+"/api/payments/:transaction_hash", wrapper.GetTransactionStatus)
 	router.GET(options.BaseURL+"/api/users/:user_address/limits", wrapper.GetUserLimits)
 }
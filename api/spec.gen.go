@@ -18,50 +18,181 @@ import (
 // Base64 encoded, gzipped, json marshaled Swagger object
 var swaggerSpec = []string{
 
-	"H4sIAAAAAAAC/9xZX1PbVhb/KpqbPjRdgy3/A/xGgDad6U6Zxn2qsyCk61itLamSnI2X8YxpYGtS86cN",
-	"IcSQEhIozG6xyTQl1Jjkw8RXMk98hZ17r2TLkiilTXdn9gmkc+89f+45v9858jTg5ZwiS1DSNZCYBhqf",
-	"gTmO/DusiJ9ATZElDeJHRZUVqOoiJEJeFshbAWq8Kiq6KEsgAVpHVXRvy7x3aJRmzCczIADgHS6nZCFI",
-	"sKFQKAD0ggJBAoiSDm9BFRQDQOB0znsQur+AGivGgwNjoQYCQMpns9wUPkZX87BzjDz1OeR1UCwGgAq/",
-	"zIsqFEDiM2rbTc+qABjnCjko6Z/AL/NQ071OcTk5L+lea06//vb06WMmL0q6+fwYff+N2zHiW1pWc5xO",
-	"vYtHQQDkREnM5XMgwfo5zudVFUp8gapLc/ks3js8ngQBl/r2i110NGPuLpjPj7FmCZ/5mbX00xujIyAA",
-	"xpLXQQCMjH30Mfa8Y5y1yNKu6aoo3cLKJaj/XVa/6NXNKbqs9elQ0yWoe6ww12vGk6/Nk2/N4w2n/55t",
-	"HmWyrnhj+vF4ksnAO8aTJmou9ZwnQE6YgjANAkDhdB2qePnfPuP60sN974f6hm7+5R0/LQpXgHCCEwTV",
-	"q8xYOTR+fIU2DtDj0jlaQ3e4KV6AaTYcicbiA4NDofOee+0K3XFYNs0G4tHiueap55jXOl672LyLDaPP",
-	"v8c8VwE5bKW31xPdgF0n3hLDB4lSWqYIIekcT6pJ4nJ4VVKUCuNcgRke/5C5kVcUWSVV6ar8zUarsch8",
-	"MnYjmc5nmfbubLty16zOGit1HKbFHbT07KxZMVbqRmUGbVdRpYEePzy9/4pm5lmzPIzz8U1pZkzPQBXm",
-	"c29KMyMwK58151uNbWPtPtqeNZf/+ab0VUpKSVeuMBbWkJi36y+MtcWUZMyXjI15qq118tpc2TOPN1tH",
-	"JbM661x+1qympMnJyc81WUpJ0ymJYVIEflIgwRgPDtD+wwB9iWEOv5xmgu8xaO5l6+Q+RTejvIrRjXkv",
-	"yBRTUpEcl5LQ/IL577rx5BdjoXY9mRzvQCoqbxur++36S+P5V8ZaHS3/YJSX0b1NHBKyGmtH+w+N2s90",
-	"KdVlVmed2Nx13oXYqFZt/TKPJVcYenBHxLyLka6PHRoaupqS+hj8lGCMjQV0bws92mu/Xm5vVYz9Z+jo",
-	"yBKzCcaKeHkdHTfoeZYs3JGR22gd7VuCiC0wt2rt2ra9iZp0uvKoXa87TApjk8K2SWFiEkVr9HrudOsY",
-	"be+2GoshS8jawvbhHPq6cfpoGZUPLRk26OTR6dPHraOF9uFP1ttIgsFA1TpaMPafmVs163U0wZjNBjr4",
-	"FmvZKNF4W7KY7UDraAHtr6GNPUsQTzDGwyfGg7JZnXVCuu0cTWBz/hdUq3odZbGjrO0oix2lG7Capbq5",
-	"smcJWFtwOrdgntToSZYsbMvar783FndsGc0EkubGzzPm3jcpie1naA62D+6aK3vM5PjHN5JMkFPEoEJZ",
-	"VJtkrHsllUl9Tknh/m5OGM9KxuaOlY21ivHghVHaPWuWcey2n9P37dcraP37jqc4uGfN+ZQU6Xfm1np7",
-	"q0I1nP6r0q7PnDXL9B+0/bz9Yue0umRsNLxHhfrwHZLzov0M3UEzCpUf0eVWFt4vo6MKNs3ejfOXbIzZ",
-	"kTBWDlH5wLkeLS+eNSs0cMzkB2OuAAWndZWTNI7H0DaR4bRMcZJpn9Ta9afG5k67/pQqS0kgALIiD61G",
-	"y0LLv36YxOioi3rWBZ4gAG5DVaN4yfaH+kOEZRUocYoIEiDSH+qPUBrIkL4mSN5Pg1vQp7mhl+Sq4w5M",
-	"AHKwyuHFHwogAa5DLqtnRjKQ/wJg1qANIlETDoVs4Ie0jeIUJSvyZHMQYyR+Z3Ea2ZEhh5EWSMvncpxa",
-	"wAZ10oeACfaWy+Zht+ukraTdOGo6p+c1TGZ5noeahlmoGLAaWbzgHRWmQQJcCXY73aDV5gadPS7Z5oqN",
-	"yxRycMdQNLODGi9p/DCncrc0zJ5aQdNhDtzEi3vygTScsuZzB1YhrR50iI6mGUZ124RWs4rmyueUVMWc",
-	"PUTLi36FUulJe7zGnfaEDTwXPaJCTodWywxogwA1/ZosFC55y1OcJvITVhR67xptNoyNH6nL5naj9eob",
-	"53Xb/Xinxe72zFbD2+ljAdQzfRpU5KzIWU1LAgxG4dTgYBjG4mw0PDQYFSAXTkM4NDAQFuIhPhQTIoOD",
-	"saEomxbCsXB4IM5GY2w0Gk9H4xyMRgZ6+x7chI1d4wXh/XR8bGQ0wkdGBT4WDXOx0ZFrbGhoeFQIRadY",
-	"eG0ADoHehu8yO3Ha8DAr+wcMdzLM74kXGQ2cAcM6HBH7Xzj6m8vUNbgVextWPBMW/zAW2fDRi0Wkaoyl",
-	"5VZjm5aMLxyxvnA0lRN1HQoYGlw8QAKFr4hlWTYcDocjkUgkGo1GY7FYLB6PxwcGBgYumkfeJtB5+zRM",
-	"KdFLBzEnapoo3ZpIizAruGLp7Zi8scT0342l+zTXZGKPIcSf21xWFIhhE2lOzOK8cCp3YiLtGXyVd3gF",
-	"9+RvL7y0X6ftlptEPD2Ug0o6zOElE5/m4nyKJ5OBE/Jp/2E12oTr0XeVdv0H4+6cH+l/APVkV9sNmuK4",
-	"8lUuB3WoYmOn/VOKNkqlJsCTIUiQpgTjEG1yPIXhrmvnBfRMw1x4KsJHhRiMpy+eiX2K51em5PNm5Onf",
-	"+0WEOP5lHqqFruc2EDsdPP9TjNuWm38Y7nhZSotqjtZJr182azC0Smn2WHNDqYKW1lBl9axZHR5PBj+9",
-	"MTrybo+xV/HknbxOJQ5SvsoY5VUGsxAVOennKgh4StU5/vlibqSLEw6ao9+7uiznDqQKeSjehsKEhys7",
-	"wN2NDKnkzuMENtmHjf+QvRfR8uXtVaAk4CTxgb/OrO1rYLhr4Pnx62i11bx1EqJwRAHKSUXRSya4JOsT",
-	"aTkv+RJBZzb3ZYHYn8MCbtW9ROCF5F8ngrwGVS04jf8QQoSaVgxmxZxIB41fIQI85pdf0s+N1iBK3nSG",
-	"D/ph5E1pBh28Or27Z6y/MFYPjB+3jAcH6LuKsVFC27te0TnE8akG1Y+oWRcwhtMuf7pwOvvbqeJP/XD6",
-	"/0UKeO7o5pAL6KhTPZV5wWx+LqyRi+wqynF3JnROzE7kFYHTsS1sKGAnPV3nQD7HygmeomLEgqFuD997",
-	"K5YhPYxGmzK09JXx4MDJaz1hflOacZAY4TCXK+4Kvmxk3Lf6tkMTK75FiKYlSvHBC9GXTTdRIl17p6J7",
-	"Ry/yrdRCKfsHES9WR/7rHbsTMmkkHEBNUNkaSTSo3vZHulF4G2ZlBV8hQ1fhi1ezIAEyuq4kgsGszHPZ",
-	"jKzpiaEQHvU8ODOuykKeNM5+J2iJICaJPl2UCgpX6FdUKIi8rmS5Qv+dwj8AxgrLZO9vZeTjwvoRmvuJ",
-	"/gDSxacODfngHqUQ3200KD57fjo2jzf991hf0Yo3i/8JAAD//yfSAv6jHgAA",
+	"H4sIAAAAAAAC/+x9a1MbSZboX6mo2Q/TO2D05KGIjRtu2z3dd3umfdvu2YhtvKKQUkbTeo1Ucpt1ECFs",
+	"A8IIRNsYbMCNcYNhbIPwo3lJmP9yraySPvEXbpw8WVUpqcTDjb13ds0nVJWVj5OZ5/24IQfi0UQ8RmJq",
+	"SvbdkFOBPhJV2L9nE+FvSSoRj6UI/Ewk4wmSVMOEvQzEg+xpkKQCyXBCDcdjsk8ub8/SO4v6nU0tM6g/",
+	"HpRbZHJdiSYiRPY5HQ5Hi6z2J4jsk8MxlVwlSXmgRQ4qqtLYEb03TnentPsb2vi63CLH0pGI0gvdqMk0",
+	"MbuJ9/6VBFR5YKBFTpK/pcNJEpR93+PcrjS0apEvKv1RElO/JX9Lk5TauCglGk/H1MbZVEd+qj55JKXD",
+	"MVV/WaQ/j9UvjK0tFE9GFRVX1+6RW+RoOBaOpqOyz2m38EA6mSSxQD8OF1LSEfj27MXLckvd8JU3K3R7",
+	"UF8Z118WYeQY9Pk9b/rdpfPn5Bb5wuUv5Rb53IWvv5GvCJPjjfjoKTUZjl2FwWNE/TGe/KF2bCWhxlOt",
+	"KkmpMaI2zEKfW9cej+h7P+nFeXH9DZ81DBZXE40w/ebiZamPXNcel2gpX9NfkCjBXkJCcoucUFSVJKH5",
+	"f3yvtIbOtn7haO268od/shslofQT4leCwWTjYNrUpvbiLZ3foI8yTUZ1XFd6A0EScrrcHm97R2eXo9nv",
+	"2nk5rgszu+FsafcMNJ1essn0ysUHR0/v6Inh7/ecXpIE4tFoWPWHg40T1KdeShe/uXRZalMS4bYEXqNU",
+	"m/mRVNmfonM/67O3q09ua/nJ8u6S9NX5g1KOrue07KT5UJ+9rU0VYLkzm3TxOR1+eFDK0uwcLe6abeDV",
+	"/p5+f/mgNFoDAmdne3vQEfIqrl53wBP0kvZQh9LZ29W4nDp8IIAeD2PNYWkxrr0dxvg30tsXj//QHGMk",
+	"wv4fSL8NAss/0+7cr2buapO7dPtppbAsnU2EW/+V9EuVwpb28iZdeoMLN2FyUJrT5sfpnUX6cJXmn5X3",
+	"H9G1B3RoU8uN6Ls7NaCI/uCPhK8RvyfUpbgCdvtJrhkYvQ6XrD+pPhjSZ2+Xd8fKxc2DUk6//1D/+25l",
+	"cVVf2qVDq9Vbq3KLHFZJlH1tIBu+52dS6d5oWFVJUG4xnwXisVA4Ga15FlLCERKUr9hMjT9Qkkml/6iL",
+	"a4KxUlgWL7EJtLpLcuHzQDD4Raj9wrnz7oD7fDDg9bgU7/lznzsdXWfPBx2eXif5vIN02YEsnYzYYI4J",
+	"GBeBpc/elvpUNfH71GcSTqNmdHiV8rW1RUky0KfE1DP81ZlAPNqmhmP9CaW/7Uc8UKkjDy3M5oodpUuR",
+	"QDoZVvsvAaXGU3g2GA3HLsd/IDEb2jU0ru+tVxd3JAVanVGh2UEpq2Wn9YkCfXKL5h9UR/LS2fN/+urP",
+	"/svf/OuFPx+URvXZ2zRTkluQH4AZ9BIlSZLWtGG18gBMKBwLxZEliKlKgF2SmMK+uRyO9V9U+qWzF7+S",
+	"LqUTiXiSXaq6LV7YLe9OSN9euHQ5lI5IlZXbldwtC1FMLNP8LwelnDZV0HKDdGmW5nbpo5nqvbdIig5K",
+	"2bNAgN5lBi+ofSRJ0tF3mcFzJBI/KI0COnlwjy7d1ieH32Vudse6Y7/7ncSZC4ZkK4U32oOJ7pg2mtHm",
+	"R3G08t6+PrWqFxfK23DQxOYHpdnuWE9Pz19T8Vh37EZ3TJK6Gb/RLfsk7f4GXZtpwYfA18DDG1LbP0t0",
+	"aKu8dw/ZGS07DeyM9M9t0kB3bIB11x2jo+P684L2eEcbX//y8uWLJg9Fs0va9BriDO1BgU4+1bKT9M4C",
+	"gIS1htHp2oy2/is2xbHghgvMmLX4OhaNrs+Wd0bhze8k7Nh8Jf0eWJtWZ1dX12fdsVYJfvkkE0VV9icr",
+	"izlt7Re6vc1fO30ShzjDbNgff+fySeJulLfX+Au38UJfXK+sL9V85PFJFl0QX3h9Es1Pa5tZ3ufWK2zE",
+	"X7f7JEBESkolSYkWhqt3l3FZ0HDujTa9wRt2+CR+IyXEjHTrFU6eN+i0GtCtV9WRcW16g955TIeWES9V",
+	"HyzQ7AzC9ndSdephpVAQIOgCCLoMCLoYBJGbpPtD1cUiXVop7044+Eun8bKyOURHdqsPJ2l2k78D+O09",
+	"rD55VN4er2y+5k/dPgkYqfL2uLb2i764zh97fJJe2qUbP8Eo8xk8Hvyd14B3eXscCMz8Kn/R7pO0mcfa",
+	"/aw+e1tkOfnrDp9Ed27qa6PVqXW69UqfWi3vTkAfkzn4gB1Q3rTTJ+lz23QyZ35gtoDzMr9acwZcDkeX",
+	"sM+V9Q26d79uck44eQwh0PxN7f5GeXvC/KC8PV7ezlRG3vCmTqEzuvUKb7OWnaZbryr7I9r8Am/n8kmV",
+	"wjJiEQAgQzDml7yReTorT55X770tb9+hk1l996k2dlcvPgLuZWKBzj2urAxKcOPPJImSiscOSqP8ew8b",
+	"BBk8bXoHcAzitVo4HJRylcKWvjpOJyeqI+OVwn3+vblbMPsnz/X1RX1ymP70AE8/b9Tuk/AF4q/K+lKl",
+	"MEiXXlbeLGvZaW3+GZ0s6FMGKDvs1q3N7WvjT3Ao3q5TGLq8fad67y0CEbZmfhVfaS8f4+zh5Ly+XzOr",
+	"LmEc7dUiLH1tqeFeYmOXo/4iwsSR8tudB5ez4eKyc4EH+MhtcbG9h/MIaJlNvVIo0vy0cZFx1vroDl2f",
+	"bbzUTrjUTuNSO+FSm+CkeRPUTofTeMGpMOuJv3MZ7yr7P2sTyzXv3A2gw26BA6kHICyWsW10cB4Puogn",
+	"2JI5emeYW39eaFyQCxbkMhbkggUBtG+uCrNywWrwbiDSA1xaXGxycl2wPhyxOjJOJwu0UKqMvDFu7Jo2",
+	"vWYQJLyfvw7qq2PdMecZCUlhZeOWPrUq9TSKHT0SJy/sIhlH1nXGIk3aLxltYZkTxfWcdv+Nllk5KGUB",
+	"Jy69xOdcYjGAAEiTwcp9RiRxc5XFHI5QfZarFAYPSln8B29XdTavze82duVoBdzM+vOckfALpF80+xCb",
+	"86t1L0u3c/RuDheDZ52JTlmzPyCsrCuvARttapNmN2p6mJw4KOUQlFLPHy/US2o31KQSSykB4Ln8fUqq",
+	"b6BHquytVwpPtIXlSuEJDtYdaz8jcU6Izad6a69cmsVZ0flVCzBvnmrzL/Dm03yhmpmt7I/YjNsjYfcG",
+	"43V/WMtumSj2oJQtby9VH2xq678aItBodwxb0XyhXFymQ9nmB8GSP3sAA5m7nxsVMTWQnpXbAPf1RX19",
+	"hlMRhNrOJhdWDZ7db3Z5UMp1x3CrRJlWondzEpeUAY51GwVn6F3mZnU2TycnDj3CO5t0ftXEQXAjmKAo",
+	"9YiSeI+EArQBnVx3TKSCAECDsrzLDKKAhP8jH/EuM4hk/F1m0GCXR5HzqKWgQD4tHpEx1FK5+Itwv/iz",
+	"7TtMdoRndGVML2b1qZe4YXAmslvlvfny9i7N/1TNDGrTO/raWzo5DnCeyGqPbkk9oG2MEJX4OShg5xA5",
+	"lneHBdymzWwKv0AcZh1LIUIkJs9LVtcMItJVJWWtgJZuamvLBiW32YH/paT6Y4F/AXViD3SPV117vFh9",
+	"BseCbgxzOj31lg4t08IO3Z3iRGH2togFQYJ+PkbHX+NZkYB5BwojHXmpXXhWumN0coLmN+jWcnlvno7n",
+	"9dWxypuFyptfkOjCvd4Zo5MTh9xu/p8/HBwwrly5WDT5fCa1JEmAhK+RIMh+E8C3sus7+n+H70pcqA/H",
+	"rsJLA0XXvmQfmpwArHptlL4dQqadNzUVAVKbhAoAtj6RDGgzm+Ui8A84B1POo/kHbHvZVyKggJIclEZB",
+	"OcJYpjpFEs0XKrf2gB8t5s2p8c5ihARTfrhOsUA4ElYA+cFxm89Ung5qj7dpYQcRBQ4G2zA8Todf0/VZ",
+	"lLSQngmnirGzBqtre6+Rm5J6vgqSaCKugoIXlD49ltbnXeYmnV/FMw/LmB/V7me1+QU6PATUBc7LkuTy",
+	"SHQjr81sghCL4ihjqEHDNrWO82JvcwZ46cNVfe6NNrHMcdbTae3FIg5qyrCwg+wfuvRGmKLa+i1JRJR+",
+	"EvRJ7EIweOeAry7NwrYtjfNDwe79QWmuO1YZWxaHwD012VmTyolEsRNYhbFlDojhIbq+g+KDbesOvBqG",
+	"AKHlRqUerkHrkaqzU/rTIs4ROmAIqBFO5d270GDvJb03Luzi+GJ5Lw+s1IW//Eky8Bv8bzBxd8zjhW+5",
+	"LDE5AciOXVZ97idtYqWytVDZfvYucxOb1XHFeEEqm0OV/RFru2c2qzNvrI12SzQ7XL27AHiZ0XBzN8Xl",
+	"SLF4LEAYSpl7U33+ADoaHdOLa5U3O1wm3dnX7q6xRQoyApDQRirEFEFtAkdgxx+0BZRYgER6JH1qlU7m",
+	"ytsZPgkA7dwml1NHnlX2XgDmF8QHvPPCg3Jplg69rgxO0ewGnVgQseJBaRYEyWKxvJ2hE8M0/wopM3D/",
+	"tciTozWAHzIlWwtAZbMbdO8eHR03jmYuCUc5QIJ+cX0SsKsju5Unz3FeJg1jF0jUJCAOoZMT4vDNkZPD",
+	"6TWwODtbF+uYc+3l4+6YVsiXt5/h4cI9weNAl2bL289sGPqclhtF7uyglE3G07FgazLeGwb5hclzGZDs",
+	"51ermSljFdkIUVJqaySuBGGGo9XMqDb2d8YSMllgb6K8O8Hp7PZY9eEk4AQ2GoDyyfPKyDN6Z7Wy9bqy",
+	"P4L7Jyy/Tupg7LNF5LPTyCiAmIt6HKNr1JECO2SK4/lnlVt7+tRL/nP9ltWYMTonOcDmlFJtN0B7TFKp",
+	"gbZgUgnHeiSUhQx4zorA0odW6PY2Dgegnt6w1CHD4wi4SmHZ+HauO3a8kZMklY6SHkkbfEKXxvH2vsvc",
+	"RHYae4OTa0iI2gxnXOpRn9PhFrmC9Vk48iMj2uMtPEeNmq3Z23RiQZ9aKBd/oduDuOMHpZzFKNTPuofb",
+	"a7ApYJXNbCUzU80s6BO4/eKxoT/dqaw9rT7I0kwJjDOTP5nYzNKNsbOALQ9Kc0wmGIX9n18VAYDdVvZH",
+	"ynsT0NXGMJdxH49U1jfK27smeGpumdPhQJJUHdynQ+Oc8s+vcjafiWICfmfUQOKmm4bjZAgr9ZvKlwI0",
+	"PDcq2hoOSlnLRIFXkGm0cUlcW8GpBbepaDOPgctAAYu9EhixBlNKLWM1+i4z2GBZwSYmsyU0MZESgsHk",
+	"KQURGY4aFy5MCmqyI0x+X+YgHX9N9+7Slz/DrjOWV1goyFFsrcAjNtqpWBs0VUncKuYPB43paIW89mKR",
+	"q0xv7WkPCtL/vvTNn5lRETUIOBuph9sMWi+AAavnXWbQfHKegM0r2d8DE2A9MZ56VGxzORwlKVWJJqDR",
+	"d7HwdUlfuQs8VHGZ5u90x8yGl8JXY4qaThKfdM35L91ph8Md6CPX2T/AAOXo0Caj4SBkAPL/8k9nz7Ve",
+	"+vKsy9v++xQJJInaIqnGaNIfpG75TLcs/UHqjQf7Pzso5bCNBFwR2wp+TmY28erhJqHgxB5IruvXJRAj",
+	"VobL27u4PlPTTyef0uxDOJe5Ee3+RjWTqQ7uIxd4UJrDfwC+9zcA623sAf1iPVT250BVvVYs7y9yWtco",
+	"rdee/7YgwjlMUHzX58f4bGaW6T7IO3Rn86hLJHTSdoP/D+cB0CT/2SMh54KdW9cXLzW3uL15Qkv3u2Ow",
+	"ARaDRksZOlmQer4n16J+7smQOhOOBcl1krzSI9UzUsUJE2FVft2iK2NoQqJzP9PF55JhpEJtgXmFQbDM",
+	"DQKLxZi8yiQAFViDpTeVsWW6wy8jgHz2NjBgrE/c1vxP+tRC9d7b6si4XryNfHrlyXO6tgSiImsIZ8sw",
+	"wiIJpnM/a8VZurNZLk5Af9lhmh9FEKFuEvs21s68Lpovmm8QSu0IRvpwFa7zQSn7x6SS6Ps/XwMuy42i",
+	"LkcfzWrzLw5K2WskmWJy0iiOimCRuP1S0lYX6aMZ0X5M88/o5E+VOzf1mztc9Nxd0acWKvsPy7tzpuXU",
+	"5LdQ86EV8tXFX1GwRS4DlXb6zR3A86gynJwAWBjP9eKKXgQWF5gVZrbiutX5DO6v1HMVFva3iD+djPQw",
+	"cFmHHA3iIOsXtBz/EqcrThTmlxvSxl5o88/0Yl6bf2GplWoFT9a93CJHwgHCPaS41fNPX11mZu6wGqkz",
+	"gsotMgcvuDOccZxxQMN4gsSURFj2ye4zjjNu9N/oY4bdNvb8hnyV2HglIWDq7HGmuU9mHSeZ0PtVUPbJ",
+	"XxIlovad6yOBH2QwNaNnFxvG5XAYBlyC/k9KIhEJB9jHbWDrhGfcnM2+6GOdMb+HVDoaVZL9MCETVMwo",
+	"CKtVImliuYuhD5jh8ZVSFTWdkn1yKh0IkFQKrMkD3OLMGvxTkoRkn/y7NstFrQ3fptpE5zT2WR1s6qbC",
+	"OjYnSgeX6e4Wwg8s2srVFJjcU/0plUTlK9DYlm9quhWIwkXRUJvPVPZ/Km9ntBeLyL0BmzbZqL63Y92y",
+	"P5Lwu8xgRImCyTwlAccRD6hKCqldd4z3+GyG5n+hw+DOIXJwB6VsbzoZ8ycV0LORpL8vnk4ykjZEMyWw",
+	"C6wv0qFlkywCEigs47fl0oa+Dvebjv69vPcQlRDa/Q123HuVCAiFUnl7QmocQQKZYHwQSE52k67N6C+e",
+	"lrdfgQwCetxxtGki2UdLuf7qLd192sBvSkqEJFV/L4nEf2R6wEj8R7819C7TUYA+aGGSX2PkMOdXK3vr",
+	"IHQy1Gpx3NhpOqZcU8LMi9DqmpFETkH85nsJhb1IiiBzZOn9kGFnzEoj2+5gK0HtEp1flc6m1b54Mvyf",
+	"7BZZDJep6jWJEjKo5eKSPpo7KGU/Z54WBufUcI+/DqfUi9aRBGyRVKKE/fB9b+NAgzMV14GwqXFeIWpf",
+	"a4ok4pGwIoNLh+yT/5YmyX65xUBrHEyyeEPrXViu/GbEUnvZrCtrXRs8KkchF7Gf72/IXEpjPkIdHlfQ",
+	"7T0XaG93e845vG5Xl8uruHs7PZ7PA12kw9vlDTl6L/Q65BZZOImyz+Wo+2uR+amUfbKns+6l3CI3XhHZ",
+	"J7sa2zGZFQDoY4euRYamKX+EhFTZ5/LAdvhDkfDVPpW5kgrXwfzC2Jz6jWw424YHbYqBPOhnDqsuh8vb",
+	"6nC2OpyXnS6fw+FzOP5dbpEbrozs8zaAIB0LQxc/krA8cOU0MTiqexgHDFTS43Ce8CilYwq/gSRYe5hE",
+	"A3njQQKDunGQwFPn9NYkXnS0RWrZaTSx1rh2sSMrOnV9fwWulkBpGXAkm0th0DJGug4hZfUqE3Zn4ik7",
+	"NuMIBUnOdL4xuTxUsSCDDCaoO4+rmQVwHmCKNrAdTL00ezC/tcxRxXFQtK7fEnVCzN0ENKzb43RwXptn",
+	"fpO3hujwr+XtMUT8lrpnKMt9dKY3rGmieXxmxXwCClpBMYSjoRXAlBmAmKwUqw+XPg5yPw97YWL3o5B7",
+	"Ay9hOCWG8a3aZ6FvAwOKXoYcFZhHW/SkPAGWbHBkbCBCuNGjGTq/auNAfhLa03zyp0+L0omgotbjjjo/",
+	"Mls61G7RofckPIcTFotgIBAE+uBC+hBKx4KpI6jDKSLrI5zt/kfgb1ii54RLjMVVfwj0+3XrO9ptynbZ",
+	"XR9m2Tii6YF1MlKFaNdu/u9BqlDH3pxW0Y1hHM8SAASPTaQSYK4TpI06cwhQGPYW1fhgWSqOcxuJZR35",
+	"4HTgW7bQT4TgEyE4JiHgmP4TJfhECf5/pQSIUU9OCY7nqNCcKNR5UYCjHHOiMD0oDvNoKNb7Oouq7PLe",
+	"Pp1Y4G+ZFzEa8U6bRrDIsGfYDN9qM5uix7dpNuX2ByEyoqnjRe403B70uXU6Og5RWvOjgoUGvaDsSds5",
+	"tl2XrW08UoX1dginaoIefUTQy5tDPzuNTiSi3wkGENqQwPozdGxa6BRjD4+MxGz8fRwayRf0j0Yj8Rb6",
+	"rfjAGjxmG6xjSzK9FsnkXTbsFuxEV6iTdATbA95ej+LucnU6OxztIS/xBN0BV69TcRz1Xm6RQ4SNHAlH",
+	"QYPlQv1hlM1DJik1HFVUAmczGQb66+5wmTqvRDIcB5TnZ104PR4bbVjbVSVVE2ldt3PxZPhqOKY0Wd4p",
+	"HDTLJ/Sw2NlTJP32e8yooeM9qGGCxIKMtRHPUV1Aii3t66ylfS2stxSGHzYwcc2jYGz77vgwdFUkIQjG",
+	"T4zSyRilupA2uyU6vB9mifVDn0w8ZrttOlKbR+9wlsiMIj7cIImGsbpAKe41mxuik8+5Dz3jwyT0WPl4",
+	"Rqx/s0KhfyPtEcFhgbZ22TQ7U1lcPcpsZPX0/Q05kCSKamMjcTgsG4kRZ//9MaPhD8fGtcHwJ4tnZ+Hr",
+	"JwxC/2Sx+cgWm9ozaXPPW5pZYWp9Eo/hg1gZeQ1uwizLBnNmyxyU5srbS5XMUPXWHj4HH0wjwwKdBKmC",
+	"5nbgStd4uQC6YM0lPO1gOedDMgSDQ4JjjpEFhOMSCa02GFWDoyDG4W5Dhm+c6RWH/hIfBwWdY7ebIyHO",
+	"LJOU+nk82H9ymzWyksJ2126Xud3mwT0p3vjIeOH4F6guQ8rAwEC93DHw22ULRMT1KKEmXt8Ws3cIwsQ/",
+	"FDLH+wNErC9FAv6uXmfIEWgnrqBH6Qx1EHfA2+tUuoIdIRfxBNqV99/m00KU9bvxXmx/OHZNiYTrWXSW",
+	"yECyYMlCMI3EOza0wOW0dh3jvlEvaXweTxpfS+GUZJ7V0weGEJH+iR7W873smBxND+35XsG9uLl7ZG7U",
+	"pC7VmTc0c5fu5jlfLCQWAP835pxONx5VChDrohfvaT9DXhkWymkqvSColi30XWawkpnB2DxGWtCRkXmN",
+	"Qe4yII90/DV4cTO/a+Q8Pzpffd4C0Ql8xMz8UAijJiqmVLrX7AAUDIeplloOG44HRxwxnKDKONFInOVg",
+	"O9hsJWzvaro1U15xBQTcAQZKRgVgh23SWjUODk7s25nq4q8WVxQj11V/IJ1MxZNNpmO+PMkqmSs1hAWM",
+	"5MF3GgMLvQ7mDp6hSyuQYaXJeKj8EoeLKtcxaaHL4Tg8heEpKA1rr7HAPgkBB0eJbGIf4OmnqiSaUI0f",
+	"zYl9MI33xh9NyT6ny9HCz4IfB/E6HLDAY7MNss+WaRBZhPZeV8Ad9BBvqF3p6O0MdDXR0nkUZ21LucVy",
+	"lmbnr6XhBh6eHg+h09liWDIbl+PyOTp8bse/y7Bo8Zz6ZPmTjPhfIiPWXYITU8ZmgTeHuk+IpBEMLBgM",
+	"gXTsLnP1Y3tnYmyINRVcLHhmmJ1NzIlgtMlhiBL4nWfvlosTkPywlKErY9x9cJ4lnGMEGIcu7z/S7z/8",
+	"WL4WHDCWLHgouRRBJH113t7EJID+mNalQ5DE6Vtr/pYm6QYbTfP8ZrY4uLMBB/f7j0J3JhoziOtp2h8O",
+	"mf8nbfqJtOnHS8VlK3w5PszK64c+EWIVAw0N9Hp8jHqjjtYO8BNPVJsM2DT7uPpwiQuAkxPoMw2BMeu3",
+	"uDRiokR2MiFZxsasNs+VcCzIlmdbotkNbX6B6casIM6P5IbMVndMfFh7WJpixEaZ4QRY8ThJf0+FHSWH",
+	"6Zlga4/BjhJVWNPx+bQPo41hc/6E/v4noz88BMfXtVi5o20VK5CwZvFXI9TXCtPmodYsfBtjh0HmbRJe",
+	"DdoZFght5O8F9UltKPKoqLI5YWAunxdL4Qt6GSUQgBzf4MQEKRK33mUGrZyc2WkMxMfoyiQJhBNhElPf",
+	"ZQZZsuR3mUHMEP4uMxgi4D9mBCNCAAnLhsLzBew9xJ/lvXEIOQwSVQlHMCsZVwIw1RJ6kYnaAKn66Gf9",
+	"77sYkQhOzEObPN8Zf8+znpaAsYEsDflCZWKL5qfL22OoZDgozQUgtDcRD8fUM9A1ANTcGKQx5q7oL14w",
+	"wDw7cg/eZW7iK5hx3Qh+Fn0Oc6/ObeLcwV+RfQp0bTdP81t0Iw+BOyzTC+4Kz2TKptZci3WuTwnHLuBJ",
+	"PIIA1VUmOC2fqEbFlXCE6eS4vrIBtvS3Y/TpTYmXljjH09sFm8yDjXGiQXE4bXOU5iEtlZmShJ9oFhts",
+	"HlnB461uYN78pGNb3nbNFtToSneSAT6yjuwRpMg4tirMrIzhdbR8XL2YERFfqxQTdSdHcCHWTYVfAFPZ",
+	"5wX3sHbHIUogh8/hZjotoYDA9zfMs1NbheJsbyB44Qvrt/J5XRUKo5qK7LQiYiPxwA+n6sSHPcbS0V6S",
+	"5Ev0AveBqBcX/6MffrARvSF3AFbHHPdkh6GgE1zzfDU+9rxDfOhmJwcSbhwGO3C7v4qoUfa5mzsAmpeW",
+	"Tezs5w3QPC/+Ptt7LngBi4+YWWeaayRVZARgvwId5z2fO7vaz/U6znV87nAGOzzuL3oD7U5nu9LlcLk7",
+	"us51uNywXx/KEZHfjwYM+bE1jSe9hNFwKhWOXfULtXIarJL8HafPtl5nng/EC9tOYKBF9p4c26jxpHKV",
+	"+EkyGU/WLlPMAm2zOpfjA/G7NePWJu/AjRUz/CKJFBhaIx2nwNOaj47D1TbmOTYT4ZmZeA8xL34Uhu/w",
+	"5BDHYZtEB5XmhjdyUn6l+OA4vZ6Qfp+IxTtBv2JytpNbCI2UumiP4Sl0rR/shWgM4p4jLbJdZtpjGRXF",
+	"I4eRi6ikp5PPMUPet1+ck9xud5dk1pCyW1MoGY/WrMisXQacQStQGPnEs7mDszGdS08yITV+CtP5xE1+",
+	"IG6ylkof7vAUtdhGzv+ZBfIC8XDMz9fnuK7Ix7OuWnXyeDG7vjAQrP4jDbymrcG8pgMtTb9w1XxhXeBD",
+	"PvHWfGLd84FjeIZZTGF99bw6n7ETFaWzKsydrF6czSI+HDt4mPSBUP3AXsnHuT6nMjSq4w9hYsRgO3v2",
+	"pZk7MMe80xt1mapr8h6yRChNamHk9NubkHrPpsJFrqZexeQEYlNRF4ApPXlK0eaZzpG74aZf9hAC6Zun",
+	"J69kIDJBe1Dg9hoh5zgbkUcrjI6LS0SdlqnMg1hOMaf7ziaklfklc1CaTfUpkLwzriY+YxnMQCiUGLUC",
+	"l2cjCyer2zHXHePhoCxpGS+G8XASnpjtnAelLCsuY/hwMJyP4Zqsct+yWIjF+s5lfCdkV0KFomGPwSmj",
+	"ft2snITp2CvrG5U3T1lNgAV80gAm/Bc8kIUYQisD5psdCIhdm9bvL0MWxeIWK9P4c/XhEM84brSxyn4I",
+	"xxSYbkhRuYx1daAcg6GgzdLx++W9cai5EyLkDITCHeF8zTnVoxhVI+cbc6wTCjvUl16wr7rQTCEG9R/s",
+	"CTSPleeUqjcejxAlZsv/rD/Rslv684I+taBlWRKJqQKwIFPrAI6nN6XvvmMpamGmmMBofRSK9bArURPW",
+	"2RHwKJ1BR1drQHF3tHqIq7e1K+QNtooI11hJH1GCJGktpe4G1rtzfU1iV9U+2efyeo/D02H2ZCu4wJy7",
+	"dueOGGLADZbFh5g+UIxEEDJDH1620nY5PEfxiZZx5bf47/cqqXDAKEhSJ/8u7IKpAaWEpd3y2zGRV2rk",
+	"cCxeBQvyNo3+VEF70+khvZ2dLuJtd3pcXZ2eIFFcIUK6OjpcwXZHwOENujs7vV0eZyjo8rpcHe1Oj9fp",
+	"8bSHPO0K8bg7fpOLeR27cOwvgZAFSCRuDzAovCi9D7xY6WIRYDCGALH/ioUemwepKyz9QYIg7Ll4RqRF",
+	"EmDLywse8bVRz06HQwx7BkYwHLCCnp1CTDNLQGpENR8vuNiWo27CWcI8nE6n0+Vyudxut9vj8Xi8Xq+3",
+	"vb29vaOjo+NIznLgtCM6xSKWsGaXw3XCLVMCAZJQbfysuIeSUEbIdttcgkL/uJjlxDtjSUenyHRjOvmt",
+	"VzQ/o08Om9WCaql31qrBBIar91LSBsBM6E8SKI9bD+jGeo1muQwAqwQ2Sqap4A0nFiA3OCsciIa0RmWu",
+	"WzSxwMhcZypf8H/157+c/fqr8/5vLl+UW6zAk8t9REok49fCQRKUvrl8UQrGSUqKxVUpqqiBPkntI4wT",
+	"NWrpptKhUDgAdgEryae4KJPTbWQxbWcsnKEGfhOIKdw7NjTDCH5yPUBIsAGSAjMsstoiY2w7urM2I6vJ",
+	"JRuZRNnIhqI9FCaRYMpOzy6WMT1cyX6jobe6+t9GsW82slVrLRpOsf2oQ69HlRy19X5x1EyGdUuCwnxq",
+	"psAintARnasHbeLaxZqDtuv/UG4nNRIs3M+uE4d0+RPJ+NVkA+UypEReMcp2VQ2ZHHAne9Op/iaXoll9",
+	"Vdt9+lCWmeOVvgVRPTuNpZXEO0XzT6Hu9IspfvjQO5DXy4EMpWwjXCelRVCPI0nSqQZqZMyTDr/Snw3a",
+	"7sMHynpxZDlhNGe538e32R9KRyJ1l7mhdqmtRcv5YRZrVzm1ngAONEbIicqd41m4rKqYzR39sRQmYDTR",
+	"jMYwmlVZ7xglMrnbEPigXPjLn1DavxSPKDHFSAQ1e7OxtDL4CRkSu9OFOgtWCAI8Wm2r6pn+rJZmXyyT",
+	"WVMl81CFgwma3xTvLQLY2jBIf2Wu8ZOw+A8jQ9UUC2+SVshZw2II9V+5H4sr4CFOpaPXHfSG2kmn4gi4",
+	"ej3B9lAncSjOgJt4ezuCXSGn4g54SUdvV9AZciveQAfp6nUG3SHZ5jCQ64lwkqTs1OROw8LRVAYQ7sZR",
+	"kWEfQsl/ipJEXfn/3+bJ8aEYTLigyM0dP9WUuTBb1sT1Mfg5G3JTV6H5mBSnMSlj86jsxzva+LpoTDAc",
+	"ieEJV9rfzVUKT7VbQwyV19ATIFboyvrkOZYcQj9S8Gll1gFOWHh4Ect1NIXWBe6EgRcD5s0rGerFh3Rk",
+	"FzXglbdvaRZsKpiNSd9dodnnbBbcP5bNks8Yy6M+KNLFn1G6hczzRt6z8t4wsG2jK1CTx8hb2DA5fG8q",
+	"+s0yohITK81Si2v8Q8ivyOvI5XD14A29sMwsKABLy8jg5WR1AYvecUeS2qJwJjFtqFOc09aWzAamEwzM",
+	"sKaqcBbr3NU5IRlKf6u6KlS3qq0BgInWsGYVlCybWKg5DM3Le9bspVnrs7df4tsotBZsVQ3swB+JKmSg",
+	"vGS4eBxuhhB6hVKIDSVK/wGTTdb51VjD15ulj+txYxpRGnr4ACknTWt1A5oxyCm/Qoh1cJmVDNSHprnp",
+	"g9Ls2YuX2767dP7c72sm+9m7zOCFy1/iG4GyfsYYXVBU4ytRQ/2Z3NIgtfMKcc1dLNy2+j30cmjuIWAo",
+	"7PwNHKWdMwJT1xs//TBlG4X9b5rvUZr73zLfFEP+jftbQxSghunSNMd9DfgdcOXiKvDjRu5IWywPVT6T",
+	"ceAOoWr5oDkFtuchyNQJcVCoF7Sgx+fxHvAzpwnuWEb3tYzgpW++roErAqM1SK6970H4a7zXVsvE0lXZ",
+	"lVs268Gb1UWPWtjRYo6hMiXXSSDN4haT5BoBhskn8QxAEnBUvKVfVL6emtabQ8GESpO8tYcB5pBi+Edp",
+	"848GUq2Zpqvd43KaVhojOW3rj2G1rzWajqjhRCRMkpbdxu10NEtW63K8R7La0zf3nIaM0SIfliO2ueqv",
+	"di+aI9oPGawu8ruNLkr/TZOi1rk/iTA4XNJIp1gdkHSKKyFYMRB2N1JHSBoY7seTJrJR8YlovKDZTahS",
+	"tfG2emsVyxRgLV16lyfQaXxlV2Dzj0T9LkWSX+O0jopZE+ZlzzeKiz0+z3h8p7+EoqokCeP+h+P690pr",
+	"6GzrF47Wris3nC3tnoF/+u/PPYIPg3WG6jgisUDscZ1km/A/bCOtgaLKdRaP5EdHSEg+5Gipt46ZhEFo",
+	"6edRWG6OhixdVu2umNKYwPpyawuTz0QGuAbM7zKDAtpnjE/dUt7XfbgZhj1t0HgHThFF4xVF/PDbo4k4",
+	"Z2Pe6Fo2gyUJ5FjK8JM/xPr80bRCIspESAiImmFlrvhKkeQ1e0x3nlwjkXiCceTYqiZbpa+tLRIPKJG+",
+	"eEr1dTmAsjfgmYvJeDDNGAi7HiDfpZIIt/L0lmcSSRIMB9REROk/c73/P5k/Np9yk/CPuW069BoraTQk",
+	"nUvZzIeTENvPECg237wu6sUF+2944eGBKwP/bwDhQbizj58AAA==",
 }
 
 // GetSwagger returns the content of the embedded swagger specification file
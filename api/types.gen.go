@@ -3,6 +3,14 @@
 // Code generated by github.com/oapi-codegen/oapi-codegen/v2 version v2.5.0 DO NOT EDIT.
 package api
 
+import (
+	"time"
+)
+
+const (
+	AdminTokenScopes = "AdminToken.Scopes"
+)
+
 // Defines values for PaymentRequestCurrency.
 const (
 	APT  PaymentRequestCurrency = "APT"
@@ -11,6 +19,30 @@ const (
 	USDC PaymentRequestCurrency = "USDC"
 )
 
+// Defines values for WebhookRequestEvents.
+const (
+	PaymentConfirmed WebhookRequestEvents = "payment.confirmed"
+	PaymentFailed    WebhookRequestEvents = "payment.failed"
+	PaymentSubmitted WebhookRequestEvents = "payment.submitted"
+)
+
+// Defines values for ListWebhookDeliveriesParamsStatus.
+const (
+	Dead      ListWebhookDeliveriesParamsStatus = "dead"
+	Delivered ListWebhookDeliveriesParamsStatus = "delivered"
+	Pending   ListWebhookDeliveriesParamsStatus = "pending"
+)
+
+// Defines values for ListPaymentsParamsStatus.
+const (
+	Confirmed           ListPaymentsParamsStatus = "confirmed"
+	Failed              ListPaymentsParamsStatus = "failed"
+	NeedsReconciliation ListPaymentsParamsStatus = "needs_reconciliation"
+	Received            ListPaymentsParamsStatus = "received"
+	Submitted           ListPaymentsParamsStatus = "submitted"
+	Submitting          ListPaymentsParamsStatus = "submitting"
+)
+
 // ApiResponse defines model for ApiResponse.
 type ApiResponse struct {
 	// Code 业务状态码
@@ -39,11 +71,140 @@ type PaymentRequest struct {
 
 	// PayerAddr 付款地址 hex格式
 	PayerAddr string `json:"payer_addr"`
+
+	// PrecommitId 由 POST /api/payments/precommit 返回的预提交 ID，完成预提交的支付时填写（创建预提交时忽略）
+	PrecommitId *string `json:"precommit_id,omitempty"`
 }
 
 // PaymentRequestCurrency 货币种类
 type PaymentRequestCurrency string
 
+// WebhookRequest defines model for WebhookRequest.
+type WebhookRequest struct {
+	// ApiKey 只投递携带该 Api-Key 请求头创建的支付；服务器只保存其指纹
+	ApiKey *string `json:"api_key,omitempty"`
+
+	// Events 订阅的事件，留空表示全部
+	Events *[]WebhookRequestEvents `json:"events,omitempty"`
+
+	// PayeeAddr 只投递该收款地址的支付
+	PayeeAddr *string `json:"payee_addr,omitempty"`
+
+	// Url 接收事件的 http(s) 地址
+	Url string `json:"url"`
+}
+
+// WebhookRequestEvents defines model for WebhookRequest.Events.
+type WebhookRequestEvents string
+
+// ListPaymastersParams defines parameters for ListPaymasters.
+type ListPaymastersParams struct {
+	// Network 只返回该网络的密钥
+	Network *string `form:"network,omitempty" json:"network,omitempty"`
+}
+
+// DrainPaymasterParams defines parameters for DrainPaymaster.
+type DrainPaymasterParams struct {
+	// Network 密钥所在网络
+	Network string `form:"network" json:"network"`
+}
+
+// ResumePaymasterParams defines parameters for ResumePaymaster.
+type ResumePaymasterParams struct {
+	// Network 密钥所在网络
+	Network string `form:"network" json:"network"`
+}
+
+// CancelTransactionParams defines parameters for CancelTransaction.
+type CancelTransactionParams struct {
+	// Network 交易所在网络
+	Network string `form:"network" json:"network"`
+}
+
+// ListWebhookDeliveriesParams defines parameters for ListWebhookDeliveries.
+type ListWebhookDeliveriesParams struct {
+	// SubscriptionId 只返回该订阅的投递
+	SubscriptionId *string `form:"subscription_id,omitempty" json:"subscription_id,omitempty"`
+
+	// PaymentId 只返回该支付的投递
+	PaymentId *string `form:"payment_id,omitempty" json:"payment_id,omitempty"`
+
+	// Status 投递状态
+	Status *ListWebhookDeliveriesParamsStatus `form:"status,omitempty" json:"status,omitempty"`
+
+	// Cursor 上一页返回的 next_cursor
+	Cursor *string `form:"cursor,omitempty" json:"cursor,omitempty"`
+
+	// Limit 每页数量，默认 50，最大 200
+	Limit *int `form:"limit,omitempty" json:"limit,omitempty"`
+}
+
+// ListWebhookDeliveriesParamsStatus defines parameters for ListWebhookDeliveries.
+type ListWebhookDeliveriesParamsStatus string
+
+// ListChainEventsParams defines parameters for ListChainEvents.
+type ListChainEventsParams struct {
+	// Network 目标网络
+	Network string `form:"network" json:"network"`
+
+	// Type 合约事件名称，例如 PaymentCompleted
+	Type *string `form:"type,omitempty" json:"type,omitempty"`
+
+	// Account 事件涉及的地址（account 或 recipient）
+	Account *string `form:"account,omitempty" json:"account,omitempty"`
+
+	// TransactionHash 交易哈希
+	TransactionHash *string `form:"transaction_hash,omitempty" json:"transaction_hash,omitempty"`
+
+	// Cursor 上一页返回的 next_cursor
+	Cursor *string `form:"cursor,omitempty" json:"cursor,omitempty"`
+
+	// Limit 每页条数
+	Limit *int `form:"limit,omitempty" json:"limit,omitempty"`
+}
+
+// ListPaymentsParams defines parameters for ListPayments.
+type ListPaymentsParams struct {
+	// Payee 收款地址
+	Payee *string `form:"payee,omitempty" json:"payee,omitempty"`
+
+	// Payer 付款地址
+	Payer *string `form:"payer,omitempty" json:"payer,omitempty"`
+
+	// Network 目标网络
+	Network *string `form:"network,omitempty" json:"network,omitempty"`
+
+	// Status 支付状态
+	Status *ListPaymentsParamsStatus `form:"status,omitempty" json:"status,omitempty"`
+
+	// From 创建时间下限（包含），RFC 3339 格式
+	From *time.Time `form:"from,omitempty" json:"from,omitempty"`
+
+	// To 创建时间上限（不包含），RFC 3339 格式
+	To *time.Time `form:"to,omitempty" json:"to,omitempty"`
+
+	// Cursor 上一页返回的 next_cursor
+	Cursor *string `form:"cursor,omitempty" json:"cursor,omitempty"`
+
+	// Limit 每页条数
+	Limit *int `form:"limit,omitempty" json:"limit,omitempty"`
+}
+
+// ListPaymentsParamsStatus defines parameters for ListPayments.
+type ListPaymentsParamsStatus string
+
+// CreatePaymentParams defines parameters for CreatePayment.
+type CreatePaymentParams struct {
+	// Async 为 true 时异步提交，立即返回 202 和支付记录 ID
+	Async *bool `form:"async,omitempty" json:"async,omitempty"`
+
+	// IdempotencyKey 客户端生成的唯一键（如 UUID），用于安全重试
+	IdempotencyKey *string `json:"Idempotency-Key,omitempty"`
+
+	// ApiKey 商户 API 密钥，用于把支付事件投递给按该密钥订阅的 webhook
+	ApiKey *string `json:"Api-Key,omitempty"`
+}
+
 // GetTransactionStatusParams defines parameters for GetTransactionStatus.
 type GetTransactionStatusParams struct {
 	// Network 目标网络
@@ -56,5 +217,11 @@ type GetUserLimitsParams struct {
 	Network *string `form:"network,omitempty" json:"network,omitempty"`
 }
 
+// CreateWebhookJSONRequestBody defines body for CreateWebhook for application/json ContentType.
+type CreateWebhookJSONRequestBody = WebhookRequest
+
 // CreatePaymentJSONRequestBody defines body for CreatePayment for application/json ContentType.
 type CreatePaymentJSONRequestBody = PaymentRequest
+
+// CreatePrecommitJSONRequestBody defines body for CreatePrecommit for application/json ContentType.
+type CreatePrecommitJSONRequestBody = PaymentRequest
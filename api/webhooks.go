@@ -0,0 +1,517 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"tinypay-server/config"
+	"tinypay-server/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Headers of a webhook delivery
+const (
+	webhookEventHeader     = "TinyPay-Event"
+	webhookDeliveryHeader  = "TinyPay-Delivery"
+	webhookTimestampHeader = "TinyPay-Timestamp"
+	webhookSignatureHeader = "TinyPay-Signature"
+)
+
+const (
+	// webhookPollInterval is how often the dispatcher looks for retries that came due
+	webhookPollInterval = time.Second
+	// webhookBatchSize bounds the deliveries attempted at the same time
+	webhookBatchSize = 16
+	// webhookLeaseMargin is added to the delivery timeout to lease a claimed delivery
+	webhookLeaseMargin = 30 * time.Second
+	// webhookResponseLimit bounds how much of a merchant's answer is read
+	webhookResponseLimit = 64 << 10
+)
+
+// webhookEvent is the JSON body of a webhook delivery
+type webhookEvent struct {
+	Event     string         `json:"event"`
+	CreatedAt time.Time      `json:"created_at"`
+	Payment   *store.Payment `json:"payment"`
+}
+
+// webhookDispatcher posts queued webhook deliveries to merchants. Deliveries live in the ledger:
+// a failed attempt is retried with exponential backoff, and a delivery whose attempts ran out
+// stays in the ledger as a dead letter until an admin redelivers it.
+type webhookDispatcher struct {
+	ledger         *store.Store
+	maxAttempts    int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	timeout        time.Duration
+	pollInterval   time.Duration
+	httpClient     *http.Client
+	now            func() time.Time
+	wake           chan struct{}
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// newWebhookDispatcher creates a dispatcher of the ledger's delivery queue; start begins delivering
+func newWebhookDispatcher(ledger *store.Store, cfg *config.Config) *webhookDispatcher {
+	maxAttempts := config.DefaultWebhookMaxAttempts
+	initialBackoff, maxBackoff := config.DefaultWebhookInitialBackoff, config.DefaultWebhookMaxBackoff
+	timeout := config.DefaultWebhookTimeout
+	if cfg != nil {
+		if cfg.WebhookMaxAttempts > 0 {
+			maxAttempts = cfg.WebhookMaxAttempts
+		}
+		if cfg.WebhookInitialBackoff > 0 {
+			initialBackoff = cfg.WebhookInitialBackoff
+		}
+		if cfg.WebhookMaxBackoff > 0 {
+			maxBackoff = cfg.WebhookMaxBackoff
+		}
+		if cfg.WebhookTimeout > 0 {
+			timeout = cfg.WebhookTimeout
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &webhookDispatcher{
+		ledger:         ledger,
+		maxAttempts:    maxAttempts,
+		initialBackoff: initialBackoff,
+		maxBackoff:     maxBackoff,
+		timeout:        timeout,
+		pollInterval:   webhookPollInterval,
+		httpClient:     &http.Client{Timeout: timeout},
+		now:            time.Now,
+		wake:           make(chan struct{}, 1),
+		ctx:            ctx,
+		cancel:         cancel,
+	}
+}
+
+// start delivers what is due right away and then whenever deliveries are queued or come due
+func (d *webhookDispatcher) start() {
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		ticker := time.NewTicker(d.pollInterval)
+		defer ticker.Stop()
+		for {
+			d.dispatch(d.ctx)
+			select {
+			case <-d.ctx.Done():
+				return
+			case <-ticker.C:
+			case <-d.wake:
+			}
+		}
+	}()
+}
+
+// stop ends delivering and waits for the attempts in progress
+func (d *webhookDispatcher) stop() {
+	d.cancel()
+	d.wg.Wait()
+}
+
+// notify wakes the dispatcher after a delivery was queued
+func (d *webhookDispatcher) notify() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// dispatch attempts every delivery that is due, a batch at a time
+func (d *webhookDispatcher) dispatch(ctx context.Context) {
+	for ctx.Err() == nil {
+		deliveries, err := d.ledger.ClaimWebhookDeliveries(d.now(), d.timeout+webhookLeaseMargin, webhookBatchSize)
+		if err != nil {
+			log.Printf("Failed to claim webhook deliveries: %v", err)
+			return
+		}
+		if len(deliveries) == 0 {
+			return
+		}
+
+		var batch sync.WaitGroup
+		for _, delivery := range deliveries {
+			batch.Add(1)
+			go func(delivery *store.WebhookDelivery) {
+				defer batch.Done()
+				d.deliver(ctx, delivery)
+			}(delivery)
+		}
+		batch.Wait()
+	}
+}
+
+// deliver posts one claimed delivery and records the attempt
+func (d *webhookDispatcher) deliver(ctx context.Context, delivery *store.WebhookDelivery) {
+	subscription, err := d.ledger.GetWebhookSubscription(delivery.SubscriptionID)
+	if errors.Is(err, store.ErrNotFound) {
+		attempt := store.DeliveryAttempt{At: d.now().UTC(), Error: "subscription deleted"}
+		if _, err := d.ledger.RecordWebhookAttempt(delivery.ID, attempt, store.DeliveryDead, time.Time{}); err != nil {
+			log.Printf("Failed to record webhook delivery %s: %v", delivery.ID, err)
+		}
+		return
+	}
+	if err != nil {
+		// The lease brings the delivery back
+		log.Printf("Failed to load webhook subscription %s: %v", delivery.SubscriptionID, err)
+		return
+	}
+
+	start := d.now()
+	statusCode, err := d.post(ctx, subscription, delivery, start)
+	if ctx.Err() != nil {
+		// Shutting down; the lease brings the delivery back after a restart
+		return
+	}
+	attempt := store.DeliveryAttempt{At: start.UTC(), StatusCode: statusCode, DurationMs: d.now().Sub(start).Milliseconds()}
+	if err == nil && (statusCode < 200 || statusCode >= 300) {
+		err = fmt.Errorf("merchant answered %d", statusCode)
+	}
+
+	status, retryAt := store.DeliveryDelivered, time.Time{}
+	if err != nil {
+		attempt.Error = err.Error()
+		tries := delivery.Tries + 1
+		if tries >= d.maxAttempts {
+			status = store.DeliveryDead
+			log.Printf("Webhook delivery %s of %s to %s failed %d times, moved to dead letters: %v", delivery.ID, delivery.Event, subscription.URL, tries, err)
+		} else {
+			status, retryAt = store.DeliveryPending, d.now().Add(d.backoff(tries))
+			log.Printf("Webhook delivery %s of %s to %s failed, retrying at %s: %v", delivery.ID, delivery.Event, subscription.URL, retryAt.UTC().Format(time.RFC3339), err)
+		}
+	}
+	if _, err := d.ledger.RecordWebhookAttempt(delivery.ID, attempt, status, retryAt); err != nil {
+		log.Printf("Failed to record webhook delivery %s: %v", delivery.ID, err)
+	}
+}
+
+// post sends a delivery signed with its subscription's secret and returns the HTTP status
+func (d *webhookDispatcher) post(ctx context.Context, subscription *store.WebhookSubscription, delivery *store.WebhookDelivery, at time.Time) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, subscription.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := at.Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(webhookEventHeader, delivery.Event)
+	req.Header.Set(webhookDeliveryHeader, delivery.ID)
+	req.Header.Set(webhookTimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(webhookSignatureHeader, "v1="+webhookSignature(subscription.Secret, timestamp, delivery.Payload))
+
+	resp, err := d.httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, webhookResponseLimit))
+	return resp.StatusCode, nil
+}
+
+// backoff returns the wait before retry number tries: the initial backoff, doubled for every
+// retry after the first, up to the maximum
+func (d *webhookDispatcher) backoff(tries int) time.Duration {
+	wait := d.initialBackoff
+	for i := 1; i < tries && wait < d.maxBackoff; i++ {
+		wait *= 2
+	}
+	return min(wait, d.maxBackoff)
+}
+
+// webhookSignature signs a delivery: hex HMAC-SHA256 of "<timestamp>.<body>" keyed with the secret
+func webhookSignature(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// newWebhookSecret returns a random signing secret for a new subscription
+func newWebhookSecret() string {
+	var secret [24]byte
+	if _, err := rand.Read(secret[:]); err != nil {
+		panic("failed to read random bytes: " + err.Error())
+	}
+	return "whsec_" + hex.EncodeToString(secret[:])
+}
+
+// apiKeyID returns the fingerprint stored in place of a merchant API key; empty without a key
+func apiKeyID(key *string) string {
+	if key == nil || strings.TrimSpace(*key) == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(strings.TrimSpace(*key)))
+	return hex.EncodeToString(sum[:8])
+}
+
+// emitPaymentEvent queues event for every webhook subscription that matches the payment
+func (s *APIServer) emitPaymentEvent(event string, payment *store.Payment) {
+	subscriptions, err := s.ledger.ListWebhookSubscriptions()
+	if err != nil {
+		log.Printf("Failed to load webhook subscriptions for %s of payment %s: %v", event, payment.ID, err)
+		return
+	}
+
+	var payload []byte
+	queued := false
+	for _, subscription := range subscriptions {
+		if !subscription.Matches(event, payment) {
+			continue
+		}
+		if payload == nil {
+			if payload, err = json.Marshal(webhookEvent{Event: event, CreatedAt: time.Now().UTC(), Payment: payment}); err != nil {
+				log.Printf("Failed to encode %s of payment %s: %v", event, payment.ID, err)
+				return
+			}
+		}
+		delivery, created, err := s.ledger.EnqueueWebhookDelivery(subscription, event, payment, payload)
+		if err != nil {
+			log.Printf("Failed to queue %s of payment %s for webhook %s: %v", event, payment.ID, subscription.ID, err)
+			continue
+		}
+		if created {
+			log.Printf("Queued webhook delivery %s: %s of payment %s to %s", delivery.ID, event, payment.ID, subscription.URL)
+			queued = true
+		}
+	}
+	if queued {
+		s.webhooks.notify()
+	}
+}
+
+// webhookSubscribed reports whether a subscription waits for the outcome of a payment
+func (s *APIServer) webhookSubscribed(payment *store.Payment) bool {
+	subscriptions, err := s.ledger.ListWebhookSubscriptions()
+	if err != nil {
+		log.Printf("Failed to load webhook subscriptions for payment %s: %v", payment.ID, err)
+		return false
+	}
+	for _, subscription := range subscriptions {
+		if subscription.Matches(store.EventPaymentConfirmed, payment) || subscription.Matches(store.EventPaymentFailed, payment) {
+			return true
+		}
+	}
+	return false
+}
+
+// webhookData describes a subscription; the secret is only shown when it is created
+func webhookData(subscription *store.WebhookSubscription, withSecret bool) map[string]interface{} {
+	data := map[string]interface{}{
+		"id":         subscription.ID,
+		"url":        subscription.URL,
+		"created_at": subscription.CreatedAt,
+	}
+	if withSecret {
+		data["secret"] = subscription.Secret
+	}
+	if subscription.PayeeAddr != "" {
+		data["payee_addr"] = subscription.PayeeAddr
+	}
+	if subscription.APIKeyID != "" {
+		data["api_key_id"] = subscription.APIKeyID
+	}
+	if len(subscription.Events) > 0 {
+		data["events"] = subscription.Events
+	}
+	return data
+}
+
+// validateWebhookRequest returns why a subscription request is invalid, or "" when it is valid
+func validateWebhookRequest(req *WebhookRequest) string {
+	target, err := url.Parse(req.Url)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return "url must be an absolute http or https URL"
+	}
+	if (req.PayeeAddr == nil || *req.PayeeAddr == "") && apiKeyID(req.ApiKey) == "" {
+		return "payee_addr or api_key is required"
+	}
+	if req.Events != nil {
+		for _, event := range *req.Events {
+			if !slices.Contains(store.WebhookEvents, string(event)) {
+				return fmt.Sprintf("unknown event %s", event)
+			}
+		}
+	}
+	return ""
+}
+
+// CreateWebhook implements the POST /api/admin/webhooks endpoint
+func (s *APIServer) CreateWebhook(c *gin.Context) {
+	if !s.authorizeAdmin(c) {
+		return
+	}
+
+	var req WebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response := CreateApiResponseWithMap(CodeInvalidWebhook, map[string]interface{}{"reason": "invalid request body"})
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+	if reason := validateWebhookRequest(&req); reason != "" {
+		response := CreateApiResponseWithMap(CodeInvalidWebhook, map[string]interface{}{"reason": reason})
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	subscription := &store.WebhookSubscription{
+		URL:      req.Url,
+		Secret:   newWebhookSecret(),
+		APIKeyID: apiKeyID(req.ApiKey),
+	}
+	if req.PayeeAddr != nil {
+		subscription.PayeeAddr = *req.PayeeAddr
+	}
+	if req.Events != nil {
+		for _, event := range *req.Events {
+			subscription.Events = append(subscription.Events, string(event))
+		}
+	}
+	if err := s.ledger.CreateWebhookSubscription(subscription); err != nil {
+		log.Printf("Failed to create webhook subscription: %v", err)
+		response := CreateApiResponseWithNullData(CodeStorageError)
+		c.JSON(http.StatusInternalServerError, response)
+		return
+	}
+	log.Printf("Created webhook subscription %s to %s", subscription.ID, subscription.URL)
+
+	response := CreateApiResponseWithMap(CodeWebhookCreated, webhookData(subscription, true))
+	c.JSON(http.StatusOK, response)
+}
+
+// ListWebhooks implements the GET /api/admin/webhooks endpoint
+func (s *APIServer) ListWebhooks(c *gin.Context) {
+	if !s.authorizeAdmin(c) {
+		return
+	}
+
+	subscriptions, err := s.ledger.ListWebhookSubscriptions()
+	if err != nil {
+		log.Printf("Failed to list webhook subscriptions: %v", err)
+		response := CreateApiResponseWithNullData(CodeStorageError)
+		c.JSON(http.StatusInternalServerError, response)
+		return
+	}
+	webhooks := make([]map[string]interface{}, len(subscriptions))
+	for i, subscription := range subscriptions {
+		webhooks[i] = webhookData(subscription, false)
+	}
+	response := CreateApiResponseWithMap(CodeServerHealthy, map[string]interface{}{
+		"webhooks": webhooks,
+	})
+	c.JSON(http.StatusOK, response)
+}
+
+// DeleteWebhook implements the DELETE /api/admin/webhooks/{subscription_id} endpoint
+func (s *APIServer) DeleteWebhook(c *gin.Context, subscriptionId string) {
+	if !s.authorizeAdmin(c) {
+		return
+	}
+
+	if err := s.ledger.DeleteWebhookSubscription(subscriptionId); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			response := CreateApiResponseWithNullData(CodeWebhookNotFound)
+			c.JSON(http.StatusNotFound, response)
+			return
+		}
+		log.Printf("Failed to delete webhook subscription %s: %v", subscriptionId, err)
+		response := CreateApiResponseWithNullData(CodeStorageError)
+		c.JSON(http.StatusInternalServerError, response)
+		return
+	}
+	log.Printf("Deleted webhook subscription %s", subscriptionId)
+
+	response := CreateApiResponseWithMap(CodeServerHealthy, map[string]interface{}{
+		"subscription_id": subscriptionId,
+		"deleted":         true,
+	})
+	c.JSON(http.StatusOK, response)
+}
+
+// ListWebhookDeliveries implements the GET /api/admin/webhooks/deliveries endpoint
+func (s *APIServer) ListWebhookDeliveries(c *gin.Context, params ListWebhookDeliveriesParams) {
+	if !s.authorizeAdmin(c) {
+		return
+	}
+
+	filter := store.DeliveryFilter{}
+	if params.SubscriptionId != nil {
+		filter.SubscriptionID = *params.SubscriptionId
+	}
+	if params.PaymentId != nil {
+		filter.PaymentID = *params.PaymentId
+	}
+	if params.Status != nil {
+		filter.Status = store.DeliveryStatus(*params.Status)
+	}
+	if params.Cursor != nil {
+		filter.Cursor = *params.Cursor
+	}
+	if params.Limit != nil {
+		filter.Limit = *params.Limit
+	}
+
+	deliveries, nextCursor, err := s.ledger.ListWebhookDeliveries(filter)
+	if err != nil {
+		log.Printf("Failed to list webhook deliveries: %v", err)
+		response := CreateApiResponseWithNullData(CodeStorageError)
+		c.JSON(http.StatusInternalServerError, response)
+		return
+	}
+
+	data := map[string]interface{}{
+		"deliveries":  deliveries,
+		"next_cursor": nextCursor,
+	}
+	response := CreateApiResponseWithMap(CodeServerHealthy, data)
+	c.JSON(http.StatusOK, response)
+}
+
+// RedeliverWebhook implements the POST /api/admin/webhooks/deliveries/{delivery_id}/redeliver endpoint
+func (s *APIServer) RedeliverWebhook(c *gin.Context, deliveryId string) {
+	if !s.authorizeAdmin(c) {
+		return
+	}
+
+	delivery, err := s.ledger.RedeliverWebhook(deliveryId)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			response := CreateApiResponseWithNullData(CodeWebhookNotFound)
+			c.JSON(http.StatusNotFound, response)
+			return
+		}
+		log.Printf("Failed to redeliver webhook delivery %s: %v", deliveryId, err)
+		response := CreateApiResponseWithNullData(CodeStorageError)
+		c.JSON(http.StatusInternalServerError, response)
+		return
+	}
+	log.Printf("Redelivering webhook delivery %s (%s of payment %s)", delivery.ID, delivery.Event, delivery.PaymentID)
+	s.webhooks.notify()
+
+	response := CreateApiResponseWithMap(CodeWebhookQueued, map[string]interface{}{
+		"delivery_id": delivery.ID,
+		"status":      string(delivery.Status),
+	})
+	c.JSON(http.StatusOK, response)
+}
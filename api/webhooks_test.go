@@ -0,0 +1,250 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"tinypay-server/client"
+	"tinypay-server/config"
+	"tinypay-server/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+// webhookReceiver is a merchant endpoint that keeps the deliveries posted to it
+type webhookReceiver struct {
+	mu         sync.Mutex
+	statusCode int
+	requests   []receivedWebhook
+}
+
+type receivedWebhook struct {
+	header http.Header
+	body   []byte
+}
+
+func (r *webhookReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.requests = append(r.requests, receivedWebhook{header: req.Header.Clone(), body: body})
+	if r.statusCode != 0 {
+		w.WriteHeader(r.statusCode)
+	}
+}
+
+// await waits until n deliveries arrived and returns them
+func (r *webhookReceiver) await(t *testing.T, n int) []receivedWebhook {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		r.mu.Lock()
+		received := append([]receivedWebhook(nil), r.requests...)
+		r.mu.Unlock()
+		if len(received) >= n {
+			return received
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("expected %d webhook deliveries", n)
+	return nil
+}
+
+func TestWebhooks_DeliverSignedPaymentEvents(t *testing.T) {
+	receiver := &webhookReceiver{}
+	merchant := httptest.NewServer(receiver)
+	defer merchant.Close()
+
+	server, backend, _ := newTestAPIServer(t)
+	router := gin.New()
+	RegisterHandlers(router, server)
+	backend.cfg.AdminToken = "secret"
+	auth := map[string]string{"Authorization": "Bearer secret"}
+
+	rec := doRawRequest(t, router, http.MethodPost, "/api/admin/webhooks", map[string]interface{}{"url": merchant.URL}, auth)
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "payee_addr or api_key") {
+		t.Fatalf("expected a subscription without scope to be rejected, got %d: %s", rec.Code, rec.Body.String())
+	}
+	rec = doRawRequest(t, router, http.MethodPost, "/api/admin/webhooks", map[string]interface{}{"url": merchant.URL, "payee_addr": "0x2222"}, auth)
+	var created ApiResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &created); err != nil || rec.Code != http.StatusOK || created.Code != CodeWebhookCreated {
+		t.Fatalf("expected the subscription to be created, got %d: %s", rec.Code, rec.Body.String())
+	}
+	secret, _ := (*created.Data)["secret"].(string)
+	// A subscription of another merchant hears nothing of this payment
+	doRawRequest(t, router, http.MethodPost, "/api/admin/webhooks", map[string]interface{}{"url": merchant.URL + "/other", "payee_addr": "0x3333"}, auth)
+
+	status, resp := doRequest(t, router, http.MethodPost, "/api/payments", map[string]interface{}{
+		"payer_addr": "0x1111",
+		"payee_addr": "0x2222",
+		"otp":        "deadbeef",
+		"amount":     100,
+		"network":    "fake-evm",
+	})
+	if status != http.StatusOK || resp.Code != CodeTransactionCreated {
+		t.Fatalf("expected the payment to be submitted, got %d/%d", status, resp.Code)
+	}
+	receiver.await(t, 1)
+
+	backend.mu.Lock()
+	backend.txInfo = &client.TransactionInfo{Confirmed: true, Success: true}
+	backend.mu.Unlock()
+	doRequest(t, router, http.MethodGet, "/api/payments/0xabc?network=fake-evm", nil)
+
+	events := map[string]bool{}
+	for _, delivery := range receiver.await(t, 2) {
+		timestamp, err := strconv.ParseInt(delivery.header.Get(webhookTimestampHeader), 10, 64)
+		if err != nil {
+			t.Fatalf("expected a timestamp header, got %q", delivery.header.Get(webhookTimestampHeader))
+		}
+		if signature := delivery.header.Get(webhookSignatureHeader); signature != "v1="+webhookSignature(secret, timestamp, delivery.body) {
+			t.Errorf("expected a valid signature, got %q", signature)
+		}
+		var event webhookEvent
+		if err := json.Unmarshal(delivery.body, &event); err != nil {
+			t.Fatalf("decode delivery: %v", err)
+		}
+		if event.Event != delivery.header.Get(webhookEventHeader) || event.Payment.PayeeAddr != "0x2222" {
+			t.Errorf("unexpected delivery %s: %+v", delivery.header.Get(webhookEventHeader), event)
+		}
+		events[event.Event] = true
+	}
+	if !events[store.EventPaymentSubmitted] || !events[store.EventPaymentConfirmed] {
+		t.Errorf("expected submitted and confirmed events, got %v", events)
+	}
+
+	// Looking the payment up again does not send the confirmation twice
+	doRequest(t, router, http.MethodGet, "/api/payments/0xabc?network=fake-evm", nil)
+	server.webhooks.dispatch(context.Background())
+	if received := receiver.await(t, 2); len(received) != 2 {
+		t.Errorf("expected each event to be delivered once, got %d deliveries", len(received))
+	}
+}
+
+func TestWebhooks_MatchPaymentsByAPIKey(t *testing.T) {
+	router, backend, ledger := newTestServerWithLedger(t)
+	backend.cfg.AdminToken = "secret"
+	auth := map[string]string{"Authorization": "Bearer secret"}
+
+	doRawRequest(t, router, http.MethodPost, "/api/admin/webhooks", map[string]interface{}{
+		"url":     "https://merchant.example/hooks",
+		"api_key": "mk_test",
+		"events":  []string{store.EventPaymentFailed},
+	}, auth)
+
+	backend.sendErr = errors.New("insufficient balance")
+	body := map[string]interface{}{"payer_addr": "0x1111", "payee_addr": "0x2222", "otp": "deadbeef", "amount": 100, "network": "fake-evm"}
+	doRawRequest(t, router, http.MethodPost, "/api/payments", body, nil)
+	doRawRequest(t, router, http.MethodPost, "/api/payments", body, map[string]string{"Api-Key": "mk_test"})
+
+	deliveries, _, err := ledger.ListWebhookDeliveries(store.DeliveryFilter{})
+	if err != nil {
+		t.Fatalf("list deliveries: %v", err)
+	}
+	if len(deliveries) != 1 || deliveries[0].Event != store.EventPaymentFailed {
+		t.Fatalf("expected one failed event for the payment made with the API key, got %d", len(deliveries))
+	}
+	payment, err := ledger.GetPayment(deliveries[0].PaymentID)
+	if err != nil || payment.APIKeyID == "" || strings.Contains(payment.APIKeyID, "mk_test") {
+		t.Errorf("expected the payment to keep a fingerprint of its API key, got %+v (%v)", payment, err)
+	}
+}
+
+func TestWebhookDispatcher_RetriesWithBackoffThenDeadLetters(t *testing.T) {
+	receiver := &webhookReceiver{statusCode: http.StatusInternalServerError}
+	merchant := httptest.NewServer(receiver)
+	defer merchant.Close()
+
+	ledger, err := store.Open(filepath.Join(t.TempDir(), "ledger.db"))
+	if err != nil {
+		t.Fatalf("open ledger: %v", err)
+	}
+	defer ledger.Close()
+	subscription := &store.WebhookSubscription{URL: merchant.URL, Secret: "whsec_test", PayeeAddr: "0x2222"}
+	payment := &store.Payment{PayerAddr: "0x1111", PayeeAddr: "0x2222", Amount: 100, Network: "fake-evm"}
+	if err := ledger.CreateWebhookSubscription(subscription); err != nil {
+		t.Fatalf("create subscription: %v", err)
+	}
+	if err := ledger.CreatePayment(payment); err != nil {
+		t.Fatalf("create payment: %v", err)
+	}
+	delivery, _, err := ledger.EnqueueWebhookDelivery(subscription, store.EventPaymentConfirmed, payment, []byte(`{}`))
+	if err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+
+	d := newWebhookDispatcher(ledger, &config.Config{WebhookMaxAttempts: 3, WebhookInitialBackoff: time.Minute, WebhookMaxBackoff: 90 * time.Second})
+	now := time.Now()
+	d.now = func() time.Time { return now }
+
+	// 1 minute, then doubled but capped at 90 seconds
+	for _, wait := range []time.Duration{time.Minute, 90 * time.Second} {
+		d.dispatch(context.Background())
+		pending, _ := ledger.GetWebhookDelivery(delivery.ID)
+		if pending.Status != store.DeliveryPending || pending.NextAttemptAt == nil || !pending.NextAttemptAt.Equal(now.Add(wait)) {
+			t.Fatalf("expected a retry after %s, got %+v", wait, pending)
+		}
+		d.dispatch(context.Background())
+		if len(receiver.await(t, 0)) != len(pending.Attempts) {
+			t.Fatal("expected no attempt before the retry is due")
+		}
+		now = now.Add(wait)
+	}
+
+	d.dispatch(context.Background())
+	dead, _ := ledger.GetWebhookDelivery(delivery.ID)
+	if dead.Status != store.DeliveryDead || len(dead.Attempts) != 3 || dead.Attempts[2].StatusCode != http.StatusInternalServerError {
+		t.Fatalf("expected a dead letter after three attempts, got %+v", dead)
+	}
+}
+
+func TestRedeliverWebhook(t *testing.T) {
+	receiver := &webhookReceiver{}
+	merchant := httptest.NewServer(receiver)
+	defer merchant.Close()
+
+	router, backend, ledger := newTestServerWithLedger(t)
+	backend.cfg.AdminToken = "secret"
+	auth := map[string]string{"Authorization": "Bearer secret"}
+
+	subscription := &store.WebhookSubscription{URL: merchant.URL, Secret: "whsec_test", PayeeAddr: "0x2222"}
+	payment := &store.Payment{PayerAddr: "0x1111", PayeeAddr: "0x2222", Amount: 100, Network: "fake-evm"}
+	ledger.CreateWebhookSubscription(subscription)
+	ledger.CreatePayment(payment)
+	delivery, _, _ := ledger.EnqueueWebhookDelivery(subscription, store.EventPaymentFailed, payment, []byte(`{}`))
+	// Lease it away from the server's dispatcher and dead-letter it
+	ledger.ClaimWebhookDeliveries(time.Now(), time.Hour, 10)
+	ledger.RecordWebhookAttempt(delivery.ID, store.DeliveryAttempt{At: time.Now(), Error: "connection refused"}, store.DeliveryDead, time.Time{})
+
+	rec := doRawRequest(t, router, http.MethodGet, "/api/admin/webhooks/deliveries?status=dead", nil, auth)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), delivery.ID) {
+		t.Fatalf("expected the dead letter in the delivery log, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = doRawRequest(t, router, http.MethodPost, "/api/admin/webhooks/deliveries/"+delivery.ID+"/redeliver", nil, auth)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"code":1008`) {
+		t.Fatalf("expected the delivery to be queued again, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if received := receiver.await(t, 1); received[0].header.Get(webhookDeliveryHeader) != delivery.ID {
+		t.Errorf("expected delivery %s, got %q", delivery.ID, received[0].header.Get(webhookDeliveryHeader))
+	}
+
+	rec = doRawRequest(t, router, http.MethodPost, "/api/admin/webhooks/deliveries/unknown/redeliver", nil, auth)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown delivery, got %d", rec.Code)
+	}
+	rec = doRawRequest(t, router, http.MethodPost, "/api/admin/webhooks/deliveries/"+delivery.ID+"/redeliver", nil, nil)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 without the admin token, got %d", rec.Code)
+	}
+}
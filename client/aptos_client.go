@@ -1,17 +1,22 @@
 package client
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"log"
 	"strconv"
 	"strings"
+	"sync"
 
 	"tinypay-server/config"
+	"tinypay-server/signer"
 	"tinypay-server/utils"
 
 	"github.com/aptos-labs/aptos-go-sdk"
+	"github.com/aptos-labs/aptos-go-sdk/api"
 	"github.com/aptos-labs/aptos-go-sdk/bcs"
 	"github.com/aptos-labs/aptos-go-sdk/crypto"
 )
@@ -19,11 +24,23 @@ import (
 type AptosClient struct {
 	client           *aptos.Client
 	config           *config.Config
-	merchantAccount  *aptos.Account
-	paymasterAccount *aptos.Account
+	merchantAccount  *aptosSigner
+	merchantErr      error            // why the merchant key could not be loaded; merchantAccount is then a throwaway
+	feePayers        []*aptosSigner   // Paymaster keys paying for the merchant's transactions, in the order of the pool
+	paymasters       *paymasterPool   // Selects the fee payer of each transaction; nil without a paymaster
+	sequencesMu      sync.Mutex
+	sequences        map[aptos.AccountAddress]*sequenceManager
+	indexerURL       string // Indexer API the contract events are listed from
 }
 
 func NewAptosClient(cfg *config.Config) (*AptosClient, error) {
+	if err := cfg.AptosFees.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid Aptos fee policy: %w", err)
+	}
+	if err := cfg.AptosPaymasters.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid Aptos paymaster pool: %w", err)
+	}
+
 	// Create network config based on environment
 	var networkConfig aptos.NetworkConfig
 	switch cfg.AptosNetwork {
@@ -47,37 +64,54 @@ func NewAptosClient(cfg *config.Config) (*AptosClient, error) {
 		return nil, fmt.Errorf("failed to create Aptos client: %w", err)
 	}
 
-	// Load merchant account from private key
-	merchantAccount, err := aptos.NewEd25519Account()
-	if err != nil {
-		return nil, fmt.Errorf("failed to create merchant account: %w", err)
-	}
-
-	// Load paymaster account if provided
-	var paymasterAccount *aptos.Account
-	if cfg.PaymasterPrivateKey != "" {
-		// paymasterAccount, err = aptos.NewEd25519Account()
-		// if err != nil {
-		// 	return nil, fmt.Errorf("failed to create paymaster account: %w", err)
-		// }
-		// Create a sender locally
-		key := crypto.Ed25519PrivateKey{}
-		err = key.FromHex(cfg.PaymasterPrivateKey)
+	// Load merchant account from its key, held locally or by the remote signer. The merchant sends
+	// every transaction; an unusable key falls back to a throwaway account so the client can still
+	// serve lookups.
+	merchantAccount, merchantErr := newAptosSigner(cfg.MerchantPrivateKey, cfg.RemoteSigner())
+	if merchantErr != nil {
+		log.Printf("Warning: invalid Aptos merchant private key (%v), using a generated account; payments and precommits will not be accepted", merchantErr)
+		merchantAccount, err = generatedAptosSigner()
 		if err != nil {
-			panic("Failed to decode Ed25519 private key:" + err.Error())
+			return nil, fmt.Errorf("failed to create merchant account: %w", err)
 		}
-		paymasterAccount, err = aptos.NewAccountFromSigner(&key)
-		if err != nil {
-			panic("Failed to create sender:" + err.Error())
+	}
+
+	ac := &AptosClient{
+		client:          client,
+		config:          cfg,
+		merchantAccount: merchantAccount,
+		merchantErr:     merchantErr,
+		sequences:       make(map[aptos.AccountAddress]*sequenceManager),
+		indexerURL:      networkConfig.IndexerUrl,
+	}
+	if cfg.AptosIndexer.GraphQLURL != "" {
+		ac.indexerURL = cfg.AptosIndexer.GraphQLURL
+	}
+
+	// Load paymaster accounts if provided; they pay the gas of the merchant's transactions as fee payers
+	keys := config.PaymasterKeys(cfg.PaymasterPrivateKey, cfg.PaymasterPrivateKeys)
+	if len(keys) > 0 {
+		addresses := make([]string, 0, len(keys))
+		for i, key := range keys {
+			feePayer, err := newAptosSigner(key, cfg.RemoteSigner())
+			if err != nil {
+				return nil, fmt.Errorf("invalid Aptos paymaster private key #%d: %w", i+1, err)
+			}
+			ac.feePayers = append(ac.feePayers, feePayer)
+			addresses = append(addresses, feePayer.Address.String())
 		}
+		ac.paymasters = newPaymasterPool(AptosNetworkName, addresses, cfg.AptosPaymasters, ac.feePayerBalance)
 	}
+	return ac, nil
+}
 
-	return &AptosClient{
-		client:           client,
-		config:           cfg,
-		merchantAccount:  merchantAccount,
-		paymasterAccount: paymasterAccount,
-	}, nil
+// generatedAptosSigner creates an account with a new local key
+func generatedAptosSigner() (*aptosSigner, error) {
+	key, err := crypto.GenerateEd25519PrivateKey()
+	if err != nil {
+		return nil, err
+	}
+	return aptosSignerFor(signer.NewLocalEd25519(key.Inner))
 }
 
 // MerchantPrecommit executes the merchant_precommit function
@@ -90,6 +124,12 @@ func (ac *AptosClient) MerchantPrecommit(commitHash []byte) (string, error) {
 		return "", fmt.Errorf("failed to serialize commit hash: %w", err)
 	}
 
+	// Price the transaction with the fee policy
+	options, _, err := ac.transactionOptions()
+	if err != nil {
+		return "", err
+	}
+
 	// Build transaction
 	rawTxn, err := ac.client.BuildTransaction(
 		ac.merchantAccount.AccountAddress(),
@@ -106,15 +146,20 @@ func (ac *AptosClient) MerchantPrecommit(commitHash []byte) (string, error) {
 				},
 			},
 		},
-		aptos.MaxGasAmount(ac.config.MaxGasAmount),
-		aptos.GasUnitPrice(ac.config.GasUnitPrice),
+		options...,
 	)
 	if err != nil {
 		return "", fmt.Errorf("failed to build transaction: %w", err)
 	}
 
+	feePayer, release, err := ac.acquireFeePayer()
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
 	// Simulate transaction (optional but recommended)
-	simulationResult, err := ac.client.SimulateTransaction(rawTxn, ac.merchantAccount)
+	simulationResult, err := ac.simulate(rawTxn, ac.merchantAccount, feePayer)
 	if err != nil {
 		log.Printf("Warning: failed to simulate transaction: %v", err)
 	} else {
@@ -124,26 +169,16 @@ func (ac *AptosClient) MerchantPrecommit(commitHash []byte) (string, error) {
 			simulationResult[0].GasUsed*simulationResult[0].GasUnitPrice)
 	}
 
-	// Sign transaction
-	signedTxn, err := rawTxn.SignedTransaction(ac.merchantAccount)
-	if err != nil {
-		return "", fmt.Errorf("failed to sign transaction: %w", err)
-	}
-
-	// Submit transaction
-	submitResult, err := ac.client.SubmitTransaction(signedTxn)
+	// Sign and submit with a sequence number from the local allocator
+	txHash, err := ac.submitSequenced(ac.merchantAccount, feePayer, rawTxn)
 	if err != nil {
-		return "", fmt.Errorf("failed to submit transaction: %w", err)
+		return "", err
 	}
 
-	// Wait for transaction completion
-	_, err = ac.client.WaitForTransaction(submitResult.Hash)
-	if err != nil {
-		return "", fmt.Errorf("failed to wait for transaction: %w", err)
-	}
-
-	log.Printf("Merchant precommit successful, transaction hash: %s", submitResult.Hash)
-	return submitResult.Hash, nil
+	// Once submitted the transaction may commit, so its hash is returned without waiting; a
+	// completion that reaches the chain before it fails like any other and can be retried
+	log.Printf("Merchant precommit submitted, transaction hash: %s", txHash)
+	return txHash, nil
 }
 
 // CompletePayment completes a payment transaction with APT using FA system
@@ -186,15 +221,8 @@ func (ac *AptosClient) CompletePaymentWithCoinType(otp []byte, payer, recipient
 		return "", fmt.Errorf("failed to serialize commit hash: %w", err)
 	}
 
-	// Choose the caller (merchant or paymaster)
-	var caller *aptos.Account
-	if ac.paymasterAccount != nil {
-		caller = ac.paymasterAccount
-		log.Println("Using paymaster account as caller")
-	} else {
-		caller = ac.merchantAccount
-		log.Println("Using merchant account as caller")
-	}
+	// The merchant authorizes the payment; the paymaster, if any, pays its gas
+	caller := ac.merchantAccount
 
 	// Parse coin type for type arguments
 	coinTypeTag, err := aptos.ParseTypeTag(coinType)
@@ -202,6 +230,12 @@ func (ac *AptosClient) CompletePaymentWithCoinType(otp []byte, payer, recipient
 		return "", fmt.Errorf("failed to parse coin type: %w", err)
 	}
 
+	// Price the transaction with the fee policy
+	options, _, err := ac.transactionOptions()
+	if err != nil {
+		return "", err
+	}
+
 	// Build transaction
 	rawTxn, err := ac.client.BuildTransaction(
 		caller.AccountAddress(),
@@ -222,15 +256,20 @@ func (ac *AptosClient) CompletePaymentWithCoinType(otp []byte, payer, recipient
 				},
 			},
 		},
-		aptos.MaxGasAmount(ac.config.MaxGasAmount),
-		aptos.GasUnitPrice(ac.config.GasUnitPrice),
+		options...,
 	)
 	if err != nil {
 		return "", fmt.Errorf("failed to build transaction: %w", err)
 	}
 
+	feePayer, release, err := ac.acquireFeePayer()
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
 	// Simulate transaction (optional but recommended)
-	simulationResult, err := ac.client.SimulateTransaction(rawTxn, caller)
+	simulationResult, err := ac.simulate(rawTxn, caller, feePayer)
 	if err != nil {
 		log.Printf("Warning: failed to simulate transaction: %v", err)
 		return "", fmt.Errorf("failed to simulate transaction: %w", err)
@@ -242,39 +281,38 @@ func (ac *AptosClient) CompletePaymentWithCoinType(otp []byte, payer, recipient
 	}
 
 	if len(simulationResult) == 1 && (!simulationResult[0].Success) {
-		return "", fmt.Errorf("simulationResult failed %s", simulationResult[0].VmStatus)
+		return "", aptosSimulationError(simulationResult[0].VmStatus)
 	}
 
-	// Sign transaction
-	signedTxn, err := rawTxn.SignedTransaction(caller)
+	// Sign and submit with a sequence number from the local allocator
+	txHash, err := ac.submitSequenced(caller, feePayer, rawTxn)
 	if err != nil {
-		return "", fmt.Errorf("failed to sign transaction: %w", err)
+		return "", err
 	}
 
-	// Submit transaction
-	submitResult, err := ac.client.SubmitTransaction(signedTxn)
-	if err != nil {
-		return "", fmt.Errorf("failed to submit transaction: %w", err)
-	}
+	// The outcome is read later with GetTransactionDetails
+	log.Printf("Payment completion submitted, transaction hash: %s", txHash)
+	return txHash, nil
+}
 
-	// Wait for transaction completion
-	_, err = ac.client.WaitForTransaction(submitResult.Hash)
+// CompletePaymentWithFA completes a payment transaction using FA (Fungible Asset) system
+func (ac *AptosClient) CompletePaymentWithFA(otp []byte, payer, recipient string, amount uint64, commitHash []byte, currency string) (string, error) {
+	submission, err := ac.completePaymentWithFA(otp, payer, recipient, amount, commitHash, currency)
 	if err != nil {
-		return "", fmt.Errorf("failed to wait for transaction: %w", err)
+		return "", err
 	}
-
-	log.Printf("Payment completion successful, transaction hash: %s", submitResult.Hash)
-	return submitResult.Hash, nil
+	return submission.TxHash, nil
 }
 
-// CompletePaymentWithFA completes a payment transaction using FA (Fungible Asset) system
-func (ac *AptosClient) CompletePaymentWithFA(otp []byte, payer, recipient string, amount uint64, commitHash []byte, currency string) (string, error) {
+// completePaymentWithFA submits complete_payment through the FA system, sent by the merchant with
+// the paymaster as fee payer, and reports its hash with the fee it was priced at
+func (ac *AptosClient) completePaymentWithFA(otp []byte, payer, recipient string, amount uint64, commitHash []byte, currency string) (*Submission, error) {
 	log.Printf("Executing complete_payment with FA - Payer: %s, Recipient: %s, Amount: %d, Currency: %s", payer, recipient, amount, currency)
 
 	// Get metadata address for the currency
 	metadataAddr, err := utils.GetMetadataAddress(ac.config, currency)
 	if err != nil {
-		return "", fmt.Errorf("failed to get metadata address: %w", err)
+		return nil, fmt.Errorf("failed to get metadata address: %w", err)
 	}
 
 	// Parse addresses
@@ -285,44 +323,43 @@ func (ac *AptosClient) CompletePaymentWithFA(otp []byte, payer, recipient string
 	// Serialize parameters
 	optBytes, err := bcs.SerializeBytes(otp)
 	if err != nil {
-		return "", fmt.Errorf("failed to serialize otp: %w", err)
+		return nil, fmt.Errorf("failed to serialize otp: %w", err)
 	}
 
 	payerBytes, err := bcs.Serialize(&payerAddr)
 	if err != nil {
-		return "", fmt.Errorf("failed to serialize payer address: %w", err)
+		return nil, fmt.Errorf("failed to serialize payer address: %w", err)
 	}
 
 	recipientBytes, err := bcs.Serialize(&recipientAddr)
 	if err != nil {
-		return "", fmt.Errorf("failed to serialize recipient address: %w", err)
+		return nil, fmt.Errorf("failed to serialize recipient address: %w", err)
 	}
 
 	amountBytes, err := bcs.SerializeU64(amount)
 	if err != nil {
-		return "", fmt.Errorf("failed to serialize amount: %w", err)
+		return nil, fmt.Errorf("failed to serialize amount: %w", err)
 	}
 
 	metadataBytes, err := bcs.Serialize(&metadataAddress)
 	if err != nil {
-		return "", fmt.Errorf("failed to serialize metadata address: %w", err)
+		return nil, fmt.Errorf("failed to serialize metadata address: %w", err)
 	}
 
 	commitHashBytes, err := bcs.SerializeBytes(commitHash)
 	if err != nil {
-		return "", fmt.Errorf("failed to serialize commit hash: %w", err)
+		return nil, fmt.Errorf("failed to serialize commit hash: %w", err)
 	}
 
-	// Choose the caller (merchant or paymaster)
-	var caller *aptos.Account
-	if ac.paymasterAccount != nil {
-		caller = ac.paymasterAccount
-		log.Println("Using paymaster account as caller")
-	} else {
-		caller = ac.merchantAccount
-		log.Println("Using merchant account as caller")
+	// Price the transaction with the fee policy
+	options, fee, err := ac.transactionOptions()
+	if err != nil {
+		return nil, err
 	}
 
+	// The merchant authorizes the payment; the paymaster, if any, pays its gas
+	caller := ac.merchantAccount
+
 	// Build transaction for FA system (no type arguments needed)
 	rawTxn, err := ac.client.BuildTransaction(
 		caller.AccountAddress(),
@@ -344,18 +381,23 @@ func (ac *AptosClient) CompletePaymentWithFA(otp []byte, payer, recipient string
 				},
 			},
 		},
-		aptos.MaxGasAmount(ac.config.MaxGasAmount),
-		aptos.GasUnitPrice(ac.config.GasUnitPrice),
+		options...,
 	)
 	if err != nil {
-		return "", fmt.Errorf("failed to build transaction: %w", err)
+		return nil, fmt.Errorf("failed to build transaction: %w", err)
 	}
 
+	feePayer, release, err := ac.acquireFeePayer()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
 	// Simulate transaction (optional but recommended)
-	simulationResult, err := ac.client.SimulateTransaction(rawTxn, caller)
+	simulationResult, err := ac.simulate(rawTxn, caller, feePayer)
 	if err != nil {
 		log.Printf("Warning: failed to simulate transaction: %v", err)
-		return "", fmt.Errorf("failed to simulate transaction: %w", err)
+		return nil, fmt.Errorf("failed to simulate transaction: %w", err)
 	} else {
 		log.Printf("Simulation - Gas used: %d, Gas unit price: %d, Total fee: %d",
 			simulationResult[0].GasUsed,
@@ -364,29 +406,24 @@ func (ac *AptosClient) CompletePaymentWithFA(otp []byte, payer, recipient string
 	}
 
 	if len(simulationResult) == 1 && (!simulationResult[0].Success) {
-		return "", fmt.Errorf("simulationResult failed %s", simulationResult[0].VmStatus)
+		return nil, aptosSimulationError(simulationResult[0].VmStatus)
 	}
 
-	// Sign transaction
-	signedTxn, err := rawTxn.SignedTransaction(caller)
+	// Sign and submit with a sequence number from the local allocator
+	txHash, err := ac.submitSequenced(caller, feePayer, rawTxn)
 	if err != nil {
-		return "", fmt.Errorf("failed to sign transaction: %w", err)
+		return nil, err
 	}
 
-	// Submit transaction
-	submitResult, err := ac.client.SubmitTransaction(signedTxn)
-	if err != nil {
-		return "", fmt.Errorf("failed to submit transaction: %w", err)
+	// Once submitted the transaction may commit, so its hash is returned right away and the
+	// outcome is read later with GetTransactionDetails; waiting here would hold the payer lock and
+	// lose the hash when the wait times out
+	log.Printf("FA Payment submitted, transaction hash: %s", txHash)
+	submission := &Submission{TxHash: txHash, Fee: fee}
+	if feePayer != nil {
+		submission.Sender = feePayer.Address.String()
 	}
-
-	// Wait for transaction completion
-	_, err = ac.client.WaitForTransaction(submitResult.Hash)
-	if err != nil {
-		return "", fmt.Errorf("failed to wait for transaction: %w", err)
-	}
-
-	log.Printf("FA Payment completion successful, transaction hash: %s", submitResult.Hash)
-	return submitResult.Hash, nil
+	return submission, nil
 }
 
 // Helper function to compute payment parameters hash for FA system
@@ -462,12 +499,12 @@ func (ac *AptosClient) GetMerchantAddress() string {
 	return ac.merchantAccount.Address.String()
 }
 
-// GetPaymasterAddress returns the paymaster account address (if available)
+// GetPaymasterAddress returns the address of the first paymaster account (if available)
 func (ac *AptosClient) GetPaymasterAddress() string {
-	if ac.paymasterAccount == nil {
+	if len(ac.feePayers) == 0 {
 		return ""
 	}
-	return ac.paymasterAccount.Address.String()
+	return ac.feePayers[0].Address.String()
 }
 
 // GetConfig 返回客户端的配置
@@ -475,8 +512,86 @@ func (ac *AptosClient) GetConfig() *config.Config {
 	return ac.config
 }
 
+// GetNetwork returns the network name the Aptos client serves
+func (ac *AptosClient) GetNetwork() string {
+	return AptosNetworkName
+}
+
+// CheckConfig reports a missing contract address, which would otherwise send every call to 0x0,
+// and a merchant key that could not be loaded
+func (ac *AptosClient) CheckConfig() error {
+	if strings.TrimSpace(ac.config.ContractAddress) == "" {
+		return fmt.Errorf("aptos contract address not configured")
+	}
+	if ac.merchantErr != nil {
+		return fmt.Errorf("aptos merchant key not usable: %w", ac.merchantErr)
+	}
+	return nil
+}
+
+// SupportedCurrencies returns the currencies accepted on Aptos
+func (ac *AptosClient) SupportedCurrencies() []string {
+	return utils.NewNetworkCurrencyValidationMatrix(ac.config).GetSupportedCurrenciesForNetwork(AptosNetworkName)
+}
+
+// DefaultCurrency returns the currency used when a payment does not specify one
+func (ac *AptosClient) DefaultCurrency() string {
+	return "APT"
+}
+
+// ResolveCoinType returns the FA metadata address for a currency
+func (ac *AptosClient) ResolveCoinType(currency string) (string, error) {
+	coinType, err := utils.GetCoinType(ac.config, currency)
+	if err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(coinType) == "" {
+		return "", fmt.Errorf("aptos %s metadata address not configured", strings.ToUpper(currency))
+	}
+	return coinType, nil
+}
+
+// NormalizeAddress returns the canonical form of an Aptos address, so 0x1 and its zero-padded
+// spelling name the same account
+func (ac *AptosClient) NormalizeAddress(address string) string {
+	var addr aptos.AccountAddress
+	if err := addr.ParseStringRelaxed(strings.TrimSpace(address)); err != nil {
+		return strings.ToLower(strings.TrimSpace(address))
+	}
+	return addr.String()
+}
+
+// SendPayment submits a complete_payment transaction through the FA system
+func (ac *AptosClient) SendPayment(ctx context.Context, payment *Payment) (*Submission, error) {
+	otpBytes := utils.HexToASCIIBytes(payment.Otp)
+	log.Printf("Aptos CLI format for otp: u8:%s", strings.Join(strings.Fields(fmt.Sprint(otpBytes)), ","))
+
+	commitHash := []byte("")
+	if len(payment.CommitHash) > 0 {
+		log.Println("Completing precommitted payment")
+		commitHash = payment.CommitHash
+	}
+	return ac.completePaymentWithFA(otpBytes, payment.PayerAddr, payment.PayeeAddr, payment.Amount, commitHash, payment.Currency)
+}
+
+// Precommit computes the FA payment hash and records it with merchant_precommit
+func (ac *AptosClient) Precommit(ctx context.Context, payment *Payment) (*Precommit, error) {
+	otpBytes := utils.HexToASCIIBytes(payment.Otp)
+
+	commitHash, err := ac.ComputePaymentHashWithCurrency(payment.PayerAddr, payment.PayeeAddr, payment.Amount, otpBytes, payment.Currency)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute commit hash: %w", err)
+	}
+
+	txHash, err := ac.MerchantPrecommit(commitHash)
+	if err != nil {
+		return nil, err
+	}
+	return &Precommit{CommitHash: commitHash, TxHash: txHash}, nil
+}
+
 // SimulatePayment simulates a payment transaction without submitting it
-func (ac *AptosClient) SimulatePayment(otp []byte, payer, recipient string, amount uint64) (*aptos.Account, *aptos.RawTransaction, error) {
+func (ac *AptosClient) SimulatePayment(otp []byte, payer, recipient string, amount uint64) (*aptosSigner, *aptos.RawTransaction, error) {
 	log.Printf("Simulating payment - Payer: %s, Recipient: %s, Amount: %d", payer, recipient, amount)
 
 	caller, rawTxn, err := ac.paymentsRawTx(otp, payer, recipient, amount)
@@ -484,8 +599,14 @@ func (ac *AptosClient) SimulatePayment(otp []byte, payer, recipient string, amou
 		return nil, nil, err
 	}
 
+	feePayer, release, err := ac.acquireFeePayer()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer release()
+
 	// Simulate transaction
-	simulationResult, err := ac.client.SimulateTransaction(rawTxn, caller)
+	simulationResult, err := ac.simulate(rawTxn, caller, feePayer)
 	if err != nil {
 		return nil, nil, fmt.Errorf("transaction simulation failed: %w", err)
 	}
@@ -507,7 +628,7 @@ func (ac *AptosClient) SimulatePayment(otp []byte, payer, recipient string, amou
 	return caller, rawTxn, nil
 }
 
-func (ac *AptosClient) paymentsRawTx(otp []byte, payer string, recipient string, amount uint64) (*aptos.Account, *aptos.RawTransaction, error) {
+func (ac *AptosClient) paymentsRawTx(otp []byte, payer string, recipient string, amount uint64) (*aptosSigner, *aptos.RawTransaction, error) {
 	// Parse addresses
 	payerAddr := parseAccountAddress(payer)
 	recipientAddr := parseAccountAddress(recipient)
@@ -534,12 +655,9 @@ func (ac *AptosClient) paymentsRawTx(otp []byte, payer string, recipient string,
 	}
 
 	var commitHash []byte
-	// Choose the caller (merchant or paymaster)
-	var caller *aptos.Account
-	if ac.paymasterAccount != nil {
-		caller = ac.paymasterAccount
-	} else {
-		caller = ac.merchantAccount
+	// The merchant sends; payments are only sponsored when a paymaster pays the gas
+	caller := ac.merchantAccount
+	if len(ac.feePayers) == 0 {
 		// Compute commit hash for simulation
 		commitHash, err = ac.ComputePaymentHash(payer, recipient, amount, otp)
 		if err != nil {
@@ -552,6 +670,12 @@ func (ac *AptosClient) paymentsRawTx(otp []byte, payer string, recipient string,
 		return nil, nil, fmt.Errorf("failed to serialize commit hash: %w", err)
 	}
 
+	// Price the transaction with the fee policy
+	options, _, err := ac.transactionOptions()
+	if err != nil {
+		return nil, nil, err
+	}
+
 	// Build transaction for simulation
 	rawTxn, err := ac.client.BuildTransaction(
 		caller.AccountAddress(),
@@ -572,8 +696,7 @@ func (ac *AptosClient) paymentsRawTx(otp []byte, payer string, recipient string,
 				},
 			},
 		},
-		aptos.MaxGasAmount(ac.config.MaxGasAmount),
-		aptos.GasUnitPrice(ac.config.GasUnitPrice),
+		options...,
 	)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to build transaction: %w", err)
@@ -581,6 +704,14 @@ func (ac *AptosClient) paymentsRawTx(otp []byte, payer string, recipient string,
 	return caller, rawTxn, nil
 }
 
+// aptosSimulationError reports a failed simulation with its decoded VM status
+func aptosSimulationError(vmStatus string) error {
+	if chainErr := DecodeAptosVMStatus(vmStatus); chainErr != nil {
+		return fmt.Errorf("simulation failed: %w", chainErr)
+	}
+	return fmt.Errorf("simulation failed: %s", vmStatus)
+}
+
 // TransactionInfo contains detailed transaction information
 type TransactionInfo struct {
 	Confirmed bool
@@ -589,37 +720,63 @@ type TransactionInfo struct {
 	CoinType  string // "APT" or "USDC"
 	Error     string
 	TokenAddress string // For EVM transactions, the token contract address
+	Commitment string // For Solana transactions, the commitment level reached (processed, confirmed or finalized)
+	Failure *ChainError // Decoded reason of a failed transaction, when the chain reports one
 }
 
-// GetTransactionStatus gets the status of a transaction by hash
+// lookupTransaction fetches a transaction by hash without waiting for it to be committed.
+// Hashes the node does not know return ErrTransactionNotFound.
+func (ac *AptosClient) lookupTransaction(txHash string) (*api.Transaction, error) {
+	txn, err := ac.client.TransactionByHash(txHash)
+	if err != nil {
+		var httpErr *aptos.HttpError
+		if errors.As(err, &httpErr) && httpErr.StatusCode == 404 {
+			return nil, ErrTransactionNotFound
+		}
+		return nil, fmt.Errorf("failed to look up transaction %s: %w", txHash, err)
+	}
+	if txn == nil {
+		return nil, ErrTransactionNotFound
+	}
+	return txn, nil
+}
+
+// GetTransactionStatus reports whether a transaction has been committed, successfully or not.
+// Pending transactions report false; unknown hashes return ErrTransactionNotFound.
 func (ac *AptosClient) GetTransactionStatus(txHash string) (bool, error) {
 	log.Printf("Getting transaction status for hash: %s", txHash)
 
-	// Try to wait for transaction to check if it exists and is confirmed
-	_, err := ac.client.WaitForTransaction(txHash)
+	txn, err := ac.lookupTransaction(txHash)
 	if err != nil {
-		// Transaction might be pending or failed
-		return false, nil
+		return false, err
 	}
-
-	// Transaction is confirmed
-	return true, nil
+	return txn.Type != api.TransactionVariantPending, nil
 }
 
-// GetTransactionDetails gets detailed information about a transaction
-func (ac *AptosClient) GetTransactionDetails(txHash string) (*TransactionInfo, error) {
+// GetTransactionDetails gets detailed information about a transaction without blocking:
+// pending transactions are reported unconfirmed and failed ones carry their VM status as Error
+func (ac *AptosClient) GetTransactionDetails(ctx context.Context, txHash string) (*TransactionInfo, error) {
 	log.Printf("Getting transaction details for hash: %s", txHash)
 
-	// Try to wait for transaction to check if it exists and get details
-	txnResult, err := ac.client.WaitForTransaction(txHash)
+	txn, err := ac.lookupTransaction(txHash)
 	if err != nil {
-		// Transaction does not exist or failed to retrieve
-		log.Printf("Transaction not found or failed to retrieve: %v", err)
-		return nil, fmt.Errorf("transaction not found")
+		log.Printf("Transaction lookup failed: %v", err)
+		return nil, err
 	}
 
-	// Transaction exists and is confirmed
-	log.Printf("Transaction found - Success: %t, Gas used: %d", txnResult.Success, txnResult.GasUsed)
+	if txn.Type == api.TransactionVariantPending {
+		return &TransactionInfo{Confirmed: false}, nil
+	}
+
+	txnResult, err := txn.UserTransaction()
+	if err != nil {
+		// Committed, but not a user transaction and therefore not a payment
+		success := txn.Success() != nil && *txn.Success()
+		return &TransactionInfo{Confirmed: true, Success: success}, nil
+	}
+
+	// Transaction exists and is committed
+	log.Printf("Transaction found - Success: %t, Gas used: %d, VM status: %s", txnResult.Success, txnResult.GasUsed, txnResult.VmStatus)
 
 	// Extract amount and currency type from transaction events
 	amount := uint64(0)
@@ -694,12 +851,20 @@ func (ac *AptosClient) GetTransactionDetails(txHash string) (*TransactionInfo, e
 
 	log.Printf("Final extracted amount: %d octas", amount)
 
+	errorMsg := ""
+	var failure *ChainError
+	if !txnResult.Success {
+		errorMsg = txnResult.VmStatus
+		failure = DecodeAptosVMStatus(txnResult.VmStatus)
+	}
+
 	return &TransactionInfo{
 		Confirmed: true,
 		Success:   txnResult.Success,
 		Amount:    amount,
 		CoinType:  currency,
-		Error:     "",
+		Error:     errorMsg,
+		Failure:   failure,
 	}, nil
 }
 
@@ -713,20 +878,20 @@ func (ac *AptosClient) SubmitPayment(otp []byte, payer, recipient string, amount
 		return "", fmt.Errorf("simulation failed: %w", err)
 	}
 
-	// Sign transaction
-	signedTxn, err := rawTxn.SignedTransaction(caller)
+	feePayer, release, err := ac.acquireFeePayer()
 	if err != nil {
-		return "", fmt.Errorf("failed to sign transaction: %w", err)
+		return "", err
 	}
+	defer release()
 
-	// Submit transaction
-	submitResult, err := ac.client.SubmitTransaction(signedTxn)
+	// Sign and submit with a sequence number from the local allocator
+	txHash, err := ac.submitSequenced(caller, feePayer, rawTxn)
 	if err != nil {
-		return "", fmt.Errorf("failed to submit transaction: %w", err)
+		return "", err
 	}
 
-	log.Printf("Payment submitted successfully, transaction hash: %s", submitResult.Hash)
-	return submitResult.Hash, nil
+	log.Printf("Payment submitted successfully, transaction hash: %s", txHash)
+	return txHash, nil
 }
 
 // Helper function to parse account address
@@ -747,7 +912,7 @@ type UserLimits struct {
 }
 
 // GetUserLimits calls the get_user_limits view function from the contract
-func (ac *AptosClient) GetUserLimits(userAddress string) (*UserLimits, error) {
+func (ac *AptosClient) GetUserLimits(ctx context.Context, userAddress string) (*UserLimits, error) {
 	log.Printf("Getting user limits for address: %s", userAddress)
 
 	// Parse the user address
@@ -800,6 +965,88 @@ func (ac *AptosClient) GetUserLimits(userAddress string) (*UserLimits, error) {
 	}, nil
 }
 
+// GetBalance calls the get_balance view function for the currency's FA metadata
+func (ac *AptosClient) GetBalance(ctx context.Context, userAddress, currency string) (uint64, error) {
+	metadataAddr, err := utils.GetMetadataAddress(ac.config, currency)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get metadata address: %w", err)
+	}
+
+	var userAddr, metadataAddress aptos.AccountAddress
+	if err := userAddr.ParseStringRelaxed(userAddress); err != nil {
+		return 0, fmt.Errorf("invalid user address %s: %w", userAddress, err)
+	}
+	if err := metadataAddress.ParseStringRelaxed(metadataAddr); err != nil {
+		return 0, fmt.Errorf("invalid metadata address %s: %w", metadataAddr, err)
+	}
+
+	viewRequest := &aptos.ViewPayload{
+		Module: aptos.ModuleId{
+			Address: parseAccountAddress(ac.config.ContractAddress),
+			Name:    "tinypay",
+		},
+		Function: "get_balance",
+		ArgTypes: []aptos.TypeTag{},
+		Args: [][]byte{
+			userAddr[:],
+			metadataAddress[:],
+		},
+	}
+
+	result, err := ac.client.View(viewRequest)
+	if err != nil {
+		return 0, fmt.Errorf("failed to call get_balance view function: %w", err)
+	}
+	if len(result) != 1 {
+		return 0, fmt.Errorf("unexpected result length: expected 1, got %d", len(result))
+	}
+
+	balance, err := parseU64FromInterface(result[0])
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse balance: %w", err)
+	}
+	return balance, nil
+}
+
+// GetUserTail calls the get_user_tail view function, which returns the user's tail as vector<u8>
+func (ac *AptosClient) GetUserTail(ctx context.Context, userAddress string) ([]byte, error) {
+	var userAddr aptos.AccountAddress
+	if err := userAddr.ParseStringRelaxed(userAddress); err != nil {
+		return nil, fmt.Errorf("invalid user address %s: %w", userAddress, err)
+	}
+
+	viewRequest := &aptos.ViewPayload{
+		Module: aptos.ModuleId{
+			Address: parseAccountAddress(ac.config.ContractAddress),
+			Name:    "tinypay",
+		},
+		Function: "get_user_tail",
+		ArgTypes: []aptos.TypeTag{},
+		Args: [][]byte{
+			userAddr[:],
+		},
+	}
+
+	result, err := ac.client.View(viewRequest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call get_user_tail view function: %w", err)
+	}
+	if len(result) != 1 {
+		return nil, fmt.Errorf("unexpected result length: expected 1, got %d", len(result))
+	}
+
+	// vector<u8> results are returned as 0x-prefixed hex strings
+	encoded, ok := result[0].(string)
+	if !ok {
+		return nil, fmt.Errorf("unexpected tail type: %T", result[0])
+	}
+	tail, err := hex.DecodeString(strings.TrimPrefix(encoded, "0x"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode tail: %w", err)
+	}
+	return tail, nil
+}
+
 // parseU64FromInterface parses a uint64 value from an interface{}
 func parseU64FromInterface(value interface{}) (uint64, error) {
 	switch v := value.(type) {
@@ -0,0 +1,110 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"tinypay-server/config"
+)
+
+// aptosTransactions maps transaction hashes to the node's transactions/by_hash responses
+var aptosTransactions = map[string]string{
+	"0xpending": `{"type": "pending_transaction", "hash": "0xpending", "sender": "0x1", "sequence_number": "3"}`,
+	"0xsuccess": `{
+		"type": "user_transaction", "version": "100", "hash": "0xsuccess", "success": true,
+		"vm_status": "Executed successfully", "gas_used": "12",
+		"events": [{
+			"type": "0xcafe::tinypay::PaymentCompleted",
+			"guid": {"creation_number": "0", "account_address": "0x0"},
+			"sequence_number": "0",
+			"data": {"amount": "2500"}
+		}]
+	}`,
+	"0xfailure": `{
+		"type": "user_transaction", "version": "101", "hash": "0xfailure", "success": false,
+		"vm_status": "Move abort in 0xcafe::tinypay: E_INVALID_OTP(0x3): ", "gas_used": "8", "events": []
+	}`,
+}
+
+func newTestAptosClient(t *testing.T) *AptosClient {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hash := strings.TrimPrefix(r.URL.Path, "/transactions/by_hash/")
+		body, ok := aptosTransactions[hash]
+		w.Header().Set("Content-Type", "application/json")
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message": "Transaction not found by Transaction hash", "error_code": "transaction_not_found"}`))
+			return
+		}
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+
+	ac, err := NewAptosClient(&config.Config{AptosNetwork: "local", AptosNodeURL: server.URL})
+	if err != nil {
+		t.Fatalf("new aptos client: %v", err)
+	}
+	return ac
+}
+
+func TestAptosGetTransactionDetails(t *testing.T) {
+	ac := newTestAptosClient(t)
+	ctx := context.Background()
+
+	info, err := ac.GetTransactionDetails(ctx, "0xpending")
+	if err != nil || info.Confirmed {
+		t.Errorf("expected a pending transaction, got %+v err=%v", info, err)
+	}
+
+	info, err = ac.GetTransactionDetails(ctx, "0xsuccess")
+	if err != nil {
+		t.Fatalf("lookup success: %v", err)
+	}
+	if !info.Confirmed || !info.Success || info.Amount != 2500 || info.Error != "" {
+		t.Errorf("unexpected successful transaction: %+v", info)
+	}
+
+	info, err = ac.GetTransactionDetails(ctx, "0xfailure")
+	if err != nil {
+		t.Fatalf("lookup failure: %v", err)
+	}
+	if !info.Confirmed || info.Success || !strings.Contains(info.Error, "E_INVALID_OTP") {
+		t.Errorf("expected the VM status of a failed transaction, got %+v", info)
+	}
+
+	if _, err := ac.GetTransactionDetails(ctx, "0xunknown"); !errors.Is(err, ErrTransactionNotFound) {
+		t.Errorf("expected ErrTransactionNotFound, got %v", err)
+	}
+}
+
+func TestAptosGetTransactionStatus(t *testing.T) {
+	ac := newTestAptosClient(t)
+
+	if committed, err := ac.GetTransactionStatus("0xpending"); err != nil || committed {
+		t.Errorf("expected pending, got committed=%v err=%v", committed, err)
+	}
+	if committed, err := ac.GetTransactionStatus("0xfailure"); err != nil || !committed {
+		t.Errorf("expected committed, got committed=%v err=%v", committed, err)
+	}
+	if _, err := ac.GetTransactionStatus("0xunknown"); !errors.Is(err, ErrTransactionNotFound) {
+		t.Errorf("expected ErrTransactionNotFound, got %v", err)
+	}
+}
+
+func TestAptosCheckConfigRejectsGeneratedMerchant(t *testing.T) {
+	ac := newTestAptosClient(t)
+	ac.config.ContractAddress = "0xcafe"
+
+	// No merchant key was configured, so the client fell back to a generated account
+	if err := ac.CheckConfig(); err == nil || !strings.Contains(err.Error(), "merchant key") {
+		t.Errorf("expected the missing merchant key to be reported, got %v", err)
+	}
+	if _, err := ac.submitSequenced(ac.merchantAccount, nil, nil); err == nil {
+		t.Error("expected the generated merchant account to send nothing")
+	}
+}
@@ -0,0 +1,139 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aptos-labs/aptos-go-sdk/api"
+)
+
+// aptosIndexerTimeout bounds one query of the Aptos indexer API
+const aptosIndexerTimeout = 30 * time.Second
+
+// aptosEventsQuery lists the events matching any of $types from event $start_index of ledger
+// version $start on, in ledger order. Events are only indexed for transactions that succeeded.
+const aptosEventsQuery = `query ContractEvents($types: [events_bool_exp!]!, $start: bigint!, $start_index: bigint!, $limit: Int!) {
+  events(
+    where: {
+      _and: [
+        {_or: $types}
+        {_or: [
+          {transaction_version: {_gt: $start}}
+          {transaction_version: {_eq: $start}, event_index: {_gte: $start_index}}
+        ]}
+      ]
+    }
+    order_by: [{transaction_version: asc}, {event_index: asc}]
+    limit: $limit
+  ) {
+    transaction_version
+    event_index
+    type
+    data
+  }
+}`
+
+// AptosModuleEvent is an event listed by the Aptos indexer API
+type AptosModuleEvent struct {
+	Version uint64 // ledger version of the transaction that emitted the event
+	Index   uint64 // position among the events of the transaction
+	Type    string
+	Data    map[string]any
+}
+
+// indexerUint decodes a bigint column, which the indexer API may return as a number or a string
+type indexerUint uint64
+
+func (u *indexerUint) UnmarshalJSON(raw []byte) error {
+	value, err := strconv.ParseUint(strings.Trim(string(raw), `"`), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid bigint %s: %w", raw, err)
+	}
+	*u = indexerUint(value)
+	return nil
+}
+
+// IndexerURL returns the Aptos indexer API the client lists events from, empty when there is none
+func (ac *AptosClient) IndexerURL() string {
+	return ac.indexerURL
+}
+
+// ModuleEvents lists up to limit events whose type starts with one of typePrefixes, such as
+// 0x1::coin::, from event startIndex of ledger version start on, in ledger order
+func (ac *AptosClient) ModuleEvents(ctx context.Context, typePrefixes []string, start, startIndex, limit uint64) ([]AptosModuleEvent, error) {
+	if ac.indexerURL == "" {
+		return nil, errors.New("no Aptos indexer API configured")
+	}
+
+	types := make([]map[string]any, len(typePrefixes))
+	for i, prefix := range typePrefixes {
+		types[i] = map[string]any{"indexed_type": map[string]any{"_like": prefix + "%"}}
+	}
+	body, err := json.Marshal(map[string]any{
+		"query":     aptosEventsQuery,
+		"variables": map[string]any{"types": types, "start": start, "start_index": startIndex, "limit": limit},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, aptosIndexerTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, ac.indexerURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("indexer API request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	payload, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read indexer API response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("indexer API returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(payload)))
+	}
+
+	var out struct {
+		Data struct {
+			Events []struct {
+				TransactionVersion indexerUint    `json:"transaction_version"`
+				EventIndex         indexerUint    `json:"event_index"`
+				Type               string         `json:"type"`
+				Data               map[string]any `json:"data"`
+			} `json:"events"`
+		} `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := json.Unmarshal(payload, &out); err != nil {
+		return nil, fmt.Errorf("failed to decode indexer API response: %w", err)
+	}
+	if len(out.Errors) > 0 {
+		return nil, fmt.Errorf("indexer API query failed: %s", out.Errors[0].Message)
+	}
+
+	events := make([]AptosModuleEvent, len(out.Data.Events))
+	for i, e := range out.Data.Events {
+		events[i] = AptosModuleEvent{Version: uint64(e.TransactionVersion), Index: uint64(e.EventIndex), Type: e.Type, Data: e.Data}
+	}
+	return events, nil
+}
+
+// TransactionByVersion reads the committed transaction at a ledger version
+func (ac *AptosClient) TransactionByVersion(version uint64) (*api.CommittedTransaction, error) {
+	return ac.client.TransactionByVersion(version)
+}
@@ -0,0 +1,43 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestModuleEventsQueriesTheIndexerAPI(t *testing.T) {
+	var variables map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Variables map[string]any `json:"variables"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		variables = req.Variables
+		// bigint columns may come back as numbers or strings
+		w.Write([]byte(`{"data":{"events":[
+			{"transaction_version":"42","event_index":1,"type":"0xc0::tinypay::DepositMade","data":{"user":"0x1","amount":"5"}}
+		]}}`))
+	}))
+	defer server.Close()
+
+	ac := &AptosClient{indexerURL: server.URL}
+	events, err := ac.ModuleEvents(context.Background(), []string{"0xc0::tinypay::"}, 40, 3, 10)
+	if err != nil {
+		t.Fatalf("module events: %v", err)
+	}
+	if len(events) != 1 || events[0].Version != 42 || events[0].Index != 1 || events[0].Data["amount"] != "5" {
+		t.Errorf("unexpected events %+v", events)
+	}
+	types, _ := json.Marshal(variables["types"])
+	if string(types) != `[{"indexed_type":{"_like":"0xc0::tinypay::%"}}]` || variables["start"] != float64(40) ||
+		variables["start_index"] != float64(3) || variables["limit"] != float64(10) {
+		t.Errorf("unexpected query variables %v", variables)
+	}
+
+	if _, err := (&AptosClient{}).ModuleEvents(context.Background(), nil, 0, 0, 10); err == nil {
+		t.Error("expected an error without an indexer API")
+	}
+}
@@ -0,0 +1,131 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"tinypay-server/config"
+
+	"github.com/aptos-labs/aptos-go-sdk"
+	"github.com/aptos-labs/aptos-go-sdk/api"
+	"github.com/aptos-labs/aptos-go-sdk/crypto"
+)
+
+// acquireFeePayer takes the paymaster account that pays the gas of the next transaction. Without
+// a paymaster it returns nil and senders pay their own gas. The returned func gives the account back.
+func (ac *AptosClient) acquireFeePayer() (*aptosSigner, func(), error) {
+	if ac.paymasters == nil {
+		return nil, func() {}, nil
+	}
+	index, err := ac.paymasters.acquire()
+	if err != nil {
+		return nil, nil, err
+	}
+	return ac.feePayers[index], func() { ac.paymasters.release(index) }, nil
+}
+
+// feePayerBalance reads the APT balance, in octas, of the paymaster account at index
+func (ac *AptosClient) feePayerBalance(ctx context.Context, index int) (*big.Int, error) {
+	balance, err := ac.client.AccountAPTBalance(ac.feePayers[index].AccountAddress())
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetUint64(balance), nil
+}
+
+// GetPaymasterAddresses returns the addresses of the paymaster accounts
+func (ac *AptosClient) GetPaymasterAddresses() []string {
+	addresses := make([]string, len(ac.feePayers))
+	for i, feePayer := range ac.feePayers {
+		addresses[i] = feePayer.Address.String()
+	}
+	return addresses
+}
+
+// Paymasters returns the state of the paymaster accounts; none without a paymaster
+func (ac *AptosClient) Paymasters() []PaymasterKey {
+	if ac.paymasters == nil {
+		return nil
+	}
+	return ac.paymasters.states()
+}
+
+// PaymasterBalance reads the APT balance, in octas, of the paymaster account with address
+func (ac *AptosClient) PaymasterBalance(ctx context.Context, address string) (*big.Int, error) {
+	if ac.paymasters == nil {
+		return nil, fmt.Errorf("%w: %s on %s", ErrPaymasterNotFound, address, AptosNetworkName)
+	}
+	return ac.paymasters.readBalance(ctx, address)
+}
+
+// NativeUnit names the unit of paymaster balances
+func (ac *AptosClient) NativeUnit() string {
+	return "octas"
+}
+
+// PaymasterPool returns the configuration of the paymaster pool
+func (ac *AptosClient) PaymasterPool() config.PaymasterPool {
+	return ac.config.AptosPaymasters
+}
+
+// DrainPaymaster stops selecting a paymaster account as fee payer of new transactions
+func (ac *AptosClient) DrainPaymaster(address string) (*PaymasterKey, error) {
+	if ac.paymasters == nil {
+		return nil, fmt.Errorf("%w: %s on %s", ErrPaymasterNotFound, address, AptosNetworkName)
+	}
+	return ac.paymasters.setDraining(address, true)
+}
+
+// ResumePaymaster puts a drained paymaster account back into rotation
+func (ac *AptosClient) ResumePaymaster(address string) (*PaymasterKey, error) {
+	if ac.paymasters == nil {
+		return nil, fmt.Errorf("%w: %s on %s", ErrPaymasterNotFound, address, AptosNetworkName)
+	}
+	return ac.paymasters.setDraining(address, false)
+}
+
+// feePayerTransaction wraps rawTxn into a fee-payer transaction whose gas feePayer pays
+func feePayerTransaction(rawTxn *aptos.RawTransaction, feePayer aptos.AccountAddress) *aptos.RawTransactionWithData {
+	return &aptos.RawTransactionWithData{
+		Variant: aptos.MultiAgentWithFeePayerRawTransactionWithDataVariant,
+		Inner: &aptos.MultiAgentWithFeePayerRawTransactionWithData{
+			RawTxn:           rawTxn,
+			SecondarySigners: []aptos.AccountAddress{},
+			FeePayer:         &feePayer,
+		},
+	}
+}
+
+// simulate simulates rawTxn sent by sender, with feePayer paying its gas when not nil
+func (ac *AptosClient) simulate(rawTxn *aptos.RawTransaction, sender, feePayer *aptosSigner) ([]*api.UserTransaction, error) {
+	if feePayer == nil {
+		return ac.client.SimulateTransaction(rawTxn, sender)
+	}
+	address := feePayer.AccountAddress()
+	return ac.client.SimulateTransactionMultiAgent(feePayerTransaction(rawTxn, address), sender, aptos.FeePayer(&address))
+}
+
+// signTransaction signs rawTxn as sender and, when not nil, as feePayer. The sender authorizes
+// the entry function; the fee payer's signature only covers paying for gas.
+func signTransaction(rawTxn *aptos.RawTransaction, sender, feePayer *aptosSigner) (*aptos.SignedTransaction, error) {
+	if feePayer == nil {
+		return rawTxn.SignedTransaction(sender)
+	}
+
+	txn := feePayerTransaction(rawTxn, feePayer.AccountAddress())
+	senderAuth, err := txn.Sign(sender)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign as sender: %w", err)
+	}
+	feePayerAuth, err := txn.Sign(feePayer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign as fee payer: %w", err)
+	}
+	signed, ok := txn.ToFeePayerSignedTransaction(senderAuth, feePayerAuth, []crypto.AccountAuthenticator{})
+	if !ok {
+		return nil, errors.New("failed to assemble fee payer transaction")
+	}
+	return signed, nil
+}
@@ -0,0 +1,124 @@
+package client
+
+import (
+	"testing"
+
+	"tinypay-server/signer"
+	"tinypay-server/signer/signertest"
+
+	"github.com/aptos-labs/aptos-go-sdk"
+	"github.com/aptos-labs/aptos-go-sdk/crypto"
+)
+
+func newTestAptosAccount(t *testing.T) *aptosSigner {
+	t.Helper()
+	account, err := generatedAptosSigner()
+	if err != nil {
+		t.Fatalf("new account: %v", err)
+	}
+	return account
+}
+
+func TestAptosSignTransaction_PaymasterPaysAsFeePayer(t *testing.T) {
+	merchant, paymaster := newTestAptosAccount(t), newTestAptosAccount(t)
+	rawTxn := &aptos.RawTransaction{
+		Sender:                     merchant.AccountAddress(),
+		SequenceNumber:             4,
+		Payload:                    aptos.TransactionPayload{Payload: &aptos.EntryFunction{Module: aptos.ModuleId{Address: aptos.AccountOne, Name: "tinypay"}, Function: "complete_payment", ArgTypes: []aptos.TypeTag{}, Args: [][]byte{}}},
+		MaxGasAmount:               1000,
+		GasUnitPrice:               100,
+		ExpirationTimestampSeconds: 1700000000,
+		ChainId:                    4,
+	}
+
+	signed, err := signTransaction(rawTxn, merchant, paymaster)
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	if signed.Transaction.Sender != merchant.AccountAddress() {
+		t.Errorf("expected the merchant to send, got %s", signed.Transaction.Sender)
+	}
+	auth, ok := signed.Authenticator.Auth.(*aptos.FeePayerTransactionAuthenticator)
+	if signed.Authenticator.Variant != aptos.TransactionAuthenticatorFeePayer || !ok {
+		t.Fatalf("expected a fee payer authenticator, got variant %d", signed.Authenticator.Variant)
+	}
+	if *auth.FeePayer != paymaster.AccountAddress() {
+		t.Errorf("expected the paymaster as fee payer, got %s", auth.FeePayer)
+	}
+
+	// Both signatures cover the fee payer transaction, each by its own key
+	message, err := feePayerTransaction(rawTxn, paymaster.AccountAddress()).SigningMessage()
+	if err != nil {
+		t.Fatalf("signing message: %v", err)
+	}
+	if !auth.Sender.Verify(message) || auth.Sender.PubKey().ToHex() != merchant.PubKey().ToHex() {
+		t.Error("expected the merchant to sign as sender")
+	}
+	if !auth.FeePayerAuthenticator.Verify(message) || auth.FeePayerAuthenticator.PubKey().ToHex() != paymaster.PubKey().ToHex() {
+		t.Error("expected the paymaster to sign as fee payer")
+	}
+}
+
+func TestAptosSignTransaction_WithoutPaymaster(t *testing.T) {
+	merchant := newTestAptosAccount(t)
+	rawTxn := &aptos.RawTransaction{
+		Sender:  merchant.AccountAddress(),
+		Payload: aptos.TransactionPayload{Payload: &aptos.EntryFunction{Module: aptos.ModuleId{Address: aptos.AccountOne, Name: "tinypay"}, Function: "complete_payment", ArgTypes: []aptos.TypeTag{}, Args: [][]byte{}}},
+		ChainId: 4,
+	}
+
+	signed, err := signTransaction(rawTxn, merchant, nil)
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	if signed.Authenticator.Variant == aptos.TransactionAuthenticatorFeePayer {
+		t.Error("expected the merchant to pay its own gas without a paymaster")
+	}
+	if err := signed.Verify(); err != nil {
+		t.Errorf("verify: %v", err)
+	}
+}
+
+func TestAptosSignTransaction_RemoteSigner(t *testing.T) {
+	server := signertest.NewServer("token")
+	defer server.Close()
+	remote := signer.RemoteConfig{URL: server.URL, Token: "token"}
+
+	newRemoteAccount := func() *aptosSigner {
+		key, err := crypto.GenerateEd25519PrivateKey()
+		if err != nil {
+			t.Fatalf("generate key: %v", err)
+		}
+		account, err := newAptosSigner(signer.RemotePrefix+server.AddEd25519(key.Inner), remote)
+		if err != nil {
+			t.Fatalf("new remote account: %v", err)
+		}
+		local, _ := aptos.NewAccountFromSigner(key)
+		if account.AccountAddress() != local.AccountAddress() {
+			t.Fatalf("expected the remote account at %s, got %s", local.Address, account.Address)
+		}
+		return account
+	}
+	merchant, paymaster := newRemoteAccount(), newRemoteAccount()
+	rawTxn := &aptos.RawTransaction{
+		Sender:  merchant.AccountAddress(),
+		Payload: aptos.TransactionPayload{Payload: &aptos.EntryFunction{Module: aptos.ModuleId{Address: aptos.AccountOne, Name: "tinypay"}, Function: "complete_payment", ArgTypes: []aptos.TypeTag{}, Args: [][]byte{}}},
+		ChainId: 4,
+	}
+
+	signed, err := signTransaction(rawTxn, merchant, paymaster)
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	auth := signed.Authenticator.Auth.(*aptos.FeePayerTransactionAuthenticator)
+	message, err := feePayerTransaction(rawTxn, paymaster.AccountAddress()).SigningMessage()
+	if err != nil {
+		t.Fatalf("signing message: %v", err)
+	}
+	if !auth.Sender.Verify(message) || !auth.FeePayerAuthenticator.Verify(message) {
+		t.Error("expected valid sender and fee payer signatures")
+	}
+	if server.Requests() != 2 {
+		t.Errorf("expected the sender and fee payer signatures from the remote signer, got %d requests", server.Requests())
+	}
+}
@@ -0,0 +1,41 @@
+package client
+
+import (
+	"fmt"
+
+	"github.com/aptos-labs/aptos-go-sdk"
+)
+
+// transactionOptions returns the BuildTransaction options that price an Aptos transaction under the
+// fee policy, with the fee they price at. Static prices and limits default to the gas settings.
+func (ac *AptosClient) transactionOptions() ([]any, *Fee, error) {
+	policy := ac.config.AptosFees
+	fee := &Fee{
+		Mode:  feeMode(policy),
+		Unit:  FeeUnitOctasPerGas,
+		Price: ac.config.GasUnitPrice,
+		Limit: ac.config.MaxGasAmount,
+	}
+	if policy.Limit > 0 {
+		fee.Limit = policy.Limit
+	}
+
+	switch {
+	case policy.Estimated():
+		estimate, err := ac.client.EstimateGasPrice()
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to estimate gas price: %w", err)
+		}
+		fee.Price, fee.Capped = scaleFee(policy, estimate.GasEstimate, policy.MaxPrice)
+	case policy.Price > 0:
+		fee.Price, fee.Capped = scaleFee(policy, policy.Price, policy.MaxPrice)
+	default:
+		fee.Price, fee.Capped = scaleFee(policy, fee.Price, policy.MaxPrice)
+	}
+
+	return []any{
+		aptos.MaxGasAmount(fee.Limit),
+		aptos.GasUnitPrice(fee.Price),
+		aptos.ExpirationSeconds(aptosTransactionExpirationSeconds),
+	}, fee, nil
+}
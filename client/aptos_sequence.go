@@ -0,0 +1,230 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aptos-labs/aptos-go-sdk"
+)
+
+// aptosTransactionExpirationSeconds is how long a submitted transaction stays valid. A transaction
+// dropped from the mempool holds up its sequence number, and every later one, until it expires.
+const aptosTransactionExpirationSeconds = 60
+
+// aptosSequenceResyncInterval is how long an idle allocator trusts its local sequence number
+// before reading the account again, which picks up transactions sent with the key from elsewhere
+const aptosSequenceResyncInterval = 30 * time.Second
+
+// sequenceManager hands out sequence numbers for one Aptos account, so several transactions of the
+// account can wait in the mempool at once. The chain only reports the committed sequence number,
+// so submitted transactions are tracked until they commit or expire.
+type sequenceManager struct {
+	fetch     func() (uint64, error) // committed sequence number of the account
+	mu        sync.Mutex
+	synced    bool
+	syncedAt  time.Time
+	next      uint64               // lowest sequence number never handed out
+	free      []uint64             // handed-out sequence numbers that were not used, reused lowest first
+	inFlight  map[uint64]struct{}  // handed out, not submitted yet
+	submitted map[uint64]time.Time // accepted or possibly accepted by the node, with their expiry
+	now       func() time.Time
+}
+
+func newSequenceManager(fetch func() (uint64, error)) *sequenceManager {
+	return &sequenceManager{
+		fetch:     fetch,
+		inFlight:  make(map[uint64]struct{}),
+		submitted: make(map[uint64]time.Time),
+		now:       time.Now,
+	}
+}
+
+// acquire returns the sequence number for the next transaction. Every number must be passed to release.
+func (s *sequenceManager) acquire() (uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	idle := len(s.inFlight) == 0 && s.now().Sub(s.syncedAt) >= aptosSequenceResyncInterval
+	if !s.synced || idle || s.hasExpired() {
+		if err := s.resync(); err != nil {
+			return 0, err
+		}
+	}
+
+	var seq uint64
+	if len(s.free) > 0 {
+		seq, s.free = s.free[0], s.free[1:]
+	} else {
+		seq = s.next
+		s.next++
+	}
+	s.inFlight[seq] = struct{}{}
+	return seq, nil
+}
+
+// release records the outcome of submitting a transaction with seq that expires at expiresAt
+func (s *sequenceManager) release(seq uint64, expiresAt time.Time, submitErr error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.inFlight, seq)
+
+	var httpErr *aptos.HttpError
+	switch {
+	case submitErr == nil:
+		s.submitted[seq] = expiresAt
+	case isSequenceTooNew(submitErr):
+		// The account is behind the local view, for example after transactions expired elsewhere
+		log.Printf("Sequence number %d rejected as too new, resyncing", seq)
+		s.free = insertSorted(s.free, seq)
+		s.synced = false
+	case isSequenceUsed(submitErr):
+		// Another transaction holds the number; keep it reserved until that one commits or expires
+		log.Printf("Sequence number %d already used (%v), resyncing", seq, submitErr)
+		s.submitted[seq] = expiresAt
+		s.synced = false
+	case errors.As(submitErr, &httpErr) && httpErr.StatusCode < http.StatusInternalServerError:
+		// The node refused the transaction, so the number was not used
+		s.free = insertSorted(s.free, seq)
+	default:
+		// The transaction may have reached the mempool; it is reserved until it expires
+		s.submitted[seq] = expiresAt
+	}
+}
+
+// abandon frees a sequence number whose transaction was never submitted
+func (s *sequenceManager) abandon(seq uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.inFlight, seq)
+	s.free = insertSorted(s.free, seq)
+}
+
+// hasExpired reports whether a submitted transaction has passed its expiry
+func (s *sequenceManager) hasExpired() bool {
+	now := s.now()
+	for _, expiresAt := range s.submitted {
+		if !now.Before(expiresAt) {
+			return true
+		}
+	}
+	return false
+}
+
+// resync reloads the committed sequence number. Numbers below it are used. Submitted transactions
+// that expired without committing leave a gap that blocks every later transaction of the account,
+// so their numbers are reused, as are numbers that are tracked nowhere.
+func (s *sequenceManager) resync() error {
+	committed, err := s.fetch()
+	if err != nil {
+		return fmt.Errorf("failed to get account sequence number: %w", err)
+	}
+
+	free := s.free[:0]
+	for _, seq := range s.free {
+		if seq >= committed && seq < s.next {
+			free = append(free, seq)
+		}
+	}
+	s.free = free
+
+	now := s.now()
+	for seq, expiresAt := range s.submitted {
+		switch {
+		case seq < committed:
+			delete(s.submitted, seq)
+		case !now.Before(expiresAt):
+			log.Printf("Transaction with sequence number %d expired without committing, reusing it", seq)
+			delete(s.submitted, seq)
+			s.free = insertSorted(s.free, seq)
+		}
+	}
+
+	if committed >= s.next {
+		s.next = committed
+	} else {
+		for seq := committed; seq < s.next; seq++ {
+			_, inFlight := s.inFlight[seq]
+			_, submitted := s.submitted[seq]
+			if inFlight || submitted || containsSorted(s.free, seq) {
+				continue
+			}
+			log.Printf("Sequence number gap detected at %d, reusing it", seq)
+			s.free = insertSorted(s.free, seq)
+		}
+	}
+
+	s.synced = true
+	s.syncedAt = now
+	return nil
+}
+
+// isSequenceTooNew reports whether a submission failed because the account is not at its sequence number yet
+func isSequenceTooNew(err error) bool {
+	return strings.Contains(strings.ToUpper(err.Error()), "SEQUENCE_NUMBER_TOO_NEW")
+}
+
+// isSequenceUsed reports whether a submission failed because its sequence number is taken
+func isSequenceUsed(err error) bool {
+	msg := strings.ToUpper(err.Error())
+	return strings.Contains(msg, "SEQUENCE_NUMBER_TOO_OLD") || strings.Contains(msg, "ALREADY IN MEMPOOL")
+}
+
+// sequencesFor returns the sequence allocator of one of the client's accounts
+func (ac *AptosClient) sequencesFor(account *aptosSigner) *sequenceManager {
+	ac.sequencesMu.Lock()
+	defer ac.sequencesMu.Unlock()
+
+	address := account.AccountAddress()
+	manager, ok := ac.sequences[address]
+	if !ok {
+		manager = newSequenceManager(func() (uint64, error) {
+			info, err := ac.client.Account(address)
+			if err != nil {
+				if strings.Contains(err.Error(), "account_not_found") {
+					return 0, nil
+				}
+				return 0, err
+			}
+			return info.SequenceNumber()
+		})
+		ac.sequences[address] = manager
+	}
+	return manager
+}
+
+// submitSequenced signs rawTxn as caller, and as feePayer when not nil, with a sequence number
+// from the caller's allocator and submits it. rawTxn is built and simulated beforehand with the committed sequence number, since
+// the node only simulates transactions that could run next.
+func (ac *AptosClient) submitSequenced(caller, feePayer *aptosSigner, rawTxn *aptos.RawTransaction) (string, error) {
+	// The generated stand-in for an unusable merchant key is unknown to the contract
+	if caller == ac.merchantAccount && ac.merchantErr != nil {
+		return "", fmt.Errorf("aptos merchant key not usable: %w", ac.merchantErr)
+	}
+	sequences := ac.sequencesFor(caller)
+	seq, err := sequences.acquire()
+	if err != nil {
+		return "", err
+	}
+
+	sequenced := *rawTxn
+	sequenced.SequenceNumber = seq
+	expiresAt := time.Unix(int64(sequenced.ExpirationTimestampSeconds), 0)
+
+	signedTxn, err := signTransaction(&sequenced, caller, feePayer)
+	if err != nil {
+		sequences.abandon(seq)
+		return "", fmt.Errorf("failed to sign transaction: %w", err)
+	}
+
+	submitResult, err := ac.client.SubmitTransaction(signedTxn)
+	sequences.release(seq, expiresAt, err)
+	if err != nil {
+		return "", fmt.Errorf("failed to submit transaction: %w", err)
+	}
+	return submitResult.Hash, nil
+}
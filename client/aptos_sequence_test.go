@@ -0,0 +1,137 @@
+package client
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aptos-labs/aptos-go-sdk"
+)
+
+// fakeSequences reports a fixed committed sequence number and counts lookups
+type fakeSequences struct {
+	mu        sync.Mutex
+	committed uint64
+	calls     int
+}
+
+func (f *fakeSequences) fetch() (uint64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+	return f.committed, nil
+}
+
+func newTestSequenceManager(committed uint64) (*sequenceManager, *fakeSequences, *time.Time) {
+	source := &fakeSequences{committed: committed}
+	s := newSequenceManager(source.fetch)
+	now := time.Now()
+	s.now = func() time.Time { return now }
+	return s, source, &now
+}
+
+func TestSequenceManager_HandsOutDistinctNumbers(t *testing.T) {
+	s, source, now := newTestSequenceManager(4)
+
+	var mu sync.Mutex
+	seen := make(map[uint64]bool)
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			seq, err := s.acquire()
+			if err != nil {
+				t.Errorf("acquire: %v", err)
+				return
+			}
+			mu.Lock()
+			if seen[seq] {
+				t.Errorf("sequence number %d handed out twice", seq)
+			}
+			seen[seq] = true
+			mu.Unlock()
+			s.release(seq, now.Add(time.Minute), nil)
+		}()
+	}
+	wg.Wait()
+
+	for seq := uint64(4); seq < 24; seq++ {
+		if !seen[seq] {
+			t.Errorf("sequence number %d was skipped", seq)
+		}
+	}
+	if source.calls != 1 {
+		t.Errorf("expected one lookup, got %d", source.calls)
+	}
+}
+
+func TestSequenceManager_ReusesRejectedNumbers(t *testing.T) {
+	s, _, now := newTestSequenceManager(0)
+	expiry := now.Add(time.Minute)
+
+	first, _ := s.acquire()
+	second, _ := s.acquire()
+	s.release(second, expiry, nil)
+	s.release(first, expiry, &aptos.HttpError{StatusCode: http.StatusBadRequest, Body: []byte(`{"error_code":"invalid_input"}`)})
+
+	if seq, _ := s.acquire(); seq != first {
+		t.Errorf("expected the rejected number %d to be reused, got %d", first, seq)
+	}
+
+	third, _ := s.acquire()
+	s.abandon(third)
+	if seq, _ := s.acquire(); seq != third {
+		t.Errorf("expected the abandoned number %d to be reused, got %d", third, seq)
+	}
+}
+
+func TestSequenceManager_ReusesExpiredNumbers(t *testing.T) {
+	s, source, now := newTestSequenceManager(0)
+
+	for i := 0; i < 3; i++ {
+		seq, _ := s.acquire()
+		s.release(seq, now.Add(time.Minute), nil)
+	}
+
+	// Only the first transaction committed; the second was dropped and the third waits behind it
+	source.committed = 1
+	*now = now.Add(time.Minute)
+	for _, want := range []uint64{1, 2, 3} {
+		seq, _ := s.acquire()
+		if seq != want {
+			t.Errorf("expected sequence number %d, got %d", want, seq)
+		}
+	}
+}
+
+func TestSequenceManager_SequenceErrors(t *testing.T) {
+	s, source, now := newTestSequenceManager(5)
+	expiry := now.Add(time.Minute)
+
+	seq, _ := s.acquire()
+	source.committed = 9 // the key was used from elsewhere
+	s.release(seq, expiry, &aptos.HttpError{StatusCode: http.StatusBadRequest, Body: []byte(`{"vm_error_code":"SEQUENCE_NUMBER_TOO_OLD"}`)})
+	if seq, _ := s.acquire(); seq != 9 {
+		t.Errorf("expected sequence number 9 after resync, got %d", seq)
+	}
+
+	seq, _ = s.acquire()
+	source.committed = 3 // transactions sent from elsewhere expired
+	s.release(seq, expiry, &aptos.HttpError{StatusCode: http.StatusBadRequest, Body: []byte(`{"vm_error_code":"SEQUENCE_NUMBER_TOO_NEW"}`)})
+	if seq, _ := s.acquire(); seq != 3 {
+		t.Errorf("expected the gap at 3 to be filled first, got %d", seq)
+	}
+}
+
+func TestSequenceManager_ReservesPossiblySubmittedNumbers(t *testing.T) {
+	s, _, now := newTestSequenceManager(0)
+
+	seq, _ := s.acquire()
+	s.release(seq, now.Add(time.Minute), errors.New("connection reset by peer"))
+	if next, _ := s.acquire(); next == seq {
+		t.Errorf("expected %d to stay reserved until it expires", seq)
+	}
+}
@@ -0,0 +1,93 @@
+package client
+
+import (
+	"context"
+	"fmt"
+
+	"tinypay-server/signer"
+
+	"github.com/aptos-labs/aptos-go-sdk"
+	"github.com/aptos-labs/aptos-go-sdk/crypto"
+)
+
+// aptosSigner is an Aptos account whose Ed25519 key is held by a signer.Signer, locally or on the
+// remote signer. It implements the SDK's TransactionSigner, so the SDK builds authenticators and
+// simulations for it as it does for *aptos.Account.
+type aptosSigner struct {
+	Address   aptos.AccountAddress
+	signer    signer.Signer
+	publicKey *crypto.Ed25519PublicKey
+}
+
+var _ aptos.TransactionSigner = (*aptosSigner)(nil)
+
+// newAptosSigner creates the account of a configured key field, a hex private key or
+// remote:<public key>
+func newAptosSigner(value string, remote signer.RemoteConfig) (*aptosSigner, error) {
+	s, err := signer.NewAptos(value, remote)
+	if err != nil {
+		return nil, err
+	}
+	return aptosSignerFor(s)
+}
+
+// aptosSignerFor derives the account address of an Ed25519 signer
+func aptosSignerFor(s signer.Signer) (*aptosSigner, error) {
+	if s.Scheme() != signer.SchemeEd25519 {
+		return nil, fmt.Errorf("aptos accounts need an %s key, got %s", signer.SchemeEd25519, s.Scheme())
+	}
+	publicKey := &crypto.Ed25519PublicKey{}
+	if err := publicKey.FromBytes(s.PublicKey()); err != nil {
+		return nil, fmt.Errorf("invalid signer public key: %w", err)
+	}
+	account := &aptosSigner{signer: s, publicKey: publicKey}
+	account.Address.FromAuthKey(publicKey.AuthKey())
+	return account, nil
+}
+
+// Sign signs a transaction's signing message and wraps the signature into an authenticator
+func (a *aptosSigner) Sign(msg []byte) (*crypto.AccountAuthenticator, error) {
+	signature, err := a.SignMessage(msg)
+	if err != nil {
+		return nil, err
+	}
+	return &crypto.AccountAuthenticator{
+		Variant: crypto.AccountAuthenticatorEd25519,
+		Auth: &crypto.Ed25519Authenticator{
+			PubKey: a.publicKey,
+			Sig:    signature.(*crypto.Ed25519Signature),
+		},
+	}, nil
+}
+
+// SignMessage signs msg through the signer. The SDK gives no context, so a remote request is only
+// bounded by the remote signer's timeout.
+func (a *aptosSigner) SignMessage(msg []byte) (crypto.Signature, error) {
+	raw, err := a.signer.Sign(context.Background(), msg)
+	if err != nil {
+		return nil, err
+	}
+	signature := &crypto.Ed25519Signature{}
+	if err := signature.FromBytes(raw); err != nil {
+		return nil, fmt.Errorf("invalid Ed25519 signature: %w", err)
+	}
+	return signature, nil
+}
+
+// SimulationAuthenticator returns an authenticator with an empty signature, as the node expects
+// for simulations
+func (a *aptosSigner) SimulationAuthenticator() *crypto.AccountAuthenticator {
+	return &crypto.AccountAuthenticator{
+		Variant: crypto.AccountAuthenticatorEd25519,
+		Auth: &crypto.Ed25519Authenticator{
+			PubKey: a.publicKey,
+			Sig:    &crypto.Ed25519Signature{},
+		},
+	}
+}
+
+func (a *aptosSigner) AuthKey() *crypto.AuthenticationKey { return a.publicKey.AuthKey() }
+
+func (a *aptosSigner) PubKey() crypto.PublicKey { return a.publicKey }
+
+func (a *aptosSigner) AccountAddress() aptos.AccountAddress { return a.Address }
@@ -0,0 +1,208 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"tinypay-server/config"
+)
+
+// AptosNetworkName is the network name the Aptos client is registered under
+const AptosNetworkName = "aptos-testnet"
+
+// ErrTransactionNotFound is returned by transaction lookups when the chain does not know the hash,
+// as opposed to a transaction that is known but still pending
+var ErrTransactionNotFound = errors.New("transaction not found")
+
+// ErrBalanceUnavailable is returned by balance lookups for currencies whose deposits the
+// contract does not track per payer
+var ErrBalanceUnavailable = errors.New("balance not tracked for this currency")
+
+// ErrTransactionNotPending is returned when a transaction to replace was already mined
+var ErrTransactionNotPending = errors.New("transaction is no longer pending")
+
+// Payment describes a complete_payment call independently of the target chain
+type Payment struct {
+	PayerAddr  string
+	PayeeAddr  string
+	Amount     uint64
+	Otp        string // hex OTP exactly as submitted by the payer
+	Currency   string
+	CommitHash []byte // optional merchant precommit hash, nil when not used
+	Sender     string // paymaster key that has to send the payment, such as the one that made its precommit; empty lets the backend choose
+}
+
+// Submission is the result of handing a payment to the chain
+type Submission struct {
+	TxHash string
+	Fee    *Fee   // price the transaction was sent with; nil when the chain client does not report it
+	Sender string // paymaster key that sent or paid for the transaction; empty when the chain client does not report it
+}
+
+// Precommit is a merchant commitment to a payment, recorded on chain before the payment is completed
+type Precommit struct {
+	CommitHash []byte
+	TxHash     string
+	ExpiresAt  time.Time // zero when the chain does not report an expiry
+	Sender     string    // paymaster key that made the precommit and has to complete it; empty when any key may
+}
+
+// Precommitter is implemented by backends whose contract supports the merchant precommit flow.
+// A later SendPayment with Payment.CommitHash set completes the precommitted payment.
+type Precommitter interface {
+	// Precommit computes the chain-specific commit hash of payment and submits merchant_precommit
+	Precommit(ctx context.Context, payment *Payment) (*Precommit, error)
+}
+
+// BalanceReader is implemented by backends that can read how much a payer has deposited with the contract
+type BalanceReader interface {
+	// GetBalance returns the payer's deposited balance of currency in its smallest unit
+	GetBalance(ctx context.Context, userAddress, currency string) (uint64, error)
+}
+
+// TransactionReplacement reports that a new transaction stands for a submitted payment: a copy
+// with higher fees, a cancellation, or an earlier version that was mined instead of its replacement
+type TransactionReplacement struct {
+	Original    string // hash the payment was first submitted with
+	Replacement string // hash that now stands for the payment
+	Fee         *Fee   // price of the replacement
+	Cancelled   bool   // the replacement is a zero-value self-send that voids the payment
+}
+
+// TransactionReplacer is implemented by backends that rebroadcast stuck transactions with the
+// same nonce, so a payment's transaction hash can change after submission
+type TransactionReplacer interface {
+	// OnReplaced registers fn to be called for every replacement of a submitted payment
+	OnReplaced(fn func(TransactionReplacement))
+	// CancelTransaction replaces a pending transaction with a zero-value self-send
+	CancelTransaction(ctx context.Context, txHash string) (*TransactionReplacement, error)
+}
+
+// AddressNormalizer is implemented by backends whose address format allows several spellings of
+// the same account, such as mixed-case hex or short Aptos addresses
+type AddressNormalizer interface {
+	// NormalizeAddress returns the canonical spelling of an account address
+	NormalizeAddress(address string) string
+}
+
+// NormalizeAddress returns the canonical spelling of an address on the backend's chain. Backends
+// without a normalizer only have 0x-prefixed hex addresses lowercased.
+func NormalizeAddress(backend ChainBackend, address string) string {
+	if normalizer, ok := backend.(AddressNormalizer); ok {
+		return normalizer.NormalizeAddress(address)
+	}
+	address = strings.TrimSpace(address)
+	if strings.HasPrefix(address, "0x") || strings.HasPrefix(address, "0X") {
+		return strings.ToLower(address)
+	}
+	return address
+}
+
+// ConfigChecker is implemented by backends that can be registered while settings that payments
+// need are missing, such as the contract address; their network is then reported unavailable
+type ConfigChecker interface {
+	// CheckConfig returns an error naming the first missing setting
+	CheckConfig() error
+}
+
+// ChainBackend is implemented by every chain client the API server can route payments to
+type ChainBackend interface {
+	// GetNetwork returns the network name the backend is registered under
+	GetNetwork() string
+	// GetConfig returns the configuration used by the backend
+	GetConfig() *config.Config
+	// SupportedCurrencies lists the currency symbols accepted on this network
+	SupportedCurrencies() []string
+	// DefaultCurrency returns the currency used when a request does not name one
+	DefaultCurrency() string
+	// ResolveCoinType maps a currency symbol to the coin type reported to API callers
+	ResolveCoinType(currency string) (string, error)
+	// SendPayment submits a complete_payment transaction
+	SendPayment(ctx context.Context, payment *Payment) (*Submission, error)
+	// GetTransactionDetails looks up a previously submitted transaction
+	GetTransactionDetails(ctx context.Context, txHash string) (*TransactionInfo, error)
+	// GetUserLimits reads the payer limits stored by the TinyPay contract
+	GetUserLimits(ctx context.Context, userAddress string) (*UserLimits, error)
+}
+
+// BackendRegistry maps network names to chain backends
+type BackendRegistry struct {
+	mu       sync.RWMutex
+	backends map[string]ChainBackend
+	networks []string
+}
+
+// NewBackendRegistry creates an empty backend registry
+func NewBackendRegistry() *BackendRegistry {
+	return &BackendRegistry{
+		backends: make(map[string]ChainBackend),
+	}
+}
+
+// Register adds a backend under its network name, replacing any previous backend for that network
+func (r *BackendRegistry) Register(backend ChainBackend) {
+	key := strings.ToLower(backend.GetNetwork())
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.backends[key]; !exists {
+		r.networks = append(r.networks, backend.GetNetwork())
+	}
+	r.backends[key] = backend
+}
+
+// Get returns the backend registered for a network, or nil if there is none
+func (r *BackendRegistry) Get(network string) ChainBackend {
+	if r == nil {
+		return nil
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.backends[strings.ToLower(network)]
+}
+
+// Networks returns the registered network names in registration order
+func (r *BackendRegistry) Networks() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	networks := make([]string, len(r.networks))
+	copy(networks, r.networks)
+	return networks
+}
+
+var (
+	_ ChainBackend = (*AptosClient)(nil)
+	_ ChainBackend = (*EVMClient)(nil)
+	_ ChainBackend = (*SolanaClient)(nil)
+
+	_ Precommitter = (*AptosClient)(nil)
+	_ Precommitter = (*EVMClient)(nil)
+
+	_ TailReader = (*AptosClient)(nil)
+	_ TailReader = (*EVMClient)(nil)
+	_ TailReader = (*SolanaClient)(nil)
+
+	_ BalanceReader = (*AptosClient)(nil)
+	_ BalanceReader = (*EVMClient)(nil)
+	_ BalanceReader = (*SolanaClient)(nil)
+
+	_ AddressNormalizer = (*AptosClient)(nil)
+	_ AddressNormalizer = (*EVMClient)(nil)
+	_ AddressNormalizer = (*SolanaClient)(nil)
+
+	_ TransactionReplacer = (*EVMClient)(nil)
+
+	_ PaymasterRotator = (*AptosClient)(nil)
+	_ PaymasterRotator = (*EVMClient)(nil)
+	_ PaymasterRotator = (*SolanaClient)(nil)
+
+	_ PaymasterFunds = (*AptosClient)(nil)
+	_ PaymasterFunds = (*EVMClient)(nil)
+	_ PaymasterFunds = (*SolanaClient)(nil)
+)
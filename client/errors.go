@@ -0,0 +1,252 @@
+package client
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+
+	tinypaybindings "tinypay-server/binds/tinypay"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	gethrpc "github.com/ethereum/go-ethereum/rpc"
+	"github.com/gagliardetto/solana-go/rpc/jsonrpc"
+)
+
+// FailureReason classifies why a chain rejected a payment, independently of the chain
+type FailureReason string
+
+const (
+	FailureInvalidOTP          FailureReason = "invalid_otp"
+	FailureLimitExceeded       FailureReason = "limit_exceeded"
+	FailureInsufficientBalance FailureReason = "insufficient_balance"
+	FailureInvalidAmount       FailureReason = "invalid_amount"
+	FailureUnsupportedCurrency FailureReason = "unsupported_currency"
+	FailurePrecommit           FailureReason = "precommit"
+	FailureUnknown             FailureReason = "unknown"
+)
+
+// ChainError is a rejection decoded from a chain-specific error format
+type ChainError struct {
+	Reason  FailureReason
+	Code    string // chain-specific error name: Move abort code, Solidity error or Anchor error code
+	Message string // the reason exactly as reported by the chain
+}
+
+func (e *ChainError) Error() string {
+	if e.Message == "" || e.Message == e.Code {
+		return e.Code
+	}
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+// failureKeywords classifies an error name or revert string. Entries are checked in order,
+// so more specific keywords come first.
+var failureKeywords = []struct {
+	keywords []string
+	reason   FailureReason
+}{
+	{[]string{"COMMIT"}, FailurePrecommit},
+	{[]string{"OTP", "OPT", "TAIL"}, FailureInvalidOTP},
+	{[]string{"INSUFFICIENT", "BALANCE", "FUNDS", "LAMPORTS"}, FailureInsufficientBalance},
+	{[]string{"LIMIT", "EXCEED"}, FailureLimitExceeded},
+	{[]string{"AMOUNT", "ZERO"}, FailureInvalidAmount},
+	{[]string{"COIN", "TOKEN", "CURRENCY", "ASSET", "MINT"}, FailureUnsupportedCurrency},
+}
+
+// classifyFailure maps an error name or message to a FailureReason
+func classifyFailure(text string) FailureReason {
+	normalized := strings.ToUpper(strings.NewReplacer("_", "", " ", "", "-", "").Replace(text))
+	for _, entry := range failureKeywords {
+		for _, keyword := range entry.keywords {
+			if strings.Contains(normalized, keyword) {
+				return entry.reason
+			}
+		}
+	}
+	return FailureUnknown
+}
+
+var (
+	// Move abort in 0x1::coin: EINSUFFICIENT_BALANCE(0x10006): Not enough coins to complete transaction
+	aptosNamedAbort = regexp.MustCompile(`Move abort in (0x[0-9a-fA-F]+::\w+): (\w+)\((0x[0-9a-fA-F]+)\)(?::\s*(.*))?`)
+	// Move abort in 0xcafe::tinypay: 0x3
+	aptosNumericAbort = regexp.MustCompile(`Move abort in (0x[0-9a-fA-F]+::\w+): (0x[0-9a-fA-F]+|\d+)`)
+)
+
+// aptosFrameworkAborts names the framework abort reasons a payment can run into when the
+// VM status only carries the numeric code. Keys are module name and the reason (lower 16 bits).
+var aptosFrameworkAborts = map[string]map[uint64]string{
+	"coin":                   {6: "EINSUFFICIENT_BALANCE"},
+	"fungible_asset":         {4: "EINSUFFICIENT_BALANCE"},
+	"primary_fungible_store": {4: "EINSUFFICIENT_BALANCE"},
+}
+
+// DecodeAptosVMStatus decodes the VM status of a failed Aptos transaction or simulation.
+// It returns nil for successful statuses.
+func DecodeAptosVMStatus(vmStatus string) *ChainError {
+	vmStatus = strings.TrimSpace(vmStatus)
+	if vmStatus == "" || strings.EqualFold(vmStatus, "Executed successfully") {
+		return nil
+	}
+
+	if m := aptosNamedAbort.FindStringSubmatch(vmStatus); m != nil {
+		message := m[4]
+		if message == "" {
+			message = vmStatus
+		}
+		return &ChainError{Reason: classifyFailure(m[2]), Code: m[2], Message: message}
+	}
+
+	if m := aptosNumericAbort.FindStringSubmatch(vmStatus); m != nil {
+		code, err := strconv.ParseUint(strings.TrimPrefix(m[2], "0x"), 16, 64)
+		if !strings.HasPrefix(m[2], "0x") {
+			code, err = strconv.ParseUint(m[2], 10, 64)
+		}
+		if err == nil {
+			module := m[1][strings.LastIndex(m[1], "::")+2:]
+			if name, ok := aptosFrameworkAborts[module][code&0xffff]; ok {
+				return &ChainError{Reason: classifyFailure(name), Code: name, Message: vmStatus}
+			}
+		}
+		return &ChainError{Reason: FailureUnknown, Code: m[1] + ":" + m[2], Message: vmStatus}
+	}
+
+	// Non-abort statuses such as OUT_OF_GAS or INSUFFICIENT_BALANCE_FOR_TRANSACTION_FEE. The
+	// latter concerns the fee payer, not the payer's TinyPay balance.
+	code := strings.Fields(vmStatus)[0]
+	if strings.Contains(code, "TRANSACTION_FEE") {
+		return &ChainError{Reason: FailureUnknown, Code: code, Message: vmStatus}
+	}
+	return &ChainError{Reason: classifyFailure(code), Code: code, Message: vmStatus}
+}
+
+var (
+	// revertSelector is the selector of Error(string), used by require and revert with a message
+	revertSelector = []byte{0x08, 0xc3, 0x79, 0xa0}
+	// panicSelector is the selector of Panic(uint256), used for assertion and arithmetic failures
+	panicSelector = []byte{0x4e, 0x48, 0x7b, 0x71}
+)
+
+// DecodeEVMError decodes a reverted call or gas estimation of the TinyPay contract: custom errors
+// declared in the contract ABI, Error(string) revert reasons and Panic(uint256) codes.
+// It returns nil when err does not carry a revert.
+func DecodeEVMError(err error) *ChainError {
+	if err == nil {
+		return nil
+	}
+
+	var dataErr gethrpc.DataError
+	if errors.As(err, &dataErr) {
+		if hexData, ok := dataErr.ErrorData().(string); ok {
+			if data, decodeErr := hexutil.Decode(hexData); decodeErr == nil && len(data) >= 4 {
+				return decodeEVMRevertData(data)
+			}
+		}
+	}
+
+	// Nodes that do not return revert data still report the reason in the message
+	message := err.Error()
+	if idx := strings.Index(message, "execution reverted"); idx >= 0 {
+		reason := strings.TrimSpace(strings.TrimPrefix(message[idx+len("execution reverted"):], ":"))
+		if reason == "" {
+			return &ChainError{Reason: FailureUnknown, Code: "Revert", Message: "execution reverted"}
+		}
+		return &ChainError{Reason: classifyFailure(reason), Code: "Error", Message: reason}
+	}
+	return nil
+}
+
+// decodeEVMRevertData decodes raw revert data returned by the contract
+func decodeEVMRevertData(data []byte) *ChainError {
+	selector := data[:4]
+	switch {
+	case bytes.Equal(selector, revertSelector):
+		reason, err := abi.UnpackRevert(data)
+		if err != nil {
+			break
+		}
+		return &ChainError{Reason: classifyFailure(reason), Code: "Error", Message: reason}
+	case bytes.Equal(selector, panicSelector):
+		code := fmt.Sprintf("0x%x", new(big.Int).SetBytes(data[4:]))
+		return &ChainError{Reason: FailureUnknown, Code: "Panic(" + code + ")", Message: "contract panicked with code " + code}
+	}
+
+	if contractABI, err := tinypaybindings.TinypayMetaData.GetAbi(); err == nil {
+		for name, abiErr := range contractABI.Errors {
+			if !bytes.Equal(abiErr.ID[:4], selector) {
+				continue
+			}
+			message := name
+			if args, err := abiErr.Unpack(data); err == nil {
+				message = fmt.Sprintf("%s%v", name, args)
+			}
+			return &ChainError{Reason: classifyFailure(name), Code: name, Message: message}
+		}
+	}
+	return &ChainError{Reason: FailureUnknown, Code: hexutil.Encode(selector), Message: "unrecognized revert " + hexutil.Encode(data)}
+}
+
+var (
+	// Program log: AnchorError thrown in programs/tinypay/src/lib.rs:120. Error Code: InvalidOtp. Error Number: 6001. Error Message: Invalid OTP.
+	anchorErrorLog = regexp.MustCompile(`Error Code: (\w+)\. Error Number: (\d+)\. Error Message: (.*?)\.?$`)
+	// Transaction simulation failed: Error processing Instruction 0: custom program error: 0x1771
+	solanaCustomError = regexp.MustCompile(`custom program error: (0x[0-9a-fA-F]+)`)
+)
+
+// DecodeSolanaLogs decodes the failure reported in a transaction's program logs: Anchor errors
+// raised by the TinyPay program and balance failures of the system and token programs
+func DecodeSolanaLogs(logs []string) *ChainError {
+	for _, line := range logs {
+		if m := anchorErrorLog.FindStringSubmatch(line); m != nil {
+			return &ChainError{Reason: classifyFailure(m[1]), Code: m[1], Message: m[3]}
+		}
+	}
+	for _, line := range logs {
+		switch {
+		case strings.Contains(line, "Transfer: insufficient lamports"):
+			return &ChainError{Reason: FailureInsufficientBalance, Code: "InsufficientLamports", Message: strings.TrimPrefix(line, "Program log: ")}
+		case strings.Contains(line, "Error: insufficient funds"):
+			return &ChainError{Reason: FailureInsufficientBalance, Code: "InsufficientFunds", Message: strings.TrimPrefix(line, "Program log: ")}
+		}
+	}
+	return nil
+}
+
+// DecodeSolanaError decodes a failed transaction submission. Preflight failures carry the
+// simulation logs in the RPC error data. It returns nil when err is not a program failure.
+func DecodeSolanaError(err error) *ChainError {
+	if err == nil {
+		return nil
+	}
+
+	var rpcErr *jsonrpc.RPCError
+	if errors.As(err, &rpcErr) {
+		if data, ok := rpcErr.Data.(map[string]interface{}); ok {
+			if rawLogs, ok := data["logs"].([]interface{}); ok {
+				logs := make([]string, 0, len(rawLogs))
+				for _, line := range rawLogs {
+					if s, ok := line.(string); ok {
+						logs = append(logs, s)
+					}
+				}
+				if chainErr := DecodeSolanaLogs(logs); chainErr != nil {
+					return chainErr
+				}
+			}
+		}
+		if m := solanaCustomError.FindStringSubmatch(rpcErr.Message); m != nil {
+			return &ChainError{Reason: FailureUnknown, Code: "Custom(" + m[1] + ")", Message: rpcErr.Message}
+		}
+		return nil
+	}
+
+	if m := solanaCustomError.FindStringSubmatch(err.Error()); m != nil {
+		return &ChainError{Reason: FailureUnknown, Code: "Custom(" + m[1] + ")", Message: err.Error()}
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/gagliardetto/solana-go/rpc/jsonrpc"
+)
+
+func TestDecodeAptosVMStatus(t *testing.T) {
+	cases := []struct {
+		vmStatus string
+		reason   FailureReason
+		code     string
+	}{
+		{"Move abort in 0xcafe::tinypay: E_INVALID_OTP(0x10003): The provided OTP does not match the tail", FailureInvalidOTP, "E_INVALID_OTP"},
+		{"Move abort in 0xcafe::tinypay: E_EXCEEDS_PAYMENT_LIMIT(0x10005): ", FailureLimitExceeded, "E_EXCEEDS_PAYMENT_LIMIT"},
+		{"Move abort in 0x1::fungible_asset: EINSUFFICIENT_BALANCE(0x10004): Insufficient balance", FailureInsufficientBalance, "EINSUFFICIENT_BALANCE"},
+		{"Move abort in 0x1::coin: 0x10006", FailureInsufficientBalance, "EINSUFFICIENT_BALANCE"},
+		{"Move abort in 0xcafe::tinypay: 0x7", FailureUnknown, "0xcafe::tinypay:0x7"},
+		{"OUT_OF_GAS", FailureUnknown, "OUT_OF_GAS"},
+		{"INSUFFICIENT_BALANCE_FOR_TRANSACTION_FEE", FailureUnknown, "INSUFFICIENT_BALANCE_FOR_TRANSACTION_FEE"},
+	}
+	for _, tc := range cases {
+		got := DecodeAptosVMStatus(tc.vmStatus)
+		if got == nil || got.Reason != tc.reason || got.Code != tc.code {
+			t.Errorf("DecodeAptosVMStatus(%q) = %+v, want %s/%s", tc.vmStatus, got, tc.reason, tc.code)
+		}
+	}
+
+	if got := DecodeAptosVMStatus("Executed successfully"); got != nil {
+		t.Errorf("expected nil for a successful status, got %+v", got)
+	}
+}
+
+// revertError mimics the JSON-RPC error go-ethereum returns for a reverted call
+type revertError struct {
+	data string
+}
+
+func (e *revertError) Error() string          { return "execution reverted" }
+func (e *revertError) ErrorData() interface{} { return e.data }
+
+func TestDecodeEVMError(t *testing.T) {
+	// Error(string) with reason "Invalid OTP"
+	reasonData := "0x08c379a0" +
+		"0000000000000000000000000000000000000000000000000000000000000020" +
+		"000000000000000000000000000000000000000000000000000000000000000b" +
+		"496e76616c6964204f5450000000000000000000000000000000000000000000"
+	got := DecodeEVMError(fmt.Errorf("completePayment: %w", &revertError{data: reasonData}))
+	if got == nil || got.Reason != FailureInvalidOTP || got.Message != "Invalid OTP" {
+		t.Errorf("unexpected decoded revert reason: %+v", got)
+	}
+
+	// Panic(0x11): arithmetic underflow or overflow
+	panicData := hexutil.Encode(append(panicSelector, make([]byte, 31)...)) + "11"
+	got = DecodeEVMError(&revertError{data: panicData})
+	if got == nil || got.Code != "Panic(0x11)" || got.Reason != FailureUnknown {
+		t.Errorf("unexpected decoded panic: %+v", got)
+	}
+
+	got = DecodeEVMError(errors.New("execution reverted: Exceeds payment limit"))
+	if got == nil || got.Reason != FailureLimitExceeded {
+		t.Errorf("unexpected decoded revert message: %+v", got)
+	}
+
+	if got := DecodeEVMError(errors.New("dial tcp: connection refused")); got != nil {
+		t.Errorf("expected nil for a transport error, got %+v", got)
+	}
+}
+
+func TestDecodeSolanaError(t *testing.T) {
+	err := fmt.Errorf("send: %w", &jsonrpc.RPCError{
+		Code:    -32002,
+		Message: "Transaction simulation failed: Error processing Instruction 0: custom program error: 0x1771",
+		Data: map[string]interface{}{
+			"logs": []interface{}{
+				"Program 88oZkwPMg9iWjPTUqYJXkRE2JYmFEvRraC6vYTcH9CGH invoke [1]",
+				"Program log: Instruction: CompletePayment",
+				"Program log: AnchorError thrown in programs/tinypay/src/lib.rs:120. Error Code: InvalidOtp. Error Number: 6001. Error Message: Invalid OTP.",
+			},
+		},
+	})
+	got := DecodeSolanaError(err)
+	if got == nil || got.Reason != FailureInvalidOTP || got.Code != "InvalidOtp" || got.Message != "Invalid OTP" {
+		t.Errorf("unexpected decoded Anchor error: %+v", got)
+	}
+
+	got = DecodeSolanaError(&jsonrpc.RPCError{Message: "custom program error: 0x1772"})
+	if got == nil || got.Code != "Custom(0x1772)" || got.Reason != FailureUnknown {
+		t.Errorf("unexpected decoded custom error: %+v", got)
+	}
+
+	got = DecodeSolanaLogs([]string{"Program 11111111111111111111111111111111 invoke [2]", "Transfer: insufficient lamports 10, need 20"})
+	if got == nil || got.Reason != FailureInsufficientBalance {
+		t.Errorf("unexpected decoded system program failure: %+v", got)
+	}
+
+	if got := DecodeSolanaError(errors.New("connection reset by peer")); got != nil {
+		t.Errorf("expected nil for a transport error, got %+v", got)
+	}
+}
@@ -2,33 +2,47 @@ package client
 
 import (
 	"context"
-	"crypto/ecdsa"
 	"errors"
 	"fmt"
 	"log"
+	"math"
 	"math/big"
 	"strings"
+	"sync"
+	"time"
 
 	tinypaybindings "tinypay-server/binds/tinypay"
 	"tinypay-server/config"
 	"tinypay-server/utils"
 
+	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethclient"
 )
 
+// evmPaymasterTTL is how long the contract's paymaster address is trusted before it is read
+// again. Only the contract owner can change it, and a sponsored payment that fails reads it again.
+const evmPaymasterTTL = 5 * time.Minute
+
 // EVMClient provides helpers to interact with the TinyPay Solidity contract.
 type EVMClient struct {
 	cfg        *config.Config
 	ethClient  *ethclient.Client
 	contract   *tinypaybindings.Tinypay
-	privateKey *ecdsa.PrivateKey
 	chainID    *big.Int
-	from       common.Address
 	network    string // Track which network this client is configured for
+	fees       config.FeePolicy
+	senders    []*evmSender   // Paymaster keys, in the order of the pool
+	paymasters *paymasterPool // Selects the key of each transaction
+
+	contractPaymaster func(ctx context.Context) (common.Address, error) // Reads the contract's paymaster()
+	paymasterMu       sync.Mutex
+	paymasterAddr     common.Address // last paymaster() read, trusted for evmPaymasterTTL
+	paymasterReadAt   time.Time      // zero when paymasterAddr has to be read again
 }
 
 // EVMNetworkConfig holds network-specific configuration parameters
@@ -37,9 +51,12 @@ type EVMNetworkConfig struct {
 	ChainID         uint64
 	ContractAddress string
 	PrivateKey      string
+	PrivateKeys     []string
 	Network         string
 	NativeToken     config.EVMNativeToken
 	Tokens          []config.EVMToken
+	Fees            config.FeePolicy
+	Paymasters      config.PaymasterPool
 }
 
 // getNetworkConfig extracts network-specific configuration based on network type
@@ -56,9 +73,12 @@ func getNetworkConfig(cfg *config.Config, network string) (*EVMNetworkConfig, er
 				ChainID:         evmNetwork.ChainID,
 				ContractAddress: evmNetwork.ContractAddress,
 				PrivateKey:      evmNetwork.PrivateKey,
+				PrivateKeys:     evmNetwork.PrivateKeys,
 				Network:         network,
 				NativeToken:     evmNetwork.NativeToken,
 				Tokens:          evmNetwork.Tokens,
+				Fees:            evmNetwork.Fees,
+				Paymasters:      evmNetwork.Paymasters,
 			}, nil
 		}
 	}
@@ -103,37 +123,25 @@ func NewEVMClientForNetwork(cfg *config.Config, network string) (*EVMClient, err
 	}
 
 	// Validate network-specific configuration
-	if strings.TrimSpace(netCfg.RPCURL) == "" {
-		return nil, fmt.Errorf("%s RPC URL is required", strings.ToUpper(strings.Replace(network, "-", "_", -1)))
-	}
-	if strings.TrimSpace(netCfg.ContractAddress) == "" {
-		return nil, fmt.Errorf("%s contract address is required", strings.ToUpper(strings.Replace(network, "-", "_", -1)))
-	}
-	if strings.TrimSpace(netCfg.PrivateKey) == "" {
-		return nil, fmt.Errorf("%s private key is required", strings.ToUpper(strings.Replace(network, "-", "_", -1)))
+	if err := checkNetworkConfig(netCfg, network); err != nil {
+		return nil, err
 	}
+	keys := config.PaymasterKeys(netCfg.PrivateKey, netCfg.PrivateKeys)
 	if netCfg.ChainID == 0 {
 		return nil, fmt.Errorf("%s chain ID must be greater than 0", strings.ToUpper(strings.Replace(network, "-", "_", -1)))
 	}
+	if err := netCfg.Fees.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid %s fee policy: %w", network, err)
+	}
+	if err := netCfg.Paymasters.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid %s paymaster pool: %w", network, err)
+	}
 
 	client, err := ethclient.Dial(netCfg.RPCURL)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to %s RPC: %w", network, err)
 	}
 
-	privateKey, err := crypto.HexToECDSA(strings.TrimPrefix(netCfg.PrivateKey, "0x"))
-	if err != nil {
-		return nil, fmt.Errorf("invalid %s private key: %w", network, err)
-	}
-
-	publicKey := privateKey.Public()
-	ecdsaPubKey, ok := publicKey.(*ecdsa.PublicKey)
-	if !ok {
-		return nil, fmt.Errorf("failed to parse %s public key", network)
-	}
-
-	fromAddress := crypto.PubkeyToAddress(*ecdsaPubKey)
-
 	chainID := big.NewInt(int64(netCfg.ChainID))
 
 	contractAddress := common.HexToAddress(ensureHexPrefix(netCfg.ContractAddress))
@@ -142,19 +150,35 @@ func NewEVMClientForNetwork(cfg *config.Config, network string) (*EVMClient, err
 		return nil, fmt.Errorf("failed to bind Tinypay contract for %s: %w", network, err)
 	}
 
-	return &EVMClient{
-		cfg:        cfg,
-		ethClient:  client,
-		contract:   contract,
-		privateKey: privateKey,
-		chainID:    chainID,
-		from:       fromAddress,
-		network:    network,
-	}, nil
+	c := &EVMClient{
+		cfg:       cfg,
+		ethClient: client,
+		contract:  contract,
+		chainID:   chainID,
+		network:   network,
+		fees:      netCfg.Fees,
+	}
+	c.contractPaymaster = c.queryPaymaster
+
+	addresses := make([]string, 0, len(keys))
+	for i, key := range keys {
+		sender, err := c.newSender(key)
+		if err != nil {
+			c.Close()
+			return nil, fmt.Errorf("invalid %s private key #%d: %w", network, i+1, err)
+		}
+		c.senders = append(c.senders, sender)
+		addresses = append(addresses, sender.from.Hex())
+	}
+	c.paymasters = newPaymasterPool(network, addresses, netCfg.Paymasters, c.senderBalance)
+	return c, nil
 }
 
 // Close releases underlying network resources.
 func (c *EVMClient) Close() error {
+	for _, sender := range c.senders {
+		sender.monitor.close()
+	}
 	if c.ethClient != nil {
 		c.ethClient.Close()
 	}
@@ -171,6 +195,94 @@ func (c *EVMClient) GetNetwork() string {
 	return c.network
 }
 
+// CheckConfig reports a missing RPC URL, contract address or paymaster key
+func (c *EVMClient) CheckConfig() error {
+	netCfg, err := getNetworkConfig(c.cfg, c.network)
+	if err != nil {
+		return err
+	}
+	return checkNetworkConfig(netCfg, c.network)
+}
+
+// checkNetworkConfig checks the settings an EVM network needs to take payments
+func checkNetworkConfig(netCfg *EVMNetworkConfig, network string) error {
+	if strings.TrimSpace(netCfg.RPCURL) == "" {
+		return fmt.Errorf("%s RPC URL is required", strings.ToUpper(strings.Replace(network, "-", "_", -1)))
+	}
+	if strings.TrimSpace(netCfg.ContractAddress) == "" {
+		return fmt.Errorf("%s contract address is required", strings.ToUpper(strings.Replace(network, "-", "_", -1)))
+	}
+	if len(config.PaymasterKeys(netCfg.PrivateKey, netCfg.PrivateKeys)) == 0 {
+		return fmt.Errorf("%s private key is required", strings.ToUpper(strings.Replace(network, "-", "_", -1)))
+	}
+	return nil
+}
+
+// SupportedCurrencies returns the currencies configured for this EVM network
+func (c *EVMClient) SupportedCurrencies() []string {
+	return utils.NewNetworkCurrencyValidationMatrix(c.cfg).GetSupportedCurrenciesForNetwork(c.network)
+}
+
+// DefaultCurrency returns the native token symbol of this EVM network
+func (c *EVMClient) DefaultCurrency() string {
+	return utils.GetDefaultCurrencyForNetwork(c.cfg, c.network)
+}
+
+// ResolveCoinType validates that a currency has a token address on this network.
+// EVM payments report the currency symbol itself as the coin type.
+func (c *EVMClient) ResolveCoinType(currency string) (string, error) {
+	tokenAddress, err := utils.GetEVMTokenAddressByNetwork(c.cfg, currency, c.network)
+	if err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(tokenAddress) == "" {
+		return "", fmt.Errorf("token address for %s not configured on network %s", currency, c.network)
+	}
+	return strings.ToUpper(currency), nil
+}
+
+// NormalizeAddress returns the lowercase hex form of an EVM address
+func (c *EVMClient) NormalizeAddress(address string) string {
+	address = strings.TrimSpace(address)
+	if common.IsHexAddress(address) {
+		return strings.ToLower(common.HexToAddress(address).Hex())
+	}
+	return strings.ToLower(address)
+}
+
+// SendPayment submits a completePayment transaction for the configured network
+func (c *EVMClient) SendPayment(ctx context.Context, payment *Payment) (*Submission, error) {
+	tokenAddress, err := utils.GetEVMTokenAddressByNetwork(c.cfg, payment.Currency, c.network)
+	if err != nil {
+		return nil, err
+	}
+
+	amount := new(big.Int).SetUint64(payment.Amount)
+
+	sender, release, sponsored, err := c.paymentSender(ctx, payment)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	commitHash := common.Hash{}
+	if payment.CommitHash != nil {
+		commitHash = common.BytesToHash(payment.CommitHash)
+	} else if !sponsored {
+		commitHash, err = c.ComputePaymentHash(tokenAddress, payment.PayerAddr, payment.PayeeAddr, amount, payment.Otp)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	submission, err := c.completePayment(ctx, sender, tokenAddress, payment.PayerAddr, payment.PayeeAddr, amount, payment.Otp, commitHash.Hex())
+	if err != nil && sponsored {
+		// The contract's paymaster may have changed since it was cached
+		c.forgetPaymaster()
+	}
+	return submission, err
+}
+
 // CompletePayment executes the TinyPay completePayment function on the EVM contract.
 // optString will be converted to contract bytes using UTF-8 encoding, equivalent to
 // ethers.hexlify(ethers.toUtf8Bytes(optString)) in the TypeScript example.
@@ -183,11 +295,35 @@ func (c *EVMClient) CompletePayment(
 	optString string,
 	commitHashHex string,
 ) (common.Hash, error) {
+	sender, release, err := c.acquireSender("")
+	if err != nil {
+		return common.Hash{}, err
+	}
+	defer release()
+
+	submission, err := c.completePayment(ctx, sender, tokenAddress, payerAddress, recipientAddress, amount, optString, commitHashHex)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return common.HexToHash(submission.TxHash), nil
+}
+
+// completePayment sends completePayment from sender and reports its hash with the fee it was priced at
+func (c *EVMClient) completePayment(
+	ctx context.Context,
+	sender *evmSender,
+	tokenAddress string,
+	payerAddress string,
+	recipientAddress string,
+	amount *big.Int,
+	optString string,
+	commitHashHex string,
+) (*Submission, error) {
 	if c == nil {
-		return common.Hash{}, errors.New("EVMClient is nil")
+		return nil, errors.New("EVMClient is nil")
 	}
 	if amount == nil {
-		return common.Hash{}, errors.New("amount cannot be nil")
+		return nil, errors.New("amount cannot be nil")
 	}
 
 	token := common.HexToAddress(ensureHexPrefix(tokenAddress))
@@ -198,30 +334,183 @@ func (c *EVMClient) CompletePayment(
 
 	commitHashBytes, err := hexutil.Decode(ensureHexPrefix(commitHashHex))
 	if err != nil {
-		return common.Hash{}, fmt.Errorf("invalid commit hash: %w", err)
+		return nil, fmt.Errorf("invalid commit hash: %w", err)
 	}
 	if len(commitHashBytes) != 32 {
-		return common.Hash{}, fmt.Errorf("commit hash must be 32 bytes, got %d", len(commitHashBytes))
+		return nil, fmt.Errorf("commit hash must be 32 bytes, got %d", len(commitHashBytes))
 	}
 
 	var commitHash [32]byte
 	copy(commitHash[:], commitHashBytes)
 
-	auth, err := bind.NewKeyedTransactorWithChainID(c.privateKey, c.chainID)
+	tx, fee, err := c.transact(ctx, sender, func(auth *bind.TransactOpts) (*types.Transaction, error) {
+		return c.contract.CompletePayment(auth, token, tailBytes, payer, recipient, amount, commitHash)
+	})
 	if err != nil {
-		return common.Hash{}, fmt.Errorf("failed to create transactor: %w", err)
+		if chainErr := DecodeEVMError(err); chainErr != nil {
+			return nil, fmt.Errorf("completePayment reverted: %w", chainErr)
+		}
+		return nil, fmt.Errorf("completePayment call failed: %w", err)
 	}
-	auth.From = c.from
-	auth.Context = ctx
 
-	log.Printf("auth from %s:", auth.From.String())
+	// Followed until mined, and rebroadcast with higher fees if it gets stuck
+	sender.monitor.track(tx, fee.Capped)
+	return &Submission{TxHash: tx.Hash().Hex(), Fee: fee, Sender: sender.from.Hex()}, nil
+}
 
-	tx, err := c.contract.CompletePayment(auth, token, tailBytes, payer, recipient, amount, commitHash)
+// Precommit submits merchantPrecommit and waits for it to be mined. The contract computes the
+// commit hash itself, so it is read back from the PreCommitMade event together with its expiry.
+func (c *EVMClient) Precommit(ctx context.Context, payment *Payment) (*Precommit, error) {
+	tokenAddress, err := utils.GetEVMTokenAddressByNetwork(c.cfg, payment.Currency, c.network)
 	if err != nil {
-		return common.Hash{}, fmt.Errorf("completePayment call failed: %w", err)
+		return nil, err
 	}
 
-	return tx.Hash(), nil
+	token := common.HexToAddress(ensureHexPrefix(tokenAddress))
+	payer := common.HexToAddress(ensureHexPrefix(payment.PayerAddr))
+	recipient := common.HexToAddress(ensureHexPrefix(payment.PayeeAddr))
+	amount := new(big.Int).SetUint64(payment.Amount)
+
+	sender, release, err := c.acquireSender("")
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	tx, _, err := c.transact(ctx, sender, func(auth *bind.TransactOpts) (*types.Transaction, error) {
+		return c.contract.MerchantPrecommit(auth, token, payer, recipient, amount, []byte(payment.Otp))
+	})
+	if err != nil {
+		if chainErr := DecodeEVMError(err); chainErr != nil {
+			return nil, fmt.Errorf("merchantPrecommit reverted: %w", chainErr)
+		}
+		return nil, fmt.Errorf("merchantPrecommit call failed: %w", err)
+	}
+	log.Printf("merchantPrecommit sent on %s: %s", c.network, tx.Hash().Hex())
+
+	receipt, err := bind.WaitMined(ctx, c.ethClient, tx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to wait for merchantPrecommit: %w", err)
+	}
+	if receipt.Status != 1 {
+		return nil, fmt.Errorf("merchantPrecommit reverted in transaction %s", tx.Hash().Hex())
+	}
+
+	for _, lg := range receipt.Logs {
+		if lg == nil {
+			continue
+		}
+		if evt, err := c.contract.ParsePreCommitMade(*lg); err == nil {
+			if expected, err := c.ComputePaymentHash(tokenAddress, payment.PayerAddr, payment.PayeeAddr, amount, payment.Otp); err == nil && expected != common.Hash(evt.CommitHash) {
+				log.Printf("Warning: precommit hash %s on %s differs from locally computed %s", common.Hash(evt.CommitHash).Hex(), c.network, expected.Hex())
+			}
+			return &Precommit{
+				CommitHash: evt.CommitHash[:],
+				TxHash:     tx.Hash().Hex(),
+				ExpiresAt:  time.Unix(int64(evt.ExpiryTime), 0).UTC(),
+				Sender:     sender.from.Hex(),
+			}, nil
+		}
+	}
+	return nil, fmt.Errorf("PreCommitMade event not found in transaction %s", tx.Hash().Hex())
+}
+
+// paymentHashArguments mirrors abi.encode(address token, address payer, address recipient, uint256 amount, bytes opt)
+var paymentHashArguments = func() abi.Arguments {
+	addressType, _ := abi.NewType("address", "", nil)
+	uint256Type, _ := abi.NewType("uint256", "", nil)
+	bytesType, _ := abi.NewType("bytes", "", nil)
+	return abi.Arguments{
+		{Name: "token", Type: addressType},
+		{Name: "payer", Type: addressType},
+		{Name: "recipient", Type: addressType},
+		{Name: "amount", Type: uint256Type},
+		{Name: "opt", Type: bytesType},
+	}
+}()
+
+// ComputePaymentHash returns the commit hash the contract derives for a payment:
+// keccak256(abi.encode(token, payer, recipient, amount, opt)), with optString UTF-8 encoded
+// the same way CompletePayment sends it.
+func (c *EVMClient) ComputePaymentHash(
+	tokenAddress string,
+	payerAddress string,
+	recipientAddress string,
+	amount *big.Int,
+	optString string,
+) (common.Hash, error) {
+	if amount == nil {
+		return common.Hash{}, errors.New("amount cannot be nil")
+	}
+
+	token := common.HexToAddress(ensureHexPrefix(tokenAddress))
+	payer := common.HexToAddress(ensureHexPrefix(payerAddress))
+	recipient := common.HexToAddress(ensureHexPrefix(recipientAddress))
+
+	encoded, err := paymentHashArguments.Pack(token, payer, recipient, amount, []byte(optString))
+	if err != nil {
+		return common.Hash{}, fmt.Errorf("failed to encode payment hash input: %w", err)
+	}
+	return crypto.Keccak256Hash(encoded), nil
+}
+
+// paymentSender takes the key that sends payment and reports whether it signs as the contract's
+// paymaster. Only the paymaster may complete a payment with the all-zero commit hash, so payments
+// without a precommit go to the paymaster's key and the other keys only complete their own
+// precommits. When no key is the paymaster, the pool picks one and the payment carries its
+// computed hash.
+func (c *EVMClient) paymentSender(ctx context.Context, payment *Payment) (*evmSender, func(), bool, error) {
+	if payment.CommitHash != nil {
+		sender, release, err := c.acquireSender(payment.Sender)
+		return sender, release, false, err
+	}
+
+	paymaster, err := c.currentPaymaster(ctx)
+	if err != nil {
+		return nil, nil, false, fmt.Errorf("failed to query paymaster: %w", err)
+	}
+	index, err := c.paymasters.acquireUsable(paymaster.Hex())
+	if err == nil {
+		return c.senders[index], func() { c.paymasters.release(index) }, true, nil
+	}
+	if !errors.Is(err, ErrPaymasterNotFound) {
+		return nil, nil, false, err
+	}
+
+	sender, release, err := c.acquireSender("")
+	return sender, release, false, err
+}
+
+// queryPaymaster reads the contract's paymaster address
+func (c *EVMClient) queryPaymaster(ctx context.Context) (common.Address, error) {
+	return c.contract.Paymaster(&bind.CallOpts{Context: ctx})
+}
+
+// currentPaymaster returns the contract's paymaster address, read again once it is older than
+// evmPaymasterTTL. Concurrent payments may read it twice when it expires.
+func (c *EVMClient) currentPaymaster(ctx context.Context) (common.Address, error) {
+	c.paymasterMu.Lock()
+	if !c.paymasterReadAt.IsZero() && time.Since(c.paymasterReadAt) < evmPaymasterTTL {
+		defer c.paymasterMu.Unlock()
+		return c.paymasterAddr, nil
+	}
+	c.paymasterMu.Unlock()
+
+	paymaster, err := c.contractPaymaster(ctx)
+	if err != nil {
+		return common.Address{}, err
+	}
+	c.paymasterMu.Lock()
+	c.paymasterAddr, c.paymasterReadAt = paymaster, time.Now()
+	c.paymasterMu.Unlock()
+	return paymaster, nil
+}
+
+// forgetPaymaster makes the next payment read the contract's paymaster address again
+func (c *EVMClient) forgetPaymaster() {
+	c.paymasterMu.Lock()
+	defer c.paymasterMu.Unlock()
+	c.paymasterReadAt = time.Time{}
 }
 
 func ensureHexPrefix(value string) string {
@@ -273,7 +562,7 @@ func (c *EVMClient) GetTransactionDetails(ctx context.Context, txHashHex string)
 			if (evt.Token == common.Address{}) {
 				// Set native token symbol based on dynamic network config
 				if netCfg := utils.GetEVMNetworkConfig(c.cfg, c.network); netCfg != nil {
-					info.CoinType = netCfg.NativeToken.Symbol
+					info.CoinType = strings.ToUpper(netCfg.NativeToken.Symbol)
 				} else {
 					info.CoinType = "UNKNOWN"
 				}
@@ -281,12 +570,12 @@ func (c *EVMClient) GetTransactionDetails(ctx context.Context, txHashHex string)
 			} else {
 				info.TokenAddress = evt.Token.Hex()
 				// Map token address to currency using network-specific utility function
-				if currency := utils.GetCurrencyFromEVMTokenAddressByNetwork(c.cfg, evt.Token.Hex(), c.network); currency != "" {
+				if currency := utils.GetCurrencyFromEVMTokenAddressByNetwork(c.cfg, evt.Token.Hex(), c.network); currency != "UNKNOWN" {
 					info.CoinType = currency
 				} else {
 					// Fallback to native token based on dynamic network config
 					if netCfg := utils.GetEVMNetworkConfig(c.cfg, c.network); netCfg != nil {
-						info.CoinType = netCfg.NativeToken.Symbol
+						info.CoinType = strings.ToUpper(netCfg.NativeToken.Symbol)
 					} else {
 						info.CoinType = "UNKNOWN"
 					}
@@ -318,3 +607,37 @@ func (c *EVMClient) GetUserLimits(ctx context.Context, userAddress string) (*Use
 		MaxTailUpdates:  res.MaxTailUpdates,
 	}, nil
 }
+
+// GetBalance returns the payer's TinyPay balance of a currency on this network
+func (c *EVMClient) GetBalance(ctx context.Context, userAddress, currency string) (uint64, error) {
+	if c == nil || c.contract == nil {
+		return 0, errors.New("EVM client not initialized")
+	}
+	tokenAddress, err := utils.GetEVMTokenAddressByNetwork(c.cfg, currency, c.network)
+	if err != nil {
+		return 0, err
+	}
+	user := common.HexToAddress(ensureHexPrefix(userAddress))
+	balance, err := c.contract.GetBalance(&bind.CallOpts{Context: ctx}, user, common.HexToAddress(ensureHexPrefix(tokenAddress)))
+	if err != nil {
+		return 0, fmt.Errorf("getBalance failed: %w", err)
+	}
+	// Payment amounts are uint64, so any larger balance covers every payment
+	if !balance.IsUint64() {
+		return math.MaxUint64, nil
+	}
+	return balance.Uint64(), nil
+}
+
+// GetUserTail returns the hash chain tail the TinyPay contract stores for a user
+func (c *EVMClient) GetUserTail(ctx context.Context, userAddress string) ([]byte, error) {
+	if c == nil || c.contract == nil {
+		return nil, errors.New("EVM client not initialized")
+	}
+	addr := common.HexToAddress(ensureHexPrefix(userAddress))
+	tail, err := c.contract.GetUserTail(&bind.CallOpts{Context: ctx}, addr)
+	if err != nil {
+		return nil, fmt.Errorf("getUserTail failed: %w", err)
+	}
+	return tail, nil
+}
@@ -0,0 +1,197 @@
+package client
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+
+	"tinypay-server/config"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Golden vectors computed independently of go-ethereum from the Solidity encoding
+// keccak256(abi.encode(address token, address payer, address recipient, uint256 amount, bytes opt))
+var paymentHashVectors = []struct {
+	name      string
+	token     string
+	payer     string
+	recipient string
+	amount    string
+	opt       string
+	hash      string
+}{
+	{
+		name:      "native token",
+		token:     "0x0000000000000000000000000000000000000000",
+		payer:     "0x1111111111111111111111111111111111111111",
+		recipient: "0x2222222222222222222222222222222222222222",
+		amount:    "1000000",
+		opt:       "deadbeef",
+		hash:      "0xa7cb262130eeb2190ff904a39d466f67d6494c5a84d91019e8cb8aafc8bf8790",
+	},
+	{
+		name:      "empty opt and amount above uint64",
+		token:     "0x1c7D4B196Cb0C7B01d743Fbc6116a902379C7238",
+		payer:     "0x70997970C51812dc3A010C7d01b50e0d17dc79C8",
+		recipient: "0x3C44CdDdB6a900fa2b585dd299e03d12FA4293BC",
+		amount:    "12345678901234567890",
+		opt:       "",
+		hash:      "0x88cdb6f5a5e2611582167629bfc978a24d07750da0b90271632da00abd3e69e2",
+	},
+	{
+		name:      "opt spanning several words",
+		token:     "a0b86991c6218b36c1d19d4a2e9eb0ce3606eb48",
+		payer:     "0x70997970C51812dc3A010C7d01b50e0d17dc79C8",
+		recipient: "0x3C44CdDdB6a900fa2b585dd299e03d12FA4293BC",
+		amount:    "1",
+		opt:       "0x" + strings.Repeat("ab", 40),
+		hash:      "0x9cdd6565e40f1e0ac09df8e739e3b4adb784129fd14ee0e7ffe512e63fb70a1b",
+	},
+}
+
+func TestComputePaymentHashGoldenVectors(t *testing.T) {
+	c := &EVMClient{}
+	for _, v := range paymentHashVectors {
+		t.Run(v.name, func(t *testing.T) {
+			amount, ok := new(big.Int).SetString(v.amount, 10)
+			if !ok {
+				t.Fatalf("bad amount %q", v.amount)
+			}
+			hash, err := c.ComputePaymentHash(v.token, v.payer, v.recipient, amount, v.opt)
+			if err != nil {
+				t.Fatalf("compute payment hash: %v", err)
+			}
+			if hash != common.HexToHash(v.hash) {
+				t.Errorf("expected %s, got %s", v.hash, hash.Hex())
+			}
+		})
+	}
+}
+
+func TestPaymentHashEncodingLayout(t *testing.T) {
+	v := paymentHashVectors[0]
+	encoded, err := paymentHashArguments.Pack(
+		common.HexToAddress(v.token),
+		common.HexToAddress(v.payer),
+		common.HexToAddress(v.recipient),
+		big.NewInt(1000000),
+		[]byte(v.opt),
+	)
+	if err != nil {
+		t.Fatalf("pack: %v", err)
+	}
+
+	// Three addresses, the amount, the offset of opt, its length and its right-padded bytes
+	want := strings.Join([]string{
+		"0000000000000000000000000000000000000000000000000000000000000000",
+		"0000000000000000000000001111111111111111111111111111111111111111",
+		"0000000000000000000000002222222222222222222222222222222222222222",
+		"00000000000000000000000000000000000000000000000000000000000f4240",
+		"00000000000000000000000000000000000000000000000000000000000000a0",
+		"0000000000000000000000000000000000000000000000000000000000000008",
+		"6465616462656566000000000000000000000000000000000000000000000000",
+	}, "")
+	if got := hex.EncodeToString(encoded); got != want {
+		t.Errorf("unexpected encoding:\n got %s\nwant %s", got, want)
+	}
+}
+
+func TestComputePaymentHashRejectsNilAmount(t *testing.T) {
+	c := &EVMClient{}
+	if _, err := c.ComputePaymentHash("0x00", "0x01", "0x02", nil, "otp"); err == nil {
+		t.Error("expected an error for a nil amount")
+	}
+}
+
+// newTestPoolClient returns a client over keys with addresses whose contract reports paymaster
+func newTestPoolClient(paymaster common.Address, addresses ...common.Address) *EVMClient {
+	c := &EVMClient{network: "sepolia"}
+	var hexes []string
+	for _, address := range addresses {
+		c.senders = append(c.senders, &evmSender{from: address})
+		hexes = append(hexes, address.Hex())
+	}
+	c.paymasters = newPaymasterPool(c.network, hexes, config.PaymasterPool{}, nil)
+	c.contractPaymaster = func(context.Context) (common.Address, error) { return paymaster, nil }
+	return c
+}
+
+func TestPaymentSenderRoutesDirectPaymentsToThePaymaster(t *testing.T) {
+	other := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	paymaster := common.HexToAddress("0x2222222222222222222222222222222222222222")
+	c := newTestPoolClient(paymaster, other, paymaster)
+
+	// Round-robin would hand out the other key every second payment
+	for n := 0; n < 3; n++ {
+		sender, release, sponsored, err := c.paymentSender(context.Background(), &Payment{})
+		if err != nil {
+			t.Fatalf("payment sender: %v", err)
+		}
+		if sender.from != paymaster || !sponsored {
+			t.Errorf("expected the paymaster to send the zero commit hash, got %s (sponsored %v)", sender.from.Hex(), sponsored)
+		}
+		release()
+	}
+
+	// A precommit is completed by the key that made it, with its own commit hash
+	sender, release, sponsored, err := c.paymentSender(context.Background(), &Payment{CommitHash: make([]byte, 32), Sender: other.Hex()})
+	if err != nil || sender.from != other || sponsored {
+		t.Errorf("expected the precommitting key, got %v (sponsored %v): %v", sender, sponsored, err)
+	} else {
+		release()
+	}
+
+	// The other key never takes over from a drained paymaster
+	if _, err := c.DrainPaymaster(paymaster.Hex()); err != nil {
+		t.Fatalf("drain: %v", err)
+	}
+	if _, _, _, err := c.paymentSender(context.Background(), &Payment{}); !errors.Is(err, ErrNoPaymasterAvailable) {
+		t.Errorf("expected ErrNoPaymasterAvailable while the paymaster is drained, got %v", err)
+	}
+}
+
+func TestPaymentSenderComputesHashWhenNoKeyIsThePaymaster(t *testing.T) {
+	first := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	second := common.HexToAddress("0x3333333333333333333333333333333333333333")
+	c := newTestPoolClient(common.HexToAddress("0x2222222222222222222222222222222222222222"), first, second)
+
+	sender, release, sponsored, err := c.paymentSender(context.Background(), &Payment{})
+	if err != nil {
+		t.Fatalf("payment sender: %v", err)
+	}
+	defer release()
+	if sponsored {
+		t.Errorf("expected %s to send the computed payment hash", sender.from.Hex())
+	}
+}
+
+func TestCurrentPaymasterIsCached(t *testing.T) {
+	paymaster := common.HexToAddress("0x2222222222222222222222222222222222222222")
+	c := newTestPoolClient(paymaster, paymaster)
+	queries := 0
+	c.contractPaymaster = func(context.Context) (common.Address, error) {
+		queries++
+		return paymaster, nil
+	}
+
+	for n := 0; n < 3; n++ {
+		if got, err := c.currentPaymaster(context.Background()); err != nil || got != paymaster {
+			t.Fatalf("expected %s, got %s: %v", paymaster.Hex(), got.Hex(), err)
+		}
+	}
+	if queries != 1 {
+		t.Errorf("expected one paymaster() call within the TTL, got %d", queries)
+	}
+
+	c.paymasterReadAt = c.paymasterReadAt.Add(-evmPaymasterTTL)
+	c.currentPaymaster(context.Background())
+	c.forgetPaymaster()
+	c.currentPaymaster(context.Background())
+	if queries != 3 {
+		t.Errorf("expected paymaster() to be read again after expiry and after a failed payment, got %d calls", queries)
+	}
+}
@@ -0,0 +1,122 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"math/big"
+
+	"tinypay-server/config"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// evmFeeSource provides the network data fee estimates are based on
+type evmFeeSource interface {
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+	SuggestGasPrice(ctx context.Context) (*big.Int, error)
+	SuggestGasTipCap(ctx context.Context) (*big.Int, error)
+}
+
+// evmFeeQuote is the pricing of one transaction. A nil field leaves the value to go-ethereum.
+type evmFeeQuote struct {
+	gasPrice  *big.Int // legacy chains without a base fee
+	gasFeeCap *big.Int
+	gasTipCap *big.Int
+	capped    bool
+}
+
+// quoteEVMFees prices a transaction under policy. Static policies without a price keep
+// go-ethereum's defaults; estimates use EIP-1559 fees, or the gas price on chains without a base fee.
+func quoteEVMFees(ctx context.Context, source evmFeeSource, policy config.FeePolicy) (*evmFeeQuote, error) {
+	if !policy.Estimated() {
+		quote := &evmFeeQuote{}
+		if policy.Price > 0 {
+			quote.gasFeeCap = new(big.Int).SetUint64(policy.Price)
+			quote.gasTipCap = new(big.Int).SetUint64(min(policy.PriorityFee, policy.Price))
+		}
+		return quote, nil
+	}
+
+	head, err := source.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get latest header: %w", err)
+	}
+
+	if head.BaseFee == nil {
+		estimate, err := source.SuggestGasPrice(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to estimate gas price: %w", err)
+		}
+		price, capped := scaleFee(policy, bigToUint64(estimate), policy.MaxPrice)
+		return &evmFeeQuote{gasPrice: new(big.Int).SetUint64(price), capped: capped}, nil
+	}
+
+	estimate, err := source.SuggestGasTipCap(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to estimate priority fee: %w", err)
+	}
+	tip, capped := scaleFee(policy, bigToUint64(estimate), policy.MaxPriorityFee)
+
+	// Like go-ethereum, leave room for the base fee to double before the transaction is included
+	baseFee := bigToUint64(head.BaseFee)
+	headroom, _ := scaleFee(policy, saturatingAdd(baseFee, baseFee), 0)
+	feeCap := saturatingAdd(headroom, tip)
+	if policy.MaxPrice > 0 && feeCap > policy.MaxPrice {
+		feeCap, capped = policy.MaxPrice, true
+		if feeCap < baseFee {
+			log.Printf("Warning: fee cap %d is below the base fee %d; transactions wait until the base fee drops", feeCap, baseFee)
+		}
+	}
+	tip = min(tip, feeCap)
+
+	return &evmFeeQuote{
+		gasFeeCap: new(big.Int).SetUint64(feeCap),
+		gasTipCap: new(big.Int).SetUint64(tip),
+		capped:    capped,
+	}, nil
+}
+
+// apply sets the quoted fees on auth
+func (q *evmFeeQuote) apply(auth *bind.TransactOpts) {
+	auth.GasPrice = q.gasPrice
+	auth.GasFeeCap = q.gasFeeCap
+	auth.GasTipCap = q.gasTipCap
+}
+
+// evmFee records the fees a built transaction carries
+func evmFee(policy config.FeePolicy, tx *types.Transaction, capped bool) *Fee {
+	fee := &Fee{
+		Mode:   feeMode(policy),
+		Unit:   FeeUnitWeiPerGas,
+		Limit:  tx.Gas(),
+		Capped: capped,
+	}
+	if tx.Type() == types.DynamicFeeTxType {
+		fee.Price = bigToUint64(tx.GasFeeCap())
+		fee.PriorityFee = bigToUint64(tx.GasTipCap())
+	} else {
+		fee.Price = bigToUint64(tx.GasPrice())
+	}
+	return fee
+}
+
+// bigToUint64 converts a non-negative amount, saturating at the largest uint64
+func bigToUint64(value *big.Int) uint64 {
+	if value == nil || value.Sign() < 0 {
+		return 0
+	}
+	if !value.IsUint64() {
+		return math.MaxUint64
+	}
+	return value.Uint64()
+}
+
+func saturatingAdd(a, b uint64) uint64 {
+	if a > math.MaxUint64-b {
+		return math.MaxUint64
+	}
+	return a + b
+}
@@ -0,0 +1,426 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"tinypay-server/config"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+const (
+	// minReplacementBumpPercent is the fee increase nodes require before a transaction may replace
+	// another with the same nonce
+	minReplacementBumpPercent = 10
+	// evmMonitorCallTimeout bounds the node calls of one monitor pass
+	evmMonitorCallTimeout = 30 * time.Second
+)
+
+// errReplacementCapped is returned when the fee policy's caps leave no room for a replacement
+var errReplacementCapped = errors.New("fee caps leave no room for a replacement")
+
+// stuckTxChain is the part of the node API the stuck transaction monitor uses
+type stuckTxChain interface {
+	evmFeeSource
+	NonceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (uint64, error)
+	TransactionByHash(ctx context.Context, hash common.Hash) (*types.Transaction, bool, error)
+	TransactionReceipt(ctx context.Context, hash common.Hash) (*types.Receipt, error)
+	SendTransaction(ctx context.Context, tx *types.Transaction) error
+}
+
+// sentVersion is one broadcast of a monitored nonce
+type sentVersion struct {
+	tx     *types.Transaction
+	cancel bool // zero-value self-send that voids the transaction
+	capped bool // fees were cut to the policy's caps
+}
+
+// monitoredTx follows every version sent with one nonce until one of them is mined
+type monitoredTx struct {
+	nonce    uint64
+	original common.Hash
+	versions []sentVersion // latest last
+	sentAt   time.Time     // when the latest version was sent
+}
+
+func (m *monitoredTx) latest() sentVersion {
+	return m.versions[len(m.versions)-1]
+}
+
+// stuckTxMonitor rebroadcasts payment transactions that stay in the mempool past a timeout with
+// the same nonce and higher fees, and replaces them with zero-value self-sends on request
+type stuckTxMonitor struct {
+	chain           stuckTxChain
+	account         common.Address
+	sign            func(context.Context, *types.Transaction) (*types.Transaction, error)
+	policy          config.FeePolicy
+	network         string
+	timeout         time.Duration
+	interval        time.Duration
+	bumpPercent     uint64
+	maxReplacements int
+
+	replaceMu  sync.Mutex // serializes replacements, and every change to a tracked entry
+	mu         sync.Mutex // guards txs and onReplaced; changes to an entry's versions also hold it, so tracked needs no replaceMu
+	txs        map[uint64]*monitoredTx
+	onReplaced func(TransactionReplacement)
+	now        func() time.Time
+
+	stop context.CancelFunc
+	done chan struct{}
+}
+
+func newStuckTxMonitor(chain stuckTxChain, account common.Address, sign func(context.Context, *types.Transaction) (*types.Transaction, error), policy config.FeePolicy, network string, cfg *config.Config) *stuckTxMonitor {
+	m := &stuckTxMonitor{
+		chain:           chain,
+		account:         account,
+		sign:            sign,
+		policy:          policy,
+		network:         network,
+		timeout:         config.DefaultEVMStuckTxTimeout,
+		interval:        config.DefaultEVMMonitorInterval,
+		bumpPercent:     config.DefaultEVMFeeBumpPercent,
+		maxReplacements: config.DefaultEVMMaxReplacements,
+		txs:             make(map[uint64]*monitoredTx),
+		now:             time.Now,
+	}
+	if cfg != nil {
+		if cfg.EVMStuckTxTimeout > 0 {
+			m.timeout = cfg.EVMStuckTxTimeout
+		}
+		if cfg.EVMMonitorInterval > 0 {
+			m.interval = cfg.EVMMonitorInterval
+		}
+		if cfg.EVMFeeBumpPercent > 0 {
+			m.bumpPercent = cfg.EVMFeeBumpPercent
+		}
+		if cfg.EVMMaxReplacements > 0 {
+			m.maxReplacements = cfg.EVMMaxReplacements
+		}
+	}
+	if m.bumpPercent < minReplacementBumpPercent {
+		log.Printf("Warning: fee bump of %d%% on %s is below the %d%% nodes require, using %d%%", m.bumpPercent, network, minReplacementBumpPercent, minReplacementBumpPercent)
+		m.bumpPercent = minReplacementBumpPercent
+	}
+	return m
+}
+
+// start runs the monitor in the background until close is called
+func (m *stuckTxMonitor) start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	m.stop = cancel
+	m.done = make(chan struct{})
+
+	go func() {
+		defer close(m.done)
+		ticker := time.NewTicker(m.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				m.check(ctx)
+			}
+		}
+	}()
+}
+
+// close stops the background monitor and waits for a running pass to finish
+func (m *stuckTxMonitor) close() {
+	if m.stop == nil {
+		return
+	}
+	m.stop()
+	<-m.done
+}
+
+// setOnReplaced registers the function told about every replacement
+func (m *stuckTxMonitor) setOnReplaced(fn func(TransactionReplacement)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onReplaced = fn
+}
+
+// track starts following a transaction that was just sent
+func (m *stuckTxMonitor) track(tx *types.Transaction, capped bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.txs[tx.Nonce()] = &monitoredTx{
+		nonce:    tx.Nonce(),
+		original: tx.Hash(),
+		versions: []sentVersion{{tx: tx, capped: capped}},
+		sentAt:   m.now(),
+	}
+}
+
+// tracked returns the entry following hash, whichever of its versions hash is
+func (m *stuckTxMonitor) tracked(hash common.Hash) *monitoredTx {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, entry := range m.txs {
+		for _, version := range entry.versions {
+			if version.tx.Hash() == hash {
+				return entry
+			}
+		}
+	}
+	return nil
+}
+
+// check settles mined transactions and speeds up those pending for longer than the timeout
+func (m *stuckTxMonitor) check(ctx context.Context) {
+	m.replaceMu.Lock()
+	defer m.replaceMu.Unlock()
+
+	m.mu.Lock()
+	entries := make([]*monitoredTx, 0, len(m.txs))
+	for _, entry := range m.txs {
+		entries = append(entries, entry)
+	}
+	m.mu.Unlock()
+	if len(entries) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, evmMonitorCallTimeout)
+	defer cancel()
+
+	mined, err := m.chain.NonceAt(ctx, m.account, nil)
+	if err != nil {
+		log.Printf("Stuck transaction monitor on %s failed to read the nonce of %s: %v", m.network, m.account.Hex(), err)
+		return
+	}
+
+	for _, entry := range entries {
+		if entry.nonce < mined {
+			m.settle(ctx, entry)
+			continue
+		}
+		if m.now().Sub(entry.sentAt) < m.timeout {
+			continue
+		}
+		if replacements := len(entry.versions) - 1; replacements >= m.maxReplacements {
+			continue
+		}
+		if _, err := m.replace(ctx, entry, entry.latest().cancel); err != nil {
+			log.Printf("Failed to speed up %s on %s: %v", entry.original.Hex(), m.network, err)
+		}
+	}
+}
+
+// settle stops following a nonce that was mined, reporting the version that made it when it
+// is not the latest one
+func (m *stuckTxMonitor) settle(ctx context.Context, entry *monitoredTx) {
+	for i := len(entry.versions) - 1; i >= 0; i-- {
+		version := entry.versions[i]
+		if _, err := m.chain.TransactionReceipt(ctx, version.tx.Hash()); err != nil {
+			if errors.Is(err, ethereum.NotFound) {
+				continue
+			}
+			// Look again on the next pass
+			log.Printf("Failed to read receipt of %s on %s: %v", version.tx.Hash().Hex(), m.network, err)
+			return
+		}
+		if i != len(entry.versions)-1 {
+			m.notify(entry, version)
+		}
+		m.forget(entry)
+		return
+	}
+
+	log.Printf("Nonce %d of %s on %s was used by a transaction the monitor did not send", entry.nonce, m.account.Hex(), m.network)
+	m.forget(entry)
+}
+
+func (m *stuckTxMonitor) forget(entry *monitoredTx) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.txs[entry.nonce] == entry {
+		delete(m.txs, entry.nonce)
+	}
+}
+
+// cancel replaces the pending transaction hash with a zero-value self-send. Transactions sent
+// before a restart are not tracked and are looked up on the node instead.
+func (m *stuckTxMonitor) cancel(ctx context.Context, hash common.Hash) (*TransactionReplacement, error) {
+	m.replaceMu.Lock()
+	defer m.replaceMu.Unlock()
+
+	entry := m.tracked(hash)
+	if entry == nil {
+		tx, pending, err := m.chain.TransactionByHash(ctx, hash)
+		if errors.Is(err, ethereum.NotFound) {
+			return nil, ErrTransactionNotFound
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up transaction: %w", err)
+		}
+		if !pending {
+			return nil, ErrTransactionNotPending
+		}
+		sender, err := types.Sender(types.LatestSignerForChainID(tx.ChainId()), tx)
+		if err != nil || sender != m.account {
+			return nil, fmt.Errorf("transaction %s was not sent by %s", hash.Hex(), m.account.Hex())
+		}
+		m.track(tx, false)
+		entry = m.tracked(hash)
+	} else if entry.nonce < m.minedNonce(ctx) {
+		return nil, ErrTransactionNotPending
+	}
+
+	return m.replace(ctx, entry, true)
+}
+
+// minedNonce returns the account's nonce in the latest block, or 0 when the node cannot tell
+func (m *stuckTxMonitor) minedNonce(ctx context.Context) uint64 {
+	nonce, err := m.chain.NonceAt(ctx, m.account, nil)
+	if err != nil {
+		return 0
+	}
+	return nonce
+}
+
+// replace sends a new version of entry with bumped fees: the same call, or a zero-value
+// self-send when cancel is set. Callers hold replaceMu.
+func (m *stuckTxMonitor) replace(ctx context.Context, entry *monitoredTx, cancel bool) (*TransactionReplacement, error) {
+	previous := entry.latest().tx
+	feeCap, tip, capped, err := m.bumpedFees(ctx, previous)
+	if err != nil {
+		return nil, err
+	}
+
+	unsigned := replacementTx(previous, feeCap, tip, cancel, m.account)
+	tx, err := m.sign(ctx, unsigned)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign replacement: %w", err)
+	}
+	if err := m.chain.SendTransaction(ctx, tx); err != nil {
+		if strings.Contains(strings.ToLower(err.Error()), "nonce too low") {
+			// An earlier version was mined meanwhile; the next pass settles it
+			return nil, fmt.Errorf("%w: %v", ErrTransactionNotPending, err)
+		}
+		return nil, err
+	}
+
+	version := sentVersion{tx: tx, cancel: cancel, capped: capped}
+	m.mu.Lock()
+	entry.versions = append(entry.versions, version)
+	entry.sentAt = m.now()
+	m.mu.Unlock()
+	log.Printf("Replaced %s on %s with %s (nonce %d, cancel %v)", previous.Hash().Hex(), m.network, tx.Hash().Hex(), entry.nonce, cancel)
+
+	return m.notify(entry, version), nil
+}
+
+// notify reports that version now stands for entry
+func (m *stuckTxMonitor) notify(entry *monitoredTx, version sentVersion) *TransactionReplacement {
+	replacement := &TransactionReplacement{
+		Original:    entry.original.Hex(),
+		Replacement: version.tx.Hash().Hex(),
+		Fee:         evmFee(m.policy, version.tx, version.capped),
+		Cancelled:   version.cancel,
+	}
+
+	m.mu.Lock()
+	onReplaced := m.onReplaced
+	m.mu.Unlock()
+	if onReplaced != nil {
+		onReplaced(*replacement)
+	}
+	return replacement
+}
+
+// bumpedFees prices a replacement of tx: its fees raised by the bump percentage, or the current
+// quote when that is higher, within the policy's caps. Nodes only accept the replacement when
+// both fees rose by at least minReplacementBumpPercent.
+func (m *stuckTxMonitor) bumpedFees(ctx context.Context, tx *types.Transaction) (feeCap, tip *big.Int, capped bool, err error) {
+	quote, err := quoteEVMFees(ctx, m.chain, m.policy)
+	if err != nil {
+		return nil, nil, false, err
+	}
+
+	if tx.Type() != types.DynamicFeeTxType {
+		price := maxBig(bumpFee(tx.GasPrice(), m.bumpPercent), quote.gasPrice)
+		price, capped = capFee(price, m.policy.MaxPrice)
+		if price.Cmp(bumpFee(tx.GasPrice(), minReplacementBumpPercent)) < 0 {
+			return nil, nil, capped, errReplacementCapped
+		}
+		return price, nil, capped, nil
+	}
+
+	tip = maxBig(bumpFee(tx.GasTipCap(), m.bumpPercent), quote.gasTipCap)
+	tip, tipCapped := capFee(tip, m.policy.MaxPriorityFee)
+	feeCap = maxBig(bumpFee(tx.GasFeeCap(), m.bumpPercent), quote.gasFeeCap)
+	feeCap, feeCapped := capFee(feeCap, m.policy.MaxPrice)
+	if tip.Cmp(feeCap) > 0 {
+		tip = new(big.Int).Set(feeCap)
+	}
+	if feeCap.Cmp(bumpFee(tx.GasFeeCap(), minReplacementBumpPercent)) < 0 || tip.Cmp(bumpFee(tx.GasTipCap(), minReplacementBumpPercent)) < 0 {
+		return nil, nil, true, errReplacementCapped
+	}
+	return feeCap, tip, tipCapped || feeCapped, nil
+}
+
+// replacementTx returns an unsigned transaction with the nonce of tx and the given fees. A
+// legacy transaction takes feeCap as its gas price. A cancellation sends nothing to self.
+func replacementTx(tx *types.Transaction, feeCap, tip *big.Int, cancel bool, self common.Address) *types.Transaction {
+	to, value, data, gas := tx.To(), tx.Value(), tx.Data(), tx.Gas()
+	if cancel {
+		to, value, data, gas = &self, new(big.Int), nil, params.TxGas
+	}
+
+	if tx.Type() == types.DynamicFeeTxType {
+		return types.NewTx(&types.DynamicFeeTx{
+			ChainID:    tx.ChainId(),
+			Nonce:      tx.Nonce(),
+			GasTipCap:  tip,
+			GasFeeCap:  feeCap,
+			Gas:        gas,
+			To:         to,
+			Value:      value,
+			Data:       data,
+			AccessList: tx.AccessList(),
+		})
+	}
+	return types.NewTx(&types.LegacyTx{
+		Nonce:    tx.Nonce(),
+		GasPrice: feeCap,
+		Gas:      gas,
+		To:       to,
+		Value:    value,
+		Data:     data,
+	})
+}
+
+// bumpFee raises fee by percent, rounding up
+func bumpFee(fee *big.Int, percent uint64) *big.Int {
+	bumped := new(big.Int).Mul(fee, new(big.Int).SetUint64(100+percent))
+	bumped.Add(bumped, big.NewInt(99))
+	return bumped.Div(bumped, big.NewInt(100))
+}
+
+// capFee cuts fee to max, which is no cap when zero
+func capFee(fee *big.Int, max uint64) (*big.Int, bool) {
+	if max > 0 && fee.Cmp(new(big.Int).SetUint64(max)) > 0 {
+		return new(big.Int).SetUint64(max), true
+	}
+	return fee, false
+}
+
+func maxBig(a, b *big.Int) *big.Int {
+	if b != nil && b.Cmp(a) > 0 {
+		return b
+	}
+	return a
+}
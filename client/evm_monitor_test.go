@@ -0,0 +1,251 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"tinypay-server/config"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// fakeMonitorChain is a node whose mempool and mined receipts the test controls
+type fakeMonitorChain struct {
+	fakeFeeSource
+	mined    uint64                             // nonce of the account in the latest block
+	receipts map[common.Hash]bool               // mined transactions
+	pool     map[common.Hash]*types.Transaction // pending transactions
+	sent     []*types.Transaction
+	onSend   func() // called before a transaction enters the pool
+}
+
+func newFakeMonitorChain() *fakeMonitorChain {
+	return &fakeMonitorChain{
+		fakeFeeSource: fakeFeeSource{baseFee: big.NewInt(100), tip: 10},
+		receipts:      make(map[common.Hash]bool),
+		pool:          make(map[common.Hash]*types.Transaction),
+	}
+}
+
+func (f *fakeMonitorChain) NonceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (uint64, error) {
+	return f.mined, nil
+}
+
+func (f *fakeMonitorChain) TransactionByHash(ctx context.Context, hash common.Hash) (*types.Transaction, bool, error) {
+	if tx, ok := f.pool[hash]; ok {
+		return tx, true, nil
+	}
+	return nil, false, ethereum.NotFound
+}
+
+func (f *fakeMonitorChain) TransactionReceipt(ctx context.Context, hash common.Hash) (*types.Receipt, error) {
+	if f.receipts[hash] {
+		return &types.Receipt{Status: 1}, nil
+	}
+	return nil, ethereum.NotFound
+}
+
+func (f *fakeMonitorChain) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	if f.onSend != nil {
+		f.onSend()
+	}
+	f.sent = append(f.sent, tx)
+	f.pool[tx.Hash()] = tx
+	return nil
+}
+
+// newTestMonitor returns a monitor with a 1 minute timeout on a fake chain, its clock and the
+// replacements it reported
+func newTestMonitor(t *testing.T, policy config.FeePolicy) (*stuckTxMonitor, *fakeMonitorChain, *time.Time, *[]TransactionReplacement) {
+	t.Helper()
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	chainID := big.NewInt(11155111)
+	sign := func(_ context.Context, tx *types.Transaction) (*types.Transaction, error) {
+		return types.SignTx(tx, types.LatestSignerForChainID(chainID), key)
+	}
+
+	chain := newFakeMonitorChain()
+	cfg := &config.Config{EVMStuckTxTimeout: time.Minute, EVMFeeBumpPercent: 20, EVMMaxReplacements: 2}
+	m := newStuckTxMonitor(chain, crypto.PubkeyToAddress(key.PublicKey), sign, policy, "sepolia", cfg)
+
+	now := time.Unix(1700000000, 0)
+	m.now = func() time.Time { return now }
+	var replacements []TransactionReplacement
+	m.setOnReplaced(func(r TransactionReplacement) { replacements = append(replacements, r) })
+	return m, chain, &now, &replacements
+}
+
+// sendTracked signs, sends and tracks a payment transaction with nonce
+func sendTracked(t *testing.T, m *stuckTxMonitor, chain *fakeMonitorChain, nonce uint64) *types.Transaction {
+	t.Helper()
+	to := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	tx, err := m.sign(context.Background(), types.NewTx(&types.DynamicFeeTx{
+		ChainID:   big.NewInt(11155111),
+		Nonce:     nonce,
+		GasFeeCap: big.NewInt(210),
+		GasTipCap: big.NewInt(10),
+		Gas:       90000,
+		To:        &to,
+		Data:      []byte{0xde, 0xad},
+	}))
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	chain.SendTransaction(context.Background(), tx)
+	m.track(tx, false)
+	return tx
+}
+
+func TestStuckTxMonitorSpeedsUpAfterTimeout(t *testing.T) {
+	m, chain, now, replacements := newTestMonitor(t, config.FeePolicy{})
+	ctx := context.Background()
+	original := sendTracked(t, m, chain, 7)
+
+	m.check(ctx)
+	if len(chain.sent) != 1 {
+		t.Fatalf("expected no replacement before the timeout, sent %d", len(chain.sent))
+	}
+
+	*now = now.Add(time.Minute)
+	m.check(ctx)
+	if len(chain.sent) != 2 {
+		t.Fatalf("expected a replacement after the timeout, sent %d", len(chain.sent))
+	}
+	replacement := chain.sent[1]
+	if replacement.Nonce() != 7 || replacement.GasFeeCap().Int64() != 252 || replacement.GasTipCap().Int64() != 12 {
+		t.Errorf("expected nonce 7 with fees raised by 20%%, got nonce %d, fees %v/%v", replacement.Nonce(), replacement.GasFeeCap(), replacement.GasTipCap())
+	}
+	if string(replacement.Data()) != string(original.Data()) || *replacement.To() != *original.To() {
+		t.Error("expected the replacement to repeat the original call")
+	}
+	if len(*replacements) != 1 || (*replacements)[0].Original != original.Hash().Hex() || (*replacements)[0].Replacement != replacement.Hash().Hex() {
+		t.Errorf("unexpected replacements %+v", *replacements)
+	}
+
+	// The timeout restarts with every replacement, and stops at the configured number of replacements
+	m.check(ctx)
+	*now = now.Add(time.Minute)
+	m.check(ctx)
+	*now = now.Add(time.Minute)
+	m.check(ctx)
+	if len(chain.sent) != 3 {
+		t.Errorf("expected 2 replacements at most, sent %d", len(chain.sent))
+	}
+}
+
+func TestStuckTxMonitorLookupDuringReplacement(t *testing.T) {
+	m, chain, now, _ := newTestMonitor(t, config.FeePolicy{})
+	original := sendTracked(t, m, chain, 7)
+	*now = now.Add(time.Minute)
+
+	// Cancellation looks entries up without replaceMu, here while the replacement is being sent
+	looked := make(chan struct{})
+	chain.onSend = func() {
+		go func() {
+			defer close(looked)
+			m.tracked(original.Hash())
+		}()
+		// Not waiting on looked, so only the monitor's own locking orders the lookup
+		time.Sleep(10 * time.Millisecond)
+	}
+	m.check(context.Background())
+	<-looked
+
+	if m.tracked(chain.sent[1].Hash()) == nil {
+		t.Error("expected the replacement to be tracked")
+	}
+}
+
+func TestStuckTxMonitorRespectsFeeCaps(t *testing.T) {
+	m, chain, now, _ := newTestMonitor(t, config.FeePolicy{MaxPrice: 220})
+	sendTracked(t, m, chain, 7)
+
+	*now = now.Add(time.Minute)
+	m.check(context.Background())
+	if len(chain.sent) != 1 {
+		t.Errorf("expected no replacement when the cap allows less than the required bump, sent %d", len(chain.sent))
+	}
+}
+
+func TestStuckTxMonitorSettlesMinedVersion(t *testing.T) {
+	m, chain, now, replacements := newTestMonitor(t, config.FeePolicy{})
+	ctx := context.Background()
+	original := sendTracked(t, m, chain, 7)
+	*now = now.Add(time.Minute)
+	m.check(ctx)
+
+	// The original made it into a block after all
+	chain.mined = 8
+	chain.receipts[original.Hash()] = true
+	m.check(ctx)
+
+	if len(*replacements) != 2 || (*replacements)[1].Replacement != original.Hash().Hex() {
+		t.Errorf("expected the mined original to be reported, got %+v", *replacements)
+	}
+	if len(m.txs) != 0 {
+		t.Errorf("expected the mined nonce to be forgotten, still tracking %d", len(m.txs))
+	}
+}
+
+func TestStuckTxMonitorCancel(t *testing.T) {
+	m, chain, _, replacements := newTestMonitor(t, config.FeePolicy{})
+	ctx := context.Background()
+	original := sendTracked(t, m, chain, 7)
+
+	replacement, err := m.cancel(ctx, original.Hash())
+	if err != nil {
+		t.Fatalf("cancel: %v", err)
+	}
+	cancel := chain.sent[len(chain.sent)-1]
+	if *cancel.To() != m.account || cancel.Value().Sign() != 0 || len(cancel.Data()) != 0 || cancel.Gas() != 21000 || cancel.Nonce() != 7 {
+		t.Errorf("expected a zero-value self-send with nonce 7, got %+v", cancel)
+	}
+	if !replacement.Cancelled || replacement.Replacement != cancel.Hash().Hex() || len(*replacements) != 1 {
+		t.Errorf("unexpected cancel replacement %+v", replacement)
+	}
+
+	chain.mined = 8
+	if _, err := m.cancel(ctx, original.Hash()); !errors.Is(err, ErrTransactionNotPending) {
+		t.Errorf("expected ErrTransactionNotPending for a mined nonce, got %v", err)
+	}
+}
+
+func TestStuckTxMonitorCancelsUntrackedTransaction(t *testing.T) {
+	m, chain, _, _ := newTestMonitor(t, config.FeePolicy{})
+	ctx := context.Background()
+
+	// Sent before a restart: pending on the node but unknown to the monitor
+	pending := sendTracked(t, m, chain, 3)
+	m.txs = make(map[uint64]*monitoredTx)
+
+	if _, err := m.cancel(ctx, pending.Hash()); err != nil {
+		t.Fatalf("cancel: %v", err)
+	}
+	if cancel := chain.sent[len(chain.sent)-1]; cancel.Nonce() != 3 || *cancel.To() != m.account {
+		t.Errorf("expected a self-send with nonce 3, got %+v", cancel)
+	}
+	if _, err := m.cancel(ctx, common.HexToHash("0x01")); !errors.Is(err, ErrTransactionNotFound) {
+		t.Errorf("expected ErrTransactionNotFound for an unknown hash, got %v", err)
+	}
+}
+
+func TestBumpedFeesUseHigherQuote(t *testing.T) {
+	m, _, _, _ := newTestMonitor(t, config.FeePolicy{Mode: config.FeeModeEstimate})
+	to := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	tx := types.NewTx(&types.DynamicFeeTx{GasFeeCap: big.NewInt(50), GasTipCap: big.NewInt(1), To: &to})
+
+	// The base fee of 100 has risen far above the transaction's fee cap
+	feeCap, tip, capped, err := m.bumpedFees(context.Background(), tx)
+	if err != nil || feeCap.Int64() != 210 || tip.Int64() != 10 || capped {
+		t.Errorf("expected the current quote 210/10, got %v/%v (capped %v, %v)", feeCap, tip, capped, err)
+	}
+}
@@ -0,0 +1,230 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	gethrpc "github.com/ethereum/go-ethereum/rpc"
+)
+
+// evmNonceResyncInterval is how long an idle allocator trusts its local nonce before asking the
+// node again, which picks up transactions sent with the same key from elsewhere
+const evmNonceResyncInterval = 30 * time.Second
+
+// nonceSource reads the next nonce of an account, counting transactions in the node's pool
+type nonceSource interface {
+	PendingNonceAt(ctx context.Context, account common.Address) (uint64, error)
+}
+
+// nonceManager hands out nonces for one sending account without a round trip per transaction,
+// so concurrent payments from one paymaster key get distinct, gap-free nonces
+type nonceManager struct {
+	source   nonceSource
+	account  common.Address
+	mu       sync.Mutex
+	synced   bool
+	syncedAt time.Time
+	next     uint64              // lowest nonce never handed out
+	free     []uint64            // handed-out nonces that were not used, reused lowest first
+	inFlight map[uint64]struct{} // handed out, send outcome not known yet
+	now      func() time.Time
+}
+
+func newNonceManager(source nonceSource, account common.Address) *nonceManager {
+	return &nonceManager{
+		source:   source,
+		account:  account,
+		inFlight: make(map[uint64]struct{}),
+		now:      time.Now,
+	}
+}
+
+// acquire returns the nonce for the next transaction. Every nonce must be passed to release.
+func (n *nonceManager) acquire(ctx context.Context) (uint64, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	idle := len(n.inFlight) == 0 && n.now().Sub(n.syncedAt) >= evmNonceResyncInterval
+	if !n.synced || idle {
+		if err := n.resync(ctx); err != nil {
+			return 0, err
+		}
+	}
+
+	var nonce uint64
+	if len(n.free) > 0 {
+		nonce, n.free = n.free[0], n.free[1:]
+	} else {
+		nonce = n.next
+		n.next++
+	}
+	n.inFlight[nonce] = struct{}{}
+	return nonce, nil
+}
+
+// release records the outcome of sending a transaction with nonce
+func (n *nonceManager) release(nonce uint64, sendErr error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	delete(n.inFlight, nonce)
+
+	var rpcErr gethrpc.Error
+	switch {
+	case sendErr == nil:
+	case isNonceError(sendErr):
+		// The node disagrees with the local view, for example because the key was used elsewhere
+		log.Printf("Nonce %d of %s rejected (%v), resyncing from the node", nonce, n.account.Hex(), sendErr)
+		n.synced = false
+	case errors.As(sendErr, &rpcErr):
+		// The node refused the transaction, so the nonce was not used
+		n.free = insertSorted(n.free, nonce)
+	default:
+		// The transaction may or may not have reached the node; the next resync finds out
+		n.synced = false
+	}
+}
+
+// resync reloads the pending nonce from the node. Nonces below it are used. Handed-out nonces at
+// or above it that are neither in flight nor free were sent but are unknown to the node: the
+// transactions were dropped, and the gap would block every later transaction, so they are reused.
+func (n *nonceManager) resync(ctx context.Context) error {
+	pending, err := n.source.PendingNonceAt(ctx, n.account)
+	if err != nil {
+		return fmt.Errorf("failed to get pending nonce: %w", err)
+	}
+
+	free := n.free[:0]
+	for _, nonce := range n.free {
+		if nonce >= pending && nonce < n.next {
+			free = append(free, nonce)
+		}
+	}
+	n.free = free
+
+	if pending >= n.next {
+		n.next = pending
+	} else {
+		for nonce := pending; nonce < n.next; nonce++ {
+			if _, ok := n.inFlight[nonce]; ok || containsSorted(n.free, nonce) {
+				continue
+			}
+			log.Printf("Nonce gap detected for %s at %d, reusing it", n.account.Hex(), nonce)
+			n.free = insertSorted(n.free, nonce)
+		}
+	}
+
+	n.synced = true
+	n.syncedAt = n.now()
+	return nil
+}
+
+// isNonceError reports whether a send failed because the nonce is already used or out of order
+func isNonceError(err error) bool {
+	msg := strings.ToLower(err.Error())
+	for _, fragment := range []string{"nonce too low", "nonce too high", "already known", "known transaction", "replacement transaction underpriced"} {
+		if strings.Contains(msg, fragment) {
+			return true
+		}
+	}
+	return false
+}
+
+func insertSorted(nonces []uint64, nonce uint64) []uint64 {
+	i := sort.Search(len(nonces), func(i int) bool { return nonces[i] >= nonce })
+	if i < len(nonces) && nonces[i] == nonce {
+		return nonces
+	}
+	nonces = append(nonces, 0)
+	copy(nonces[i+1:], nonces[i:])
+	nonces[i] = nonce
+	return nonces
+}
+
+func containsSorted(nonces []uint64, nonce uint64) bool {
+	i := sort.Search(len(nonces), func(i int) bool { return nonces[i] >= nonce })
+	return i < len(nonces) && nonces[i] == nonce
+}
+
+// transact builds a contract call with build, priced by the network's fee policy, and sends it
+// from sender with a nonce from the sender's allocator. The call is first built without being
+// signed or sent, so calls that revert during gas estimation never take a nonce, and the key
+// only signs the transaction that is sent.
+func (c *EVMClient) transact(ctx context.Context, sender *evmSender, build func(*bind.TransactOpts) (*types.Transaction, error)) (*types.Transaction, *Fee, error) {
+	auth := &bind.TransactOpts{
+		From:    sender.from,
+		Context: ctx,
+		// Leaves the built transaction unsigned
+		Signer: func(from common.Address, tx *types.Transaction) (*types.Transaction, error) {
+			if from != sender.from {
+				return nil, bind.ErrNotAuthorized
+			}
+			return tx, nil
+		},
+	}
+	auth.NoSend = true
+	auth.Nonce = new(big.Int) // placeholder, replaced below
+	auth.GasLimit = c.fees.Limit
+
+	quote, err := quoteEVMFees(ctx, c.ethClient, c.fees)
+	if err != nil {
+		return nil, nil, err
+	}
+	quote.apply(auth)
+
+	unsent, err := build(auth)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	nonce, err := sender.nonces.acquire(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	tx, err := signEVMTransaction(ctx, sender.signer, c.chainID, withNonce(unsent, nonce))
+	if err != nil {
+		sender.nonces.release(nonce, err)
+		return nil, nil, fmt.Errorf("failed to sign transaction: %w", err)
+	}
+
+	err = c.ethClient.SendTransaction(ctx, tx)
+	sender.nonces.release(nonce, err)
+	if err != nil {
+		return nil, nil, err
+	}
+	return tx, evmFee(c.fees, tx, quote.capped), nil
+}
+
+// withNonce returns an unsigned copy of tx that uses nonce
+func withNonce(tx *types.Transaction, nonce uint64) *types.Transaction {
+	if tx.Type() == types.DynamicFeeTxType {
+		return types.NewTx(&types.DynamicFeeTx{
+			ChainID:    tx.ChainId(),
+			Nonce:      nonce,
+			GasTipCap:  tx.GasTipCap(),
+			GasFeeCap:  tx.GasFeeCap(),
+			Gas:        tx.Gas(),
+			To:         tx.To(),
+			Value:      tx.Value(),
+			Data:       tx.Data(),
+			AccessList: tx.AccessList(),
+		})
+	}
+	return types.NewTx(&types.LegacyTx{
+		Nonce:    nonce,
+		GasPrice: tx.GasPrice(),
+		Gas:      tx.Gas(),
+		To:       tx.To(),
+		Value:    tx.Value(),
+		Data:     tx.Data(),
+	})
+}
@@ -0,0 +1,174 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"tinypay-server/signer"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// fakeNonceSource reports a fixed pending nonce and counts lookups
+type fakeNonceSource struct {
+	mu      sync.Mutex
+	pending uint64
+	calls   int
+}
+
+func (f *fakeNonceSource) PendingNonceAt(ctx context.Context, account common.Address) (uint64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+	return f.pending, nil
+}
+
+// rejectedError mimics an error response from the node
+type rejectedError struct{ msg string }
+
+func (e rejectedError) Error() string  { return e.msg }
+func (e rejectedError) ErrorCode() int { return -32000 }
+
+func TestNonceManager_HandsOutDistinctNonces(t *testing.T) {
+	source := &fakeNonceSource{pending: 7}
+	n := newNonceManager(source, common.Address{})
+
+	var mu sync.Mutex
+	seen := make(map[uint64]bool)
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			nonce, err := n.acquire(context.Background())
+			if err != nil {
+				t.Errorf("acquire: %v", err)
+				return
+			}
+			mu.Lock()
+			if seen[nonce] {
+				t.Errorf("nonce %d handed out twice", nonce)
+			}
+			seen[nonce] = true
+			mu.Unlock()
+			n.release(nonce, nil)
+		}()
+	}
+	wg.Wait()
+
+	for nonce := uint64(7); nonce < 27; nonce++ {
+		if !seen[nonce] {
+			t.Errorf("nonce %d was skipped", nonce)
+		}
+	}
+	if source.calls != 1 {
+		t.Errorf("expected one lookup, got %d", source.calls)
+	}
+}
+
+func TestNonceManager_ReusesRejectedNonces(t *testing.T) {
+	n := newNonceManager(&fakeNonceSource{pending: 3}, common.Address{})
+	ctx := context.Background()
+
+	first, _ := n.acquire(ctx)
+	second, _ := n.acquire(ctx)
+	n.release(second, nil)
+	n.release(first, rejectedError{"insufficient funds for gas * price + value"})
+
+	if nonce, _ := n.acquire(ctx); nonce != first {
+		t.Errorf("expected the rejected nonce %d to be reused, got %d", first, nonce)
+	}
+	if nonce, _ := n.acquire(ctx); nonce != 5 {
+		t.Errorf("expected nonce 5, got %d", nonce)
+	}
+}
+
+func TestNonceManager_ResyncsOnNonceErrors(t *testing.T) {
+	source := &fakeNonceSource{pending: 3}
+	n := newNonceManager(source, common.Address{})
+	ctx := context.Background()
+
+	nonce, _ := n.acquire(ctx)
+	source.pending = 10 // the key was used from elsewhere
+	n.release(nonce, rejectedError{"nonce too low: next nonce 10, tx nonce 3"})
+
+	if nonce, _ := n.acquire(ctx); nonce != 10 {
+		t.Errorf("expected nonce 10 after resync, got %d", nonce)
+	}
+}
+
+func TestNonceManager_FillsGaps(t *testing.T) {
+	source := &fakeNonceSource{pending: 0}
+	n := newNonceManager(source, common.Address{})
+	now := time.Now()
+	n.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		nonce, _ := n.acquire(ctx)
+		n.release(nonce, nil)
+	}
+
+	// Nonce 1 was dropped from the pool, so the node only counts nonce 0
+	source.pending = 1
+	now = now.Add(evmNonceResyncInterval)
+	for _, want := range []uint64{1, 2, 3} {
+		nonce, _ := n.acquire(ctx)
+		if nonce != want {
+			t.Errorf("expected nonce %d, got %d", want, nonce)
+		}
+	}
+}
+
+func TestNonceManager_UnknownSendOutcomeResyncs(t *testing.T) {
+	source := &fakeNonceSource{pending: 0}
+	n := newNonceManager(source, common.Address{})
+	ctx := context.Background()
+
+	nonce, _ := n.acquire(ctx)
+	source.pending = 1 // the transaction reached the node before the connection failed
+	n.release(nonce, errors.New("context deadline exceeded"))
+
+	if nonce, _ := n.acquire(ctx); nonce != 1 {
+		t.Errorf("expected nonce 1, got %d", nonce)
+	}
+	if source.calls != 2 {
+		t.Errorf("expected a resync, got %d lookups", source.calls)
+	}
+}
+
+func TestWithNonce(t *testing.T) {
+	to := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	dynamic := types.NewTx(&types.DynamicFeeTx{Nonce: 0, Gas: 21000, To: &to, Data: []byte{1, 2}})
+	legacy := types.NewTx(&types.LegacyTx{Nonce: 0, Gas: 21000, To: &to})
+
+	for _, tx := range []*types.Transaction{dynamic, legacy} {
+		got := withNonce(tx, 42)
+		if got.Nonce() != 42 || got.Type() != tx.Type() || got.Gas() != tx.Gas() || *got.To() != to || string(got.Data()) != string(tx.Data()) {
+			t.Errorf("type %d: unexpected copy %+v", tx.Type(), got)
+		}
+	}
+
+	// Contract bindings build dynamic fee transactions without a chain ID; signing the unsigned copy adds it
+	key, _ := crypto.GenerateKey()
+	local, err := signer.NewEVM(hexutil.Encode(crypto.FromECDSA(key)), signer.RemoteConfig{})
+	if err != nil {
+		t.Fatalf("new signer: %v", err)
+	}
+	chainID := big.NewInt(11155111)
+	signed, err := signEVMTransaction(context.Background(), local, chainID, withNonce(dynamic, 42))
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	from, err := types.Sender(types.LatestSignerForChainID(chainID), signed)
+	if err != nil || from != crypto.PubkeyToAddress(key.PublicKey) || signed.ChainId().Cmp(chainID) != 0 || signed.Nonce() != 42 {
+		t.Errorf("expected nonce 42 signed by the key for chain %s, got %+v from %s: %v", chainID, signed, from.Hex(), err)
+	}
+}
@@ -0,0 +1,154 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"tinypay-server/config"
+	"tinypay-server/signer"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// evmSender is one paymaster key of an EVM network. Every key has its own nonce stream, so each
+// gets its own allocator and stuck transaction monitor.
+type evmSender struct {
+	signer  signer.Signer // Holds the key locally or asks the remote signer
+	from    common.Address
+	nonces  *nonceManager
+	monitor *stuckTxMonitor // Speeds up and cancels the key's pending payment transactions
+}
+
+// newSender creates the signer of a configured key field, a hex private key or remote:<public key>,
+// and starts the monitor of its transactions
+func (c *EVMClient) newSender(value string) (*evmSender, error) {
+	var remote signer.RemoteConfig
+	if c.cfg != nil {
+		remote = c.cfg.RemoteSigner()
+	}
+	s, err := signer.NewEVM(value, remote)
+	if err != nil {
+		return nil, err
+	}
+	from, err := evmSignerAddress(s)
+	if err != nil {
+		return nil, err
+	}
+
+	sender := &evmSender{
+		signer: s,
+		from:   from,
+		nonces: newNonceManager(c.ethClient, from),
+	}
+	sign := func(ctx context.Context, tx *types.Transaction) (*types.Transaction, error) {
+		return signEVMTransaction(ctx, s, c.chainID, tx)
+	}
+	sender.monitor = newStuckTxMonitor(c.ethClient, from, sign, c.fees, c.network, c.cfg)
+	sender.monitor.start()
+	return sender, nil
+}
+
+// senderBalance reads the native balance of the paymaster key at index
+func (c *EVMClient) senderBalance(ctx context.Context, index int) (*big.Int, error) {
+	return c.ethClient.BalanceAt(ctx, c.senders[index].from, nil)
+}
+
+// acquireSender takes the paymaster key of the next transaction: the key with address when set,
+// otherwise the pool's choice. The returned func gives the key back.
+func (c *EVMClient) acquireSender(address string) (*evmSender, func(), error) {
+	var index int
+	var err error
+	if address != "" {
+		index, err = c.paymasters.acquireAddress(address)
+	} else {
+		index, err = c.paymasters.acquire()
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return c.senders[index], func() { c.paymasters.release(index) }, nil
+}
+
+// GetPaymasterAddresses returns the addresses of the network's paymaster keys
+func (c *EVMClient) GetPaymasterAddresses() []string {
+	addresses := make([]string, len(c.senders))
+	for i, sender := range c.senders {
+		addresses[i] = sender.from.Hex()
+	}
+	return addresses
+}
+
+// DrainPaymaster stops selecting a paymaster key for new transactions. Its pending transactions
+// are still followed, sped up and cancelled.
+func (c *EVMClient) DrainPaymaster(address string) (*PaymasterKey, error) {
+	return c.paymasters.setDraining(address, true)
+}
+
+// ResumePaymaster puts a drained paymaster key back into rotation
+func (c *EVMClient) ResumePaymaster(address string) (*PaymasterKey, error) {
+	return c.paymasters.setDraining(address, false)
+}
+
+// Paymasters returns the state of the network's paymaster keys
+func (c *EVMClient) Paymasters() []PaymasterKey {
+	return c.paymasters.states()
+}
+
+// PaymasterBalance reads the native balance, in wei, of the paymaster key with address
+func (c *EVMClient) PaymasterBalance(ctx context.Context, address string) (*big.Int, error) {
+	return c.paymasters.readBalance(ctx, address)
+}
+
+// NativeUnit names the unit of paymaster balances
+func (c *EVMClient) NativeUnit() string {
+	return "wei"
+}
+
+// PaymasterPool returns the configuration of the network's paymaster pool
+func (c *EVMClient) PaymasterPool() config.PaymasterPool {
+	return c.paymasters.cfg
+}
+
+// OnReplaced registers fn to be called when a payment transaction is sped up or cancelled
+func (c *EVMClient) OnReplaced(fn func(TransactionReplacement)) {
+	for _, sender := range c.senders {
+		sender.monitor.setOnReplaced(fn)
+	}
+}
+
+// CancelTransaction replaces a pending transaction of one of the network's paymaster keys with a
+// zero-value self-send using the same nonce and higher fees
+func (c *EVMClient) CancelTransaction(ctx context.Context, txHash string) (*TransactionReplacement, error) {
+	hash := common.HexToHash(ensureHexPrefix(txHash))
+	for _, sender := range c.senders {
+		if sender.monitor.tracked(hash) != nil {
+			return sender.monitor.cancel(ctx, hash)
+		}
+	}
+
+	// Sent before a restart: the sender is recovered from the transaction itself
+	tx, pending, err := c.ethClient.TransactionByHash(ctx, hash)
+	if errors.Is(err, ethereum.NotFound) {
+		return nil, ErrTransactionNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up transaction: %w", err)
+	}
+	if !pending {
+		return nil, ErrTransactionNotPending
+	}
+	from, err := types.Sender(types.LatestSignerForChainID(tx.ChainId()), tx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to recover the sender of %s: %w", hash.Hex(), err)
+	}
+	for _, sender := range c.senders {
+		if sender.from == from {
+			return sender.monitor.cancel(ctx, hash)
+		}
+	}
+	return nil, fmt.Errorf("transaction %s was not sent by a paymaster of %s", hash.Hex(), c.network)
+}
@@ -0,0 +1,69 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"tinypay-server/signer"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// signEVMTransaction signs tx for chainID through s. Web3Signer signs keccak256 of the data it is
+// given, so s gets the transaction's signing preimage rather than its hash.
+func signEVMTransaction(ctx context.Context, s signer.Signer, chainID *big.Int, tx *types.Transaction) (*types.Transaction, error) {
+	preimage, err := evmSigningPreimage(chainID, tx)
+	if err != nil {
+		return nil, err
+	}
+	txSigner := types.LatestSignerForChainID(chainID)
+	// A preimage that does not hash to the signer's hash would produce a valid signature of the
+	// wrong sender; refuse rather than send it
+	if common.BytesToHash(crypto.Keccak256(preimage)) != txSigner.Hash(tx) {
+		return nil, fmt.Errorf("unsupported signing preimage for transaction type %d", tx.Type())
+	}
+	signature, err := s.Sign(ctx, preimage)
+	if err != nil {
+		return nil, err
+	}
+	return tx.WithSignature(txSigner, signature)
+}
+
+// evmSigningPreimage returns the bytes whose keccak256 is tx's EIP-155, EIP-2930 or EIP-1559
+// signing hash
+func evmSigningPreimage(chainID *big.Int, tx *types.Transaction) ([]byte, error) {
+	switch tx.Type() {
+	case types.LegacyTxType:
+		return rlp.EncodeToBytes([]any{
+			tx.Nonce(), tx.GasPrice(), tx.Gas(), tx.To(), tx.Value(), tx.Data(),
+			chainID, uint(0), uint(0),
+		})
+	case types.AccessListTxType:
+		payload, err := rlp.EncodeToBytes([]any{
+			chainID, tx.Nonce(), tx.GasPrice(), tx.Gas(), tx.To(), tx.Value(), tx.Data(),
+			tx.AccessList(),
+		})
+		return append([]byte{types.AccessListTxType}, payload...), err
+	case types.DynamicFeeTxType:
+		payload, err := rlp.EncodeToBytes([]any{
+			chainID, tx.Nonce(), tx.GasTipCap(), tx.GasFeeCap(), tx.Gas(), tx.To(), tx.Value(), tx.Data(),
+			tx.AccessList(),
+		})
+		return append([]byte{types.DynamicFeeTxType}, payload...), err
+	default:
+		return nil, fmt.Errorf("unsupported transaction type %d", tx.Type())
+	}
+}
+
+// evmSignerAddress returns the address of a secp256k1 signer
+func evmSignerAddress(s signer.Signer) (common.Address, error) {
+	publicKey, err := crypto.UnmarshalPubkey(s.PublicKey())
+	if err != nil {
+		return common.Address{}, fmt.Errorf("invalid signer public key: %w", err)
+	}
+	return crypto.PubkeyToAddress(*publicKey), nil
+}
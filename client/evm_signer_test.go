@@ -0,0 +1,61 @@
+package client
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"tinypay-server/signer"
+	"tinypay-server/signer/signertest"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestSignEVMTransaction_RemoteMatchesLocal(t *testing.T) {
+	server := signertest.NewServer("token")
+	defer server.Close()
+	key, _ := crypto.GenerateKey()
+	identifier := server.AddSecp256k1(key)
+	remote, err := signer.NewEVM(signer.RemotePrefix+identifier, signer.RemoteConfig{URL: server.URL, Token: "token"})
+	if err != nil {
+		t.Fatalf("new remote signer: %v", err)
+	}
+
+	chainID := big.NewInt(11155111)
+	to := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	accessList := types.AccessList{{Address: to, StorageKeys: []common.Hash{{0x01}}}}
+	txs := map[string]*types.Transaction{
+		"legacy": types.NewTx(&types.LegacyTx{
+			Nonce: 7, GasPrice: big.NewInt(100), Gas: 90000, To: &to, Value: big.NewInt(1), Data: []byte{0xde, 0xad},
+		}),
+		"access list": types.NewTx(&types.AccessListTx{
+			ChainID: chainID, Nonce: 8, GasPrice: big.NewInt(100), Gas: 90000, To: &to, Data: []byte{0xbe, 0xef}, AccessList: accessList,
+		}),
+		"dynamic fee": types.NewTx(&types.DynamicFeeTx{
+			ChainID: chainID, Nonce: 9, GasTipCap: big.NewInt(10), GasFeeCap: big.NewInt(210), Gas: 90000, To: &to, Data: []byte{0xca, 0xfe}, AccessList: accessList,
+		}),
+		"contract creation": types.NewTx(&types.DynamicFeeTx{
+			ChainID: chainID, Nonce: 10, GasTipCap: big.NewInt(10), GasFeeCap: big.NewInt(210), Gas: 90000, Data: []byte{0x60, 0x00},
+		}),
+	}
+
+	txSigner := types.LatestSignerForChainID(chainID)
+	for name, tx := range txs {
+		t.Run(name, func(t *testing.T) {
+			signed, err := signEVMTransaction(context.Background(), remote, chainID, tx)
+			if err != nil {
+				t.Fatalf("sign: %v", err)
+			}
+			from, err := types.Sender(txSigner, signed)
+			if err != nil || from != crypto.PubkeyToAddress(key.PublicKey) {
+				t.Errorf("expected the transaction to be sent by the remote key, got %s, %v", from.Hex(), err)
+			}
+			local, _ := types.SignTx(tx, txSigner, key)
+			if signed.Hash() != local.Hash() {
+				t.Errorf("expected the remote signature to match local signing, got %s and %s", signed.Hash().Hex(), local.Hash().Hex())
+			}
+		})
+	}
+}
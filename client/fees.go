@@ -0,0 +1,50 @@
+package client
+
+import (
+	"math"
+
+	"tinypay-server/config"
+)
+
+// Fee units recorded with each fee
+const (
+	FeeUnitOctasPerGas          = "octas/gas"
+	FeeUnitWeiPerGas            = "wei/gas"
+	FeeUnitMicroLamportsPerUnit = "micro-lamports/compute-unit"
+)
+
+// Fee is the price a transaction was sent with, in the chain's smallest units
+type Fee struct {
+	Mode        string // fee policy mode that produced the price
+	Unit        string // unit of Price and PriorityFee
+	Price       uint64 // gas unit price on Aptos, max fee per gas or gas price on EVM, compute unit price on Solana
+	PriorityFee uint64 // EVM max priority fee per gas; zero elsewhere
+	Limit       uint64 // max gas amount on Aptos, gas limit on EVM, compute unit limit on Solana; zero for the default
+	Capped      bool   // the estimate was cut to the policy's cap
+}
+
+// feeMode returns the mode recorded for a policy
+func feeMode(policy config.FeePolicy) string {
+	if policy.Mode == "" {
+		return config.FeeModeStatic
+	}
+	return policy.Mode
+}
+
+// scaleFee applies the policy's multiplier to an estimate and cuts the result to max, which is no
+// cap when zero. It reports whether the cap applied.
+func scaleFee(policy config.FeePolicy, estimate, max uint64) (uint64, bool) {
+	price := estimate
+	if multiplier := policy.EffectiveMultiplier(); multiplier != 1 {
+		scaled := math.Ceil(float64(estimate) * multiplier)
+		if scaled >= math.MaxUint64 {
+			price = math.MaxUint64
+		} else {
+			price = uint64(scaled)
+		}
+	}
+	if max > 0 && price > max {
+		return max, true
+	}
+	return price, false
+}
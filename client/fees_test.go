@@ -0,0 +1,154 @@
+package client
+
+import (
+	"context"
+	"math"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"tinypay-server/config"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+func TestScaleFee(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   config.FeePolicy
+		estimate uint64
+		max      uint64
+		want     uint64
+		capped   bool
+	}{
+		{"estimate is used as is", config.FeePolicy{Mode: config.FeeModeEstimate, Multiplier: 3}, 100, 0, 100, false},
+		{"multiplier rounds up", config.FeePolicy{Mode: config.FeeModeEstimateWithMultiplier, Multiplier: 1.5}, 101, 0, 152, false},
+		{"default multiplier", config.FeePolicy{Mode: config.FeeModeEstimateWithMultiplier}, 100, 0, 120, false},
+		{"cap applies after scaling", config.FeePolicy{Mode: config.FeeModeEstimateWithMultiplier, Multiplier: 2}, 100, 150, 150, true},
+		{"scaling saturates", config.FeePolicy{Mode: config.FeeModeEstimateWithMultiplier, Multiplier: 2}, math.MaxUint64, 0, math.MaxUint64, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, capped := scaleFee(tt.policy, tt.estimate, tt.max)
+			if got != tt.want || capped != tt.capped {
+				t.Errorf("expected %d (capped %v), got %d (capped %v)", tt.want, tt.capped, got, capped)
+			}
+		})
+	}
+}
+
+// fakeFeeSource reports fixed network fee data
+type fakeFeeSource struct {
+	baseFee  *big.Int
+	gasPrice int64
+	tip      int64
+}
+
+func (f *fakeFeeSource) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	return &types.Header{BaseFee: f.baseFee}, nil
+}
+
+func (f *fakeFeeSource) SuggestGasPrice(ctx context.Context) (*big.Int, error) {
+	return big.NewInt(f.gasPrice), nil
+}
+
+func (f *fakeFeeSource) SuggestGasTipCap(ctx context.Context) (*big.Int, error) {
+	return big.NewInt(f.tip), nil
+}
+
+func TestQuoteEVMFees(t *testing.T) {
+	ctx := context.Background()
+	london := &fakeFeeSource{baseFee: big.NewInt(100), tip: 10}
+
+	quote, err := quoteEVMFees(ctx, london, config.FeePolicy{})
+	if err != nil || quote.gasFeeCap != nil || quote.gasTipCap != nil || quote.gasPrice != nil {
+		t.Errorf("expected go-ethereum defaults for an unset static policy, got %+v (%v)", quote, err)
+	}
+
+	quote, _ = quoteEVMFees(ctx, london, config.FeePolicy{Mode: config.FeeModeStatic, Price: 50, PriorityFee: 80})
+	if quote.gasFeeCap.Uint64() != 50 || quote.gasTipCap.Uint64() != 50 {
+		t.Errorf("expected a static fee cap of 50 with the tip cut to it, got %+v", quote)
+	}
+
+	quote, _ = quoteEVMFees(ctx, london, config.FeePolicy{Mode: config.FeeModeEstimate})
+	if quote.gasFeeCap.Uint64() != 210 || quote.gasTipCap.Uint64() != 10 || quote.capped {
+		t.Errorf("expected fee cap 2*100+10 and tip 10, got %+v", quote)
+	}
+
+	quote, _ = quoteEVMFees(ctx, london, config.FeePolicy{Mode: config.FeeModeEstimateWithMultiplier, Multiplier: 2, MaxPrice: 300, MaxPriorityFee: 15})
+	if quote.gasFeeCap.Uint64() != 300 || quote.gasTipCap.Uint64() != 15 || !quote.capped {
+		t.Errorf("expected capped fees 300/15, got %+v", quote)
+	}
+
+	legacy := &fakeFeeSource{gasPrice: 40}
+	quote, _ = quoteEVMFees(ctx, legacy, config.FeePolicy{Mode: config.FeeModeEstimateWithMultiplier, Multiplier: 1.5})
+	if quote.gasPrice == nil || quote.gasPrice.Uint64() != 60 || quote.gasFeeCap != nil {
+		t.Errorf("expected a legacy gas price of 60, got %+v", quote)
+	}
+}
+
+func TestEVMFee(t *testing.T) {
+	to := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	tx := types.NewTx(&types.DynamicFeeTx{GasFeeCap: big.NewInt(210), GasTipCap: big.NewInt(10), Gas: 90000, To: &to})
+
+	fee := evmFee(config.FeePolicy{Mode: config.FeeModeEstimate}, tx, true)
+	if fee.Mode != config.FeeModeEstimate || fee.Unit != FeeUnitWeiPerGas || fee.Price != 210 || fee.PriorityFee != 10 || fee.Limit != 90000 || !fee.Capped {
+		t.Errorf("unexpected fee %+v", fee)
+	}
+
+	legacy := types.NewTx(&types.LegacyTx{GasPrice: big.NewInt(40), Gas: 21000, To: &to})
+	if fee := evmFee(config.FeePolicy{}, legacy, false); fee.Mode != config.FeeModeStatic || fee.Price != 40 || fee.PriorityFee != 0 {
+		t.Errorf("unexpected legacy fee %+v", fee)
+	}
+}
+
+func TestAptosTransactionOptions(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"deprioritized_gas_estimate": 100, "gas_estimate": 150, "prioritized_gas_estimate": 300}`))
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{AptosNetwork: "local", AptosNodeURL: server.URL, MaxGasAmount: 100000, GasUnitPrice: 100}
+	ac, err := NewAptosClient(cfg)
+	if err != nil {
+		t.Fatalf("new aptos client: %v", err)
+	}
+
+	_, fee, err := ac.transactionOptions()
+	if err != nil || fee.Price != 100 || fee.Limit != 100000 || fee.Mode != config.FeeModeStatic {
+		t.Errorf("expected the gas settings for the default policy, got %+v (%v)", fee, err)
+	}
+
+	cfg.AptosFees = config.FeePolicy{Mode: config.FeeModeEstimateWithMultiplier, Multiplier: 2, MaxPrice: 250, Limit: 5000}
+	_, fee, err = ac.transactionOptions()
+	if err != nil || fee.Price != 250 || !fee.Capped || fee.Limit != 5000 || fee.Unit != FeeUnitOctasPerGas {
+		t.Errorf("expected the estimate doubled and capped at 250, got %+v (%v)", fee, err)
+	}
+}
+
+func TestMedianPrioritizationFee(t *testing.T) {
+	if got := medianPrioritizationFee(nil); got != 0 {
+		t.Errorf("expected 0 without samples, got %d", got)
+	}
+	recent := []rpc.PriorizationFeeResult{{PrioritizationFee: 500}, {PrioritizationFee: 0}, {PrioritizationFee: 20}, {PrioritizationFee: 7000}}
+	if got := medianPrioritizationFee(recent); got != 500 {
+		t.Errorf("expected 500, got %d", got)
+	}
+}
+
+func TestWritableAccounts(t *testing.T) {
+	program, writable, readonly := solana.NewWallet().PublicKey(), solana.NewWallet().PublicKey(), solana.NewWallet().PublicKey()
+	instructions := []solana.Instruction{
+		solana.NewInstruction(program, solana.AccountMetaSlice{solana.Meta(writable).WRITE(), solana.Meta(readonly)}, nil),
+		solana.NewInstruction(program, solana.AccountMetaSlice{solana.Meta(writable).WRITE()}, nil),
+	}
+	accounts := writableAccounts(instructions)
+	if len(accounts) != 1 || !accounts[0].Equals(writable) {
+		t.Errorf("expected only the writable account once, got %v", accounts)
+	}
+}
@@ -0,0 +1,121 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"tinypay-server/utils"
+)
+
+// ErrOTPMismatch is returned when an OTP does not hash to the payer's current on-chain tail
+var ErrOTPMismatch = errors.New("otp does not match the payer's on-chain tail")
+
+// TailReader is implemented by backends that can read the hash chain tail stored for a payer
+type TailReader interface {
+	// GetUserTail returns the payer's current tail, or an empty tail when none is stored
+	GetUserTail(ctx context.Context, userAddress string) ([]byte, error)
+}
+
+// OTPMatchesTail reports whether sha256 of the OTP's ASCII bytes is the tail. Contracts store the
+// tail either as the 32-byte digest or as its 64-character hex encoding. A digest is compared as
+// is, since it may end in zero bytes; only hex tails are stripped of NUL padding.
+func OTPMatchesTail(otp string, tail []byte) bool {
+	sum := sha256.Sum256(utils.HexToASCIIBytes(otp))
+	if len(tail) == sha256.Size {
+		return bytes.Equal(tail, sum[:])
+	}
+	return strings.EqualFold(string(bytes.TrimRight(tail, "\x00")), hex.EncodeToString(sum[:]))
+}
+
+// tailEntry is a cached tail and when it was read
+type tailEntry struct {
+	tail      []byte
+	fetchedAt time.Time
+}
+
+// TailCache verifies OTPs locally against cached on-chain tails, so an OTP that cannot succeed
+// is rejected before a transaction is built or signed. The chain remains the authority: a
+// matching OTP can still be rejected on chain, and lookup failures never block a payment.
+type TailCache struct {
+	ttl   time.Duration
+	mu    sync.Mutex
+	tails map[string]tailEntry
+	now   func() time.Time
+}
+
+// NewTailCache creates a tail cache whose entries are reused for ttl
+func NewTailCache(ttl time.Duration) *TailCache {
+	return &TailCache{
+		ttl:   ttl,
+		tails: make(map[string]tailEntry),
+		now:   time.Now,
+	}
+}
+
+// tailKey identifies a payer on the backend's network
+func tailKey(backend ChainBackend, payerAddr string) string {
+	return strings.ToLower(backend.GetNetwork()) + "/" + NormalizeAddress(backend, payerAddr)
+}
+
+// VerifyOTP checks the OTP against the payer's tail on the backend's network and returns
+// ErrOTPMismatch when it cannot succeed. Backends that cannot read tails are not checked.
+func (c *TailCache) VerifyOTP(ctx context.Context, backend ChainBackend, payerAddr, otp string) error {
+	reader, ok := backend.(TailReader)
+	if !ok {
+		return nil
+	}
+	key := tailKey(backend, payerAddr)
+
+	if tail, ok := c.cached(key); ok && OTPMatchesTail(otp, tail) {
+		return nil
+	}
+
+	// A miss or a mismatch against a cached tail is confirmed against the chain, which may
+	// have moved on through payments submitted elsewhere
+	tail, err := reader.GetUserTail(ctx, payerAddr)
+	if err != nil {
+		log.Printf("Skipping OTP pre-verification for %s on %s: %v", payerAddr, backend.GetNetwork(), err)
+		return nil
+	}
+	c.store(key, tail)
+
+	if len(bytes.TrimRight(tail, "\x00")) == 0 || OTPMatchesTail(otp, tail) {
+		return nil
+	}
+	return ErrOTPMismatch
+}
+
+// Invalidate drops the cached tail of a payer, whose tail moves with every payment
+func (c *TailCache) Invalidate(backend ChainBackend, payerAddr string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.tails, tailKey(backend, payerAddr))
+}
+
+func (c *TailCache) cached(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.tails[key]
+	if !ok {
+		return nil, false
+	}
+	if c.now().Sub(entry.fetchedAt) >= c.ttl {
+		delete(c.tails, key)
+		return nil, false
+	}
+	return entry.tail, true
+}
+
+func (c *TailCache) store(key string, tail []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.tails[key] = tailEntry{tail: tail, fetchedAt: c.now()}
+}
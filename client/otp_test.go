@@ -0,0 +1,117 @@
+package client
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"testing"
+	"time"
+)
+
+// Vector from examples/hash_byte
+const (
+	testOTP  = "84eb882e56142984dea2fee9772d60c05d3885941fd2522761451446f46ae437"
+	testTail = "adb6beedc72be327ccbc58cf8c866ea608603c27568ec0752dc7d1e7608507a6"
+
+	// An OTP whose tail digest ends in a zero byte
+	zeroEndOTP  = "f15223dcc0da90206acdce51c6a9e24938b18665165a819f1abb69233c068cae"
+	zeroEndTail = "c85bb0f686de90d478745c1f2546800902e0192d8c85c9f9fa9fa4d9580cf900"
+)
+
+func TestOTPMatchesTail(t *testing.T) {
+	digest, _ := hex.DecodeString(testTail)
+	zeroEndDigest, _ := hex.DecodeString(zeroEndTail)
+	var padded [64]byte
+	copy(padded[:], testTail)
+	var paddedShort [80]byte
+	copy(paddedShort[:], testTail)
+
+	cases := []struct {
+		name string
+		otp  string
+		tail []byte
+		want bool
+	}{
+		{"hex tail", testOTP, []byte(testTail), true},
+		{"digest tail", testOTP, digest, true},
+		{"solana account tail", testOTP, padded[:], true},
+		{"nul-padded hex tail", testOTP, paddedShort[:], true},
+		{"digest tail ending in zero", zeroEndOTP, zeroEndDigest, true},
+		{"digest tail with its zero cut off", zeroEndOTP, zeroEndDigest[:31], false},
+		{"prefixed upper-case otp", "0x" + "84EB882E56142984DEA2FEE9772D60C05D3885941FD2522761451446F46AE437", []byte(testTail), true},
+		{"wrong otp", testTail, []byte(testTail), false},
+	}
+	for _, tc := range cases {
+		if got := OTPMatchesTail(tc.otp, tc.tail); got != tc.want {
+			t.Errorf("%s: OTPMatchesTail = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+// tailBackend is a ChainBackend whose tail lookups are counted
+type tailBackend struct {
+	ChainBackend
+	tail    []byte
+	err     error
+	lookups int
+}
+
+func (b *tailBackend) GetNetwork() string { return "fake-evm" }
+
+func (b *tailBackend) GetUserTail(ctx context.Context, userAddress string) ([]byte, error) {
+	b.lookups++
+	return b.tail, b.err
+}
+
+func TestTailCacheVerifyOTP(t *testing.T) {
+	ctx := context.Background()
+	backend := &tailBackend{tail: []byte(testTail)}
+	cache := NewTailCache(time.Minute)
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+
+	if err := cache.VerifyOTP(ctx, backend, "0xAbC", testOTP); err != nil {
+		t.Fatalf("expected the OTP to match, got %v", err)
+	}
+	if err := cache.VerifyOTP(ctx, backend, "0xabc", testOTP); err != nil || backend.lookups != 1 {
+		t.Fatalf("expected a cached match, got err=%v lookups=%d", err, backend.lookups)
+	}
+
+	// A mismatch is confirmed against the chain before it is reported
+	if err := cache.VerifyOTP(ctx, backend, "0xabc", "00"); !errors.Is(err, ErrOTPMismatch) || backend.lookups != 2 {
+		t.Fatalf("expected ErrOTPMismatch after a fresh lookup, got err=%v lookups=%d", err, backend.lookups)
+	}
+
+	// The tail moved through a payment submitted elsewhere; the stale entry must not reject the next OTP
+	next := "1111111111111111111111111111111111111111111111111111111111111111"
+	sum := sha256.Sum256([]byte(next))
+	backend.tail = []byte(hex.EncodeToString(sum[:]))
+	if err := cache.VerifyOTP(ctx, backend, "0xabc", next); err != nil || backend.lookups != 3 {
+		t.Fatalf("expected a match after refreshing the stale tail, got err=%v lookups=%d", err, backend.lookups)
+	}
+
+	cache.Invalidate(backend, "0xABC")
+	if err := cache.VerifyOTP(ctx, backend, "0xabc", next); err != nil || backend.lookups != 4 {
+		t.Fatalf("expected a lookup after invalidation, got err=%v lookups=%d", err, backend.lookups)
+	}
+
+	// Entries expire after the TTL
+	now = now.Add(time.Minute)
+	cache.VerifyOTP(ctx, backend, "0xabc", next)
+	if backend.lookups != 5 {
+		t.Errorf("expected an expired entry to be refreshed")
+	}
+}
+
+func TestTailCacheVerifyOTP_Unchecked(t *testing.T) {
+	ctx := context.Background()
+	cache := NewTailCache(time.Minute)
+
+	if err := cache.VerifyOTP(ctx, &tailBackend{err: errors.New("rpc unavailable")}, "0xabc", testOTP); err != nil {
+		t.Errorf("lookup failures must not block payments, got %v", err)
+	}
+	if err := cache.VerifyOTP(ctx, &tailBackend{tail: make([]byte, 64)}, "0xdef", testOTP); err != nil {
+		t.Errorf("payers without a tail must not be rejected locally, got %v", err)
+	}
+}
@@ -0,0 +1,336 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"tinypay-server/config"
+)
+
+// paymasterBalanceTTL is how long a paymaster key's balance is trusted before it is read again
+const paymasterBalanceTTL = 30 * time.Second
+
+// paymasterBalanceTimeout bounds a balance read made to refresh the pool
+const paymasterBalanceTimeout = 5 * time.Second
+
+// ErrNoPaymasterAvailable is returned when every paymaster key of a network is drained or below
+// its minimum balance
+var ErrNoPaymasterAvailable = errors.New("no paymaster key available")
+
+// ErrPaymasterNotFound is returned for addresses that are not a paymaster key of the network
+var ErrPaymasterNotFound = errors.New("paymaster key not found")
+
+// PaymasterKey reports the state of one paymaster key of a network
+type PaymasterKey struct {
+	Address  string
+	Draining bool     // the key only finishes what it started and takes no new transactions
+	InFlight int      // transactions being prepared or sent with the key
+	Balance  *big.Int // last balance read, nil before the first successful read
+	LowFunds bool     // the last balance read was below the pool's minimum balance
+}
+
+// PaymasterRotator is implemented by backends that spread their transactions over a pool of
+// paymaster keys, so keys can be drained and put back into rotation at runtime
+type PaymasterRotator interface {
+	// DrainPaymaster stops selecting the key with address for new transactions
+	DrainPaymaster(address string) (*PaymasterKey, error)
+	// ResumePaymaster puts a drained key back into rotation
+	ResumePaymaster(address string) (*PaymasterKey, error)
+}
+
+// PaymasterFunds is implemented by backends whose paymaster keys pay for gas in the chain's
+// native currency, so their balances can be monitored
+type PaymasterFunds interface {
+	// Paymasters returns the state of the network's paymaster keys, in pool order
+	Paymasters() []PaymasterKey
+	// PaymasterBalance reads the native balance of the paymaster key with address, in NativeUnit
+	PaymasterBalance(ctx context.Context, address string) (*big.Int, error)
+	// NativeUnit names the smallest unit of the native currency, such as wei
+	NativeUnit() string
+	// PaymasterPool returns the pool configuration, including the balance alert thresholds
+	PaymasterPool() config.PaymasterPool
+}
+
+// pooledKey is the selection state of one key of a paymasterPool
+type pooledKey struct {
+	address   string
+	inFlight  int
+	draining  bool
+	balance   *big.Int
+	checked   bool // balance was read successfully at checkedAt
+	checkedAt time.Time
+}
+
+// paymasterPool selects the paymaster key of each transaction. Chain clients keep their keys in a
+// slice in the same order and refer to them by index.
+type paymasterPool struct {
+	network    string
+	cfg        config.PaymasterPool
+	selection  string
+	minBalance uint64
+	balanceOf  func(ctx context.Context, index int) (*big.Int, error)
+	mu         sync.Mutex
+	keys       []*pooledKey
+	next       int  // where the round-robin search starts
+	refreshing bool // a background refresh of stale balances is running
+	now        func() time.Time
+}
+
+// newPaymasterPool creates a pool over the keys with addresses. balanceOf reads the native
+// balance of a key; selection only uses it when the pool has a minimum balance.
+func newPaymasterPool(network string, addresses []string, cfg config.PaymasterPool, balanceOf func(ctx context.Context, index int) (*big.Int, error)) *paymasterPool {
+	p := &paymasterPool{
+		network:    network,
+		cfg:        cfg,
+		selection:  cfg.Selection,
+		minBalance: cfg.MinBalance,
+		balanceOf:  balanceOf,
+		now:        time.Now,
+	}
+	for _, address := range addresses {
+		p.keys = append(p.keys, &pooledKey{address: address})
+	}
+	for _, address := range cfg.Drain {
+		i, ok := p.index(address)
+		if !ok {
+			log.Printf("Warning: paymaster %s to drain is not a key of %s", address, network)
+			continue
+		}
+		p.keys[i].draining = true
+	}
+	return p
+}
+
+// index returns the position of the key with address; addresses compare case-insensitively
+// when hex, exactly otherwise
+func (p *paymasterPool) index(address string) (int, bool) {
+	address = strings.TrimSpace(address)
+	for i, key := range p.keys {
+		if key.address == address || (strings.HasPrefix(address, "0x") && strings.EqualFold(key.address, address)) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// acquire selects the key of the next transaction. The key must be passed to release. Selection
+// uses the last known balances and never waits on the node; stale ones are read in the background.
+func (p *paymasterPool) acquire() (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.refreshInBackground()
+
+	best := -1
+	for n := 0; n < len(p.keys); n++ {
+		i := (p.next + n) % len(p.keys)
+		if !p.usable(p.keys[i]) {
+			continue
+		}
+		if best < 0 || (p.selection == config.PaymasterSelectionLeastLoaded && p.keys[i].inFlight < p.keys[best].inFlight) {
+			best = i
+		}
+		if p.selection != config.PaymasterSelectionLeastLoaded {
+			break
+		}
+	}
+	if best < 0 {
+		return 0, fmt.Errorf("%w on %s", ErrNoPaymasterAvailable, p.network)
+	}
+	p.next = (best + 1) % len(p.keys)
+	p.keys[best].inFlight++
+	return best, nil
+}
+
+// acquireAddress takes the key with address, even when it is draining or low on funds, for
+// transactions only that key can send, such as completing its own precommit
+func (p *paymasterPool) acquireAddress(address string) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	i, ok := p.index(address)
+	if !ok {
+		return 0, fmt.Errorf("%w: %s on %s", ErrPaymasterNotFound, address, p.network)
+	}
+	p.keys[i].inFlight++
+	return i, nil
+}
+
+// acquireUsable takes the key with address for a transaction only that key can send, unless it
+// is drained or low on funds
+func (p *paymasterPool) acquireUsable(address string) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.refreshInBackground()
+
+	i, ok := p.index(address)
+	if !ok {
+		return 0, fmt.Errorf("%w: %s on %s", ErrPaymasterNotFound, address, p.network)
+	}
+	if !p.usable(p.keys[i]) {
+		return 0, fmt.Errorf("%w on %s: %s is drained or below its minimum balance", ErrNoPaymasterAvailable, p.network, p.keys[i].address)
+	}
+	p.keys[i].inFlight++
+	return i, nil
+}
+
+// release returns a key taken by acquire, acquireAddress or acquireUsable
+func (p *paymasterPool) release(i int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.keys[i].inFlight--
+}
+
+// usable reports whether key may be selected for new transactions. A key whose balance could not
+// be read stays usable; the chain rejects its transactions if it really cannot pay.
+func (p *paymasterPool) usable(key *pooledKey) bool {
+	return !key.draining && !p.lowFunds(key)
+}
+
+func (p *paymasterPool) lowFunds(key *pooledKey) bool {
+	return p.minBalance > 0 && key.checked && belowBalance(key.balance, p.minBalance)
+}
+
+// belowBalance reports whether balance is less than threshold
+func belowBalance(balance *big.Int, threshold uint64) bool {
+	return balance.Cmp(new(big.Int).SetUint64(threshold)) < 0
+}
+
+// stale returns the keys in rotation whose balance is older than paymasterBalanceTTL, none when
+// the pool has no minimum balance. Callers hold mu.
+func (p *paymasterPool) stale() []int {
+	if p.minBalance == 0 || p.balanceOf == nil {
+		return nil
+	}
+	var stale []int
+	for i, key := range p.keys {
+		if !key.draining && p.now().Sub(key.checkedAt) >= paymasterBalanceTTL {
+			stale = append(stale, i)
+		}
+	}
+	return stale
+}
+
+// refreshInBackground starts refreshBalances unless it is already running or no balance is
+// stale. Callers hold mu.
+func (p *paymasterPool) refreshInBackground() {
+	if p.refreshing || len(p.stale()) == 0 {
+		return
+	}
+	p.refreshing = true
+	go func() {
+		p.refreshBalances(context.Background())
+		p.mu.Lock()
+		p.refreshing = false
+		p.mu.Unlock()
+	}()
+}
+
+// refreshBalances reads the balances that are older than paymasterBalanceTTL. Reads happen
+// outside the lock; a key sampled meanwhile by the balance monitor may be read twice.
+func (p *paymasterPool) refreshBalances(ctx context.Context) {
+	p.mu.Lock()
+	stale := p.stale()
+	p.mu.Unlock()
+
+	for _, i := range stale {
+		readCtx, cancel := context.WithTimeout(ctx, paymasterBalanceTimeout)
+		balance, err := p.balanceOf(readCtx, i)
+		cancel()
+		p.recordBalance(i, balance, err)
+	}
+}
+
+// recordBalance stores the outcome of a balance read of the key at index
+func (p *paymasterPool) recordBalance(i int, balance *big.Int, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	key := p.keys[i]
+	key.checkedAt = p.now()
+	if err != nil {
+		log.Printf("Failed to read the balance of paymaster %s on %s: %v", key.address, p.network, err)
+		key.checked = false
+		return
+	}
+	if p.minBalance > 0 && belowBalance(balance, p.minBalance) && (!key.checked || !belowBalance(key.balance, p.minBalance)) {
+		log.Printf("Paymaster %s on %s is below its minimum balance (%s < %d), skipping it", key.address, p.network, balance, p.minBalance)
+	}
+	key.balance, key.checked = balance, true
+}
+
+// readBalance reads the balance of the key with address. The pool keeps the result, so a key
+// sampled by the balance monitor is not read again for selection until paymasterBalanceTTL passes.
+func (p *paymasterPool) readBalance(ctx context.Context, address string) (*big.Int, error) {
+	p.mu.Lock()
+	i, ok := p.index(address)
+	p.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %s on %s", ErrPaymasterNotFound, address, p.network)
+	}
+	if p.balanceOf == nil {
+		return nil, fmt.Errorf("balance of paymaster %s on %s cannot be read", address, p.network)
+	}
+	balance, err := p.balanceOf(ctx, i)
+	p.recordBalance(i, balance, err)
+	return balance, err
+}
+
+// setDraining drains or resumes the key with address
+func (p *paymasterPool) setDraining(address string, draining bool) (*PaymasterKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	i, ok := p.index(address)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s on %s", ErrPaymasterNotFound, address, p.network)
+	}
+	key := p.keys[i]
+	if key.draining != draining {
+		if draining {
+			log.Printf("Draining paymaster %s on %s (%d transactions in flight)", key.address, p.network, key.inFlight)
+		} else {
+			log.Printf("Paymaster %s on %s is back in rotation", key.address, p.network)
+		}
+	}
+	key.draining = draining
+	// A resumed key has its balance read again before it is selected
+	key.checkedAt = time.Time{}
+	return p.state(key), nil
+}
+
+// states reports the state of every key, in pool order
+func (p *paymasterPool) states() []PaymasterKey {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	keys := make([]PaymasterKey, len(p.keys))
+	for i, key := range p.keys {
+		keys[i] = *p.state(key)
+	}
+	return keys
+}
+
+// state reports a key's state; callers hold mu
+func (p *paymasterPool) state(key *pooledKey) *PaymasterKey {
+	return &PaymasterKey{
+		Address:  key.address,
+		Draining: key.draining,
+		InFlight: key.inFlight,
+		Balance:  balanceCopy(key),
+		LowFunds: p.lowFunds(key),
+	}
+}
+
+// balanceCopy returns the key's last balance read, nil when there is none; callers hold mu
+func balanceCopy(key *pooledKey) *big.Int {
+	if !key.checked {
+		return nil
+	}
+	return new(big.Int).Set(key.balance)
+}
@@ -0,0 +1,141 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"slices"
+	"testing"
+	"time"
+
+	"tinypay-server/config"
+)
+
+func TestPaymasterPool_RoundRobinSkipsDrainedKeys(t *testing.T) {
+	p := newPaymasterPool("test", []string{"0xa", "0xb", "0xc"}, config.PaymasterPool{Drain: []string{"0xB"}}, nil)
+
+	var got []int
+	for n := 0; n < 4; n++ {
+		i, err := p.acquire()
+		if err != nil {
+			t.Fatalf("acquire: %v", err)
+		}
+		got = append(got, i)
+		p.release(i)
+	}
+	if want := []int{0, 2, 0, 2}; !slices.Equal(got, want) {
+		t.Errorf("expected keys %v, got %v", want, got)
+	}
+}
+
+func TestPaymasterPool_LeastLoadedPicksFewestInFlight(t *testing.T) {
+	p := newPaymasterPool("test", []string{"0xa", "0xb", "0xc"}, config.PaymasterPool{Selection: config.PaymasterSelectionLeastLoaded}, nil)
+
+	first, _ := p.acquire()
+	second, _ := p.acquire()
+	p.release(first)
+	third, _ := p.acquire()
+	if first == second || third == second {
+		t.Errorf("expected a key without transactions in flight, got %d, %d then %d", first, second, third)
+	}
+}
+
+func TestPaymasterPool_SkipsKeysBelowMinBalance(t *testing.T) {
+	balances := []*big.Int{big.NewInt(5), big.NewInt(50)}
+	p := newPaymasterPool("test", []string{"0xa", "0xb"}, config.PaymasterPool{MinBalance: 10}, func(ctx context.Context, index int) (*big.Int, error) {
+		return balances[index], nil
+	})
+	p.refreshBalances(context.Background())
+
+	for n := 0; n < 3; n++ {
+		i, err := p.acquire()
+		if err != nil {
+			t.Fatalf("acquire: %v", err)
+		}
+		if i != 1 {
+			t.Errorf("expected the funded key, got %d", i)
+		}
+		p.release(i)
+	}
+
+	// A stale balance is still used for this selection and read again in the background
+	balances[1] = big.NewInt(0)
+	p.keys[1].checkedAt = p.keys[1].checkedAt.Add(-paymasterBalanceTTL)
+	i, err := p.acquire()
+	if err != nil || i != 1 {
+		t.Fatalf("expected the last known balance to be used, got %d, %v", i, err)
+	}
+	p.release(i)
+	waitForRefresh(t, p)
+	if _, err := p.acquire(); !errors.Is(err, ErrNoPaymasterAvailable) {
+		t.Errorf("expected ErrNoPaymasterAvailable once every key is low on funds, got %v", err)
+	}
+}
+
+func TestPaymasterPool_ReportsBalancesAboveUint64(t *testing.T) {
+	balance, _ := new(big.Int).SetString("100000000000000000000", 10) // 100 ETH in wei
+	p := newPaymasterPool("test", []string{"0xa"}, config.PaymasterPool{MinBalance: 10}, func(ctx context.Context, index int) (*big.Int, error) {
+		return balance, nil
+	})
+	p.refreshBalances(context.Background())
+
+	state := p.states()[0]
+	if state.Balance == nil || state.Balance.Cmp(balance) != 0 || state.LowFunds {
+		t.Errorf("expected the full balance %s without low funds, got %+v", balance, state)
+	}
+}
+
+func TestPaymasterPool_AcquireDoesNotWaitForBalances(t *testing.T) {
+	unblock := make(chan struct{})
+	p := newPaymasterPool("test", []string{"0xa"}, config.PaymasterPool{MinBalance: 10}, func(ctx context.Context, index int) (*big.Int, error) {
+		<-unblock
+		return big.NewInt(50), nil
+	})
+	defer close(unblock)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := p.acquire()
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("acquire: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected acquire to return while the balance read hangs")
+	}
+}
+
+// waitForRefresh waits until the pool's background balance refresh has finished
+func waitForRefresh(t *testing.T, p *paymasterPool) {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		p.mu.Lock()
+		refreshing := p.refreshing
+		p.mu.Unlock()
+		if !refreshing {
+			return
+		}
+	}
+	t.Fatal("balance refresh did not finish")
+}
+
+func TestPaymasterPool_DrainedKeyFinishesItsOwnTransactions(t *testing.T) {
+	p := newPaymasterPool("test", []string{"0xa"}, config.PaymasterPool{}, nil)
+
+	state, err := p.setDraining("0xA", true)
+	if err != nil || !state.Draining {
+		t.Fatalf("expected the key to drain, got %+v, %v", state, err)
+	}
+	if _, err := p.acquire(); !errors.Is(err, ErrNoPaymasterAvailable) {
+		t.Errorf("expected no key for new transactions, got %v", err)
+	}
+	if i, err := p.acquireAddress("0xa"); err != nil || i != 0 {
+		t.Errorf("expected the drained key to complete its own precommit, got %d, %v", i, err)
+	}
+	if _, err := p.acquireAddress("0xd"); !errors.Is(err, ErrPaymasterNotFound) {
+		t.Errorf("expected ErrPaymasterNotFound for an unknown key, got %v", err)
+	}
+}
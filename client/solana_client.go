@@ -5,9 +5,13 @@ import (
 	"crypto/sha256"
 	"encoding/binary"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"log"
+	"strings"
+	"sync"
 	"tinypay-server/config"
+	"tinypay-server/utils"
 
 	"github.com/gagliardetto/solana-go"
 	"github.com/gagliardetto/solana-go/rpc"
@@ -18,8 +22,15 @@ type SolanaClient struct {
 	client    *rpc.Client
 	config    *config.Config
 	programID solana.PublicKey
-	paymaster solana.PrivateKey
 	network   string
+	fees      config.FeePolicy
+
+	// paymasterKeys sign and pay for the network's transactions, in the order of the pool
+	paymasterKeys []*solanaSigner
+	paymasters    *paymasterPool
+
+	// tokenPrograms caches the owning token program of each mint
+	tokenPrograms sync.Map
 }
 
 // NewSolanaClient creates a new Solana client for the specified network
@@ -44,9 +55,16 @@ func NewSolanaClient(cfg *config.Config, network string) (*SolanaClient, error)
 	if netCfg.ProgramID == "" {
 		return nil, fmt.Errorf("solana program ID is required for network %s", network)
 	}
-	if netCfg.PaymasterPrivateKey == "" {
+	keys := config.PaymasterKeys(netCfg.PaymasterPrivateKey, netCfg.PaymasterPrivateKeys)
+	if len(keys) == 0 {
 		return nil, fmt.Errorf("solana paymaster private key is required for network %s", network)
 	}
+	if err := validateSolanaFees(netCfg.Fees); err != nil {
+		return nil, fmt.Errorf("invalid fee policy for network %s: %w", network, err)
+	}
+	if err := netCfg.Paymasters.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid paymaster pool for network %s: %w", network, err)
+	}
 
 	// Create RPC client
 	client := rpc.New(netCfg.RPCURL)
@@ -57,19 +75,25 @@ func NewSolanaClient(cfg *config.Config, network string) (*SolanaClient, error)
 		return nil, fmt.Errorf("invalid program ID: %w", err)
 	}
 
-	// Parse paymaster private key
-	paymasterKey, err := solana.PrivateKeyFromBase58(netCfg.PaymasterPrivateKey)
-	if err != nil {
-		return nil, fmt.Errorf("invalid paymaster private key: %w", err)
-	}
-
-	return &SolanaClient{
+	// Parse paymaster private keys
+	sc := &SolanaClient{
 		client:    client,
 		config:    cfg,
 		programID: programID,
-		paymaster: paymasterKey,
 		network:   network,
-	}, nil
+		fees:      netCfg.Fees,
+	}
+	addresses := make([]string, 0, len(keys))
+	for i, key := range keys {
+		paymasterKey, err := newSolanaSigner(key, cfg.RemoteSigner())
+		if err != nil {
+			return nil, fmt.Errorf("invalid paymaster private key #%d: %w", i+1, err)
+		}
+		sc.paymasterKeys = append(sc.paymasterKeys, paymasterKey)
+		addresses = append(addresses, paymasterKey.PublicKey().String())
+	}
+	sc.paymasters = newPaymasterPool(network, addresses, netCfg.Paymasters, sc.paymasterBalance)
+	return sc, nil
 }
 
 // GetConfig returns the configuration
@@ -82,9 +106,90 @@ func (sc *SolanaClient) GetNetwork() string {
 	return sc.network
 }
 
-// GetPaymasterAddress returns the paymaster public key as a string
+// GetPaymasterAddress returns the public key of the first paymaster key as a string
 func (sc *SolanaClient) GetPaymasterAddress() string {
-	return sc.paymaster.PublicKey().String()
+	return sc.paymasterKeys[0].PublicKey().String()
+}
+
+// SupportedCurrencies returns the currencies configured for this Solana network
+func (sc *SolanaClient) SupportedCurrencies() []string {
+	return utils.NewNetworkCurrencyValidationMatrix(sc.config).GetSupportedCurrenciesForNetwork(sc.network)
+}
+
+// DefaultCurrency returns the native token symbol of this Solana network
+func (sc *SolanaClient) DefaultCurrency() string {
+	return utils.GetDefaultCurrencyForSolanaNetwork(sc.config, sc.network)
+}
+
+// ResolveCoinType validates that a currency is configured on this network.
+// Solana payments report the currency symbol itself as the coin type.
+func (sc *SolanaClient) ResolveCoinType(currency string) (string, error) {
+	if _, err := utils.GetSolanaTokenAddressByNetwork(sc.config, currency, sc.network); err != nil {
+		return "", err
+	}
+	return strings.ToUpper(currency), nil
+}
+
+// NormalizeAddress returns the base58 form of a Solana address. Base58 is case-sensitive, so
+// only surrounding whitespace is dropped.
+func (sc *SolanaClient) NormalizeAddress(address string) string {
+	address = strings.TrimSpace(address)
+	if pubkey, err := solana.PublicKeyFromBase58(address); err == nil {
+		return pubkey.String()
+	}
+	return address
+}
+
+// SendPayment parses the payer and recipient addresses and submits complete_payment
+func (sc *SolanaClient) SendPayment(ctx context.Context, payment *Payment) (*Submission, error) {
+	payerPubkey, err := utils.ParseSolanaPublicKey(payment.PayerAddr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid payer: %w", err)
+	}
+
+	recipientPubkey, err := utils.ParseSolanaPublicKey(payment.PayeeAddr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid recipient: %w", err)
+	}
+
+	paymaster, release, err := sc.acquirePaymaster(payment.Sender)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	mint, err := sc.mintForCurrency(payment.Currency)
+	if err != nil {
+		return nil, err
+	}
+
+	var sig solana.Signature
+	var fee *Fee
+	if mint.IsZero() {
+		sig, fee, err = sc.completePayment(ctx, paymaster, payerPubkey, recipientPubkey, payment.Otp, payment.Amount)
+	} else {
+		sig, fee, err = sc.completeTokenPayment(ctx, paymaster, payerPubkey, recipientPubkey, mint, payment.Otp, payment.Amount)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Submission{TxHash: sig.String(), Fee: fee, Sender: paymaster.PublicKey().String()}, nil
+}
+
+// mintForCurrency returns the SPL mint of a currency, or the zero key for native SOL
+func (sc *SolanaClient) mintForCurrency(currency string) (solana.PublicKey, error) {
+	tokenAddress, err := utils.GetSolanaTokenAddressByNetwork(sc.config, currency, sc.network)
+	if err != nil {
+		return solana.PublicKey{}, err
+	}
+	if tokenAddress == "SOL_NATIVE" {
+		return solana.PublicKey{}, nil
+	}
+	mint, err := solana.PublicKeyFromBase58(tokenAddress)
+	if err != nil {
+		return solana.PublicKey{}, fmt.Errorf("invalid %s mint address: %w", currency, err)
+	}
+	return mint, nil
 }
 
 // ConvertOTPForContract converts OTP string to bytes for contract
@@ -107,6 +212,25 @@ func (sc *SolanaClient) CompletePayment(
 	otpString string,
 	amountLamports uint64,
 ) (solana.Signature, error) {
+	paymaster, release, err := sc.acquirePaymaster("")
+	if err != nil {
+		return solana.Signature{}, err
+	}
+	defer release()
+
+	sig, _, err := sc.completePayment(ctx, paymaster, payerPubkey, recipientPubkey, otpString, amountLamports)
+	return sig, err
+}
+
+// completePayment builds complete_payment and sends it from paymaster
+func (sc *SolanaClient) completePayment(
+	ctx context.Context,
+	paymaster *solanaSigner,
+	payerPubkey solana.PublicKey,
+	recipientPubkey solana.PublicKey,
+	otpString string,
+	amountLamports uint64,
+) (solana.Signature, *Fee, error) {
 	log.Printf("Executing Solana complete_payment - Payer: %s, Recipient: %s, Amount: %d", 
 		payerPubkey.String(), recipientPubkey.String(), amountLamports)
 
@@ -122,7 +246,7 @@ func (sc *SolanaClient) CompletePayment(
 		sc.programID,
 	)
 	if err != nil {
-		return solana.Signature{}, fmt.Errorf("failed to derive user account PDA: %w", err)
+		return solana.Signature{}, nil, fmt.Errorf("failed to derive user account PDA: %w", err)
 	}
 
 	statePDA, _, err := solana.FindProgramAddress(
@@ -130,15 +254,12 @@ func (sc *SolanaClient) CompletePayment(
 		sc.programID,
 	)
 	if err != nil {
-		return solana.Signature{}, fmt.Errorf("failed to derive state PDA: %w", err)
+		return solana.Signature{}, nil, fmt.Errorf("failed to derive state PDA: %w", err)
 	}
 
-	vaultPDA, _, err := solana.FindProgramAddress(
-		[][]byte{[]byte("vault")},
-		sc.programID,
-	)
+	vaultPDA, err := sc.vaultAddress()
 	if err != nil {
-		return solana.Signature{}, fmt.Errorf("failed to derive vault PDA: %w", err)
+		return solana.Signature{}, nil, err
 	}
 
 	// Build instruction data
@@ -152,46 +273,31 @@ func (sc *SolanaClient) CompletePayment(
 			solana.Meta(statePDA).WRITE(),
 			solana.Meta(vaultPDA).WRITE(),
 			solana.Meta(recipientPubkey).WRITE(),
-			solana.Meta(sc.paymaster.PublicKey()).SIGNER(),
+			solana.Meta(paymaster.PublicKey()).SIGNER(),
 			solana.Meta(solana.SystemProgramID),
 		},
 		instructionData,
 	)
 
-	// Get latest blockhash (replaces deprecated GetRecentBlockhash)
-	recent, err := sc.client.GetLatestBlockhash(ctx, rpc.CommitmentFinalized)
-	if err != nil {
-		return solana.Signature{}, fmt.Errorf("failed to get latest blockhash: %w", err)
-	}
-
-	// Build and sign transaction
-	tx, err := solana.NewTransaction(
-		[]solana.Instruction{instruction},
-		recent.Value.Blockhash,
-		solana.TransactionPayer(sc.paymaster.PublicKey()),
-	)
+	sig, fee, err := sc.sendInstructions(ctx, paymaster, instruction)
 	if err != nil {
-		return solana.Signature{}, fmt.Errorf("failed to create transaction: %w", err)
+		return solana.Signature{}, nil, err
 	}
 
-	_, err = tx.Sign(func(key solana.PublicKey) *solana.PrivateKey {
-		if key.Equals(sc.paymaster.PublicKey()) {
-			return &sc.paymaster
-		}
-		return nil
-	})
-	if err != nil {
-		return solana.Signature{}, fmt.Errorf("failed to sign transaction: %w", err)
-	}
+	log.Printf("Solana payment completed! Signature: %s", sig)
+	return sig, fee, nil
+}
 
-	// Send transaction
-	sig, err := sc.client.SendTransaction(ctx, tx)
+// vaultAddress derives the program vault PDA: seeds ["vault"]
+func (sc *SolanaClient) vaultAddress() (solana.PublicKey, error) {
+	vaultPDA, _, err := solana.FindProgramAddress(
+		[][]byte{[]byte("vault")},
+		sc.programID,
+	)
 	if err != nil {
-		return solana.Signature{}, fmt.Errorf("failed to send transaction: %w", err)
+		return solana.PublicKey{}, fmt.Errorf("failed to derive vault PDA: %w", err)
 	}
-
-	log.Printf("Solana payment completed! Signature: %s", sig)
-	return sig, nil
+	return vaultPDA, nil
 }
 
 // computeAnchorDiscriminator computes the Anchor discriminator for an instruction
@@ -228,8 +334,54 @@ func buildCompletePaymentInstruction(otp []byte, amount uint64) []byte {
 }
 
 // GetUserLimits queries user limits from the Solana program
-func (sc *SolanaClient) GetUserLimits(ctx context.Context, userPubkey solana.PublicKey) (*UserLimits, error) {
-	log.Printf("Getting Solana user limits for address: %s", userPubkey.String())
+func (sc *SolanaClient) GetUserLimits(ctx context.Context, userAddress string) (*UserLimits, error) {
+	log.Printf("Getting Solana user limits for address: %s", userAddress)
+
+	userAccount, err := sc.getUserAccount(ctx, userAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	return &UserLimits{
+		PaymentLimit:    userAccount.PaymentLimit,
+		TailUpdateCount: userAccount.TailUpdateCount,
+		MaxTailUpdates:  userAccount.MaxTailUpdates,
+	}, nil
+}
+
+// GetBalance returns the payer's deposited balance. The user account only tracks the native
+// currency; SPL deposits are held in the vault's token accounts without a per-payer balance.
+func (sc *SolanaClient) GetBalance(ctx context.Context, userAddress, currency string) (uint64, error) {
+	mint, err := sc.mintForCurrency(currency)
+	if err != nil {
+		return 0, err
+	}
+	if !mint.IsZero() {
+		return 0, ErrBalanceUnavailable
+	}
+
+	userAccount, err := sc.getUserAccount(ctx, userAddress)
+	if err != nil {
+		return 0, err
+	}
+	return userAccount.Balance, nil
+}
+
+// GetUserTail returns the hash chain tail stored in the user's program account
+func (sc *SolanaClient) GetUserTail(ctx context.Context, userAddress string) ([]byte, error) {
+	userAccount, err := sc.getUserAccount(ctx, userAddress)
+	if err != nil {
+		return nil, err
+	}
+	return userAccount.Tail[:], nil
+}
+
+// getUserAccount reads and deserializes the program account of a user
+func (sc *SolanaClient) getUserAccount(ctx context.Context, userAddress string) (*SolanaUserAccount, error) {
+	userPubkey, err := utils.ParseSolanaPublicKey(userAddress)
+	if err != nil {
+		return nil, err
+	}
 
 	// Derive user account PDA
 	userAccountPDA, _, err := solana.FindProgramAddress(
@@ -258,12 +410,7 @@ func (sc *SolanaClient) GetUserLimits(ctx context.Context, userPubkey solana.Pub
 	if err != nil {
 		return nil, fmt.Errorf("failed to deserialize user account: %w", err)
 	}
-
-	return &UserLimits{
-		PaymentLimit:    userAccount.PaymentLimit,
-		TailUpdateCount: userAccount.TailUpdateCount,
-		MaxTailUpdates:  userAccount.MaxTailUpdates,
-	}, nil
+	return userAccount, nil
 }
 
 // SolanaUserAccount represents the user account structure in Solana program
@@ -329,6 +476,19 @@ func (sc *SolanaClient) GetTransactionDetails(ctx context.Context, signature str
 		return nil, fmt.Errorf("invalid signature format: %w", err)
 	}
 
+	// Signatures the cluster has not seen yet, or only processed, are still pending
+	status, err := sc.signatureStatus(ctx, sig, true)
+	if err != nil {
+		return nil, err
+	}
+	if status == nil {
+		return &TransactionInfo{Confirmed: false}, nil
+	}
+	commitment := string(status.ConfirmationStatus)
+	if !reachedCommitment(status.ConfirmationStatus, rpc.ConfirmationStatusConfirmed) {
+		return &TransactionInfo{Confirmed: false, Commitment: commitment}, nil
+	}
+
 	// Get transaction with details
 	maxSupportedTransactionVersion := uint64(0)
 	out, err := sc.client.GetTransaction(
@@ -341,13 +501,18 @@ func (sc *SolanaClient) GetTransactionDetails(ctx context.Context, signature str
 		},
 	)
 
+	if errors.Is(err, rpc.ErrNotFound) {
+		// Confirmed signatures can take a moment to become queryable
+		return &TransactionInfo{Confirmed: false, Commitment: commitment}, nil
+	}
 	if err != nil {
 		return nil, fmt.Errorf("transaction not found: %w", err)
 	}
 
-	if out == nil {
+	if out == nil || out.Meta == nil {
 		return &TransactionInfo{
-			Confirmed: false,
+			Confirmed:  false,
+			Commitment: commitment,
 		}, nil
 	}
 
@@ -355,31 +520,50 @@ func (sc *SolanaClient) GetTransactionDetails(ctx context.Context, signature str
 	success := out.Meta.Err == nil
 	
 	var errorMsg string
+	var failure *ChainError
 	if out.Meta.Err != nil {
 		errorMsg = fmt.Sprintf("%v", out.Meta.Err)
+		failure = DecodeSolanaLogs(out.Meta.LogMessages)
 	}
 
 	// Extract amount from transaction by parsing instruction data
 	amount := uint64(0)
 	if out.Transaction != nil {
 		tx, err := out.Transaction.GetTransaction()
-		if err == nil && len(tx.Message.Instructions) > 0 {
-			// Get the first instruction (complete_payment)
-			instruction := tx.Message.Instructions[0]
-			if len(instruction.Data) >= 20 { // discriminator(8) + otp_len(4) + amount(8)
-				// Amount is at the end of instruction data (last 8 bytes)
-				amountOffset := len(instruction.Data) - 8
-				amount = binary.LittleEndian.Uint64(instruction.Data[amountOffset:])
+		if err == nil {
+			// Token payments may be preceded by the recipient token account creation,
+			// so look for the TinyPay instruction instead of taking the first one
+			for _, instruction := range tx.Message.Instructions {
+				programID, err := tx.ResolveProgramIDIndex(instruction.ProgramIDIndex)
+				if err != nil || !programID.Equals(sc.programID) {
+					continue
+				}
+				if len(instruction.Data) >= 20 { // discriminator(8) + otp_len(4) + amount(8)
+					// Amount is at the end of instruction data (last 8 bytes)
+					amountOffset := len(instruction.Data) - 8
+					amount = binary.LittleEndian.Uint64(instruction.Data[amountOffset:])
+				}
+				break
 			}
 		}
 	}
 
+	coinType := sc.DefaultCurrency()
+	if vaultPDA, err := sc.vaultAddress(); err == nil {
+		if transfer, ok := decodeTokenTransfer(out.Meta, vaultPDA); ok {
+			amount = transfer.amount
+			coinType = sc.currencyForMint(transfer.mint)
+		}
+	}
+
 	return &TransactionInfo{
-		Confirmed: true,
-		Success:   success,
-		Amount:    amount,
-		CoinType:  "SOL", // Default to SOL, can be extended for SPL tokens
-		Error:     errorMsg,
+		Confirmed:  true,
+		Success:    success,
+		Amount:     amount,
+		CoinType:   coinType,
+		Error:      errorMsg,
+		Commitment: commitment,
+		Failure:    failure,
 	}, nil
 }
 
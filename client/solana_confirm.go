@@ -0,0 +1,194 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+const (
+	// solanaStatusPollInterval is how often a sent transaction's signature status is checked
+	solanaStatusPollInterval = 500 * time.Millisecond
+	// solanaMaxSendAttempts bounds how often a transaction is rebuilt after its blockhash expired
+	solanaMaxSendAttempts = 3
+)
+
+// errBlockhashExpired reports that a transaction can no longer land because its blockhash expired
+var errBlockhashExpired = errors.New("blockhash expired before the transaction was processed")
+
+// sendInstructions wraps instructions in a transaction signed and paid for by paymaster, sends it
+// and waits until it is confirmed. When the blockhash expires first, the transaction is rebuilt
+// with a fresh blockhash and sent again; an expired transaction can never land, so this cannot pay twice.
+func (sc *SolanaClient) sendInstructions(ctx context.Context, paymaster *solanaSigner, instructions ...solana.Instruction) (solana.Signature, *Fee, error) {
+	for attempt := 1; ; attempt++ {
+		sig, lastValidBlockHeight, fee, err := sc.signAndSend(ctx, paymaster, instructions)
+		if err != nil {
+			return solana.Signature{}, nil, err
+		}
+
+		commitment, err := sc.awaitSignature(ctx, sig, lastValidBlockHeight)
+		switch {
+		case err == nil:
+			log.Printf("Solana transaction %s reached %s", sig, commitment)
+			return sig, fee, nil
+		case errors.Is(err, errBlockhashExpired) && attempt < solanaMaxSendAttempts:
+			log.Printf("Solana transaction %s expired (attempt %d/%d), rebuilding with a new blockhash", sig, attempt, solanaMaxSendAttempts)
+		case errors.Is(err, errBlockhashExpired):
+			return solana.Signature{}, nil, fmt.Errorf("transaction not processed after %d attempts: %w", attempt, err)
+		case errors.As(err, new(*ChainError)):
+			// The transaction landed and failed, so it is not sent again
+			return solana.Signature{}, nil, err
+		default:
+			// The transaction was sent and may still land; the status lookup follows it from here
+			log.Printf("Stopped waiting for Solana transaction %s: %v", sig, err)
+			return sig, fee, nil
+		}
+	}
+}
+
+// signAndSend builds a transaction on the latest blockhash, priced by the network's fee policy, signs
+// it with paymaster and sends it. It returns the last block height at which the transaction can
+// still be processed, and the fee it was priced at.
+func (sc *SolanaClient) signAndSend(ctx context.Context, paymaster *solanaSigner, instructions []solana.Instruction) (solana.Signature, uint64, *Fee, error) {
+	budget, fee, err := sc.computeBudget(ctx, instructions)
+	if err != nil {
+		return solana.Signature{}, 0, nil, err
+	}
+
+	// Get latest blockhash (replaces deprecated GetRecentBlockhash)
+	recent, err := sc.client.GetLatestBlockhash(ctx, rpc.CommitmentFinalized)
+	if err != nil {
+		return solana.Signature{}, 0, nil, fmt.Errorf("failed to get latest blockhash: %w", err)
+	}
+
+	// Build and sign transaction
+	tx, err := solana.NewTransaction(
+		append(budget, instructions...),
+		recent.Value.Blockhash,
+		solana.TransactionPayer(paymaster.PublicKey()),
+	)
+	if err != nil {
+		return solana.Signature{}, 0, nil, fmt.Errorf("failed to create transaction: %w", err)
+	}
+
+	if err := paymaster.signTransaction(ctx, tx); err != nil {
+		return solana.Signature{}, 0, nil, fmt.Errorf("failed to sign transaction: %w", err)
+	}
+
+	// Send transaction
+	sig, err := sc.client.SendTransaction(ctx, tx)
+	if err != nil {
+		if chainErr := DecodeSolanaError(err); chainErr != nil {
+			return solana.Signature{}, 0, nil, fmt.Errorf("transaction rejected: %w", chainErr)
+		}
+		return solana.Signature{}, 0, nil, fmt.Errorf("failed to send transaction: %w", err)
+	}
+	return sig, recent.Value.LastValidBlockHeight, fee, nil
+}
+
+// awaitSignature polls the signature status until the transaction is confirmed or failed, and
+// reports errBlockhashExpired once the chain moved past lastValidBlockHeight without it. A failed
+// transaction is reported as a *ChainError decoded from its program logs.
+func (sc *SolanaClient) awaitSignature(ctx context.Context, sig solana.Signature, lastValidBlockHeight uint64) (rpc.ConfirmationStatusType, error) {
+	ticker := time.NewTicker(solanaStatusPollInterval)
+	defer ticker.Stop()
+
+	for {
+		status, err := sc.signatureStatus(ctx, sig, false)
+		if err == nil && status != nil {
+			if status.Err != nil {
+				return status.ConfirmationStatus, fmt.Errorf("transaction %s failed: %w", sig, sc.transactionFailure(ctx, sig, status.Err))
+			}
+			if reachedCommitment(status.ConfirmationStatus, rpc.ConfirmationStatusConfirmed) {
+				return status.ConfirmationStatus, nil
+			}
+		}
+
+		if err == nil && status == nil {
+			height, err := sc.client.GetBlockHeight(ctx, rpc.CommitmentConfirmed)
+			if err == nil && height > lastValidBlockHeight {
+				// The transaction may have landed between the two lookups
+				if status, err := sc.signatureStatus(ctx, sig, false); err == nil && status == nil {
+					return "", errBlockhashExpired
+				}
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// transactionFailure decodes why a processed transaction failed from its program logs. When the
+// logs cannot be read or name no known failure, the error of its status is decoded instead.
+func (sc *SolanaClient) transactionFailure(ctx context.Context, sig solana.Signature, statusErr interface{}) *ChainError {
+	maxSupportedTransactionVersion := uint64(0)
+	out, err := sc.client.GetTransaction(ctx, sig, &rpc.GetTransactionOpts{
+		Encoding:                       solana.EncodingBase64,
+		MaxSupportedTransactionVersion: &maxSupportedTransactionVersion,
+		Commitment:                     rpc.CommitmentConfirmed,
+	})
+	if err != nil {
+		log.Printf("Failed to read the logs of failed Solana transaction %s: %v", sig, err)
+	} else if out != nil && out.Meta != nil {
+		if chainErr := DecodeSolanaLogs(out.Meta.LogMessages); chainErr != nil {
+			return chainErr
+		}
+	}
+	return decodeSolanaStatusError(statusErr)
+}
+
+// decodeSolanaStatusError decodes the error of a failed signature status, such as
+// {"InstructionError":[0,{"Custom":6001}]}, the same way DecodeSolanaError names custom program errors
+func decodeSolanaStatusError(statusErr interface{}) *ChainError {
+	raw, err := json.Marshal(statusErr)
+	if err != nil {
+		return &ChainError{Reason: FailureUnknown, Code: "TransactionError", Message: fmt.Sprint(statusErr)}
+	}
+	var status struct {
+		InstructionError []json.RawMessage
+	}
+	if json.Unmarshal(raw, &status) == nil && len(status.InstructionError) == 2 {
+		var instruction struct {
+			Custom *uint32
+		}
+		if json.Unmarshal(status.InstructionError[1], &instruction) == nil && instruction.Custom != nil {
+			return &ChainError{Reason: FailureUnknown, Code: fmt.Sprintf("Custom(0x%x)", *instruction.Custom), Message: string(raw)}
+		}
+	}
+	return &ChainError{Reason: FailureUnknown, Code: "TransactionError", Message: string(raw)}
+}
+
+// signatureStatus returns the status of a signature, or nil when the cluster has not seen it
+func (sc *SolanaClient) signatureStatus(ctx context.Context, sig solana.Signature, searchHistory bool) (*rpc.SignatureStatusesResult, error) {
+	out, err := sc.client.GetSignatureStatuses(ctx, searchHistory, sig)
+	if errors.Is(err, rpc.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get signature status: %w", err)
+	}
+	if len(out.Value) == 0 {
+		return nil, nil
+	}
+	return out.Value[0], nil
+}
+
+// reachedCommitment reports whether status is at least as final as target
+func reachedCommitment(status, target rpc.ConfirmationStatusType) bool {
+	rank := map[rpc.ConfirmationStatusType]int{
+		rpc.ConfirmationStatusProcessed: 1,
+		rpc.ConfirmationStatusConfirmed: 2,
+		rpc.ConfirmationStatusFinalized: 3,
+	}
+	return rank[status] >= rank[target] && rank[status] > 0
+}
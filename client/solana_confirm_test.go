@@ -0,0 +1,188 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"tinypay-server/signer"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/programs/system"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+func TestReachedCommitment(t *testing.T) {
+	cases := []struct {
+		status rpc.ConfirmationStatusType
+		target rpc.ConfirmationStatusType
+		want   bool
+	}{
+		{rpc.ConfirmationStatusProcessed, rpc.ConfirmationStatusConfirmed, false},
+		{rpc.ConfirmationStatusConfirmed, rpc.ConfirmationStatusConfirmed, true},
+		{rpc.ConfirmationStatusFinalized, rpc.ConfirmationStatusConfirmed, true},
+		{"", rpc.ConfirmationStatusProcessed, false},
+	}
+	for _, tc := range cases {
+		if got := reachedCommitment(tc.status, tc.target); got != tc.want {
+			t.Errorf("reachedCommitment(%q, %q) = %v, want %v", tc.status, tc.target, got, tc.want)
+		}
+	}
+}
+
+// fakeSolanaRPC serves the JSON-RPC methods sendInstructions uses. Every sent transaction gets a
+// signature whose status is looked up in statuses; a missing status has not been seen by the cluster.
+type fakeSolanaRPC struct {
+	mu          sync.Mutex
+	blockHeight uint64
+	blockhashes []solana.Hash           // handed out in order, each valid up to 100 blocks past blockHeight
+	sent        []*solana.Transaction   // transactions received, in order
+	statuses    map[int]json.RawMessage // status of the n-th sent transaction
+	logs        map[int][]string        // program logs of the n-th sent transaction
+}
+
+func (f *fakeSolanaRPC) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ID     json.RawMessage   `json:"id"`
+		Method string            `json:"method"`
+		Params []json.RawMessage `json:"params"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var result any
+	switch req.Method {
+	case "getLatestBlockhash":
+		hash := f.blockhashes[0]
+		if len(f.blockhashes) > 1 {
+			f.blockhashes = f.blockhashes[1:]
+		}
+		result = map[string]any{
+			"context": map[string]any{"slot": 1},
+			"value":   map[string]any{"blockhash": hash.String(), "lastValidBlockHeight": f.blockHeight + 100},
+		}
+	case "sendTransaction":
+		var encoded string
+		json.Unmarshal(req.Params[0], &encoded)
+		tx, err := solana.TransactionFromBase64(encoded)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		f.sent = append(f.sent, tx)
+		result = tx.Signatures[0].String()
+	case "getSignatureStatuses":
+		var sigs []string
+		json.Unmarshal(req.Params[0], &sigs)
+		status := json.RawMessage("null")
+		if n := f.index(sigs[0]); n >= 0 && f.statuses[n] != nil {
+			status = f.statuses[n]
+		}
+		result = map[string]any{"context": map[string]any{"slot": 1}, "value": []json.RawMessage{status}}
+	case "getBlockHeight":
+		// Every poll moves the chain past the last valid block height of the pending transaction
+		f.blockHeight += 200
+		result = f.blockHeight
+	case "getTransaction":
+		var sig string
+		json.Unmarshal(req.Params[0], &sig)
+		n := f.index(sig)
+		result = map[string]any{"slot": 1, "meta": map[string]any{"err": json.RawMessage(f.statuses[n]), "fee": 5000, "logMessages": f.logs[n]}}
+	default:
+		http.Error(w, "unexpected method "+req.Method, http.StatusBadRequest)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]any{"jsonrpc": "2.0", "id": req.ID, "result": result})
+}
+
+// index returns which sent transaction has signature sig, or -1
+func (f *fakeSolanaRPC) index(sig string) int {
+	for n, tx := range f.sent {
+		if tx.Signatures[0].String() == sig {
+			return n
+		}
+	}
+	return -1
+}
+
+// newFakeSolanaClient returns a client on fake and a paymaster that signs for it
+func newFakeSolanaClient(t *testing.T, fake *fakeSolanaRPC) (*SolanaClient, *solanaSigner) {
+	t.Helper()
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	key, err := solana.NewRandomPrivateKey()
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	paymaster, err := newSolanaSigner(key.String(), signer.RemoteConfig{})
+	if err != nil {
+		t.Fatalf("new paymaster: %v", err)
+	}
+	return &SolanaClient{client: rpc.New(server.URL), network: "solana-devnet"}, paymaster
+}
+
+func TestSendInstructionsRebuildsExpiredTransactions(t *testing.T) {
+	fake := &fakeSolanaRPC{
+		blockhashes: []solana.Hash{{0x01}, {0x02}},
+		statuses:    map[int]json.RawMessage{1: json.RawMessage(`{"slot":1,"confirmations":1,"err":null,"confirmationStatus":"confirmed"}`)},
+	}
+	sc, paymaster := newFakeSolanaClient(t, fake)
+	transfer := system.NewTransferInstruction(1, paymaster.PublicKey(), solana.NewWallet().PublicKey()).Build()
+
+	sig, _, err := sc.sendInstructions(context.Background(), paymaster, transfer)
+	if err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	if len(fake.sent) != 2 {
+		t.Fatalf("expected the expired transaction to be sent again, got %d sends", len(fake.sent))
+	}
+	if fake.sent[0].Message.RecentBlockhash == fake.sent[1].Message.RecentBlockhash {
+		t.Errorf("expected the resend on a new blockhash, both used %s", fake.sent[0].Message.RecentBlockhash)
+	}
+	if sig != fake.sent[1].Signatures[0] {
+		t.Errorf("expected the signature of the confirmed resend, got %s", sig)
+	}
+}
+
+func TestSendInstructionsDecodesFailedTransactions(t *testing.T) {
+	fake := &fakeSolanaRPC{
+		blockhashes: []solana.Hash{{0x01}},
+		statuses: map[int]json.RawMessage{0: json.RawMessage(
+			`{"slot":1,"confirmations":1,"err":{"InstructionError":[0,{"Custom":6001}]},"confirmationStatus":"confirmed"}`)},
+		logs: map[int][]string{0: {
+			"Program log: AnchorError thrown in programs/tinypay/src/lib.rs:120. Error Code: InvalidOtp. Error Number: 6001. Error Message: Invalid OTP.",
+		}},
+	}
+	sc, paymaster := newFakeSolanaClient(t, fake)
+	transfer := system.NewTransferInstruction(1, paymaster.PublicKey(), solana.NewWallet().PublicKey()).Build()
+
+	_, _, err := sc.sendInstructions(context.Background(), paymaster, transfer)
+	var chainErr *ChainError
+	if !errors.As(err, &chainErr) {
+		t.Fatalf("expected a ChainError, got %v", err)
+	}
+	if chainErr.Code != "InvalidOtp" || chainErr.Reason != FailureInvalidOTP {
+		t.Errorf("expected the decoded Anchor error, got %+v", chainErr)
+	}
+	if len(fake.sent) != 1 {
+		t.Errorf("expected a failed transaction not to be sent again, got %d sends", len(fake.sent))
+	}
+
+	// Without readable logs the status error is still decoded
+	fake.logs = nil
+	fake.sent = nil
+	fake.statuses[0] = json.RawMessage(`{"slot":1,"confirmations":1,"err":{"InstructionError":[0,{"Custom":6001}]},"confirmationStatus":"processed"}`)
+	_, _, err = sc.sendInstructions(context.Background(), paymaster, transfer)
+	if !errors.As(err, &chainErr) || chainErr.Code != "Custom(0x1771)" {
+		t.Errorf("expected the custom program error, got %v", err)
+	}
+}
@@ -0,0 +1,82 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+
+	"tinypay-server/config"
+
+	"github.com/gagliardetto/solana-go"
+	computebudget "github.com/gagliardetto/solana-go/programs/compute-budget"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+// computeBudget returns the compute budget instructions that price a transaction of instructions
+// under the fee policy, with the fee they set. A static policy without a price or limit adds no
+// instructions, which keeps the network's defaults.
+func (sc *SolanaClient) computeBudget(ctx context.Context, instructions []solana.Instruction) ([]solana.Instruction, *Fee, error) {
+	policy := sc.fees
+	fee := &Fee{
+		Mode:  feeMode(policy),
+		Unit:  FeeUnitMicroLamportsPerUnit,
+		Limit: policy.Limit,
+	}
+
+	if policy.Estimated() {
+		recent, err := sc.client.GetRecentPrioritizationFees(ctx, writableAccounts(instructions))
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to get recent prioritization fees: %w", err)
+		}
+		fee.Price, fee.Capped = scaleFee(policy, medianPrioritizationFee(recent), policy.MaxPrice)
+	} else {
+		fee.Price, fee.Capped = scaleFee(policy, policy.Price, policy.MaxPrice)
+	}
+
+	var budget []solana.Instruction
+	if fee.Limit > 0 {
+		units := uint32(min(fee.Limit, math.MaxUint32))
+		budget = append(budget, computebudget.NewSetComputeUnitLimitInstruction(units).Build())
+	}
+	if fee.Price > 0 {
+		budget = append(budget, computebudget.NewSetComputeUnitPriceInstruction(fee.Price).Build())
+	}
+	return budget, fee, nil
+}
+
+// medianPrioritizationFee returns the median compute unit price paid in the recent slots
+func medianPrioritizationFee(recent []rpc.PriorizationFeeResult) uint64 {
+	if len(recent) == 0 {
+		return 0
+	}
+	fees := make([]uint64, len(recent))
+	for i, result := range recent {
+		fees[i] = result.PrioritizationFee
+	}
+	sort.Slice(fees, func(i, j int) bool { return fees[i] < fees[j] })
+	return fees[len(fees)/2]
+}
+
+// writableAccounts lists the accounts instructions write to, which local fee markets price by
+func writableAccounts(instructions []solana.Instruction) solana.PublicKeySlice {
+	var accounts solana.PublicKeySlice
+	seen := make(map[solana.PublicKey]bool)
+	for _, instruction := range instructions {
+		for _, meta := range instruction.Accounts() {
+			if meta.IsWritable && !seen[meta.PublicKey] {
+				seen[meta.PublicKey] = true
+				accounts = append(accounts, meta.PublicKey)
+			}
+		}
+	}
+	return accounts
+}
+
+// validateSolanaFees checks the fee policy of a Solana network
+func validateSolanaFees(policy config.FeePolicy) error {
+	if policy.Limit > math.MaxUint32 {
+		return fmt.Errorf("compute unit limit %d does not fit in 32 bits", policy.Limit)
+	}
+	return policy.Validate()
+}
@@ -0,0 +1,74 @@
+package client
+
+import (
+	"context"
+	"math/big"
+
+	"tinypay-server/config"
+
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+// paymasterBalance reads the lamport balance of the paymaster key at index
+func (sc *SolanaClient) paymasterBalance(ctx context.Context, index int) (*big.Int, error) {
+	out, err := sc.client.GetBalance(ctx, sc.paymasterKeys[index].PublicKey(), rpc.CommitmentConfirmed)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetUint64(out.Value), nil
+}
+
+// acquirePaymaster takes the paymaster key of the next transaction: the key with address when
+// set, otherwise the pool's choice. The returned func gives the key back.
+func (sc *SolanaClient) acquirePaymaster(address string) (*solanaSigner, func(), error) {
+	var index int
+	var err error
+	if address != "" {
+		index, err = sc.paymasters.acquireAddress(address)
+	} else {
+		index, err = sc.paymasters.acquire()
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return sc.paymasterKeys[index], func() { sc.paymasters.release(index) }, nil
+}
+
+// GetPaymasterAddresses returns the public keys of the network's paymaster keys
+func (sc *SolanaClient) GetPaymasterAddresses() []string {
+	addresses := make([]string, len(sc.paymasterKeys))
+	for i, key := range sc.paymasterKeys {
+		addresses[i] = key.PublicKey().String()
+	}
+	return addresses
+}
+
+// Paymasters returns the state of the network's paymaster keys
+func (sc *SolanaClient) Paymasters() []PaymasterKey {
+	return sc.paymasters.states()
+}
+
+// PaymasterBalance reads the native balance, in lamports, of the paymaster key with address
+func (sc *SolanaClient) PaymasterBalance(ctx context.Context, address string) (*big.Int, error) {
+	return sc.paymasters.readBalance(ctx, address)
+}
+
+// NativeUnit names the unit of paymaster balances
+func (sc *SolanaClient) NativeUnit() string {
+	return "lamports"
+}
+
+// PaymasterPool returns the configuration of the network's paymaster pool
+func (sc *SolanaClient) PaymasterPool() config.PaymasterPool {
+	return sc.paymasters.cfg
+}
+
+// DrainPaymaster stops selecting a paymaster key for new transactions
+func (sc *SolanaClient) DrainPaymaster(address string) (*PaymasterKey, error) {
+	return sc.paymasters.setDraining(address, true)
+}
+
+// ResumePaymaster puts a drained paymaster key back into rotation
+func (sc *SolanaClient) ResumePaymaster(address string) (*PaymasterKey, error) {
+	return sc.paymasters.setDraining(address, false)
+}
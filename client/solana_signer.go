@@ -0,0 +1,53 @@
+package client
+
+import (
+	"context"
+	"fmt"
+
+	"tinypay-server/signer"
+
+	"github.com/gagliardetto/solana-go"
+)
+
+// solanaSigner is a Solana paymaster whose Ed25519 key is held by a signer.Signer, locally or on
+// the remote signer
+type solanaSigner struct {
+	signer    signer.Signer
+	publicKey solana.PublicKey
+}
+
+// newSolanaSigner creates the paymaster of a configured key field, a base58 private key or
+// remote:<public key>
+func newSolanaSigner(value string, remote signer.RemoteConfig) (*solanaSigner, error) {
+	s, err := signer.NewSolana(value, remote)
+	if err != nil {
+		return nil, err
+	}
+	if s.Scheme() != signer.SchemeEd25519 || len(s.PublicKey()) != solana.PublicKeyLength {
+		return nil, fmt.Errorf("solana accounts need an %s key", signer.SchemeEd25519)
+	}
+	return &solanaSigner{signer: s, publicKey: solana.PublicKeyFromBytes(s.PublicKey())}, nil
+}
+
+// PublicKey returns the paymaster's account address
+func (p *solanaSigner) PublicKey() solana.PublicKey {
+	return p.publicKey
+}
+
+// signTransaction signs tx, whose only required signer must be the paymaster as fee payer
+func (p *solanaSigner) signTransaction(ctx context.Context, tx *solana.Transaction) error {
+	header := tx.Message.Header
+	if header.NumRequiredSignatures != 1 || len(tx.Message.AccountKeys) == 0 || !tx.Message.AccountKeys[0].Equals(p.publicKey) {
+		return fmt.Errorf("transaction needs signers other than the paymaster %s", p.publicKey)
+	}
+	message, err := tx.Message.MarshalBinary()
+	if err != nil {
+		return fmt.Errorf("failed to encode message: %w", err)
+	}
+	raw, err := p.signer.Sign(ctx, message)
+	if err != nil {
+		return err
+	}
+	tx.Signatures = []solana.Signature{solana.SignatureFromBytes(raw)}
+	return nil
+}
@@ -0,0 +1,55 @@
+package client
+
+import (
+	"context"
+	"crypto/ed25519"
+	"testing"
+
+	"tinypay-server/signer"
+	"tinypay-server/signer/signertest"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/programs/system"
+)
+
+func TestSolanaSigner_RemoteSignsAsFeePayer(t *testing.T) {
+	server := signertest.NewServer("token")
+	defer server.Close()
+	key, _ := solana.NewRandomPrivateKey()
+	server.AddEd25519(ed25519.PrivateKey(key))
+
+	paymaster, err := newSolanaSigner(signer.RemotePrefix+key.PublicKey().String(), signer.RemoteConfig{URL: server.URL, Token: "token"})
+	if err != nil {
+		t.Fatalf("new remote paymaster: %v", err)
+	}
+	if !paymaster.PublicKey().Equals(key.PublicKey()) {
+		t.Fatalf("expected paymaster %s, got %s", key.PublicKey(), paymaster.PublicKey())
+	}
+
+	recipient := solana.NewWallet().PublicKey()
+	tx, err := solana.NewTransaction(
+		[]solana.Instruction{system.NewTransferInstruction(1, paymaster.PublicKey(), recipient).Build()},
+		solana.Hash{0x01},
+		solana.TransactionPayer(paymaster.PublicKey()),
+	)
+	if err != nil {
+		t.Fatalf("new transaction: %v", err)
+	}
+	if err := paymaster.signTransaction(context.Background(), tx); err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	if err := tx.VerifySignatures(); err != nil {
+		t.Errorf("verify: %v", err)
+	}
+
+	// A transaction another account must sign cannot be completed by the paymaster alone
+	other := solana.NewWallet().PublicKey()
+	tx, _ = solana.NewTransaction(
+		[]solana.Instruction{system.NewTransferInstruction(1, other, recipient).Build()},
+		solana.Hash{0x01},
+		solana.TransactionPayer(paymaster.PublicKey()),
+	)
+	if err := paymaster.signTransaction(context.Background(), tx); err == nil {
+		t.Error("expected a transaction with another signer to be refused")
+	}
+}
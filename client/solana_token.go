@@ -0,0 +1,235 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"tinypay-server/utils"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+// createIdempotentATAInstruction is the associated token account program's CreateIdempotent
+// instruction, which succeeds when the account already exists
+const createIdempotentATAInstruction = 1
+
+// tokenProgramForMint returns the token program that owns a mint (SPL Token or Token-2022).
+// Mint owners never change, so the result is cached per mint.
+func (sc *SolanaClient) tokenProgramForMint(ctx context.Context, mint solana.PublicKey) (solana.PublicKey, error) {
+	if cached, ok := sc.tokenPrograms.Load(mint); ok {
+		return cached.(solana.PublicKey), nil
+	}
+
+	info, err := sc.client.GetAccountInfo(ctx, mint)
+	if err != nil {
+		return solana.PublicKey{}, fmt.Errorf("failed to get mint account %s: %w", mint, err)
+	}
+	if info == nil || info.Value == nil {
+		return solana.PublicKey{}, fmt.Errorf("mint account %s not found", mint)
+	}
+
+	owner := info.Value.Owner
+	if !owner.Equals(solana.TokenProgramID) && !owner.Equals(solana.Token2022ProgramID) {
+		return solana.PublicKey{}, fmt.Errorf("mint %s is owned by %s, not a token program", mint, owner)
+	}
+	sc.tokenPrograms.Store(mint, owner)
+	return owner, nil
+}
+
+// findAssociatedTokenAddress derives the associated token account of a wallet for a mint.
+// Unlike solana.FindAssociatedTokenAddress it takes the token program, so it also covers Token-2022.
+func findAssociatedTokenAddress(wallet, mint, tokenProgram solana.PublicKey) (solana.PublicKey, error) {
+	ata, _, err := solana.FindProgramAddress(
+		[][]byte{
+			wallet.Bytes(),
+			tokenProgram.Bytes(),
+			mint.Bytes(),
+		},
+		solana.SPLAssociatedTokenAccountProgramID,
+	)
+	if err != nil {
+		return solana.PublicKey{}, fmt.Errorf("failed to derive associated token account: %w", err)
+	}
+	return ata, nil
+}
+
+// buildCreateATAInstruction creates the associated token account of wallet, funded by payer
+func buildCreateATAInstruction(payer, ata, wallet, mint, tokenProgram solana.PublicKey) solana.Instruction {
+	return solana.NewInstruction(
+		solana.SPLAssociatedTokenAccountProgramID,
+		solana.AccountMetaSlice{
+			solana.Meta(payer).WRITE().SIGNER(),
+			solana.Meta(ata).WRITE(),
+			solana.Meta(wallet),
+			solana.Meta(mint),
+			solana.Meta(solana.SystemProgramID),
+			solana.Meta(tokenProgram),
+		},
+		[]byte{createIdempotentATAInstruction},
+	)
+}
+
+// accountExists reports whether an account has been created on chain
+func (sc *SolanaClient) accountExists(ctx context.Context, account solana.PublicKey) (bool, error) {
+	info, err := sc.client.GetAccountInfo(ctx, account)
+	if errors.Is(err, rpc.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to get account %s: %w", account, err)
+	}
+	return info != nil && info.Value != nil, nil
+}
+
+// completeTokenPayment executes complete_token_payment, which moves SPL or Token-2022 tokens
+// from the program vault's token account to the recipient's associated token account.
+// The recipient account is created in the same transaction when it does not exist yet, at the
+// expense of paymaster.
+func (sc *SolanaClient) completeTokenPayment(
+	ctx context.Context,
+	paymaster *solanaSigner,
+	payerPubkey solana.PublicKey,
+	recipientPubkey solana.PublicKey,
+	mint solana.PublicKey,
+	otpString string,
+	amount uint64,
+) (solana.Signature, *Fee, error) {
+	log.Printf("Executing Solana complete_token_payment - Payer: %s, Recipient: %s, Mint: %s, Amount: %d",
+		payerPubkey.String(), recipientPubkey.String(), mint.String(), amount)
+
+	tokenProgram, err := sc.tokenProgramForMint(ctx, mint)
+	if err != nil {
+		return solana.Signature{}, nil, err
+	}
+
+	userAccountPDA, _, err := solana.FindProgramAddress(
+		[][]byte{
+			[]byte("user"),
+			payerPubkey.Bytes(),
+		},
+		sc.programID,
+	)
+	if err != nil {
+		return solana.Signature{}, nil, fmt.Errorf("failed to derive user account PDA: %w", err)
+	}
+
+	statePDA, _, err := solana.FindProgramAddress(
+		[][]byte{[]byte("state")},
+		sc.programID,
+	)
+	if err != nil {
+		return solana.Signature{}, nil, fmt.Errorf("failed to derive state PDA: %w", err)
+	}
+
+	vaultPDA, err := sc.vaultAddress()
+	if err != nil {
+		return solana.Signature{}, nil, err
+	}
+
+	// The vault PDA owns one token account per mint
+	vaultTokenAccount, err := findAssociatedTokenAddress(vaultPDA, mint, tokenProgram)
+	if err != nil {
+		return solana.Signature{}, nil, err
+	}
+	recipientTokenAccount, err := findAssociatedTokenAddress(recipientPubkey, mint, tokenProgram)
+	if err != nil {
+		return solana.Signature{}, nil, err
+	}
+
+	var instructions []solana.Instruction
+	exists, err := sc.accountExists(ctx, recipientTokenAccount)
+	if err != nil {
+		return solana.Signature{}, nil, err
+	}
+	if !exists {
+		log.Printf("Creating associated token account %s for recipient %s", recipientTokenAccount, recipientPubkey)
+		instructions = append(instructions, buildCreateATAInstruction(paymaster.PublicKey(), recipientTokenAccount, recipientPubkey, mint, tokenProgram))
+	}
+
+	accounts := solana.AccountMetaSlice{
+		solana.Meta(userAccountPDA).WRITE(),
+		solana.Meta(statePDA).WRITE(),
+		solana.Meta(vaultPDA),
+		solana.Meta(vaultTokenAccount).WRITE(),
+		solana.Meta(recipientTokenAccount).WRITE(),
+		solana.Meta(mint),
+		solana.Meta(paymaster.PublicKey()).SIGNER(),
+		solana.Meta(tokenProgram),
+	}
+	instructionData := buildCompleteTokenPaymentInstruction(ConvertOTPForContract(otpString), amount)
+	instructions = append(instructions, solana.NewInstruction(sc.programID, accounts, instructionData))
+
+	sig, fee, err := sc.sendInstructions(ctx, paymaster, instructions...)
+	if err != nil {
+		return solana.Signature{}, nil, err
+	}
+
+	log.Printf("Solana token payment completed! Signature: %s", sig)
+	return sig, fee, nil
+}
+
+// buildCompleteTokenPaymentInstruction constructs the instruction data for complete_token_payment
+// Format: [discriminator(8)] + [otp_len(4)] + [otp] + [amount(8)], the same layout as complete_payment
+func buildCompleteTokenPaymentInstruction(otp []byte, amount uint64) []byte {
+	data := buildCompletePaymentInstruction(otp, amount)
+	copy(data[:8], computeAnchorDiscriminator("complete_token_payment"))
+	return data
+}
+
+// tokenTransfer is the token movement a payment transaction made into a non-vault account
+type tokenTransfer struct {
+	mint   solana.PublicKey
+	amount uint64
+}
+
+// decodeTokenTransfer finds the token account whose balance grew in a transaction, ignoring
+// accounts owned by vault. Accounts created by the transaction have no pre balance.
+func decodeTokenTransfer(meta *rpc.TransactionMeta, vault solana.PublicKey) (*tokenTransfer, bool) {
+	if meta == nil {
+		return nil, false
+	}
+
+	pre := make(map[uint16]uint64, len(meta.PreTokenBalances))
+	for _, balance := range meta.PreTokenBalances {
+		pre[balance.AccountIndex] = parseTokenAmount(balance.UiTokenAmount)
+	}
+
+	for _, balance := range meta.PostTokenBalances {
+		if balance.Owner != nil && balance.Owner.Equals(vault) {
+			continue
+		}
+		post := parseTokenAmount(balance.UiTokenAmount)
+		if before := pre[balance.AccountIndex]; post > before {
+			return &tokenTransfer{mint: balance.Mint, amount: post - before}, true
+		}
+	}
+	return nil, false
+}
+
+func parseTokenAmount(amount *rpc.UiTokenAmount) uint64 {
+	if amount == nil {
+		return 0
+	}
+	value, err := strconv.ParseUint(amount.Amount, 10, 64)
+	if err != nil {
+		return 0
+	}
+	return value
+}
+
+// currencyForMint returns the configured symbol of a mint, or the mint address when it is not configured
+func (sc *SolanaClient) currencyForMint(mint solana.PublicKey) string {
+	if netCfg := utils.GetSolanaNetworkConfig(sc.config, sc.network); netCfg != nil {
+		for _, token := range netCfg.Tokens {
+			if token.Address == mint.String() {
+				return strings.ToUpper(token.Symbol)
+			}
+		}
+	}
+	return mint.String()
+}
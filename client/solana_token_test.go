@@ -0,0 +1,75 @@
+package client
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+func TestFindAssociatedTokenAddress(t *testing.T) {
+	wallet := solana.MustPublicKeyFromBase58("GrDMoeqMLFjeXQ24H56S1RLgT4R76jsuWCd6SvXyGPQ5")
+	mint := solana.MustPublicKeyFromBase58("EPjFWdd5AufqSSqeM2qN1xzybapC8G4wEGGkZwyTDt1v")
+
+	want, _, err := solana.FindAssociatedTokenAddress(wallet, mint)
+	if err != nil {
+		t.Fatalf("reference derivation: %v", err)
+	}
+	got, err := findAssociatedTokenAddress(wallet, mint, solana.TokenProgramID)
+	if err != nil {
+		t.Fatalf("derive: %v", err)
+	}
+	if !got.Equals(want) {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+
+	token2022, err := findAssociatedTokenAddress(wallet, mint, solana.Token2022ProgramID)
+	if err != nil {
+		t.Fatalf("derive token-2022: %v", err)
+	}
+	if token2022.Equals(want) {
+		t.Error("expected Token-2022 accounts to be derived with their own program")
+	}
+}
+
+func TestBuildCompleteTokenPaymentInstruction(t *testing.T) {
+	native := buildCompletePaymentInstruction([]byte("otp"), 42)
+	token := buildCompleteTokenPaymentInstruction([]byte("otp"), 42)
+
+	if !bytes.Equal(token[:8], computeAnchorDiscriminator("complete_token_payment")) {
+		t.Errorf("unexpected discriminator %x", token[:8])
+	}
+	if !bytes.Equal(token[8:], native[8:]) {
+		t.Errorf("expected the same argument layout as complete_payment")
+	}
+}
+
+func TestDecodeTokenTransfer(t *testing.T) {
+	vault := solana.NewWallet().PublicKey()
+	recipient := solana.NewWallet().PublicKey()
+	mint := solana.NewWallet().PublicKey()
+
+	meta := &rpc.TransactionMeta{
+		PreTokenBalances: []rpc.TokenBalance{
+			{AccountIndex: 3, Owner: &vault, Mint: mint, UiTokenAmount: &rpc.UiTokenAmount{Amount: "5000"}},
+		},
+		PostTokenBalances: []rpc.TokenBalance{
+			{AccountIndex: 3, Owner: &vault, Mint: mint, UiTokenAmount: &rpc.UiTokenAmount{Amount: "3750"}},
+			// The recipient account was created by the transaction, so it has no pre balance
+			{AccountIndex: 4, Owner: &recipient, Mint: mint, UiTokenAmount: &rpc.UiTokenAmount{Amount: "1250"}},
+		},
+	}
+
+	transfer, ok := decodeTokenTransfer(meta, vault)
+	if !ok {
+		t.Fatal("expected a token transfer")
+	}
+	if transfer.amount != 1250 || !transfer.mint.Equals(mint) {
+		t.Errorf("unexpected transfer: %+v", transfer)
+	}
+
+	if _, ok := decodeTokenTransfer(&rpc.TransactionMeta{}, vault); ok {
+		t.Error("expected no token transfer for a native payment")
+	}
+}
@@ -1,14 +1,165 @@
 package config
 
 import (
+	"fmt"
 	"log"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 	"github.com/pelletier/go-toml/v2"
 )
 
+// DefaultStoragePath is used when no ledger database path is configured
+const DefaultStoragePath = "data/tinypay.db"
+
+// DefaultIdempotencyWindow is how long an Idempotency-Key is remembered when not configured
+const DefaultIdempotencyWindow = 24 * time.Hour
+
+// DefaultOTPTailCacheTTL is how long a payer's on-chain tail is reused for OTP pre-verification when not configured
+const DefaultOTPTailCacheTTL = 30 * time.Second
+
+// Defaults for the payer lock manager
+const (
+	DefaultLockBackend        = "memory"
+	DefaultLockDir            = "data/locks"
+	DefaultLockLeaseTTL       = 5 * time.Minute
+	DefaultLockAcquireTimeout = 30 * time.Second
+)
+
+// Defaults for the asynchronous payment submission workers
+const (
+	DefaultAsyncWorkersPerNetwork = 4
+	DefaultAsyncQueueSize         = 256
+)
+
+// Defaults for the stuck EVM transaction monitor
+const (
+	DefaultEVMStuckTxTimeout  = 3 * time.Minute
+	DefaultEVMMonitorInterval = 15 * time.Second
+	DefaultEVMFeeBumpPercent  = 20
+	DefaultEVMMaxReplacements = 3
+)
+
+// Defaults for the paymaster balance monitor
+const (
+	DefaultPaymasterMonitorInterval = time.Minute
+	DefaultPaymasterBurnRateWindow  = time.Hour
+)
+
+// Defaults for merchant webhook delivery
+const (
+	DefaultWebhookMaxAttempts    = 8
+	DefaultWebhookInitialBackoff = 30 * time.Second
+	DefaultWebhookMaxBackoff     = time.Hour
+	DefaultWebhookTimeout        = 10 * time.Second
+)
+
+// Contract event indexer defaults
+const (
+	DefaultEVMIndexerConfirmations = 12
+	DefaultEVMIndexerBatchSize     = 2000
+	DefaultAptosIndexerBatchSize   = 100 // the most rows the Aptos indexer API returns per query
+	DefaultIndexerPollInterval     = 15 * time.Second
+)
+
+// DefaultRemoteSignerTimeout bounds a remote signing request when not configured
+const DefaultRemoteSignerTimeout = 10 * time.Second
+
+// Paymaster key selection strategies
+const (
+	PaymasterSelectionRoundRobin  = "round-robin"  // Keys take turns
+	PaymasterSelectionLeastLoaded = "least-loaded" // The key with the fewest transactions in flight
+)
+
+// PaymasterPool configures how a network spreads its transactions over its paymaster keys
+type PaymasterPool struct {
+	Selection        string   `toml:"selection"`         // "round-robin" (default) or "least-loaded"
+	MinBalance       uint64   `toml:"min_balance"`       // Keys whose native balance is below this are skipped, 0 to never skip
+	Drain            []string `toml:"drain"`             // Addresses that only finish what they started and take no new transactions
+	AlertBelow       uint64   `toml:"alert_below"`       // A low-balance alert fires when a key's sampled balance drops below this, 0 for no alerts
+	UnavailableBelow uint64   `toml:"unavailable_below"` // The network is reported unavailable while every key is below this, 0 to never
+}
+
+// Validate checks the selection strategy of a paymaster pool
+func (p PaymasterPool) Validate() error {
+	switch p.Selection {
+	case "", PaymasterSelectionRoundRobin, PaymasterSelectionLeastLoaded:
+		return nil
+	default:
+		return fmt.Errorf("unknown paymaster selection %q", p.Selection)
+	}
+}
+
+// PaymasterKeys merges a single configured key with a key list, dropping blanks and duplicates
+func PaymasterKeys(key string, keys []string) []string {
+	var merged []string
+	seen := make(map[string]bool)
+	for _, k := range append([]string{key}, keys...) {
+		k = strings.TrimSpace(k)
+		if k == "" || seen[k] {
+			continue
+		}
+		seen[k] = true
+		merged = append(merged, k)
+	}
+	return merged
+}
+
+// Fee policy modes
+const (
+	FeeModeStatic                 = "static"                   // Configured price, or the chain client's default
+	FeeModeEstimate               = "estimate"                 // Price estimated by the network
+	FeeModeEstimateWithMultiplier = "estimate-with-multiplier" // Estimate scaled by the policy's multiplier
+)
+
+// DefaultFeeMultiplier scales estimates in estimate-with-multiplier mode when no multiplier is configured
+const DefaultFeeMultiplier = 1.2
+
+// FeePolicy configures how a network prices its transactions. Prices are in the chain's smallest unit:
+// octas per gas unit on Aptos, wei per gas on EVM and micro-lamports per compute unit on Solana.
+type FeePolicy struct {
+	Mode           string  `toml:"mode"`             // "static" (default), "estimate" or "estimate-with-multiplier"
+	Multiplier     float64 `toml:"multiplier"`       // Applied to estimates in estimate-with-multiplier mode
+	Price          uint64  `toml:"price"`            // Static price; max fee per gas on EVM
+	PriorityFee    uint64  `toml:"priority_fee"`     // EVM only: static max priority fee per gas
+	MaxPrice       uint64  `toml:"max_price"`        // Hard cap on the price, 0 for none
+	MaxPriorityFee uint64  `toml:"max_priority_fee"` // EVM only: hard cap on the priority fee, 0 for none
+	Limit          uint64  `toml:"limit"`            // Max gas amount on Aptos, gas limit on EVM, compute unit limit on Solana; 0 keeps the default
+}
+
+// Validate checks the mode and multiplier of a fee policy
+func (p FeePolicy) Validate() error {
+	switch p.Mode {
+	case "", FeeModeStatic, FeeModeEstimate, FeeModeEstimateWithMultiplier:
+	default:
+		return fmt.Errorf("unknown fee mode %q", p.Mode)
+	}
+	if p.Multiplier < 0 {
+		return fmt.Errorf("fee multiplier must not be negative, got %v", p.Multiplier)
+	}
+	return nil
+}
+
+// Estimated reports whether the policy prices transactions from network estimates
+func (p FeePolicy) Estimated() bool {
+	return p.Mode == FeeModeEstimate || p.Mode == FeeModeEstimateWithMultiplier
+}
+
+// EffectiveMultiplier returns the factor applied to estimates
+func (p FeePolicy) EffectiveMultiplier() float64 {
+	switch {
+	case p.Mode != FeeModeEstimateWithMultiplier:
+		return 1
+	case p.Multiplier == 0:
+		return DefaultFeeMultiplier
+	default:
+		return p.Multiplier
+	}
+}
+
 // EVMToken represents an ERC20 token configuration
 type EVMToken struct {
 	Symbol  string `toml:"symbol"`
@@ -21,6 +172,42 @@ type EVMNativeToken struct {
 	Address string `toml:"address"`
 }
 
+// EVMIndexer configures the contract event indexer of an EVM network
+type EVMIndexer struct {
+	Enabled       bool    `toml:"enabled"`
+	StartBlock    uint64  `toml:"start_block"`   // First block to backfill, usually the contract's deployment block
+	Confirmations *uint64 `toml:"confirmations"` // Blocks a block must be behind the head before it is indexed; 0 follows the head
+	BatchSize     uint64  `toml:"batch_size"`    // Blocks per log query, 0 for the default
+	PollInterval  string  `toml:"poll_interval"` // Go duration, e.g. "15s"
+}
+
+// ConfirmationDepth returns the configured confirmations, or the default when unset
+func (i EVMIndexer) ConfirmationDepth() uint64 {
+	if i.Confirmations == nil {
+		return DefaultEVMIndexerConfirmations
+	}
+	return *i.Confirmations
+}
+
+// Interval returns the configured poll interval, or the default when unset or invalid
+func (i EVMIndexer) Interval() time.Duration {
+	return parseDuration("evm_networks.indexer.poll_interval", i.PollInterval, DefaultIndexerPollInterval)
+}
+
+// AptosIndexer configures the contract event indexer of Aptos
+type AptosIndexer struct {
+	Enabled      bool   `toml:"enabled"`
+	GraphQLURL   string `toml:"graphql_url"`   // Indexer API the events are listed from; required on custom networks
+	StartVersion uint64 `toml:"start_version"` // First ledger version to backfill, usually the one that published the contract
+	BatchSize    uint64 `toml:"batch_size"`    // Events per indexer API query, at most 100; 0 for the default
+	PollInterval string `toml:"poll_interval"` // Go duration, e.g. "15s"
+}
+
+// Interval returns the configured poll interval, or the default when unset or invalid
+func (i AptosIndexer) Interval() time.Duration {
+	return parseDuration("aptos.indexer.poll_interval", i.PollInterval, DefaultIndexerPollInterval)
+}
+
 // EVMNetwork represents a single EVM network configuration
 type EVMNetwork struct {
 	Name         string         `toml:"name"`
@@ -28,8 +215,12 @@ type EVMNetwork struct {
 	ChainID      uint64         `toml:"chain_id"`
 	ContractAddress string      `toml:"contract_address"`
 	PrivateKey   string         `toml:"private_key"`
+	PrivateKeys  []string       `toml:"private_keys"` // Additional paymaster keys pooled with private_key
 	NativeToken  EVMNativeToken `toml:"native_token"`
 	Tokens       []EVMToken     `toml:"tokens"`
+	Fees         FeePolicy      `toml:"fees"`
+	Paymasters   PaymasterPool  `toml:"paymasters"`
+	Indexer      EVMIndexer     `toml:"indexer"`
 }
 
 // SolanaToken represents a Solana SPL token configuration
@@ -49,8 +240,11 @@ type SolanaNetwork struct {
 	RPCURL              string            `toml:"rpc_url"`
 	ProgramID           string            `toml:"program_id"`
 	PaymasterPrivateKey string            `toml:"paymaster_private_key"`
+	PaymasterPrivateKeys []string         `toml:"paymaster_private_keys"` // Additional paymaster keys pooled with paymaster_private_key
 	NativeToken         SolanaNativeToken `toml:"native_token"`
 	Tokens              []SolanaToken     `toml:"tokens"`
+	Fees                FeePolicy         `toml:"fees"`
+	Paymasters          PaymasterPool     `toml:"paymasters"`
 }
 
 // TomlConfig represents the TOML configuration structure
@@ -58,7 +252,10 @@ type TomlConfig struct {
 	Aptos struct {
 		Network   string `toml:"network"`
 		NodeURL   string `toml:"node_url"`
-		FaucetURL string `toml:"faucet_url"`
+		FaucetURL string    `toml:"faucet_url"`
+		Fees      FeePolicy `toml:"fees"`
+		Paymasters PaymasterPool `toml:"paymasters"`
+		Indexer    AptosIndexer  `toml:"indexer"`
 	} `toml:"aptos"`
 	
 	Contract struct {
@@ -78,7 +275,67 @@ type TomlConfig struct {
 	Keys struct {
 		MerchantPrivateKey  string `toml:"merchant_private_key"`
 		PaymasterPrivateKey string `toml:"paymaster_private_key"`
+		PaymasterPrivateKeys []string `toml:"paymaster_private_keys"`
 	} `toml:"keys"`
+
+	Keystore struct {
+		PasswordFile string `toml:"password_file"` // File holding the passphrase of keystore:<path> key fields
+	} `toml:"keystore"`
+
+	RemoteSigner struct {
+		URL        string `toml:"url"`         // Web3Signer-compatible service signing remote:<public key> key fields
+		Ed25519URL string `toml:"ed25519_url"` // Service for Aptos and Solana keys, when not url
+		Token      string `toml:"token"`       // Bearer token sent to the service
+		Timeout    string `toml:"timeout"`     // Go duration, e.g. "10s"
+	} `toml:"remote_signer"`
+
+	Storage struct {
+		Path string `toml:"path"`
+	} `toml:"storage"`
+
+	Idempotency struct {
+		Window string `toml:"window"` // Go duration, e.g. "24h"
+	} `toml:"idempotency"`
+
+	Async struct {
+		WorkersPerNetwork int `toml:"workers_per_network"`
+		QueueSize         int `toml:"queue_size"`
+	} `toml:"async"`
+
+	OTP struct {
+		TailCacheTTL string `toml:"tail_cache_ttl"` // Go duration, e.g. "30s"
+	} `toml:"otp"`
+
+	Locks struct {
+		Backend        string `toml:"backend"` // "memory" or "file"
+		Dir            string `toml:"dir"`
+		LeaseTTL       string `toml:"lease_ttl"`       // Go duration, e.g. "5m"
+		AcquireTimeout string `toml:"acquire_timeout"` // Go duration, e.g. "30s"
+	} `toml:"locks"`
+	
+	EVMMonitor struct {
+		StuckAfter      string `toml:"stuck_after"`    // Go duration, e.g. "3m"
+		CheckInterval   string `toml:"check_interval"` // Go duration, e.g. "15s"
+		FeeBumpPercent  uint64 `toml:"fee_bump_percent"`
+		MaxReplacements int    `toml:"max_replacements"`
+	} `toml:"evm_monitor"`
+
+	Admin struct {
+		Token string `toml:"token"`
+	} `toml:"admin"`
+
+	PaymasterMonitor struct {
+		Interval        string `toml:"interval"`          // Go duration, e.g. "1m"
+		BurnRateWindow  string `toml:"burn_rate_window"`  // Go duration, e.g. "1h"
+		AlertWebhookURL string `toml:"alert_webhook_url"` // Receives low-balance alerts as JSON POSTs
+	} `toml:"paymaster_monitor"`
+
+	Webhooks struct {
+		MaxAttempts    int    `toml:"max_attempts"`    // Attempts before a delivery is dead-lettered
+		InitialBackoff string `toml:"initial_backoff"` // Go duration, e.g. "30s"
+		MaxBackoff     string `toml:"max_backoff"`     // Go duration, e.g. "1h"
+		Timeout        string `toml:"timeout"`         // Go duration, e.g. "10s"
+	} `toml:"webhooks"`
 	
 	EVMNetworks    []EVMNetwork    `toml:"evm_networks"`
 	SolanaNetworks []SolanaNetwork `toml:"solana_networks"`
@@ -116,10 +373,60 @@ type Config struct {
 	// Private Keys
 	MerchantPrivateKey  string
 	PaymasterPrivateKey string
+	PaymasterPrivateKeys []string // Additional Aptos fee payer keys pooled with PaymasterPrivateKey
+	AptosPaymasters      PaymasterPool // Selection of the Aptos fee payer keys
+	AptosIndexer         AptosIndexer  // Contract event indexer of Aptos
+	KeystorePasswordFile string        // Passphrase file of key fields set to keystore:<path>; KEYSTORE_PASSWORD takes precedence
+
+	// Remote Signer Configuration (key fields set to remote:<public key>)
+	RemoteSignerURL        string        // Web3Signer-compatible signing service
+	RemoteSignerEd25519URL string        // Signing service for Aptos and Solana keys; RemoteSignerURL when empty
+	RemoteSignerToken      string        // Bearer token sent to the signing service
+	RemoteSignerTimeout    time.Duration // Bound on each signing request
 
 	// Gas Configuration
 	MaxGasAmount uint64
 	GasUnitPrice uint64
+	AptosFees    FeePolicy // Fee policy of Aptos transactions; static prices and limits default to the gas settings above
+
+	// Storage Configuration
+	StoragePath string // Path of the embedded payment ledger database
+
+	// Idempotency Configuration
+	IdempotencyWindow time.Duration // How long POST /api/payments responses are replayed for an Idempotency-Key
+
+	// Async Submission Configuration
+	AsyncWorkersPerNetwork int // Workers submitting queued payments, per network
+	AsyncQueueSize         int // Queued payments per network before new async requests are rejected
+
+	// OTP Pre-verification Configuration
+	OTPTailCacheTTL time.Duration // How long a payer's on-chain tail is cached for local OTP checks
+
+	// Payer Lock Configuration
+	LockBackend        string        // "memory", or "file" to share the locks through LockDir
+	LockDir            string        // Directory holding lease files for the file lock backend
+	LockLeaseTTL       time.Duration // How long an unrenewed payer lock lasts before it counts as abandoned
+	LockAcquireTimeout time.Duration // How long a payment waits for a payer lock before it is rejected as busy
+
+	// Stuck EVM Transaction Monitor Configuration
+	EVMStuckTxTimeout  time.Duration // How long a payment transaction may stay pending before it is rebroadcast with higher fees
+	EVMMonitorInterval time.Duration // How often pending payment transactions are checked
+	EVMFeeBumpPercent  uint64        // Fee increase of each replacement, in percent of the previous fees
+	EVMMaxReplacements int           // Automatic replacements per transaction before the monitor only waits
+
+	// Admin Configuration
+	AdminToken string // Bearer token of the /api/admin endpoints, which are disabled when empty
+
+	// Paymaster Balance Monitor Configuration
+	PaymasterMonitorInterval time.Duration // How often the native balance of every paymaster key is sampled
+	PaymasterBurnRateWindow  time.Duration // Samples the burn rate is computed over
+	PaymasterAlertWebhookURL string        // Receives low-balance alerts; alerts are only logged when empty
+
+	// Merchant Webhook Configuration
+	WebhookMaxAttempts    int           // Attempts of a delivery before it is dead-lettered
+	WebhookInitialBackoff time.Duration // Wait before the first retry; doubles with every further retry
+	WebhookMaxBackoff     time.Duration // Longest wait between two retries
+	WebhookTimeout        time.Duration // Bound on each delivery request
 	
 	// EVM Networks (new array-based configuration)
 	EVMNetworks []EVMNetwork
@@ -131,12 +438,16 @@ type Config struct {
 func LoadConfig() *Config {
 	// Try to load TOML config first
 	config := loadTomlConfig()
-	if config != nil {
-		return config
+	if config == nil {
+		// Fallback to legacy .env loading
+		config = loadEnvConfig()
 	}
-	
-	// Fallback to legacy .env loading
-	return loadEnvConfig()
+
+	// Key fields may refer to encrypted keystore files instead of holding the key
+	if err := resolveKeystores(config); err != nil {
+		log.Fatalf("Failed to decrypt keystore: %v", err)
+	}
+	return config
 }
 
 func loadTomlConfig() *Config {
@@ -169,10 +480,60 @@ func loadTomlConfig() *Config {
 		// Gas configuration
 		MaxGasAmount:          tomlConfig.Gas.MaxGasAmount,
 		GasUnitPrice:          tomlConfig.Gas.GasUnitPrice,
+		AptosFees:             tomlConfig.Aptos.Fees,
 		
 		// Private keys
 		MerchantPrivateKey:    tomlConfig.Keys.MerchantPrivateKey,
 		PaymasterPrivateKey:   tomlConfig.Keys.PaymasterPrivateKey,
+		PaymasterPrivateKeys:  tomlConfig.Keys.PaymasterPrivateKeys,
+		AptosPaymasters:       tomlConfig.Aptos.Paymasters,
+		AptosIndexer:          tomlConfig.Aptos.Indexer,
+		KeystorePasswordFile:  tomlConfig.Keystore.PasswordFile,
+		
+		// Remote signer configuration
+		RemoteSignerURL:        tomlConfig.RemoteSigner.URL,
+		RemoteSignerEd25519URL: tomlConfig.RemoteSigner.Ed25519URL,
+		RemoteSignerToken:      tomlConfig.RemoteSigner.Token,
+		RemoteSignerTimeout:    parseDuration("remote_signer.timeout", tomlConfig.RemoteSigner.Timeout, DefaultRemoteSignerTimeout),
+		
+		// Storage configuration
+		StoragePath:           tomlConfig.Storage.Path,
+		
+		// Idempotency configuration
+		IdempotencyWindow:     parseDuration("idempotency.window", tomlConfig.Idempotency.Window, DefaultIdempotencyWindow),
+		
+		// Async submission configuration
+		AsyncWorkersPerNetwork: tomlConfig.Async.WorkersPerNetwork,
+		AsyncQueueSize:         tomlConfig.Async.QueueSize,
+		
+		// OTP pre-verification configuration
+		OTPTailCacheTTL:        parseDuration("otp.tail_cache_ttl", tomlConfig.OTP.TailCacheTTL, DefaultOTPTailCacheTTL),
+		
+		// Payer lock configuration
+		LockBackend:            tomlConfig.Locks.Backend,
+		LockDir:                tomlConfig.Locks.Dir,
+		LockLeaseTTL:           parseDuration("locks.lease_ttl", tomlConfig.Locks.LeaseTTL, DefaultLockLeaseTTL),
+		LockAcquireTimeout:     parseDuration("locks.acquire_timeout", tomlConfig.Locks.AcquireTimeout, DefaultLockAcquireTimeout),
+		
+		// Stuck EVM transaction monitor configuration
+		EVMStuckTxTimeout:      parseDuration("evm_monitor.stuck_after", tomlConfig.EVMMonitor.StuckAfter, DefaultEVMStuckTxTimeout),
+		EVMMonitorInterval:     parseDuration("evm_monitor.check_interval", tomlConfig.EVMMonitor.CheckInterval, DefaultEVMMonitorInterval),
+		EVMFeeBumpPercent:      tomlConfig.EVMMonitor.FeeBumpPercent,
+		EVMMaxReplacements:     tomlConfig.EVMMonitor.MaxReplacements,
+		
+		// Admin configuration
+		AdminToken:             tomlConfig.Admin.Token,
+		
+		// Paymaster balance monitor configuration
+		PaymasterMonitorInterval: parseDuration("paymaster_monitor.interval", tomlConfig.PaymasterMonitor.Interval, DefaultPaymasterMonitorInterval),
+		PaymasterBurnRateWindow:  parseDuration("paymaster_monitor.burn_rate_window", tomlConfig.PaymasterMonitor.BurnRateWindow, DefaultPaymasterBurnRateWindow),
+		PaymasterAlertWebhookURL: tomlConfig.PaymasterMonitor.AlertWebhookURL,
+		
+		// Merchant webhook configuration
+		WebhookMaxAttempts:    tomlConfig.Webhooks.MaxAttempts,
+		WebhookInitialBackoff: parseDuration("webhooks.initial_backoff", tomlConfig.Webhooks.InitialBackoff, DefaultWebhookInitialBackoff),
+		WebhookMaxBackoff:     parseDuration("webhooks.max_backoff", tomlConfig.Webhooks.MaxBackoff, DefaultWebhookMaxBackoff),
+		WebhookTimeout:        parseDuration("webhooks.timeout", tomlConfig.Webhooks.Timeout, DefaultWebhookTimeout),
 		
 		// EVM networks (new array-based configuration)
 		EVMNetworks:           tomlConfig.EVMNetworks,
@@ -182,6 +543,31 @@ func loadTomlConfig() *Config {
 	}
 	
     // Legacy EVM fields are intentionally not set to avoid hardcoding network names.
+
+	if config.StoragePath == "" {
+		config.StoragePath = DefaultStoragePath
+	}
+	if config.AsyncWorkersPerNetwork <= 0 {
+		config.AsyncWorkersPerNetwork = DefaultAsyncWorkersPerNetwork
+	}
+	if config.AsyncQueueSize <= 0 {
+		config.AsyncQueueSize = DefaultAsyncQueueSize
+	}
+	if config.LockBackend == "" {
+		config.LockBackend = DefaultLockBackend
+	}
+	if config.LockDir == "" {
+		config.LockDir = DefaultLockDir
+	}
+	if config.EVMFeeBumpPercent == 0 {
+		config.EVMFeeBumpPercent = DefaultEVMFeeBumpPercent
+	}
+	if config.EVMMaxReplacements <= 0 {
+		config.EVMMaxReplacements = DefaultEVMMaxReplacements
+	}
+	if config.WebhookMaxAttempts <= 0 {
+		config.WebhookMaxAttempts = DefaultWebhookMaxAttempts
+	}
 	
 	// Validate required fields
 	if config.ContractAddress == "" {
@@ -220,8 +606,53 @@ func loadEnvConfig() *Config {
 		Port:                       getEnv("PORT", "9090"),
 		MerchantPrivateKey:         getEnv("MERCHANT_PRIVATE_KEY", ""),
 		PaymasterPrivateKey:        getEnv("PAYMASTER_PRIVATE_KEY", ""),
+		PaymasterPrivateKeys:       getEnvList("PAYMASTER_PRIVATE_KEYS"),
+		AptosPaymasters: PaymasterPool{
+			Selection:        getEnv("APTOS_PAYMASTER_SELECTION", PaymasterSelectionRoundRobin),
+			MinBalance:       getEnvUint64("APTOS_PAYMASTER_MIN_BALANCE", 0),
+			Drain:            getEnvList("APTOS_PAYMASTER_DRAIN"),
+			AlertBelow:       getEnvUint64("APTOS_PAYMASTER_ALERT_BELOW", 0),
+			UnavailableBelow: getEnvUint64("APTOS_PAYMASTER_UNAVAILABLE_BELOW", 0),
+		},
+		AptosIndexer: AptosIndexer{
+			Enabled:      getEnv("APTOS_INDEXER_ENABLED", "false") == "true",
+			GraphQLURL:   os.Getenv("APTOS_INDEXER_GRAPHQL_URL"),
+			StartVersion: getEnvUint64("APTOS_INDEXER_START_VERSION", 0),
+			BatchSize:    getEnvUint64("APTOS_INDEXER_BATCH_SIZE", 0),
+			PollInterval: os.Getenv("APTOS_INDEXER_POLL_INTERVAL"),
+		},
 		MaxGasAmount:               getEnvUint64("MAX_GAS_AMOUNT", 100000),
 		GasUnitPrice:               getEnvUint64("GAS_UNIT_PRICE", 100),
+		AptosFees: FeePolicy{
+			Mode:       getEnv("APTOS_FEE_MODE", FeeModeStatic),
+			Multiplier: getEnvFloat64("APTOS_FEE_MULTIPLIER", 0),
+			MaxPrice:   getEnvUint64("APTOS_FEE_MAX_PRICE", 0),
+		},
+		StoragePath:                getEnv("STORAGE_PATH", DefaultStoragePath),
+		IdempotencyWindow:          parseDuration("IDEMPOTENCY_WINDOW", os.Getenv("IDEMPOTENCY_WINDOW"), DefaultIdempotencyWindow),
+		AsyncWorkersPerNetwork:     int(getEnvUint64("ASYNC_WORKERS_PER_NETWORK", DefaultAsyncWorkersPerNetwork)),
+		AsyncQueueSize:             int(getEnvUint64("ASYNC_QUEUE_SIZE", DefaultAsyncQueueSize)),
+		OTPTailCacheTTL:            parseDuration("OTP_TAIL_CACHE_TTL", os.Getenv("OTP_TAIL_CACHE_TTL"), DefaultOTPTailCacheTTL),
+		LockBackend:                getEnv("LOCK_BACKEND", DefaultLockBackend),
+		LockDir:                    getEnv("LOCK_DIR", DefaultLockDir),
+		LockLeaseTTL:               parseDuration("LOCK_LEASE_TTL", os.Getenv("LOCK_LEASE_TTL"), DefaultLockLeaseTTL),
+		LockAcquireTimeout:         parseDuration("LOCK_ACQUIRE_TIMEOUT", os.Getenv("LOCK_ACQUIRE_TIMEOUT"), DefaultLockAcquireTimeout),
+		EVMStuckTxTimeout:          parseDuration("EVM_STUCK_TX_TIMEOUT", os.Getenv("EVM_STUCK_TX_TIMEOUT"), DefaultEVMStuckTxTimeout),
+		EVMMonitorInterval:         parseDuration("EVM_MONITOR_INTERVAL", os.Getenv("EVM_MONITOR_INTERVAL"), DefaultEVMMonitorInterval),
+		EVMFeeBumpPercent:          getEnvUint64("EVM_FEE_BUMP_PERCENT", DefaultEVMFeeBumpPercent),
+		EVMMaxReplacements:         int(getEnvUint64("EVM_MAX_REPLACEMENTS", DefaultEVMMaxReplacements)),
+		AdminToken:                 getEnv("ADMIN_TOKEN", ""),
+		PaymasterMonitorInterval:   parseDuration("PAYMASTER_MONITOR_INTERVAL", os.Getenv("PAYMASTER_MONITOR_INTERVAL"), DefaultPaymasterMonitorInterval),
+		PaymasterBurnRateWindow:    parseDuration("PAYMASTER_BURN_RATE_WINDOW", os.Getenv("PAYMASTER_BURN_RATE_WINDOW"), DefaultPaymasterBurnRateWindow),
+		PaymasterAlertWebhookURL:   getEnv("PAYMASTER_ALERT_WEBHOOK_URL", ""),
+		WebhookMaxAttempts:         int(getEnvUint64("WEBHOOK_MAX_ATTEMPTS", DefaultWebhookMaxAttempts)),
+		WebhookInitialBackoff:      parseDuration("WEBHOOK_INITIAL_BACKOFF", os.Getenv("WEBHOOK_INITIAL_BACKOFF"), DefaultWebhookInitialBackoff),
+		WebhookMaxBackoff:          parseDuration("WEBHOOK_MAX_BACKOFF", os.Getenv("WEBHOOK_MAX_BACKOFF"), DefaultWebhookMaxBackoff),
+		WebhookTimeout:             parseDuration("WEBHOOK_TIMEOUT", os.Getenv("WEBHOOK_TIMEOUT"), DefaultWebhookTimeout),
+		RemoteSignerURL:            getEnv("REMOTE_SIGNER_URL", ""),
+		RemoteSignerEd25519URL:     getEnv("REMOTE_SIGNER_ED25519_URL", ""),
+		RemoteSignerToken:          getEnv("REMOTE_SIGNER_TOKEN", ""),
+		RemoteSignerTimeout:        parseDuration("REMOTE_SIGNER_TIMEOUT", os.Getenv("REMOTE_SIGNER_TIMEOUT"), DefaultRemoteSignerTimeout),
 	}
 	
     // Skip env-to-array conversion to avoid embedding hardcoded network names.
@@ -266,3 +697,36 @@ func getEnvUint64(key string, defaultValue uint64) uint64 {
 	}
 	return defaultValue
 }
+
+// getEnvList reads a comma-separated list, dropping blank entries
+func getEnvList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+func getEnvFloat64(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+			return parsed
+		}
+	}
+	return defaultValue
+}
+
+// parseDuration parses a Go duration setting, falling back to defaultValue when it is empty or invalid
+func parseDuration(name, value string, defaultValue time.Duration) time.Duration {
+	if value == "" {
+		return defaultValue
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil || parsed <= 0 {
+		log.Printf("Warning: invalid %s %q, using %s", name, value, defaultValue)
+		return defaultValue
+	}
+	return parsed
+}
@@ -2,7 +2,10 @@ package config
 
 import (
 	"os"
+	"path/filepath"
 	"testing"
+
+	"tinypay-server/keystore"
 )
 
 func TestLoadConfig_CeloSepoliaConfiguration(t *testing.T) {
@@ -247,3 +250,60 @@ func TestGetEnvUint64_CeloSepoliaChainID(t *testing.T) {
 		})
 	}
 }
+
+func TestPaymasterKeys(t *testing.T) {
+	got := PaymasterKeys(" key1 ", []string{"key2", "", "key1", "key3"})
+	want := []string{"key1", "key2", "key3"}
+	if len(got) != len(want) {
+		t.Fatalf("Expected %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Expected %v, got %v", want, got)
+			break
+		}
+	}
+
+	if keys := PaymasterKeys("", nil); len(keys) != 0 {
+		t.Errorf("Expected no keys, got %v", keys)
+	}
+}
+
+func TestResolveKeystores(t *testing.T) {
+	privateKey, err := keystore.Generate(keystore.ChainSolana)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	data, err := keystore.Encrypt(keystore.ChainSolana, privateKey, "secret", keystore.Light)
+	if err != nil {
+		t.Fatalf("Failed to encrypt key: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "solana.json")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("Failed to write keystore: %v", err)
+	}
+
+	config := &Config{
+		MerchantPrivateKey: "0xplain",
+		SolanaNetworks: []SolanaNetwork{{
+			Name:                "solana-devnet",
+			PaymasterPrivateKey: keystore.Prefix + path,
+		}},
+	}
+	t.Setenv("KEYSTORE_PASSWORD", "secret")
+	if err := resolveKeystores(config); err != nil {
+		t.Fatalf("Failed to resolve keystores: %v", err)
+	}
+	if config.SolanaNetworks[0].PaymasterPrivateKey != privateKey {
+		t.Errorf("Expected the keystore to be decrypted into the key field")
+	}
+	if config.MerchantPrivateKey != "0xplain" {
+		t.Errorf("Expected plain keys to stay as they are, got %s", config.MerchantPrivateKey)
+	}
+
+	// A keystore of the wrong chain is rejected
+	config.MerchantPrivateKey = keystore.Prefix + path
+	if err := resolveKeystores(config); err == nil {
+		t.Error("Expected a Solana keystore to be rejected for the Aptos merchant key")
+	}
+}
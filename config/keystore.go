@@ -0,0 +1,76 @@
+package config
+
+import (
+	"fmt"
+
+	"tinypay-server/keystore"
+	"tinypay-server/signer"
+)
+
+// keyField is a configured private key and the chain family it belongs to
+type keyField struct {
+	name  string
+	chain string
+	value *string
+}
+
+// keyFields lists every private key field of the configuration
+func (c *Config) keyFields() []keyField {
+	fields := []keyField{
+		{"merchant_private_key", keystore.ChainAptos, &c.MerchantPrivateKey},
+		{"paymaster_private_key", keystore.ChainAptos, &c.PaymasterPrivateKey},
+		{"ETH_SEPOLIA_PRIVATE_KEY", keystore.ChainEVM, &c.EVMPrivateKey},
+		{"CELO_SEPOLIA_PRIVATE_KEY", keystore.ChainEVM, &c.CeloSepoliaPrivateKey},
+	}
+	for i := range c.PaymasterPrivateKeys {
+		fields = append(fields, keyField{"paymaster_private_keys", keystore.ChainAptos, &c.PaymasterPrivateKeys[i]})
+	}
+	for i := range c.EVMNetworks {
+		network := &c.EVMNetworks[i]
+		fields = append(fields, keyField{network.Name + ".private_key", keystore.ChainEVM, &network.PrivateKey})
+		for j := range network.PrivateKeys {
+			fields = append(fields, keyField{network.Name + ".private_keys", keystore.ChainEVM, &network.PrivateKeys[j]})
+		}
+	}
+	for i := range c.SolanaNetworks {
+		network := &c.SolanaNetworks[i]
+		fields = append(fields, keyField{network.Name + ".paymaster_private_key", keystore.ChainSolana, &network.PaymasterPrivateKey})
+		for j := range network.PaymasterPrivateKeys {
+			fields = append(fields, keyField{network.Name + ".paymaster_private_keys", keystore.ChainSolana, &network.PaymasterPrivateKeys[j]})
+		}
+	}
+	return fields
+}
+
+// resolveKeystores replaces every key field that refers to a keystore file with the decrypted key.
+// The passphrase is only looked up when a field is a reference.
+func resolveKeystores(config *Config) error {
+	passphrase := ""
+	for _, field := range config.keyFields() {
+		if !keystore.IsReference(*field.value) {
+			continue
+		}
+		if passphrase == "" {
+			var err error
+			if passphrase, err = keystore.Passphrase(config.KeystorePasswordFile); err != nil {
+				return err
+			}
+		}
+		key, err := keystore.Resolve(field.chain, *field.value, passphrase)
+		if err != nil {
+			return fmt.Errorf("%s: %w", field.name, err)
+		}
+		*field.value = key
+	}
+	return nil
+}
+
+// RemoteSigner returns where key fields set to remote:<public key> are signed
+func (c *Config) RemoteSigner() signer.RemoteConfig {
+	return signer.RemoteConfig{
+		URL:        c.RemoteSignerURL,
+		Ed25519URL: c.RemoteSignerEd25519URL,
+		Token:      c.RemoteSignerToken,
+		Timeout:    c.RemoteSignerTimeout,
+	}
+}
@@ -0,0 +1,245 @@
+package indexer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"tinypay-server/client"
+	"tinypay-server/config"
+	"tinypay-server/store"
+
+	"github.com/aptos-labs/aptos-go-sdk"
+	"github.com/aptos-labs/aptos-go-sdk/api"
+)
+
+// aptosModule is the Move module that emits the TinyPay events
+const aptosModule = "tinypay"
+
+// Event fields mapped onto the normalized fields of a chain event, in order of preference. The
+// rest of an event's fields go into its details.
+var (
+	aptosAccountFields   = []string{"user", "payer", "merchant", "account", "owner"}
+	aptosRecipientFields = []string{"recipient", "payee"}
+	aptosTokenFields     = []string{"asset_metadata", "token", "metadata", "coin_type"}
+	aptosAmountFields    = []string{"amount"}
+	aptosFeeFields       = []string{"fee"}
+	aptosBalanceFields   = []string{"new_balance", "balance"}
+)
+
+// AptosEventSource lists the contract's events through the Aptos indexer API and reads the
+// transactions that emitted them from the node
+type AptosEventSource interface {
+	ModuleEvents(ctx context.Context, typePrefixes []string, start, startIndex, limit uint64) ([]client.AptosModuleEvent, error)
+	TransactionByVersion(version uint64) (*api.CommittedTransaction, error)
+}
+
+// aptosIndexer lists the events of the tinypay module by ledger version, so only transactions
+// that emitted one are read from the node. Aptos transactions are final once committed, so the
+// checkpoint only needs the next version and the next event within it.
+type aptosIndexer struct {
+	source       AptosEventSource
+	ledger       *store.Store
+	network      string
+	contract     aptos.AccountAddress
+	typePrefixes []string // <contract>::tinypay:: with the address in long and short form
+	startVersion uint64
+	batchSize    uint64
+}
+
+// NewAptosIndexer returns the indexer of the TinyPay contract on Aptos. Start it to begin indexing.
+func NewAptosIndexer(source AptosEventSource, cfg *config.Config, ledger *store.Store) (*Indexer, error) {
+	x, err := newAptosIndexer(source, ledger, cfg.ContractAddress, cfg.AptosIndexer)
+	if err != nil {
+		return nil, err
+	}
+	return &Indexer{
+		network:  x.network,
+		interval: cfg.AptosIndexer.Interval(),
+		sync:     x.sync,
+	}, nil
+}
+
+func newAptosIndexer(source AptosEventSource, ledger *store.Store, contractAddress string, settings config.AptosIndexer) (*aptosIndexer, error) {
+	var contract aptos.AccountAddress
+	if err := contract.ParseStringRelaxed(contractAddress); err != nil {
+		return nil, fmt.Errorf("invalid contract address %q: %w", contractAddress, err)
+	}
+	// Event types may carry the address with or without its leading zeros
+	long := contract.StringLong()
+	short := "0x" + strings.TrimLeft(strings.TrimPrefix(long, "0x"), "0")
+	typePrefixes := []string{long + "::" + aptosModule + "::"}
+	if short != long {
+		typePrefixes = append(typePrefixes, short+"::"+aptosModule+"::")
+	}
+
+	x := &aptosIndexer{
+		source:       source,
+		ledger:       ledger,
+		network:      client.AptosNetworkName,
+		contract:     contract,
+		typePrefixes: typePrefixes,
+		startVersion: settings.StartVersion,
+		batchSize:    settings.BatchSize,
+	}
+	if x.batchSize == 0 || x.batchSize > config.DefaultAptosIndexerBatchSize {
+		x.batchSize = config.DefaultAptosIndexerBatchSize
+	}
+	return x, nil
+}
+
+// sync indexes the contract events after the checkpoint, one page at a time
+func (x *aptosIndexer) sync(ctx context.Context) error {
+	cp, err := x.ledger.GetIndexerCheckpoint(x.network)
+	if errors.Is(err, store.ErrNotFound) {
+		cp = &store.IndexerCheckpoint{Network: x.network, Next: x.startVersion}
+	} else if err != nil {
+		return fmt.Errorf("failed to load checkpoint: %w", err)
+	}
+
+	for ctx.Err() == nil {
+		found, err := x.source.ModuleEvents(ctx, x.typePrefixes, cp.Next, cp.NextIndex, x.batchSize)
+		if err != nil {
+			return fmt.Errorf("failed to list events from version %d: %w", cp.Next, err)
+		}
+		if len(found) == 0 {
+			return nil
+		}
+
+		// A full page may end inside a transaction, whose remaining events start the next page
+		full := uint64(len(found)) == x.batchSize
+		last := found[len(found)-1]
+
+		events, err := x.normalizeAll(found)
+		if err != nil {
+			return err
+		}
+
+		// The checkpoint moves in the same write as the events, so a restart resumes after the page
+		if full {
+			cp.Next, cp.NextIndex = last.Version, last.Index+1
+		} else {
+			cp.Next, cp.NextIndex = last.Version+1, 0
+		}
+		if err := x.ledger.SaveChainEvents(cp, events); err != nil {
+			return fmt.Errorf("failed to store events before version %d: %w", cp.Next, err)
+		}
+		if !full {
+			return nil
+		}
+	}
+	return ctx.Err()
+}
+
+// normalizeAll reads the transaction of every listed event once and maps the events onto the ledger form
+func (x *aptosIndexer) normalizeAll(found []client.AptosModuleEvent) ([]*store.ChainEvent, error) {
+	var events []*store.ChainEvent
+	txns := make(map[uint64]*api.UserTransaction)
+	for _, e := range found {
+		name, ok := x.eventName(e.Type)
+		if !ok {
+			continue
+		}
+		txn, ok := txns[e.Version]
+		if !ok {
+			committed, err := x.source.TransactionByVersion(e.Version)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read transaction %d: %w", e.Version, err)
+			}
+			// Only user transactions call the contract
+			if txn, err = committed.UserTransaction(); err != nil {
+				txn = nil
+			}
+			txns[e.Version] = txn
+		}
+		if txn == nil || !txn.Success {
+			continue
+		}
+		events = append(events, x.normalize(name, txn, uint(e.Index), e.Data))
+	}
+	return events, nil
+}
+
+// eventName returns the name of a tinypay module event of the contract, e.g. PaymentCompleted
+// for <contract>::tinypay::PaymentCompleted
+func (x *aptosIndexer) eventName(eventType string) (string, bool) {
+	parts := strings.SplitN(eventType, "::", 3)
+	if len(parts) != 3 || parts[1] != aptosModule {
+		return "", false
+	}
+	var addr aptos.AccountAddress
+	if err := addr.ParseStringRelaxed(parts[0]); err != nil || addr != x.contract {
+		return "", false
+	}
+	name, _, _ := strings.Cut(parts[2], "<")
+	return name, true
+}
+
+// normalize maps the JSON fields of an event onto the ledger form
+func (x *aptosIndexer) normalize(name string, txn *api.UserTransaction, index uint, fields map[string]any) *store.ChainEvent {
+	event := &store.ChainEvent{
+		Type:      name,
+		Version:   txn.Version,
+		TxHash:    txn.Hash,
+		LogIndex:  index,
+		Timestamp: time.UnixMicro(int64(txn.Timestamp)).UTC(),
+	}
+
+	data := make(map[string]any, len(fields))
+	for k, v := range fields {
+		data[k] = v
+	}
+	// Commit time already dates the event
+	delete(data, "timestamp")
+
+	event.Account = takeField(data, aptosAccountFields)
+	event.Recipient = takeField(data, aptosRecipientFields)
+	event.Token = takeField(data, aptosTokenFields)
+	event.Amount = takeField(data, aptosAmountFields)
+	event.Fee = takeField(data, aptosFeeFields)
+	event.Balance = takeField(data, aptosBalanceFields)
+	if len(data) > 0 {
+		event.Details = make(map[string]string, len(data))
+		for k, v := range data {
+			event.Details[k] = fieldString(v)
+		}
+	}
+	return event
+}
+
+// takeField removes the first of names present in data and returns its value
+func takeField(data map[string]any, names []string) string {
+	for _, name := range names {
+		if v, ok := data[name]; ok {
+			delete(data, name)
+			return fieldString(v)
+		}
+	}
+	return ""
+}
+
+// fieldString renders a Move value from the REST API. Integers wider than u32 and byte vectors
+// already arrive as strings; objects such as Object<Metadata> are reduced to their address.
+func fieldString(v any) string {
+	switch value := v.(type) {
+	case string:
+		return value
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(value)
+	case map[string]any:
+		if inner, ok := value["inner"].(string); ok && len(value) == 1 {
+			return inner
+		}
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(raw)
+}
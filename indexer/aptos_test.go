@@ -0,0 +1,225 @@
+package indexer
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"tinypay-server/client"
+	"tinypay-server/config"
+	"tinypay-server/store"
+
+	"github.com/aptos-labs/aptos-go-sdk/api"
+)
+
+const testAptosContract = "0xc0"
+
+// fakeAptosLedger serves a ledger with one transaction per version, and lists the events of its
+// successful user transactions the way the indexer API does
+type fakeAptosLedger struct {
+	txns   []*api.CommittedTransaction
+	starts [][2]uint64 // start version and event index of every event query
+}
+
+func (l *fakeAptosLedger) ModuleEvents(ctx context.Context, typePrefixes []string, start, startIndex, limit uint64) ([]client.AptosModuleEvent, error) {
+	l.starts = append(l.starts, [2]uint64{start, startIndex})
+	var page []client.AptosModuleEvent
+	for _, txn := range l.txns {
+		user, err := txn.UserTransaction()
+		if err != nil || !user.Success || user.Version < start {
+			continue
+		}
+		for i, e := range user.Events {
+			if user.Version == start && uint64(i) < startIndex {
+				continue
+			}
+			if uint64(len(page)) == limit {
+				return page, nil
+			}
+			for _, prefix := range typePrefixes {
+				if strings.HasPrefix(e.Type, prefix) {
+					page = append(page, client.AptosModuleEvent{Version: user.Version, Index: uint64(i), Type: e.Type, Data: e.Data})
+					break
+				}
+			}
+		}
+	}
+	return page, nil
+}
+
+func (l *fakeAptosLedger) TransactionByVersion(version uint64) (*api.CommittedTransaction, error) {
+	for _, txn := range l.txns {
+		if txn.Version() == version {
+			return txn, nil
+		}
+	}
+	return nil, fmt.Errorf("no transaction at version %d", version)
+}
+
+// block appends block metadata transactions up to version
+func (l *fakeAptosLedger) block(version uint64) {
+	for next := l.nextVersion(); next <= version; next++ {
+		l.txns = append(l.txns, &api.CommittedTransaction{
+			Type:  api.TransactionVariantBlockMetadata,
+			Inner: &api.BlockMetadataTransaction{Version: next},
+		})
+	}
+}
+
+// user appends a user transaction with events at the next version
+func (l *fakeAptosLedger) user(success bool, events ...*api.Event) uint64 {
+	version := l.nextVersion()
+	l.txns = append(l.txns, &api.CommittedTransaction{
+		Type: api.TransactionVariantUser,
+		Inner: &api.UserTransaction{
+			Version:   version,
+			Hash:      fmt.Sprintf("0x%064x", version),
+			Success:   success,
+			Events:    events,
+			Timestamp: 1_700_000_000_000_000 + version*1000,
+		},
+	})
+	return version
+}
+
+func (l *fakeAptosLedger) nextVersion() uint64 {
+	if len(l.txns) == 0 {
+		return 0
+	}
+	return l.txns[len(l.txns)-1].Version() + 1
+}
+
+func aptosEvent(eventType string, data map[string]any) *api.Event {
+	return &api.Event{Type: eventType, Data: data}
+}
+
+func TestAptosIndexer_IndexesContractEventsFromCheckpoint(t *testing.T) {
+	ledger, err := store.Open(filepath.Join(t.TempDir(), "ledger.db"))
+	if err != nil {
+		t.Fatalf("open ledger: %v", err)
+	}
+	defer ledger.Close()
+
+	chain := &fakeAptosLedger{}
+	chain.block(98)
+	chain.user(true, aptosEvent("0xc0::tinypay::PaymentCompleted", map[string]any{"payer": "0x1", "amount": "5"})) // before the start version
+	chain.block(100)
+	payment := chain.user(true,
+		aptosEvent("0x1::fungible_asset::Withdraw", map[string]any{"amount": "1000"}),
+		aptosEvent("0x00000000000000000000000000000000000000000000000000000000000000c0::tinypay::PaymentCompleted", map[string]any{
+			"payer":          "0x1111",
+			"recipient":      "0x2222",
+			"amount":         "1000",
+			"fee":            "0",
+			"asset_metadata": map[string]any{"inner": "0xa"},
+			"new_tail":       "0xabcd",
+			"timestamp":      "1700000000",
+		}),
+	)
+	chain.user(true, aptosEvent("0xdd::tinypay::PaymentCompleted", map[string]any{"payer": "0x1111", "amount": "7"}))
+	chain.user(false, aptosEvent("0xc0::tinypay::DepositMade", map[string]any{"user": "0x1111", "amount": "9"}))
+	deposit := chain.user(true,
+		aptosEvent("0xc0::tinypay::DepositMade", map[string]any{
+			"user":           "0x3333",
+			"asset_metadata": "0xa",
+			"amount":         "500",
+			"new_balance":    "1500",
+		}),
+		aptosEvent("0xc0::tinypay::TailRefreshed", map[string]any{"user": "0x3333", "tail_update_count": "1"}),
+	)
+	chain.block(107)
+
+	// Two events per page, so the first page ends inside the deposit transaction
+	settings := config.AptosIndexer{Enabled: true, StartVersion: 100, BatchSize: 2}
+	x, err := newAptosIndexer(chain, ledger, testAptosContract, settings)
+	if err != nil {
+		t.Fatalf("new indexer: %v", err)
+	}
+	if err := x.sync(context.Background()); err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+
+	events, _, err := ledger.ListChainEvents(store.ChainEventFilter{Network: client.AptosNetworkName})
+	if err != nil {
+		t.Fatalf("list events: %v", err)
+	}
+	if len(events) != 3 || events[0].Version != deposit || events[1].Version != deposit || events[2].Version != payment {
+		t.Fatalf("expected the deposit, its tail refresh and the payment of the contract, got %+v", events)
+	}
+	if want := [][2]uint64{{100, 0}, {deposit, 1}}; !slices.Equal(chain.starts, want) {
+		t.Errorf("expected event queries from %v, got %v", want, chain.starts)
+	}
+	paid := events[2]
+	if paid.Type != "PaymentCompleted" || paid.Account != "0x1111" || paid.Recipient != "0x2222" || paid.Token != "0xa" ||
+		paid.Amount != "1000" || paid.Fee != "0" || paid.LogIndex != 1 || paid.TxHash != fmt.Sprintf("0x%064x", payment) {
+		t.Errorf("unexpected payment event %+v", paid)
+	}
+	if len(paid.Details) != 1 || paid.Details["new_tail"] != "0xabcd" {
+		t.Errorf("expected the tail in the details, got %v", paid.Details)
+	}
+	if paid.Timestamp.UnixMicro() != int64(1_700_000_000_000_000+payment*1000) {
+		t.Errorf("expected the commit time, got %s", paid.Timestamp)
+	}
+	if events[1].Account != "0x3333" || events[1].Balance != "1500" || events[1].LogIndex != 0 {
+		t.Errorf("unexpected deposit event %+v", events[1])
+	}
+
+	// A new indexer resumes after the checkpoint instead of the start version
+	refreshed := chain.user(true, aptosEvent("0xc0::tinypay::TailRefreshed", map[string]any{"user": "0x1111", "tail_update_count": "2"}))
+	chain.starts = nil
+	x, _ = newAptosIndexer(chain, ledger, testAptosContract, settings)
+	if err := x.sync(context.Background()); err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	if len(chain.starts) != 1 || chain.starts[0] != [2]uint64{deposit + 1, 0} {
+		t.Errorf("expected one query from version %d, got %v", deposit+1, chain.starts)
+	}
+	events, _, _ = ledger.ListChainEvents(store.ChainEventFilter{Network: client.AptosNetworkName})
+	if len(events) != 4 || events[0].Version != refreshed || events[0].Details["tail_update_count"] != "2" {
+		t.Errorf("expected the tail refresh to be indexed once, got %+v", events)
+	}
+	if cp, err := ledger.GetIndexerCheckpoint(client.AptosNetworkName); err != nil || cp.Next != refreshed+1 || cp.NextIndex != 0 {
+		t.Errorf("expected the checkpoint after version %d, got %+v (%v)", refreshed, cp, err)
+	}
+}
+
+func TestAptosIndexer_PagesThroughLargeTransactions(t *testing.T) {
+	ledger, err := store.Open(filepath.Join(t.TempDir(), "ledger.db"))
+	if err != nil {
+		t.Fatalf("open ledger: %v", err)
+	}
+	defer ledger.Close()
+
+	// One transaction emits more contract events than fit on a page
+	chain := &fakeAptosLedger{}
+	var refreshes []*api.Event
+	for i := 0; i < 5; i++ {
+		refreshes = append(refreshes, aptosEvent("0xc0::tinypay::TailRefreshed", map[string]any{"user": fmt.Sprintf("0x%d", i)}))
+	}
+	batch := chain.user(true, refreshes...)
+
+	x, err := newAptosIndexer(chain, ledger, testAptosContract, config.AptosIndexer{Enabled: true, BatchSize: 2})
+	if err != nil {
+		t.Fatalf("new indexer: %v", err)
+	}
+	if err := x.sync(context.Background()); err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+
+	events, _, err := ledger.ListChainEvents(store.ChainEventFilter{Network: client.AptosNetworkName})
+	if err != nil {
+		t.Fatalf("list events: %v", err)
+	}
+	if len(events) != 5 {
+		t.Fatalf("expected every event of the transaction, got %d", len(events))
+	}
+	if want := [][2]uint64{{0, 0}, {batch, 2}, {batch, 4}}; !slices.Equal(chain.starts, want) {
+		t.Errorf("expected event queries from %v, got %v", want, chain.starts)
+	}
+	if cp, err := ledger.GetIndexerCheckpoint(client.AptosNetworkName); err != nil || cp.Next != batch+1 || cp.NextIndex != 0 {
+		t.Errorf("expected the checkpoint after version %d, got %+v (%v)", batch, cp, err)
+	}
+}
@@ -0,0 +1,363 @@
+package indexer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"strconv"
+	"time"
+
+	tinypaybindings "tinypay-server/binds/tinypay"
+	"tinypay-server/config"
+	"tinypay-server/store"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+const (
+	// evmReorgWindow is how many indexed blocks are remembered to find where a reorg forked off
+	evmReorgWindow = 128
+	// evmCallTimeout bounds one node call of the indexer
+	evmCallTimeout = 30 * time.Second
+)
+
+// errReorgDuringQuery is returned when the chain changed between reading a range's logs and its headers.
+// The range is read again on the next pass.
+var errReorgDuringQuery = errors.New("chain reorganized while reading blocks")
+
+// evmLogChain is the part of the node API the EVM indexer uses
+type evmLogChain interface {
+	BlockNumber(ctx context.Context) (uint64, error)
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+	FilterLogs(ctx context.Context, q ethereum.FilterQuery) ([]types.Log, error)
+}
+
+// evmIndexer reads the logs of the TinyPay contract block range by block range, up to the
+// configured depth behind the head, and rolls back the events of blocks a reorg orphaned
+type evmIndexer struct {
+	chain         evmLogChain
+	ledger        *store.Store
+	network       string
+	contract      common.Address
+	filterer      *tinypaybindings.TinypayFilterer
+	events        map[common.Hash]string // topic of each contract event -> its name
+	startBlock    uint64
+	confirmations uint64
+	batchSize     uint64
+}
+
+// NewEVMIndexer connects to the RPC node of network and returns its indexer. Start it to begin indexing.
+func NewEVMIndexer(network config.EVMNetwork, ledger *store.Store) (*Indexer, error) {
+	if !common.IsHexAddress(network.ContractAddress) {
+		return nil, fmt.Errorf("invalid contract address %q", network.ContractAddress)
+	}
+	ethClient, err := ethclient.Dial(network.RPCURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", network.RPCURL, err)
+	}
+	x, err := newEVMIndexer(ethClient, ledger, network)
+	if err != nil {
+		ethClient.Close()
+		return nil, err
+	}
+	return &Indexer{
+		network:  network.Name,
+		interval: network.Indexer.Interval(),
+		sync:     x.sync,
+		release:  ethClient.Close,
+	}, nil
+}
+
+func newEVMIndexer(chain evmLogChain, ledger *store.Store, network config.EVMNetwork) (*evmIndexer, error) {
+	contract := common.HexToAddress(network.ContractAddress)
+	filterer, err := tinypaybindings.NewTinypayFilterer(contract, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to bind contract: %w", err)
+	}
+	contractABI, err := tinypaybindings.TinypayMetaData.GetAbi()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse contract ABI: %w", err)
+	}
+	events := make(map[common.Hash]string, len(contractABI.Events))
+	for name, event := range contractABI.Events {
+		events[event.ID] = name
+	}
+
+	x := &evmIndexer{
+		chain:         chain,
+		ledger:        ledger,
+		network:       network.Name,
+		contract:      contract,
+		filterer:      filterer,
+		events:        events,
+		startBlock:    network.Indexer.StartBlock,
+		confirmations: network.Indexer.ConfirmationDepth(),
+		batchSize:     network.Indexer.BatchSize,
+	}
+	if x.batchSize == 0 {
+		x.batchSize = config.DefaultEVMIndexerBatchSize
+	}
+	return x, nil
+}
+
+// sync indexes every block that is deep enough, one batch at a time
+func (x *evmIndexer) sync(ctx context.Context) error {
+	cp, err := x.ledger.GetIndexerCheckpoint(x.network)
+	if errors.Is(err, store.ErrNotFound) {
+		cp = &store.IndexerCheckpoint{Network: x.network, Next: x.startBlock}
+	} else if err != nil {
+		return fmt.Errorf("failed to load checkpoint: %w", err)
+	}
+
+	callCtx, cancel := context.WithTimeout(ctx, evmCallTimeout)
+	head, err := x.chain.BlockNumber(callCtx)
+	cancel()
+	if err != nil {
+		return fmt.Errorf("failed to read the chain head: %w", err)
+	}
+	if head < x.confirmations {
+		return nil
+	}
+	safe := head - x.confirmations
+
+	for {
+		if err := x.rollbackReorg(ctx, cp); err != nil {
+			return err
+		}
+		if cp.Next > safe || ctx.Err() != nil {
+			return ctx.Err()
+		}
+		to := cp.Next + x.batchSize - 1
+		if to > safe || to < cp.Next {
+			to = safe
+		}
+		if err := x.indexRange(ctx, cp, cp.Next, to); err != nil {
+			return err
+		}
+	}
+}
+
+// indexRange stores the contract events of blocks from to to and moves the checkpoint past them
+func (x *evmIndexer) indexRange(ctx context.Context, cp *store.IndexerCheckpoint, from, to uint64) error {
+	callCtx, cancel := context.WithTimeout(ctx, evmCallTimeout)
+	defer cancel()
+
+	logs, err := x.chain.FilterLogs(callCtx, ethereum.FilterQuery{
+		FromBlock: new(big.Int).SetUint64(from),
+		ToBlock:   new(big.Int).SetUint64(to),
+		Addresses: []common.Address{x.contract},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to read logs of blocks %d-%d: %w", from, to, err)
+	}
+
+	// The headers are read after the logs, so a log whose block is no longer canonical shows up
+	// as a hash mismatch instead of being stored
+	headers := make(map[uint64]*types.Header)
+	header := func(number uint64) (*types.Header, error) {
+		if h, ok := headers[number]; ok {
+			return h, nil
+		}
+		h, err := x.chain.HeaderByNumber(callCtx, new(big.Int).SetUint64(number))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read header of block %d: %w", number, err)
+		}
+		headers[number] = h
+		return h, nil
+	}
+
+	// The last block goes first: a reorg of any earlier block after this read also changes the
+	// headers of the logged blocks, and one after every read is found by the next rollbackReorg
+	last, err := header(to)
+	if err != nil {
+		return err
+	}
+
+	var events []*store.ChainEvent
+	var blocks []store.IndexedBlock
+	for _, l := range logs {
+		if l.Removed || len(l.Topics) == 0 {
+			continue
+		}
+		h, err := header(l.BlockNumber)
+		if err != nil {
+			return err
+		}
+		if h.Hash() != l.BlockHash {
+			return fmt.Errorf("%w %d-%d", errReorgDuringQuery, from, to)
+		}
+		if n := len(blocks); n == 0 || blocks[n-1].Number != l.BlockNumber {
+			blocks = append(blocks, store.IndexedBlock{Number: l.BlockNumber, Hash: l.BlockHash.Hex()})
+		}
+
+		name, ok := x.events[l.Topics[0]]
+		if !ok {
+			continue
+		}
+		event, err := x.normalize(name, l)
+		if err != nil {
+			log.Printf("Event indexer of %s: skipping %s log %d of %s: %v", x.network, name, l.Index, l.TxHash.Hex(), err)
+			continue
+		}
+		event.Timestamp = time.Unix(int64(h.Time), 0).UTC()
+		events = append(events, event)
+	}
+	if n := len(blocks); n == 0 || blocks[n-1].Number != to {
+		blocks = append(blocks, store.IndexedBlock{Number: to, Hash: last.Hash().Hex()})
+	}
+
+	cp.Next = to + 1
+	cp.Blocks = append(cp.Blocks, blocks...)
+	if len(cp.Blocks) > evmReorgWindow {
+		cp.Blocks = append([]store.IndexedBlock(nil), cp.Blocks[len(cp.Blocks)-evmReorgWindow:]...)
+	}
+	if err := x.ledger.SaveChainEvents(cp, events); err != nil {
+		return fmt.Errorf("failed to store events of blocks %d-%d: %w", from, to, err)
+	}
+	return nil
+}
+
+// rollbackReorg compares the latest indexed block with the chain. When it was replaced, the
+// remembered blocks are walked back to the last one still canonical and every event after it is
+// removed, so the next range is read again from the new fork.
+func (x *evmIndexer) rollbackReorg(ctx context.Context, cp *store.IndexerCheckpoint) error {
+	callCtx, cancel := context.WithTimeout(ctx, evmCallTimeout)
+	defer cancel()
+
+	for i := len(cp.Blocks) - 1; i >= 0; i-- {
+		block := cp.Blocks[i]
+		h, err := x.chain.HeaderByNumber(callCtx, new(big.Int).SetUint64(block.Number))
+		if err != nil && !errors.Is(err, ethereum.NotFound) {
+			return fmt.Errorf("failed to read header of block %d: %w", block.Number, err)
+		}
+		if h != nil && h.Hash().Hex() == block.Hash {
+			if i == len(cp.Blocks)-1 {
+				return nil
+			}
+			return x.rollback(cp, block.Number+1, cp.Blocks[:i+1])
+		}
+	}
+	if len(cp.Blocks) == 0 {
+		return nil
+	}
+	// The fork is older than every remembered block
+	return x.rollback(cp, cp.Blocks[0].Number, nil)
+}
+
+func (x *evmIndexer) rollback(cp *store.IndexerCheckpoint, from uint64, kept []store.IndexedBlock) error {
+	cp.Next = from
+	cp.Blocks = append([]store.IndexedBlock(nil), kept...)
+	removed, err := x.ledger.RollbackChainEvents(cp, from)
+	if err != nil {
+		return fmt.Errorf("failed to roll back events from block %d: %w", from, err)
+	}
+	log.Printf("Event indexer of %s: chain reorganized, removed %d events from block %d onwards", x.network, removed, from)
+	return nil
+}
+
+// normalize decodes a contract log with the binding of its event into the ledger form
+func (x *evmIndexer) normalize(name string, l types.Log) (*store.ChainEvent, error) {
+	event := &store.ChainEvent{
+		Type:        name,
+		BlockNumber: l.BlockNumber,
+		BlockHash:   l.BlockHash.Hex(),
+		TxHash:      l.TxHash.Hex(),
+		LogIndex:    l.Index,
+	}
+
+	switch name {
+	case "AccountInitialized":
+		e, err := x.filterer.ParseAccountInitialized(l)
+		if err != nil {
+			return nil, err
+		}
+		event.Account = e.User.Hex()
+	case "CoinSupported":
+		e, err := x.filterer.ParseCoinSupported(l)
+		if err != nil {
+			return nil, err
+		}
+		event.Token = e.Token.Hex()
+	case "DepositMade":
+		e, err := x.filterer.ParseDepositMade(l)
+		if err != nil {
+			return nil, err
+		}
+		event.Account = e.User.Hex()
+		event.Token = e.Token.Hex()
+		event.Amount = e.Amount.String()
+		event.Balance = e.NewBalance.String()
+		event.Details = map[string]string{"tail": hexutil.Encode(e.Tail)}
+	case "FundsWithdrawn":
+		e, err := x.filterer.ParseFundsWithdrawn(l)
+		if err != nil {
+			return nil, err
+		}
+		event.Account = e.User.Hex()
+		event.Token = e.Token.Hex()
+		event.Amount = e.Amount.String()
+		event.Balance = e.NewBalance.String()
+	case "PaymentCompleted":
+		e, err := x.filterer.ParsePaymentCompleted(l)
+		if err != nil {
+			return nil, err
+		}
+		event.Account = e.Payer.Hex()
+		event.Recipient = e.Recipient.Hex()
+		event.Token = e.Token.Hex()
+		event.Amount = e.Amount.String()
+		event.Fee = e.Fee.String()
+		event.Details = map[string]string{"new_tail": hexutil.Encode(e.NewTail)}
+	case "PaymentLimitUpdated":
+		e, err := x.filterer.ParsePaymentLimitUpdated(l)
+		if err != nil {
+			return nil, err
+		}
+		event.Account = e.User.Hex()
+		event.Details = map[string]string{
+			"old_limit": strconv.FormatUint(e.OldLimit, 10),
+			"new_limit": strconv.FormatUint(e.NewLimit, 10),
+		}
+	case "PreCommitMade":
+		e, err := x.filterer.ParsePreCommitMade(l)
+		if err != nil {
+			return nil, err
+		}
+		event.Account = e.Merchant.Hex()
+		event.Token = e.Token.Hex()
+		event.Details = map[string]string{
+			"commit_hash": hexutil.Encode(e.CommitHash[:]),
+			"expiry_time": strconv.FormatUint(e.ExpiryTime, 10),
+		}
+	case "TailRefreshed":
+		e, err := x.filterer.ParseTailRefreshed(l)
+		if err != nil {
+			return nil, err
+		}
+		event.Account = e.User.Hex()
+		event.Details = map[string]string{
+			"old_tail":          hexutil.Encode(e.OldTail),
+			"new_tail":          hexutil.Encode(e.NewTail),
+			"tail_update_count": strconv.FormatUint(e.TailUpdateCount, 10),
+		}
+	case "TailUpdatesLimitSet":
+		e, err := x.filterer.ParseTailUpdatesLimitSet(l)
+		if err != nil {
+			return nil, err
+		}
+		event.Account = e.User.Hex()
+		event.Details = map[string]string{
+			"old_limit": strconv.FormatUint(e.OldLimit, 10),
+			"new_limit": strconv.FormatUint(e.NewLimit, 10),
+		}
+	default:
+		return nil, fmt.Errorf("unknown event %s", name)
+	}
+	return event, nil
+}
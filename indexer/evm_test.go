@@ -0,0 +1,245 @@
+package indexer
+
+import (
+	"context"
+	"math/big"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	tinypaybindings "tinypay-server/binds/tinypay"
+	"tinypay-server/config"
+	"tinypay-server/store"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+var (
+	testContract  = common.HexToAddress("0x00000000000000000000000000000000000000c0")
+	testPayer     = common.HexToAddress("0x1111111111111111111111111111111111111111")
+	testRecipient = common.HexToAddress("0x2222222222222222222222222222222222222222")
+	testToken     = common.HexToAddress("0x3333333333333333333333333333333333333333")
+)
+
+// fakeLogChain is a chain whose blocks from some height on can be replaced by a fork
+type fakeLogChain struct {
+	t       *testing.T
+	abi     *abi.ABI
+	mu      sync.Mutex
+	headers []*types.Header
+	logs    []types.Log
+}
+
+func newFakeLogChain(t *testing.T, head uint64) *fakeLogChain {
+	contractABI, err := tinypaybindings.TinypayMetaData.GetAbi()
+	if err != nil {
+		t.Fatalf("parse ABI: %v", err)
+	}
+	c := &fakeLogChain{t: t, abi: contractABI}
+	c.fork(0, head, "main")
+	return c
+}
+
+// fork replaces the blocks from height on with head-height+1 new blocks and drops their logs
+func (c *fakeLogChain) fork(height, head uint64, name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.headers = c.headers[:height]
+	for n := height; n <= head; n++ {
+		c.headers = append(c.headers, &types.Header{
+			Number: new(big.Int).SetUint64(n),
+			Time:   1_700_000_000 + n*12,
+			Extra:  []byte(name),
+		})
+	}
+	kept := c.logs[:0]
+	for _, l := range c.logs {
+		if l.BlockNumber < height {
+			kept = append(kept, l)
+		}
+	}
+	c.logs = kept
+}
+
+// emit adds a contract event to block; indexed lists the indexed address arguments
+func (c *fakeLogChain) emit(block uint64, name string, indexed []common.Address, args ...interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	event := c.abi.Events[name]
+	data, err := event.Inputs.NonIndexed().Pack(args...)
+	if err != nil {
+		c.t.Fatalf("pack %s: %v", name, err)
+	}
+	topics := []common.Hash{event.ID}
+	for _, addr := range indexed {
+		topics = append(topics, common.BytesToHash(addr.Bytes()))
+	}
+	c.logs = append(c.logs, types.Log{
+		Address:     testContract,
+		Topics:      topics,
+		Data:        data,
+		BlockNumber: block,
+		BlockHash:   c.headers[block].Hash(),
+		TxHash:      common.BigToHash(new(big.Int).SetUint64(block*1000 + uint64(len(c.logs)))),
+		Index:       uint(len(c.logs)),
+	})
+}
+
+func (c *fakeLogChain) BlockNumber(ctx context.Context) (uint64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return uint64(len(c.headers) - 1), nil
+}
+
+func (c *fakeLogChain) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if number.Uint64() >= uint64(len(c.headers)) {
+		return nil, ethereum.NotFound
+	}
+	return c.headers[number.Uint64()], nil
+}
+
+func (c *fakeLogChain) FilterLogs(ctx context.Context, q ethereum.FilterQuery) ([]types.Log, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var logs []types.Log
+	for _, l := range c.logs {
+		if l.BlockNumber >= q.FromBlock.Uint64() && l.BlockNumber <= q.ToBlock.Uint64() && l.Address == q.Addresses[0] {
+			logs = append(logs, l)
+		}
+	}
+	return logs, nil
+}
+
+func newTestEVMIndexer(t *testing.T, chain *fakeLogChain) (*evmIndexer, *store.Store) {
+	t.Helper()
+	ledger, err := store.Open(filepath.Join(t.TempDir(), "ledger.db"))
+	if err != nil {
+		t.Fatalf("open ledger: %v", err)
+	}
+	t.Cleanup(func() { ledger.Close() })
+
+	confirmations := uint64(3)
+	x, err := newEVMIndexer(chain, ledger, config.EVMNetwork{
+		Name:            "fake-evm",
+		ContractAddress: testContract.Hex(),
+		Indexer:         config.EVMIndexer{Enabled: true, StartBlock: 2, Confirmations: &confirmations, BatchSize: 4},
+	})
+	if err != nil {
+		t.Fatalf("new indexer: %v", err)
+	}
+	return x, ledger
+}
+
+func indexedBlocks(t *testing.T, ledger *store.Store) map[uint64]*store.ChainEvent {
+	t.Helper()
+	events, _, err := ledger.ListChainEvents(store.ChainEventFilter{Network: "fake-evm"})
+	if err != nil {
+		t.Fatalf("list events: %v", err)
+	}
+	byBlock := make(map[uint64]*store.ChainEvent)
+	for _, e := range events {
+		byBlock[e.BlockNumber] = e
+	}
+	return byBlock
+}
+
+func TestEVMIndexer_BackfillsConfirmedBlocks(t *testing.T) {
+	chain := newFakeLogChain(t, 20)
+	chain.emit(1, "AccountInitialized", []common.Address{testPayer})
+	chain.emit(5, "PaymentCompleted", []common.Address{testPayer, testRecipient, testToken}, big.NewInt(1000), big.NewInt(3), []byte{0xab}, uint64(1))
+	chain.emit(12, "DepositMade", []common.Address{testPayer, testToken}, big.NewInt(500), []byte{0xcd}, big.NewInt(1500), uint64(2))
+	chain.emit(19, "AccountInitialized", []common.Address{testRecipient})
+	x, ledger := newTestEVMIndexer(t, chain)
+
+	if err := x.sync(context.Background()); err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+
+	// Block 1 is before the start block and block 19 is not three blocks deep yet
+	events := indexedBlocks(t, ledger)
+	if len(events) != 2 || events[5] == nil || events[12] == nil {
+		t.Fatalf("expected the events of blocks 5 and 12, got %v", events)
+	}
+	payment := events[5]
+	if payment.Type != "PaymentCompleted" || payment.Account != testPayer.Hex() || payment.Recipient != testRecipient.Hex() ||
+		payment.Token != testToken.Hex() || payment.Amount != "1000" || payment.Fee != "3" || payment.Details["new_tail"] != "0xab" {
+		t.Errorf("unexpected payment event %+v", payment)
+	}
+	if payment.Timestamp.Unix() != 1_700_000_060 || payment.BlockHash != chain.headers[5].Hash().Hex() {
+		t.Errorf("expected the time and hash of block 5, got %+v", payment)
+	}
+	if deposit := events[12]; deposit.Balance != "1500" || deposit.Amount != "500" {
+		t.Errorf("unexpected deposit event %+v", deposit)
+	}
+	cp, err := ledger.GetIndexerCheckpoint("fake-evm")
+	if err != nil || cp.Next != 18 {
+		t.Fatalf("expected the checkpoint after block 17, got %+v (%v)", cp, err)
+	}
+
+	// Nothing new is confirmed, so another pass changes nothing
+	if err := x.sync(context.Background()); err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	if events := indexedBlocks(t, ledger); len(events) != 2 {
+		t.Errorf("expected the events to be stored once, got %d", len(events))
+	}
+}
+
+func TestEVMIndexer_RollsBackOrphanedEvents(t *testing.T) {
+	chain := newFakeLogChain(t, 20)
+	chain.emit(5, "PaymentCompleted", []common.Address{testPayer, testRecipient, testToken}, big.NewInt(1000), big.NewInt(3), []byte{0xab}, uint64(1))
+	chain.emit(12, "PaymentCompleted", []common.Address{testPayer, testRecipient, testToken}, big.NewInt(2000), big.NewInt(3), []byte{0xac}, uint64(2))
+	x, ledger := newTestEVMIndexer(t, chain)
+	if err := x.sync(context.Background()); err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+
+	// Blocks 11 onwards are replaced, and the payment moves from block 12 to block 14
+	chain.fork(11, 24, "fork")
+	chain.emit(14, "PaymentCompleted", []common.Address{testPayer, testRecipient, testToken}, big.NewInt(2000), big.NewInt(3), []byte{0xac}, uint64(2))
+	if err := x.sync(context.Background()); err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+
+	events := indexedBlocks(t, ledger)
+	if len(events) != 2 || events[5] == nil || events[12] != nil || events[14] == nil {
+		t.Fatalf("expected the events of blocks 5 and 14, got %v", events)
+	}
+	if events[14].BlockHash != chain.headers[14].Hash().Hex() {
+		t.Errorf("expected the event of the new fork, got %+v", events[14])
+	}
+	cp, err := ledger.GetIndexerCheckpoint("fake-evm")
+	if err != nil || cp.Next != 22 {
+		t.Fatalf("expected the checkpoint after block 21, got %+v (%v)", cp, err)
+	}
+	if last := cp.Blocks[len(cp.Blocks)-1]; last.Hash != chain.headers[21].Hash().Hex() {
+		t.Errorf("expected the checkpoint to remember the new fork, got %+v", last)
+	}
+}
+
+func TestNewEVMIndexer_ClosesItsConnection(t *testing.T) {
+	ledger, err := store.Open(filepath.Join(t.TempDir(), "ledger.db"))
+	if err != nil {
+		t.Fatalf("open ledger: %v", err)
+	}
+	defer ledger.Close()
+
+	// Dialing over HTTP does not connect yet, so no node is needed
+	x, err := NewEVMIndexer(config.EVMNetwork{
+		Name:            "fake-evm",
+		RPCURL:          "http://127.0.0.1:1",
+		ContractAddress: "0x00000000000000000000000000000000000000c0",
+	}, ledger)
+	if err != nil {
+		t.Fatalf("new indexer: %v", err)
+	}
+	if x.release == nil {
+		t.Fatal("expected the indexer to close its RPC client")
+	}
+	x.Close()
+}
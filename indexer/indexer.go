@@ -0,0 +1,59 @@
+// Package indexer keeps the TinyPay contract events of each network in the ledger, including
+// payments that were not submitted through this server
+package indexer
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// Indexer polls one network for new contract events in the background
+type Indexer struct {
+	network  string
+	interval time.Duration
+	sync     func(ctx context.Context) error // indexes everything that is ready, then returns
+	release  func()                          // closes the indexer's own node connection, if any
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// Network returns the name of the indexed network
+func (ix *Indexer) Network() string {
+	return ix.network
+}
+
+// Start runs a pass right away and then one per poll interval until Close is called
+func (ix *Indexer) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	ix.cancel = cancel
+	ix.wg.Add(1)
+	go func() {
+		defer ix.wg.Done()
+		ticker := time.NewTicker(ix.interval)
+		defer ticker.Stop()
+		for {
+			if err := ix.sync(ctx); err != nil && ctx.Err() == nil {
+				log.Printf("Event indexer of %s: %v", ix.network, err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Close stops the indexer, waits for the running pass to return and closes its node connection
+func (ix *Indexer) Close() {
+	if ix.cancel != nil {
+		ix.cancel()
+	}
+	ix.wg.Wait()
+	if ix.release != nil {
+		ix.release()
+	}
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"tinypay-server/keystore"
+
+	"golang.org/x/term"
+)
+
+const keysUsage = `Usage: tinypay-server keys <command> [flags]
+
+Commands:
+  generate -chain <evm|aptos|solana> -out <file>   Create a new key in an encrypted keystore
+  import   -chain <evm|aptos|solana> -out <file>   Encrypt an existing key, read from -key-file or stdin
+  inspect  [-verify] <file>...                     Show the chain and address of keystores
+
+The passphrase comes from -password-file, KEYSTORE_PASSWORD or KEYSTORE_PASSWORD_FILE, and is
+prompted for on a terminal otherwise. Point a key field at the keystore with keystore:<file>.
+`
+
+// runKeys runs the keys subcommand and returns the process exit code
+func runKeys(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, keysUsage)
+		return 2
+	}
+
+	var err error
+	switch args[0] {
+	case "generate":
+		err = runKeysCreate(args[0], args[1:], true)
+	case "import":
+		err = runKeysCreate(args[0], args[1:], false)
+	case "inspect":
+		err = runKeysInspect(args[1:])
+	case "help", "-h", "--help":
+		fmt.Print(keysUsage)
+		return 0
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown keys command %q\n\n%s", args[0], keysUsage)
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// runKeysCreate writes a keystore holding a generated key, or an imported one
+func runKeysCreate(command string, args []string, generate bool) error {
+	flags := flag.NewFlagSet("keys "+command, flag.ContinueOnError)
+	chain := flags.String("chain", "", "chain family of the key: evm, aptos or solana")
+	out := flags.String("out", "", "keystore file to create")
+	passwordFile := flags.String("password-file", "", "file holding the keystore passphrase")
+	light := flags.Bool("light", false, "use cheap scrypt parameters (faster to decrypt, weaker against brute force)")
+	keyFile := new(string)
+	if !generate {
+		keyFile = flags.String("key-file", "", "file holding the private key to import; stdin when empty")
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *chain == "" || *out == "" {
+		return errors.New("-chain and -out are required")
+	}
+	if _, err := os.Stat(*out); err == nil {
+		return fmt.Errorf("%s already exists", *out)
+	}
+
+	var privateKey string
+	var err error
+	if generate {
+		privateKey, err = keystore.Generate(*chain)
+	} else {
+		privateKey, err = readPrivateKey(*keyFile)
+	}
+	if err != nil {
+		return err
+	}
+	// Fail on a bad key before asking for a passphrase
+	address, err := keystore.Address(*chain, privateKey)
+	if err != nil {
+		return err
+	}
+
+	passphrase, err := newPassphrase(*passwordFile)
+	if err != nil {
+		return err
+	}
+	params := keystore.Standard
+	if *light {
+		params = keystore.Light
+	}
+	data, err := keystore.Encrypt(*chain, privateKey, passphrase, params)
+	if err != nil {
+		return err
+	}
+	if err := writeKeystore(*out, data); err != nil {
+		return err
+	}
+
+	fmt.Printf("Chain:    %s\n", *chain)
+	fmt.Printf("Address:  %s\n", address)
+	fmt.Printf("Keystore: %s\n", *out)
+	fmt.Printf("Config:   %s%s\n", keystore.Prefix, *out)
+	return nil
+}
+
+// runKeysInspect prints the chain and address of keystore files, and with -verify checks that the
+// passphrase decrypts them
+func runKeysInspect(args []string) error {
+	flags := flag.NewFlagSet("keys inspect", flag.ContinueOnError)
+	verify := flags.Bool("verify", false, "check that the passphrase decrypts the keystores")
+	passwordFile := flags.String("password-file", "", "file holding the keystore passphrase")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		return errors.New("no keystore file given")
+	}
+
+	passphrase := ""
+	if *verify {
+		var err error
+		if passphrase, err = existingPassphrase(*passwordFile); err != nil {
+			return err
+		}
+	}
+
+	for _, path := range flags.Args() {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		info, err := keystore.Inspect(data)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		fmt.Printf("%s\n  Chain:   %s\n  Address: %s\n  KDF:     %s, %s\n", path, info.Chain, info.Address, info.KDF, info.Cipher)
+		if *verify {
+			if _, _, err := keystore.Decrypt(data, passphrase); err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+			fmt.Println("  Passphrase: ok")
+		}
+	}
+	return nil
+}
+
+// readPrivateKey reads the key to import from file, or from stdin when file is empty
+func readPrivateKey(file string) (string, error) {
+	if file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(string(data)), nil
+	}
+	if term.IsTerminal(int(os.Stdin.Fd())) {
+		return prompt("Private key: ")
+	}
+	data, err := io.ReadAll(bufio.NewReader(os.Stdin))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// newPassphrase returns the passphrase of a new keystore, prompting twice on a terminal
+func newPassphrase(file string) (string, error) {
+	passphrase, err := configuredPassphrase(file)
+	if !errors.Is(err, keystore.ErrNoPassphrase) || !term.IsTerminal(int(os.Stdin.Fd())) {
+		return passphrase, err
+	}
+	passphrase, err = prompt("Passphrase: ")
+	if err != nil {
+		return "", err
+	}
+	repeated, err := prompt("Repeat passphrase: ")
+	if err != nil {
+		return "", err
+	}
+	if passphrase != repeated {
+		return "", errors.New("passphrases do not match")
+	}
+	return passphrase, nil
+}
+
+// existingPassphrase returns the passphrase of existing keystores, prompting once on a terminal
+func existingPassphrase(file string) (string, error) {
+	passphrase, err := configuredPassphrase(file)
+	if !errors.Is(err, keystore.ErrNoPassphrase) || !term.IsTerminal(int(os.Stdin.Fd())) {
+		return passphrase, err
+	}
+	return prompt("Passphrase: ")
+}
+
+// configuredPassphrase reads the passphrase from file, or else from the environment
+func configuredPassphrase(file string) (string, error) {
+	if file != "" {
+		return keystore.ReadPassphraseFile(file)
+	}
+	return keystore.Passphrase("")
+}
+
+// prompt reads a line from the terminal without echoing it
+func prompt(label string) (string, error) {
+	fmt.Fprint(os.Stderr, label)
+	value, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(value)), nil
+}
+
+// writeKeystore creates path readable by the owner only; an existing file is never overwritten
+func writeKeystore(path string, data []byte) error {
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return err
+		}
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,137 @@
+package keystore
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aptos-labs/aptos-go-sdk"
+	aptoscrypto "github.com/aptos-labs/aptos-go-sdk/crypto"
+	ethkeystore "github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/gagliardetto/solana-go"
+	"github.com/google/uuid"
+)
+
+// Generate creates a new private key for chain, spelled the way the chain's config fields take it
+func Generate(chain string) (string, error) {
+	switch chain {
+	case ChainEVM:
+		key, err := crypto.GenerateKey()
+		if err != nil {
+			return "", err
+		}
+		return hexutil.Encode(crypto.FromECDSA(key)), nil
+	case ChainAptos:
+		key, err := aptoscrypto.GenerateEd25519PrivateKey()
+		if err != nil {
+			return "", err
+		}
+		return key.ToHex(), nil
+	case ChainSolana:
+		key, err := solana.NewRandomPrivateKey()
+		if err != nil {
+			return "", err
+		}
+		return key.String(), nil
+	default:
+		return "", unknownChain(chain)
+	}
+}
+
+// Address returns the account address of privateKey on chain
+func Address(chain, privateKey string) (string, error) {
+	if chain == ChainEVM {
+		key, err := parseEVMKey(privateKey)
+		if err != nil {
+			return "", err
+		}
+		return key.Address.Hex(), nil
+	}
+	_, address, err := parseKey(chain, privateKey)
+	return address, err
+}
+
+// parseEVMKey parses a hex secp256k1 key, with or without 0x
+func parseEVMKey(privateKey string) (*ethkeystore.Key, error) {
+	key, err := crypto.HexToECDSA(strings.TrimPrefix(strings.TrimSpace(privateKey), "0x"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid EVM private key: %w", err)
+	}
+	id, err := uuid.NewRandom()
+	if err != nil {
+		return nil, err
+	}
+	return &ethkeystore.Key{Id: id, Address: crypto.PubkeyToAddress(key.PublicKey), PrivateKey: key}, nil
+}
+
+func formatEVMKey(key *ethkeystore.Key) string {
+	return hexutil.Encode(crypto.FromECDSA(key.PrivateKey))
+}
+
+// parseKey parses an Aptos or Solana key into the bytes a keystore stores: the 32-byte Ed25519
+// seed for Aptos, the 64-byte keypair for Solana. Solana keys may be base58 or a solana-keygen
+// JSON array.
+func parseKey(chain, privateKey string) ([]byte, string, error) {
+	privateKey = strings.TrimSpace(privateKey)
+	switch chain {
+	case ChainAptos:
+		// Plain hex and AIP-80 keys are both accepted, without the SDK's AIP-80 warning
+		raw, err := aptoscrypto.ParsePrivateKey(privateKey, aptoscrypto.PrivateKeyVariantEd25519, false)
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid Aptos private key: %w", err)
+		}
+		_, address, err := formatKey(chain, raw)
+		return raw, address, err
+	case ChainSolana:
+		var key solana.PrivateKey
+		var err error
+		if strings.HasPrefix(privateKey, "[") {
+			key, err = solana.PrivateKeyFromSolanaKeygenFileBytes([]byte(privateKey))
+		} else {
+			key, err = solana.PrivateKeyFromBase58(privateKey)
+		}
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid Solana private key: %w", err)
+		}
+		_, address, err := formatKey(chain, key)
+		return key, address, err
+	default:
+		return nil, "", unknownChain(chain)
+	}
+}
+
+// formatKey spells raw keystore bytes the way the chain's config fields take them
+func formatKey(chain string, raw []byte) (string, string, error) {
+	switch chain {
+	case ChainAptos:
+		key := aptoscrypto.Ed25519PrivateKey{}
+		if err := key.FromBytes(raw); err != nil {
+			return "", "", fmt.Errorf("invalid Aptos private key: %w", err)
+		}
+		account, err := aptos.NewAccountFromSigner(&key)
+		if err != nil {
+			return "", "", err
+		}
+		return key.ToHex(), account.Address.String(), nil
+	case ChainSolana:
+		key := solana.PrivateKey(raw)
+		if len(raw) != ed25519.PrivateKeySize {
+			return "", "", fmt.Errorf("invalid Solana private key size %d", len(raw))
+		}
+		// The public half must belong to the seed, or the key would sign for another account
+		if !bytes.Equal(ed25519.NewKeyFromSeed(raw[:ed25519.SeedSize]), raw) {
+			return "", "", errors.New("invalid Solana private key: public key does not match the seed")
+		}
+		return key.String(), key.PublicKey().String(), nil
+	default:
+		return "", "", unknownChain(chain)
+	}
+}
+
+func unknownChain(chain string) error {
+	return fmt.Errorf("unknown chain %q, expected %s, %s or %s", chain, ChainEVM, ChainAptos, ChainSolana)
+}
@@ -0,0 +1,310 @@
+// Package keystore encrypts private keys at rest. EVM keys use the Web3 Secret Storage (v3)
+// format understood by geth and most wallets; Aptos and Solana keys use a JSON file of the same
+// shape with scrypt and AES-256-GCM. Configured key fields refer to a file as "keystore:<path>".
+package keystore
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	ethkeystore "github.com/ethereum/go-ethereum/accounts/keystore"
+	"golang.org/x/crypto/scrypt"
+)
+
+// Prefix marks a configured key field that refers to a keystore file instead of holding the key
+const Prefix = "keystore:"
+
+// Chain families a keystore can hold a key of
+const (
+	ChainEVM    = "evm"
+	ChainAptos  = "aptos"
+	ChainSolana = "solana"
+)
+
+const (
+	// version is the version of the Aptos/Solana file format
+	version = 1
+	// cipherName and kdfName identify the algorithms in the Aptos/Solana file format
+	cipherName = "aes-256-gcm"
+	kdfName    = "scrypt"
+	// scryptR and scryptKeyLen are fixed for every file this package writes
+	scryptR      = 8
+	scryptKeyLen = 32
+	// saltLen is the length of the random scrypt salt
+	saltLen = 32
+)
+
+var (
+	// ErrWrongPassphrase is returned when a keystore cannot be decrypted with the passphrase
+	ErrWrongPassphrase = errors.New("could not decrypt keystore with the given passphrase")
+	// ErrNoPassphrase is returned when neither KEYSTORE_PASSWORD nor a passphrase file is set
+	ErrNoPassphrase = errors.New("no keystore passphrase: set KEYSTORE_PASSWORD or a passphrase file")
+)
+
+// Params are the scrypt cost parameters of a new keystore
+type Params struct {
+	N int
+	P int
+}
+
+var (
+	// Standard is the cost used by geth for new accounts; it takes about a second and 256MB to decrypt
+	Standard = Params{N: ethkeystore.StandardScryptN, P: ethkeystore.StandardScryptP}
+	// Light is a cheap cost for tests and low-memory machines
+	Light = Params{N: ethkeystore.LightScryptN, P: ethkeystore.LightScryptP}
+)
+
+// Info describes a keystore file without decrypting it
+type Info struct {
+	Chain   string
+	Address string
+	KDF     string
+	Cipher  string
+}
+
+// file is the Aptos/Solana keystore format
+type file struct {
+	Version int        `json:"version"`
+	Chain   string     `json:"chain"`
+	Address string     `json:"address"`
+	Crypto  cryptoJSON `json:"crypto"`
+}
+
+type cryptoJSON struct {
+	Cipher     string    `json:"cipher"`
+	Ciphertext string    `json:"ciphertext"`
+	Nonce      string    `json:"nonce"`
+	KDF        string    `json:"kdf"`
+	KDFParams  kdfParams `json:"kdfparams"`
+}
+
+type kdfParams struct {
+	N     int    `json:"n"`
+	R     int    `json:"r"`
+	P     int    `json:"p"`
+	DKLen int    `json:"dklen"`
+	Salt  string `json:"salt"`
+}
+
+// header holds the fields that tell the two formats apart
+type header struct {
+	Version int    `json:"version"`
+	Chain   string `json:"chain"`
+	Address string `json:"address"`
+	Crypto  struct {
+		Cipher string `json:"cipher"`
+		KDF    string `json:"kdf"`
+	} `json:"crypto"`
+}
+
+// Encrypt encrypts privateKey, spelled the way the chain's config fields take it, into a
+// keystore file for chain
+func Encrypt(chain, privateKey, passphrase string, params Params) ([]byte, error) {
+	if chain == ChainEVM {
+		key, err := parseEVMKey(privateKey)
+		if err != nil {
+			return nil, err
+		}
+		return ethkeystore.EncryptKey(key, passphrase, params.N, params.P)
+	}
+
+	raw, address, err := parseKey(chain, privateKey)
+	if err != nil {
+		return nil, err
+	}
+	salt := make([]byte, saltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	derived, err := scrypt.Key([]byte(passphrase), salt, params.N, scryptR, params.P, scryptKeyLen)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newGCM(derived)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	f := file{
+		Version: version,
+		Chain:   chain,
+		Address: address,
+		Crypto: cryptoJSON{
+			Cipher: cipherName,
+			Nonce:  hex.EncodeToString(nonce),
+			KDF:    kdfName,
+			KDFParams: kdfParams{
+				N:     params.N,
+				R:     scryptR,
+				P:     params.P,
+				DKLen: scryptKeyLen,
+				Salt:  hex.EncodeToString(salt),
+			},
+		},
+	}
+	f.Crypto.Ciphertext = hex.EncodeToString(aead.Seal(nil, nonce, raw, f.additionalData()))
+	return json.MarshalIndent(f, "", "  ")
+}
+
+// Decrypt decrypts a keystore file and returns its chain and the private key spelled the way the
+// chain's config fields take it
+func Decrypt(data []byte, passphrase string) (chain, privateKey string, err error) {
+	info, err := Inspect(data)
+	if err != nil {
+		return "", "", err
+	}
+
+	if info.Chain == ChainEVM {
+		key, err := ethkeystore.DecryptKey(data, passphrase)
+		if errors.Is(err, ethkeystore.ErrDecrypt) {
+			return "", "", ErrWrongPassphrase
+		}
+		if err != nil {
+			return "", "", err
+		}
+		return ChainEVM, formatEVMKey(key), nil
+	}
+
+	var f file
+	if err := json.Unmarshal(data, &f); err != nil {
+		return "", "", fmt.Errorf("invalid keystore: %w", err)
+	}
+	if f.Version != version || f.Crypto.Cipher != cipherName || f.Crypto.KDF != kdfName {
+		return "", "", fmt.Errorf("unsupported keystore: version %d, cipher %q, kdf %q", f.Version, f.Crypto.Cipher, f.Crypto.KDF)
+	}
+	params := f.Crypto.KDFParams
+	salt, err := hex.DecodeString(params.Salt)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid keystore salt: %w", err)
+	}
+	nonce, err := hex.DecodeString(f.Crypto.Nonce)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid keystore nonce: %w", err)
+	}
+	ciphertext, err := hex.DecodeString(f.Crypto.Ciphertext)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid keystore ciphertext: %w", err)
+	}
+
+	derived, err := scrypt.Key([]byte(passphrase), salt, params.N, params.R, params.P, params.DKLen)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid keystore kdf parameters: %w", err)
+	}
+	aead, err := newGCM(derived)
+	if err != nil {
+		return "", "", err
+	}
+	if len(nonce) != aead.NonceSize() {
+		return "", "", fmt.Errorf("invalid keystore nonce length %d", len(nonce))
+	}
+	// The chain and address are authenticated, so a file cannot be relabelled
+	raw, err := aead.Open(nil, nonce, ciphertext, f.additionalData())
+	if err != nil {
+		return "", "", ErrWrongPassphrase
+	}
+
+	privateKey, address, err := formatKey(f.Chain, raw)
+	if err != nil {
+		return "", "", err
+	}
+	if address != f.Address {
+		return "", "", fmt.Errorf("keystore key does not match its address %s", f.Address)
+	}
+	return f.Chain, privateKey, nil
+}
+
+// Inspect reads the chain and address of a keystore file without decrypting it
+func Inspect(data []byte) (*Info, error) {
+	var h header
+	if err := json.Unmarshal(data, &h); err != nil {
+		return nil, fmt.Errorf("invalid keystore: %w", err)
+	}
+
+	switch {
+	case h.Chain == "" && h.Version == 3:
+		// Web3 Secret Storage files carry no chain; the address is hex without 0x
+		address := h.Address
+		if address != "" && !strings.HasPrefix(address, "0x") {
+			address = "0x" + address
+		}
+		return &Info{Chain: ChainEVM, Address: address, KDF: h.Crypto.KDF, Cipher: h.Crypto.Cipher}, nil
+	case h.Chain == ChainAptos || h.Chain == ChainSolana:
+		return &Info{Chain: h.Chain, Address: h.Address, KDF: h.Crypto.KDF, Cipher: h.Crypto.Cipher}, nil
+	default:
+		return nil, fmt.Errorf("unsupported keystore: version %d, chain %q", h.Version, h.Chain)
+	}
+}
+
+// IsReference reports whether a configured key field refers to a keystore file
+func IsReference(value string) bool {
+	return strings.HasPrefix(strings.TrimSpace(value), Prefix)
+}
+
+// Resolve returns the private key a configured key field of chain stands for: the decrypted key of
+// the referenced keystore file, or the value itself when it is not a reference
+func Resolve(chain, value, passphrase string) (string, error) {
+	path, ok := strings.CutPrefix(strings.TrimSpace(value), Prefix)
+	if !ok {
+		return value, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read keystore: %w", err)
+	}
+	keyChain, privateKey, err := Decrypt(data, passphrase)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", path, err)
+	}
+	if keyChain != chain {
+		return "", fmt.Errorf("%s holds a %s key, expected %s", path, keyChain, chain)
+	}
+	return privateKey, nil
+}
+
+// Passphrase returns the keystore passphrase: KEYSTORE_PASSWORD when set, otherwise the content of
+// file, or of KEYSTORE_PASSWORD_FILE when file is empty
+func Passphrase(file string) (string, error) {
+	if passphrase := os.Getenv("KEYSTORE_PASSWORD"); passphrase != "" {
+		return passphrase, nil
+	}
+	if file == "" {
+		file = os.Getenv("KEYSTORE_PASSWORD_FILE")
+	}
+	if file == "" {
+		return "", ErrNoPassphrase
+	}
+	return ReadPassphraseFile(file)
+}
+
+// ReadPassphraseFile reads a passphrase from file, without its trailing line break
+func ReadPassphraseFile(file string) (string, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", fmt.Errorf("failed to read passphrase file: %w", err)
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// additionalData binds the chain and address to the ciphertext
+func (f *file) additionalData() []byte {
+	return []byte(fmt.Sprintf("tinypay-keystore/%d/%s/%s", f.Version, f.Chain, f.Address))
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
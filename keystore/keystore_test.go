@@ -0,0 +1,136 @@
+package keystore
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	ethkeystore "github.com/ethereum/go-ethereum/accounts/keystore"
+)
+
+func TestEncryptDecrypt_RoundTrip(t *testing.T) {
+	for _, chain := range []string{ChainEVM, ChainAptos, ChainSolana} {
+		t.Run(chain, func(t *testing.T) {
+			privateKey, err := Generate(chain)
+			if err != nil {
+				t.Fatalf("generate: %v", err)
+			}
+			address, err := Address(chain, privateKey)
+			if err != nil {
+				t.Fatalf("address: %v", err)
+			}
+
+			data, err := Encrypt(chain, privateKey, "secret", Light)
+			if err != nil {
+				t.Fatalf("encrypt: %v", err)
+			}
+			if strings.Contains(string(data), strings.TrimPrefix(privateKey, "0x")) {
+				t.Fatal("keystore contains the plaintext key")
+			}
+
+			info, err := Inspect(data)
+			if err != nil {
+				t.Fatalf("inspect: %v", err)
+			}
+			if info.Chain != chain || !strings.EqualFold(info.Address, address) {
+				t.Errorf("expected %s %s, got %+v", chain, address, info)
+			}
+
+			gotChain, gotKey, err := Decrypt(data, "secret")
+			if err != nil {
+				t.Fatalf("decrypt: %v", err)
+			}
+			if gotChain != chain || gotKey != privateKey {
+				t.Errorf("expected %s key %s back, got %s %s", chain, privateKey, gotChain, gotKey)
+			}
+
+			if _, _, err := Decrypt(data, "wrong"); !errors.Is(err, ErrWrongPassphrase) {
+				t.Errorf("expected ErrWrongPassphrase, got %v", err)
+			}
+		})
+	}
+}
+
+func TestEncrypt_EVMIsWeb3SecretStorage(t *testing.T) {
+	privateKey, _ := Generate(ChainEVM)
+	data, err := Encrypt(ChainEVM, privateKey, "secret", Light)
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+
+	key, err := ethkeystore.DecryptKey(data, "secret")
+	if err != nil {
+		t.Fatalf("geth could not decrypt the keystore: %v", err)
+	}
+	if formatEVMKey(key) != privateKey {
+		t.Errorf("expected geth to recover %s, got %s", privateKey, formatEVMKey(key))
+	}
+}
+
+func TestDecrypt_RejectsRelabelledKeystore(t *testing.T) {
+	privateKey, _ := Generate(ChainSolana)
+	data, err := Encrypt(ChainSolana, privateKey, "secret", Light)
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+
+	var f file
+	if err := json.Unmarshal(data, &f); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	other, _ := Generate(ChainSolana)
+	f.Address, _ = Address(ChainSolana, other)
+	tampered, _ := json.Marshal(f)
+	if _, _, err := Decrypt(tampered, "secret"); err == nil {
+		t.Error("expected a keystore with a swapped address to be rejected")
+	}
+}
+
+func TestResolve(t *testing.T) {
+	privateKey, _ := Generate(ChainAptos)
+	data, err := Encrypt(ChainAptos, privateKey, "secret", Light)
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "aptos.json")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	got, err := Resolve(ChainAptos, Prefix+path, "secret")
+	if err != nil || got != privateKey {
+		t.Errorf("expected the keystore to resolve to %s, got %s, %v", privateKey, got, err)
+	}
+	if got, err := Resolve(ChainAptos, "0x1234", "secret"); err != nil || got != "0x1234" {
+		t.Errorf("expected plain keys to pass through, got %s, %v", got, err)
+	}
+	if _, err := Resolve(ChainAptos, Prefix+path, "wrong"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("expected ErrWrongPassphrase, got %v", err)
+	}
+	if _, err := Resolve(ChainEVM, Prefix+path, "secret"); err == nil {
+		t.Error("expected an Aptos keystore to be rejected for an EVM key")
+	}
+}
+
+func TestPassphrase(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "passphrase")
+	if err := os.WriteFile(file, []byte("from-file\n"), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	t.Setenv("KEYSTORE_PASSWORD", "")
+	t.Setenv("KEYSTORE_PASSWORD_FILE", "")
+	if _, err := Passphrase(""); !errors.Is(err, ErrNoPassphrase) {
+		t.Errorf("expected ErrNoPassphrase, got %v", err)
+	}
+	if got, _ := Passphrase(file); got != "from-file" {
+		t.Errorf("expected the file's passphrase without its line break, got %q", got)
+	}
+	t.Setenv("KEYSTORE_PASSWORD", "from-env")
+	if got, _ := Passphrase(file); got != "from-env" {
+		t.Errorf("expected KEYSTORE_PASSWORD to win, got %q", got)
+	}
+}
@@ -0,0 +1,322 @@
+package locks
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// filePollInterval is how often Acquire checks whether a held lease file was released
+const filePollInterval = 100 * time.Millisecond
+
+// FileManager keeps one lease file per held key in a directory, so several processes that share
+// the directory (for example a volume mounted into every container) exclude each other.
+// Lease expiry compares wall clocks, so their clocks have to be kept in sync.
+type FileManager struct {
+	dir       string
+	opts      Options
+	owner     string
+	now       func() time.Time
+	stop      chan struct{}
+	closeOnce sync.Once
+}
+
+// leaseRecord is the content of a lease file
+type leaseRecord struct {
+	Key       string    `json:"key"`
+	Owner     string    `json:"owner"`
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// fileLease is a lease held through a FileManager
+type fileLease struct {
+	*renewal
+	manager *FileManager
+	path    string
+	token   string
+}
+
+// NewFileManager creates a lock manager that keeps lease files in dir, creating it when needed.
+// Lease files left behind by crashed holders are removed once they have expired.
+func NewFileManager(dir string, opts Options) (*FileManager, error) {
+	if opts.LeaseTTL <= 0 {
+		return nil, errors.New("lease TTL must be positive")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create lock directory: %w", err)
+	}
+
+	hostname, _ := os.Hostname()
+	m := &FileManager{
+		dir:   dir,
+		opts:  opts,
+		owner: fmt.Sprintf("%s:%d", hostname, os.Getpid()),
+		now:   time.Now,
+		stop:  make(chan struct{}),
+	}
+	if err := m.sweep(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Acquire leases key, polling the lease file until it is released or expires
+func (m *FileManager) Acquire(ctx context.Context, key string) (Lease, error) {
+	deadline := m.now().Add(m.opts.AcquireTimeout)
+	path := m.leasePath(key)
+
+	for {
+		lease, err := m.tryAcquire(key, path)
+		if err != nil {
+			return nil, err
+		}
+		if lease != nil {
+			lease.renewal = startRenewal(m.opts.LeaseTTL, m.stop, lease.renew)
+			return lease, nil
+		}
+		if !m.now().Before(deadline) {
+			return nil, ErrBusy
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(filePollInterval):
+		}
+	}
+}
+
+// Close stops renewing held leases; lease files are removed when they are released
+func (m *FileManager) Close() error {
+	m.closeOnce.Do(func() { close(m.stop) })
+	return nil
+}
+
+// tryAcquire creates the lease file, taking over an expired one. It returns nil without an
+// error while the key is held.
+func (m *FileManager) tryAcquire(key, path string) (*fileLease, error) {
+	token, err := newToken()
+	if err != nil {
+		return nil, err
+	}
+	record := leaseRecord{
+		Key:       key,
+		Owner:     m.owner,
+		Token:     token,
+		ExpiresAt: m.now().Add(m.opts.LeaseTTL),
+	}
+
+	created, err := m.publish(path, record)
+	if err != nil {
+		return nil, err
+	}
+	if created {
+		return &fileLease{manager: m, path: path, token: token}, nil
+	}
+
+	current, expired, err := m.inspect(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil // released in the meantime; picked up on the next poll
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !expired {
+		return nil, nil
+	}
+
+	if err := m.remove(path, current.Token); err != nil {
+		return nil, err
+	}
+	created, err = m.publish(path, record)
+	if err != nil || !created {
+		return nil, err
+	}
+	return &fileLease{manager: m, path: path, token: token}, nil
+}
+
+// publish atomically creates the lease file with its full content. Linking a complete temporary
+// file into place fails when the lease file exists, so concurrent holders cannot both succeed
+// and readers never see a partially written lease.
+func (m *FileManager) publish(path string, record leaseRecord) (bool, error) {
+	tmp, err := m.writeTemp(record)
+	if err != nil {
+		return false, err
+	}
+	defer os.Remove(tmp)
+
+	if err := os.Link(tmp, path); err != nil {
+		if errors.Is(err, fs.ErrExist) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to publish lease file: %w", err)
+	}
+	return true, nil
+}
+
+// writeTemp writes record to a new temporary file in the lock directory and returns its path
+func (m *FileManager) writeTemp(record leaseRecord) (string, error) {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return "", err
+	}
+
+	tmp, err := os.CreateTemp(m.dir, ".lease-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create lease file: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("failed to write lease file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("failed to write lease file: %w", err)
+	}
+	return tmp.Name(), nil
+}
+
+// inspect reads a lease file and reports whether it expired. Unreadable files count as expired
+// once they are older than the lease TTL.
+func (m *FileManager) inspect(path string) (leaseRecord, bool, error) {
+	var record leaseRecord
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return record, false, err
+	}
+	if err := json.Unmarshal(data, &record); err != nil {
+		info, statErr := os.Stat(path)
+		if statErr != nil {
+			return record, false, statErr
+		}
+		return record, m.now().Sub(info.ModTime()) >= m.opts.LeaseTTL, nil
+	}
+	return record, !m.now().Before(record.ExpiresAt), nil
+}
+
+// remove deletes the lease file when it still carries token. The file is first moved aside, so a
+// lease published by someone else between the check and the removal is put back instead.
+func (m *FileManager) remove(path, token string) error {
+	suffix, err := newToken()
+	if err != nil {
+		return err
+	}
+	aside := path + "." + suffix + ".released"
+	if err := os.Rename(path, aside); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("failed to remove lease file: %w", err)
+	}
+	defer os.Remove(aside)
+
+	data, err := os.ReadFile(aside)
+	if err != nil {
+		return fmt.Errorf("failed to remove lease file: %w", err)
+	}
+	var record leaseRecord
+	if json.Unmarshal(data, &record) == nil && record.Token != token {
+		// Put the other holder's lease back unless yet another one was published meanwhile
+		if err := os.Link(aside, path); err != nil && !errors.Is(err, fs.ErrExist) {
+			return fmt.Errorf("failed to restore lease file: %w", err)
+		}
+	}
+	return nil
+}
+
+// sweep removes expired lease files and leftover temporary files
+func (m *FileManager) sweep() error {
+	entries, err := os.ReadDir(m.dir)
+	if err != nil {
+		return fmt.Errorf("failed to read lock directory: %w", err)
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		path := filepath.Join(m.dir, name)
+		switch {
+		case strings.HasSuffix(name, ".lease"):
+			record, expired, err := m.inspect(path)
+			if err == nil && expired {
+				if err := m.remove(path, record.Token); err != nil {
+					return err
+				}
+			}
+		case strings.HasPrefix(name, ".lease-") || strings.HasSuffix(name, ".released"):
+			if info, err := entry.Info(); err == nil && m.now().Sub(info.ModTime()) >= m.opts.LeaseTTL {
+				os.Remove(path)
+			}
+		}
+	}
+	return nil
+}
+
+// leasePath returns the lease file of a key; keys are hashed since addresses are case-sensitive
+// on some chains while file systems may not be
+func (m *FileManager) leasePath(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(m.dir, hex.EncodeToString(sum[:16])+".lease")
+}
+
+// renew moves the expiry of the lease file forward while it still carries the lease's token.
+// Renewals run a third of the TTL apart, so an unexpired lease is not taken over between the
+// check and the rename that replaces the file.
+func (l *fileLease) renew() bool {
+	m := l.manager
+	record, expired, err := m.inspect(l.path)
+	if err != nil {
+		// A failed read is retried on the next tick; a missing file means the lease is gone
+		return !errors.Is(err, fs.ErrNotExist)
+	}
+	if expired || record.Token != l.token {
+		return false
+	}
+
+	record.ExpiresAt = m.now().Add(m.opts.LeaseTTL)
+	tmp, err := m.writeTemp(record)
+	if err != nil {
+		return true
+	}
+	if err := os.Rename(tmp, l.path); err != nil {
+		os.Remove(tmp)
+	}
+	return true
+}
+
+// Release removes the lease file unless the lease was taken over after it expired
+func (l *fileLease) Release() error {
+	l.stopRenewal()
+	data, err := os.ReadFile(l.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return ErrLeaseLost
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read lease file: %w", err)
+	}
+	var record leaseRecord
+	if err := json.Unmarshal(data, &record); err != nil || record.Token != l.token {
+		return ErrLeaseLost
+	}
+	return l.manager.remove(l.path, l.token)
+}
+
+// newToken returns a random lease token
+func newToken() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate lease token: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,77 @@
+// Package locks provides exclusive, expiring leases on string keys. The API server holds one
+// lease per payer while a payment is submitted, so a payer's OTP is never spent twice at once.
+package locks
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrBusy is returned when a lock is still held by someone else when the acquisition timeout passes
+	ErrBusy = errors.New("lock is held by another holder")
+	// ErrLeaseLost is returned by Release when the lease expired and another holder took the lock over
+	ErrLeaseLost = errors.New("lease expired and was taken over")
+)
+
+// Options configures the leases handed out by a manager
+type Options struct {
+	// LeaseTTL is how long a lease lasts without renewal. Held leases are renewed every third of
+	// it, so only a lease whose holder crashed or stalled is taken over by the next caller.
+	LeaseTTL time.Duration
+	// AcquireTimeout is how long Acquire waits for a held lock before returning ErrBusy
+	AcquireTimeout time.Duration
+}
+
+// Manager hands out exclusive leases on keys
+type Manager interface {
+	// Acquire waits until the key is free and leases it. It returns ErrBusy when the key is still
+	// held after the acquisition timeout, or the context error when ctx ends first.
+	Acquire(ctx context.Context, key string) (Lease, error)
+	// Close stops background work, including renewals; leases that are still held stay valid
+	// until they expire
+	Close() error
+}
+
+// Lease is an acquired lock on a key
+type Lease interface {
+	// Release frees the key. It returns ErrLeaseLost when the lease had expired and was taken over.
+	Release() error
+}
+
+// renewal extends a held lease every third of its TTL until it is released, its manager is
+// closed or the lease turns out to be lost
+type renewal struct {
+	stop chan struct{}
+	once sync.Once
+}
+
+// startRenewal calls renew periodically in the background. renew reports whether the lease is
+// still held; renewal ends once it is not.
+func startRenewal(ttl time.Duration, closed <-chan struct{}, renew func() bool) *renewal {
+	r := &renewal{stop: make(chan struct{})}
+	go func() {
+		ticker := time.NewTicker(ttl / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-r.stop:
+				return
+			case <-closed:
+				return
+			case <-ticker.C:
+				if !renew() {
+					return
+				}
+			}
+		}
+	}()
+	return r
+}
+
+// stopRenewal ends the renewal; the lease then expires after its TTL unless it is released
+func (r *renewal) stopRenewal() {
+	r.once.Do(func() { close(r.stop) })
+}
@@ -0,0 +1,205 @@
+package locks
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newManagers returns one manager of each kind with the given options
+func newManagers(t *testing.T, opts Options) map[string]Manager {
+	t.Helper()
+	memory := NewMemoryManager(opts)
+	t.Cleanup(func() { memory.Close() })
+
+	file, err := NewFileManager(t.TempDir(), opts)
+	if err != nil {
+		t.Fatalf("new file manager: %v", err)
+	}
+	t.Cleanup(func() { file.Close() })
+	return map[string]Manager{"memory": memory, "file": file}
+}
+
+func TestManager_ExcludesConcurrentHolders(t *testing.T) {
+	for name, m := range newManagers(t, Options{LeaseTTL: time.Minute, AcquireTimeout: 5 * time.Second}) {
+		t.Run(name, func(t *testing.T) {
+			var inside, maxInside int32
+			var wg sync.WaitGroup
+			for i := 0; i < 8; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					lease, err := m.Acquire(context.Background(), "fake-evm/0xabc")
+					if err != nil {
+						t.Errorf("acquire: %v", err)
+						return
+					}
+					n := atomic.AddInt32(&inside, 1)
+					for {
+						max := atomic.LoadInt32(&maxInside)
+						if n <= max || atomic.CompareAndSwapInt32(&maxInside, max, n) {
+							break
+						}
+					}
+					time.Sleep(5 * time.Millisecond)
+					atomic.AddInt32(&inside, -1)
+					if err := lease.Release(); err != nil {
+						t.Errorf("release: %v", err)
+					}
+				}()
+			}
+			wg.Wait()
+			if maxInside != 1 {
+				t.Errorf("expected one holder at a time, saw %d", maxInside)
+			}
+		})
+	}
+}
+
+func TestManager_TimesOutAsBusy(t *testing.T) {
+	for name, m := range newManagers(t, Options{LeaseTTL: time.Minute, AcquireTimeout: 50 * time.Millisecond}) {
+		t.Run(name, func(t *testing.T) {
+			lease, err := m.Acquire(context.Background(), "payer")
+			if err != nil {
+				t.Fatalf("acquire: %v", err)
+			}
+			defer lease.Release()
+
+			if _, err := m.Acquire(context.Background(), "payer"); !errors.Is(err, ErrBusy) {
+				t.Errorf("expected ErrBusy, got %v", err)
+			}
+			other, err := m.Acquire(context.Background(), "other-payer")
+			if err != nil {
+				t.Fatalf("other keys must not be blocked: %v", err)
+			}
+			other.Release()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			if _, err := m.Acquire(ctx, "payer"); !errors.Is(err, context.Canceled) {
+				t.Errorf("expected context.Canceled, got %v", err)
+			}
+		})
+	}
+}
+
+func TestManager_TakesOverExpiredLeases(t *testing.T) {
+	for name, m := range newManagers(t, Options{LeaseTTL: 30 * time.Millisecond, AcquireTimeout: time.Second}) {
+		t.Run(name, func(t *testing.T) {
+			abandoned, err := m.Acquire(context.Background(), "payer")
+			if err != nil {
+				t.Fatalf("acquire: %v", err)
+			}
+			abandon(abandoned)
+
+			lease, err := m.Acquire(context.Background(), "payer")
+			if err != nil {
+				t.Fatalf("expected the expired lease to be taken over, got %v", err)
+			}
+			if err := abandoned.Release(); !errors.Is(err, ErrLeaseLost) {
+				t.Errorf("expected ErrLeaseLost for the abandoned lease, got %v", err)
+			}
+			if err := lease.Release(); err != nil {
+				t.Errorf("release: %v", err)
+			}
+		})
+	}
+}
+
+func TestManager_RenewsHeldLeases(t *testing.T) {
+	for name, m := range newManagers(t, Options{LeaseTTL: 30 * time.Millisecond, AcquireTimeout: 50 * time.Millisecond}) {
+		t.Run(name, func(t *testing.T) {
+			lease, err := m.Acquire(context.Background(), "payer")
+			if err != nil {
+				t.Fatalf("acquire: %v", err)
+			}
+
+			// Held well past its TTL, the lease is still not taken over
+			time.Sleep(100 * time.Millisecond)
+			if _, err := m.Acquire(context.Background(), "payer"); !errors.Is(err, ErrBusy) {
+				t.Fatalf("expected the renewed lease to keep the key busy, got %v", err)
+			}
+			if err := lease.Release(); err != nil {
+				t.Errorf("expected the renewed lease to be released, got %v", err)
+			}
+		})
+	}
+}
+
+func TestMemoryManager_EvictsReleasedAndExpiredKeys(t *testing.T) {
+	m := NewMemoryManager(Options{LeaseTTL: time.Minute, AcquireTimeout: time.Second})
+	defer m.Close()
+	now := time.Now()
+	m.now = func() time.Time { return now }
+
+	lease, _ := m.Acquire(context.Background(), "released")
+	lease.Release()
+	abandoned, _ := m.Acquire(context.Background(), "abandoned")
+	abandon(abandoned)
+	if len(m.held) != 1 {
+		t.Fatalf("expected only the held key to be kept, got %d", len(m.held))
+	}
+
+	now = now.Add(time.Minute)
+	m.evict()
+	if len(m.held) != 0 {
+		t.Errorf("expected the expired key to be evicted, got %d", len(m.held))
+	}
+}
+
+func TestFileManager_SharedBetweenManagers(t *testing.T) {
+	dir := t.TempDir()
+	opts := Options{LeaseTTL: time.Minute, AcquireTimeout: 50 * time.Millisecond}
+	first, err := NewFileManager(dir, opts)
+	if err != nil {
+		t.Fatalf("new file manager: %v", err)
+	}
+	second, err := NewFileManager(dir, opts)
+	if err != nil {
+		t.Fatalf("new file manager: %v", err)
+	}
+
+	lease, err := first.Acquire(context.Background(), "payer")
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	if _, err := second.Acquire(context.Background(), "payer"); !errors.Is(err, ErrBusy) {
+		t.Fatalf("expected the second replica to be busy, got %v", err)
+	}
+	lease.Release()
+
+	lease, err = second.Acquire(context.Background(), "payer")
+	if err != nil {
+		t.Fatalf("expected the released key to be free, got %v", err)
+	}
+	lease.Release()
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 0 {
+		t.Errorf("expected no files after release, got %d", len(entries))
+	}
+}
+
+func TestFileManager_SweepsStaleFiles(t *testing.T) {
+	dir := t.TempDir()
+	stale := filepath.Join(dir, "0123.lease")
+	if err := os.WriteFile(stale, []byte(`{"key":"payer","token":"t","expires_at":"2020-01-01T00:00:00Z"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewFileManager(dir, Options{LeaseTTL: time.Minute}); err != nil {
+		t.Fatalf("new file manager: %v", err)
+	}
+	if _, err := os.Stat(stale); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected the expired lease file to be removed, got %v", err)
+	}
+}
+
+// abandon stops renewing a lease without releasing it, as a crashed holder would
+func abandon(lease Lease) {
+	lease.(interface{ stopRenewal() }).stopRenewal()
+}
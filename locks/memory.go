@@ -0,0 +1,155 @@
+package locks
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryManager keeps leases in process memory. Only keys that are currently leased are kept,
+// so the table stays bounded by the number of payments in flight.
+type MemoryManager struct {
+	opts      Options
+	mu        sync.Mutex
+	held      map[string]*memoryLease
+	stop      chan struct{}
+	closeOnce sync.Once
+	now       func() time.Time
+}
+
+// memoryLease is a lease held in a MemoryManager
+type memoryLease struct {
+	*renewal
+	manager *MemoryManager
+	key     string
+	expires time.Time
+	done    chan struct{} // closed once the lease is released or evicted
+	once    sync.Once
+}
+
+// NewMemoryManager creates an in-process lock manager and starts evicting expired leases
+func NewMemoryManager(opts Options) *MemoryManager {
+	m := &MemoryManager{
+		opts: opts,
+		held: make(map[string]*memoryLease),
+		stop: make(chan struct{}),
+		now:  time.Now,
+	}
+	go m.evictExpired()
+	return m
+}
+
+// Acquire leases key, waiting for the current holder to release it or for its lease to expire
+func (m *MemoryManager) Acquire(ctx context.Context, key string) (Lease, error) {
+	timeout := time.NewTimer(m.opts.AcquireTimeout)
+	defer timeout.Stop()
+
+	for {
+		m.mu.Lock()
+		current := m.held[key]
+		if current == nil || !m.now().Before(current.expires) {
+			if current != nil {
+				current.end()
+			}
+			lease := &memoryLease{
+				manager: m,
+				key:     key,
+				expires: m.now().Add(m.opts.LeaseTTL),
+				done:    make(chan struct{}),
+			}
+			m.held[key] = lease
+			m.mu.Unlock()
+			lease.renewal = startRenewal(m.opts.LeaseTTL, m.stop, lease.renew)
+			return lease, nil
+		}
+		done, remaining := current.done, current.expires.Sub(m.now())
+		m.mu.Unlock()
+
+		expiry := time.NewTimer(remaining)
+		select {
+		case <-done:
+		case <-expiry.C:
+		case <-timeout.C:
+			expiry.Stop()
+			return nil, ErrBusy
+		case <-ctx.Done():
+			expiry.Stop()
+			return nil, ctx.Err()
+		}
+		expiry.Stop()
+	}
+}
+
+// Close stops the eviction of expired leases and the renewal of held ones
+func (m *MemoryManager) Close() error {
+	m.closeOnce.Do(func() { close(m.stop) })
+	return nil
+}
+
+// Release frees the key unless the lease was taken over after it expired
+func (l *memoryLease) Release() error {
+	l.stopRenewal()
+	m := l.manager
+	m.mu.Lock()
+	owned := m.held[l.key] == l
+	if owned {
+		delete(m.held, l.key)
+	}
+	m.mu.Unlock()
+
+	l.end()
+	if !owned {
+		return ErrLeaseLost
+	}
+	return nil
+}
+
+// renew extends the lease while it is still the key's current one
+func (l *memoryLease) renew() bool {
+	m := l.manager
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.held[l.key] != l {
+		return false
+	}
+	l.expires = m.now().Add(m.opts.LeaseTTL)
+	return true
+}
+
+// end wakes everyone waiting for the lease
+func (l *memoryLease) end() {
+	l.once.Do(func() { close(l.done) })
+}
+
+// evictExpired drops leases whose holders never released them
+func (m *MemoryManager) evictExpired() {
+	interval := m.opts.LeaseTTL
+	if interval < time.Second {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.stop:
+			return
+		case <-ticker.C:
+			m.evict()
+		}
+	}
+}
+
+// evict drops every expired lease and wakes its waiters
+func (m *MemoryManager) evict() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	for key, lease := range m.held {
+		if !now.Before(lease.expires) {
+			delete(m.held, key)
+			lease.end()
+		}
+	}
+}
@@ -1,16 +1,42 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"tinypay-server/api"
 	"tinypay-server/client"
 	"tinypay-server/config"
+	"tinypay-server/indexer"
+	"tinypay-server/locks"
+	"tinypay-server/store"
 
 	"github.com/gin-gonic/gin"
 )
 
+// shutdownTimeout bounds how long a stopping server waits for requests in flight
+const shutdownTimeout = 30 * time.Second
+
 func main() {
+	// Key management runs instead of the server
+	if len(os.Args) > 1 && os.Args[1] == "keys" {
+		os.Exit(runKeys(os.Args[2:]))
+	}
+
+	// Set by failures after startup; deferred first so every other deferred Close still runs
+	exitCode := 0
+	defer func() {
+		if exitCode != 0 {
+			os.Exit(exitCode)
+		}
+	}()
+
 	// Load configuration
 	cfg := config.LoadConfig()
 	log.Printf("Starting TinyPay server on port %s", cfg.Port)
@@ -23,8 +49,9 @@ func main() {
 		log.Fatalf("Failed to initialize Aptos client: %v", err)
 	}
 
-	// Initialize EVM clients for different networks
-	evmClients := make(map[string]*client.EVMClient)
+	// Register every chain backend under its network name
+	backends := client.NewBackendRegistry()
+	backends.Register(aptosClient)
 
 	// Initialize EVM clients from the new EVMNetworks configuration
 	for _, evmNetwork := range cfg.EVMNetworks {
@@ -32,21 +59,18 @@ func main() {
 		if err != nil {
 			log.Printf("Warning: Failed to initialize %s client: %v. %s payments will not be available.", evmNetwork.Name, err, evmNetwork.Name)
 		} else {
-			evmClients[evmNetwork.Name] = evmClient
+			backends.Register(evmClient)
 			log.Printf("%s client initialized successfully", evmNetwork.Name)
 		}
 	}
 
-	// Initialize Solana clients for different networks
-	solanaClients := make(map[string]*client.SolanaClient)
-
 	// Initialize Solana clients from the SolanaNetworks configuration
 	for _, solanaNetwork := range cfg.SolanaNetworks {
 		solanaClient, err := client.NewSolanaClient(cfg, solanaNetwork.Name)
 		if err != nil {
 			log.Printf("Warning: Failed to initialize %s Solana client: %v. %s payments will not be available.", solanaNetwork.Name, err, solanaNetwork.Name)
 		} else {
-			solanaClients[solanaNetwork.Name] = solanaClient
+			backends.Register(solanaClient)
 			log.Printf("%s Solana client initialized successfully (Paymaster: %s)", solanaNetwork.Name, solanaClient.GetPaymasterAddress())
 		}
 	}
@@ -56,8 +80,81 @@ func main() {
 		log.Printf("Paymaster address: %s", paymasterAddr)
 	}
 
+	// Open the payment ledger
+	ledger, err := store.Open(cfg.StoragePath)
+	if err != nil {
+		log.Fatalf("Failed to open payment ledger: %v", err)
+	}
+	defer ledger.Close()
+	log.Printf("Payment ledger: %s", cfg.StoragePath)
+	if removed, err := ledger.PurgeExpiredIdempotencyRecords(time.Now()); err != nil {
+		log.Printf("Failed to purge expired idempotency keys: %v", err)
+	} else if removed > 0 {
+		log.Printf("Purged %d expired idempotency keys", removed)
+	}
+
+	// Queued async payments do not survive a restart because their OTP is only held in memory
+	if failed, err := ledger.FailInterruptedPayments(api.CodeSubmissionInterrupted, "server restarted before the payment was submitted"); err != nil {
+		log.Printf("Failed to close out interrupted payments: %v", err)
+	} else if len(failed) > 0 {
+		log.Printf("Marked %d interrupted payments as failed", len(failed))
+	}
+	// Payments that were being sent may be on chain, so they are left for an operator to check
+	if unresolved, err := ledger.FlagUnresolvedSubmissions(api.CodeSubmissionInterrupted, "server restarted while the transaction was being sent; check the chain before retrying"); err != nil {
+		log.Printf("Failed to flag unresolved submissions: %v", err)
+	} else if len(unresolved) > 0 {
+		log.Printf("Warning: %d payments were being sent when the server stopped and need reconciliation: %v", len(unresolved), unresolved)
+	}
+
+	// Index the contract events of the networks that ask for it
+	for _, evmNetwork := range cfg.EVMNetworks {
+		if !evmNetwork.Indexer.Enabled {
+			continue
+		}
+		eventIndexer, err := indexer.NewEVMIndexer(evmNetwork, ledger)
+		if err != nil {
+			log.Printf("Warning: Failed to start the %s event indexer: %v", evmNetwork.Name, err)
+			continue
+		}
+		eventIndexer.Start()
+		defer eventIndexer.Close()
+		log.Printf("%s event indexer started from block %d", evmNetwork.Name, evmNetwork.Indexer.StartBlock)
+	}
+	if cfg.AptosIndexer.Enabled {
+		eventIndexer, err := indexer.NewAptosIndexer(aptosClient, cfg, ledger)
+		if err == nil && aptosClient.IndexerURL() == "" {
+			err = errors.New("no indexer API for this network, set aptos.indexer.graphql_url")
+		}
+		if err != nil {
+			log.Printf("Warning: Failed to start the Aptos event indexer: %v", err)
+		} else {
+			eventIndexer.Start()
+			defer eventIndexer.Close()
+			log.Printf("Aptos event indexer started from version %d", cfg.AptosIndexer.StartVersion)
+		}
+	}
+
+	// Payer locks can be shared through lease files with other processes that submit for the same
+	// payers. The ledger is not shared, so only one server may run per ledger.
+	lockOptions := locks.Options{LeaseTTL: cfg.LockLeaseTTL, AcquireTimeout: cfg.LockAcquireTimeout}
+	var payerLocks locks.Manager
+	switch cfg.LockBackend {
+	case "file":
+		payerLocks, err = locks.NewFileManager(cfg.LockDir, lockOptions)
+		if err != nil {
+			log.Fatalf("Failed to open payer lock directory: %v", err)
+		}
+		log.Printf("Payer locks: lease files in %s", cfg.LockDir)
+	case "memory":
+		payerLocks = locks.NewMemoryManager(lockOptions)
+	default:
+		log.Fatalf("Unknown lock backend %q, expected memory or file", cfg.LockBackend)
+	}
+	defer payerLocks.Close()
+
 	// Initialize OpenAPI server
-	apiServer := api.NewAPIServer(aptosClient, evmClients, solanaClients, cfg)
+	apiServer := api.NewAPIServer(backends, ledger, payerLocks, cfg)
+	defer apiServer.Close()
 
 	// Setup Gin router
 	router := gin.Default()
@@ -66,7 +163,7 @@ func main() {
 	router.Use(func(c *gin.Context) {
 		c.Header("Access-Control-Allow-Origin", "*")
 		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization, Idempotency-Key, Api-Key")
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
@@ -81,9 +178,31 @@ func main() {
 	// Setup API documentation
 	api.SetupDocumentationRoutes(router)
 
-	// Start server
-	log.Printf("Server starting on :%s", cfg.Port)
-	if err := router.Run(":" + cfg.Port); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	// Serve until SIGINT or SIGTERM, then let requests in flight finish so the deferred Close
+	// calls stop the workers, indexers and locks and flush the ledger
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	server := &http.Server{Addr: ":" + cfg.Port, Handler: router}
+	serveErr := make(chan error, 1)
+	go func() {
+		log.Printf("Server starting on :%s", cfg.Port)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serveErr <- err
+		}
+	}()
+
+	select {
+	case <-ctx.Done():
+	case err := <-serveErr:
+		log.Printf("Failed to start server: %v", err)
+		exitCode = 1
+		return
+	}
+	stop()
+	log.Printf("Shutting down, waiting up to %s for requests in flight", shutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Failed to finish requests in flight: %v", err)
 	}
 }
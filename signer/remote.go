@@ -0,0 +1,182 @@
+package signer
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// DefaultTimeout bounds a signing request when RemoteConfig.Timeout is unset
+const DefaultTimeout = 10 * time.Second
+
+// maxResponseSize bounds the body read from the remote signer
+const maxResponseSize = 64 << 10
+
+// Remote is a signer whose key is held by an HTTP signing service
+type Remote struct {
+	scheme    string
+	url       string
+	token     string
+	publicKey []byte
+	client    *http.Client
+}
+
+type signRequest struct {
+	Data string `json:"data"`
+}
+
+type signResponse struct {
+	Signature string `json:"signature"`
+}
+
+// NewRemote creates a signer for the key identified by identifier, a hex public key, on the signing
+// service. secp256k1 keys are signed through Web3Signer's POST /api/v1/eth1/sign/{identifier};
+// Ed25519 keys through POST /api/v1/ed25519/sign/{identifier}, which takes the same
+// {"data": "0x.."} body and answers {"signature": "0x.."}.
+func NewRemote(cfg RemoteConfig, scheme, identifier string) (*Remote, error) {
+	publicKey, err := hexutil.Decode(ensure0x(identifier))
+	if err != nil {
+		return nil, fmt.Errorf("invalid remote public key %q: %w", identifier, err)
+	}
+
+	base := cfg.URL
+	path := "/api/v1/eth1/sign/"
+	switch scheme {
+	case SchemeSecp256k1:
+		// Web3Signer identifies keys by the 64-byte public key, without the 0x04 prefix
+		switch len(publicKey) {
+		case 64:
+			publicKey = append([]byte{0x04}, publicKey...)
+		case 65:
+		default:
+			return nil, fmt.Errorf("invalid remote secp256k1 public key length %d", len(publicKey))
+		}
+		if _, err := crypto.UnmarshalPubkey(publicKey); err != nil {
+			return nil, fmt.Errorf("invalid remote secp256k1 public key: %w", err)
+		}
+	case SchemeEd25519:
+		if len(publicKey) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid remote Ed25519 public key length %d", len(publicKey))
+		}
+		if cfg.Ed25519URL != "" {
+			base = cfg.Ed25519URL
+		}
+		path = "/api/v1/ed25519/sign/"
+	default:
+		return nil, fmt.Errorf("unknown signature scheme %q", scheme)
+	}
+	if base == "" {
+		return nil, errors.New("remote signer URL is not configured")
+	}
+
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	return &Remote{
+		scheme:    scheme,
+		url:       strings.TrimRight(base, "/") + path + ensure0x(identifier),
+		token:     cfg.Token,
+		publicKey: publicKey,
+		client:    &http.Client{Timeout: timeout},
+	}, nil
+}
+
+func (r *Remote) Scheme() string { return r.scheme }
+
+func (r *Remote) PublicKey() []byte { return r.publicKey }
+
+// Sign asks the signing service to sign data and checks the signature against the public key, so a
+// misconfigured service cannot make the client broadcast transactions signed by another key
+func (r *Remote) Sign(ctx context.Context, data []byte) ([]byte, error) {
+	body, err := json.Marshal(signRequest{Data: hexutil.Encode(data)})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/plain")
+	if r.token != "" {
+		req.Header.Set("Authorization", "Bearer "+r.token)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("remote signer request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	payload, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read remote signer response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: status %d: %s", ErrRejected, resp.StatusCode, strings.TrimSpace(string(payload)))
+	}
+
+	signature, err := decodeSignature(payload)
+	if err != nil {
+		return nil, err
+	}
+	if r.scheme == SchemeSecp256k1 {
+		return r.checkSecp256k1(data, signature)
+	}
+	if len(signature) != ed25519.SignatureSize || !ed25519.Verify(r.publicKey, data, signature) {
+		return nil, errors.New("remote signer returned an invalid Ed25519 signature")
+	}
+	return signature, nil
+}
+
+// checkSecp256k1 normalises the recovery id to 0 or 1 and checks that the signature recovers to the
+// signer's public key
+func (r *Remote) checkSecp256k1(data, signature []byte) ([]byte, error) {
+	if len(signature) != crypto.SignatureLength {
+		return nil, fmt.Errorf("remote signer returned a %d-byte secp256k1 signature", len(signature))
+	}
+	signature = bytes.Clone(signature)
+	if signature[crypto.RecoveryIDOffset] >= 27 {
+		signature[crypto.RecoveryIDOffset] -= 27
+	}
+	recovered, err := crypto.Ecrecover(crypto.Keccak256(data), signature)
+	if err != nil || !bytes.Equal(recovered, r.publicKey) {
+		return nil, errors.New("remote signer returned a signature of another key")
+	}
+	return signature, nil
+}
+
+// decodeSignature reads a hex signature from a plain-text body, as Web3Signer answers, or from a
+// JSON {"signature": ..} body
+func decodeSignature(payload []byte) ([]byte, error) {
+	text := strings.TrimSpace(string(payload))
+	if strings.HasPrefix(text, "{") {
+		var resp signResponse
+		if err := json.Unmarshal(payload, &resp); err != nil {
+			return nil, fmt.Errorf("invalid remote signer response: %w", err)
+		}
+		text = resp.Signature
+	}
+	signature, err := hexutil.Decode(ensure0x(strings.Trim(text, `"`)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid remote signer signature: %w", err)
+	}
+	return signature, nil
+}
+
+func ensure0x(value string) string {
+	if strings.HasPrefix(value, "0x") || strings.HasPrefix(value, "0X") {
+		return value
+	}
+	return "0x" + value
+}
@@ -0,0 +1,138 @@
+// Package signer signs transactions with keys that may live outside the TinyPay process. A local
+// signer holds the key in memory; a remote signer asks an HTTP signing service, speaking the
+// Web3Signer eth1 API for secp256k1 keys and a JSON protocol of the same shape for Ed25519 keys.
+package signer
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	aptoscrypto "github.com/aptos-labs/aptos-go-sdk/crypto"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/gagliardetto/solana-go"
+)
+
+// Signature schemes
+const (
+	SchemeSecp256k1 = "secp256k1" // EVM keys
+	SchemeEd25519   = "ed25519"   // Aptos and Solana keys
+)
+
+// RemotePrefix marks a configured key field that names a key held by the remote signer
+const RemotePrefix = "remote:"
+
+// ErrRejected is returned when the remote signer refuses to sign, for example for an unknown key
+var ErrRejected = errors.New("remote signer rejected the request")
+
+// Signer signs with one key
+type Signer interface {
+	// Scheme returns SchemeSecp256k1 or SchemeEd25519
+	Scheme() string
+	// PublicKey returns the public key: 65-byte uncompressed for secp256k1, 32 bytes for Ed25519
+	PublicKey() []byte
+	// Sign signs data. secp256k1 signers sign keccak256(data) and return R || S || V with V 0 or 1,
+	// like Web3Signer's eth1 endpoint; Ed25519 signers sign data itself.
+	Sign(ctx context.Context, data []byte) ([]byte, error)
+}
+
+// RemoteConfig locates the remote signing service
+type RemoteConfig struct {
+	URL        string        // base URL of the Web3Signer-compatible service
+	Ed25519URL string        // base URL for Ed25519 keys; URL when empty
+	Token      string        // bearer token sent with every request, if any
+	Timeout    time.Duration // bound on each signing request
+}
+
+// Local is a signer holding its key in memory
+type Local struct {
+	scheme    string
+	secp256k1 *ecdsa.PrivateKey
+	ed25519   ed25519.PrivateKey
+}
+
+// NewLocalSecp256k1 creates a local signer for an EVM key
+func NewLocalSecp256k1(key *ecdsa.PrivateKey) *Local {
+	return &Local{scheme: SchemeSecp256k1, secp256k1: key}
+}
+
+// NewLocalEd25519 creates a local signer for an Aptos or Solana key
+func NewLocalEd25519(key ed25519.PrivateKey) *Local {
+	return &Local{scheme: SchemeEd25519, ed25519: key}
+}
+
+func (l *Local) Scheme() string { return l.scheme }
+
+func (l *Local) PublicKey() []byte {
+	if l.scheme == SchemeSecp256k1 {
+		return crypto.FromECDSAPub(&l.secp256k1.PublicKey)
+	}
+	return []byte(l.ed25519.Public().(ed25519.PublicKey))
+}
+
+func (l *Local) Sign(ctx context.Context, data []byte) ([]byte, error) {
+	if l.scheme == SchemeSecp256k1 {
+		return crypto.Sign(crypto.Keccak256(data), l.secp256k1)
+	}
+	return ed25519.Sign(l.ed25519, data), nil
+}
+
+// NewEVM returns the signer of a configured EVM key field: a hex private key, or
+// remote:<public key> for a key held by the remote signer
+func NewEVM(value string, remote RemoteConfig) (Signer, error) {
+	if identifier, ok := remoteIdentifier(value); ok {
+		return NewRemote(remote, SchemeSecp256k1, identifier)
+	}
+	key, err := crypto.HexToECDSA(strings.TrimPrefix(strings.TrimSpace(value), "0x"))
+	if err != nil {
+		return nil, err
+	}
+	return NewLocalSecp256k1(key), nil
+}
+
+// NewAptos returns the signer of a configured Aptos key field: a hex or AIP-80 private key, or
+// remote:<hex public key>
+func NewAptos(value string, remote RemoteConfig) (Signer, error) {
+	if identifier, ok := remoteIdentifier(value); ok {
+		return NewRemote(remote, SchemeEd25519, identifier)
+	}
+	// Plain hex and AIP-80 keys are both accepted, without the SDK's AIP-80 warning
+	raw, err := aptoscrypto.ParsePrivateKey(strings.TrimSpace(value), aptoscrypto.PrivateKeyVariantEd25519, false)
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) != ed25519.SeedSize {
+		return nil, fmt.Errorf("invalid ed25519 private key size %d", len(raw))
+	}
+	return NewLocalEd25519(ed25519.NewKeyFromSeed(raw)), nil
+}
+
+// NewSolana returns the signer of a configured Solana key field: a base58 private key, or
+// remote:<base58 or hex public key>
+func NewSolana(value string, remote RemoteConfig) (Signer, error) {
+	if identifier, ok := remoteIdentifier(value); ok {
+		if !strings.HasPrefix(identifier, "0x") {
+			publicKey, err := solana.PublicKeyFromBase58(identifier)
+			if err != nil {
+				return nil, fmt.Errorf("invalid remote Solana public key: %w", err)
+			}
+			identifier = "0x" + hex.EncodeToString(publicKey[:])
+		}
+		return NewRemote(remote, SchemeEd25519, identifier)
+	}
+	key, err := solana.PrivateKeyFromBase58(strings.TrimSpace(value))
+	if err != nil {
+		return nil, err
+	}
+	return NewLocalEd25519(ed25519.PrivateKey(key)), nil
+}
+
+func remoteIdentifier(value string) (string, bool) {
+	identifier, ok := strings.CutPrefix(strings.TrimSpace(value), RemotePrefix)
+	return strings.TrimSpace(identifier), ok
+}
@@ -0,0 +1,117 @@
+package signer_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"tinypay-server/signer"
+	"tinypay-server/signer/signertest"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/gagliardetto/solana-go"
+)
+
+func TestRemoteSecp256k1_MatchesLocal(t *testing.T) {
+	server := signertest.NewServer("token")
+	defer server.Close()
+	key, _ := crypto.GenerateKey()
+	identifier := server.AddSecp256k1(key)
+
+	remote, err := signer.NewEVM(signer.RemotePrefix+identifier, signer.RemoteConfig{URL: server.URL, Token: "token"})
+	if err != nil {
+		t.Fatalf("new remote signer: %v", err)
+	}
+	local := signer.NewLocalSecp256k1(key)
+	if !bytes.Equal(remote.PublicKey(), local.PublicKey()) {
+		t.Fatal("expected the remote and local signers to share a public key")
+	}
+
+	data := []byte("transaction preimage")
+	got, err := remote.Sign(context.Background(), data)
+	if err != nil {
+		t.Fatalf("remote sign: %v", err)
+	}
+	want, _ := local.Sign(context.Background(), data)
+	// secp256k1 signatures are deterministic (RFC 6979), and the server's 27/28 recovery id is normalised
+	if !bytes.Equal(got, want) {
+		t.Errorf("expected remote signature %x to equal local %x", got, want)
+	}
+}
+
+func TestRemoteEd25519_MatchesLocal(t *testing.T) {
+	server := signertest.NewServer("")
+	defer server.Close()
+	key, _ := solana.NewRandomPrivateKey()
+	server.AddEd25519(ed25519.PrivateKey(key))
+
+	// Solana keys may be named by their base58 address
+	remote, err := signer.NewSolana(signer.RemotePrefix+key.PublicKey().String(), signer.RemoteConfig{URL: server.URL})
+	if err != nil {
+		t.Fatalf("new remote signer: %v", err)
+	}
+	local, _ := signer.NewSolana(key.String(), signer.RemoteConfig{})
+	if !bytes.Equal(remote.PublicKey(), local.PublicKey()) {
+		t.Fatal("expected the remote and local signers to share a public key")
+	}
+
+	data := []byte("message")
+	got, err := remote.Sign(context.Background(), data)
+	if err != nil {
+		t.Fatalf("remote sign: %v", err)
+	}
+	want, _ := local.Sign(context.Background(), data)
+	if !bytes.Equal(got, want) {
+		t.Errorf("expected remote signature %x to equal local %x", got, want)
+	}
+}
+
+func TestRemote_Rejections(t *testing.T) {
+	server := signertest.NewServer("token")
+	defer server.Close()
+	key, _ := crypto.GenerateKey()
+	identifier := server.AddSecp256k1(key)
+
+	wrongToken, _ := signer.NewEVM(signer.RemotePrefix+identifier, signer.RemoteConfig{URL: server.URL, Token: "other"})
+	if _, err := wrongToken.Sign(context.Background(), []byte("x")); !errors.Is(err, signer.ErrRejected) {
+		t.Errorf("expected a wrong token to be rejected, got %v", err)
+	}
+
+	public, _, _ := ed25519.GenerateKey(rand.Reader)
+	unknown, err := signer.NewAptos(signer.RemotePrefix+hexutil.Encode(public), signer.RemoteConfig{URL: server.URL, Token: "token"})
+	if err != nil {
+		t.Fatalf("new remote signer: %v", err)
+	}
+	if _, err := unknown.Sign(context.Background(), []byte("x")); !errors.Is(err, signer.ErrRejected) {
+		t.Errorf("expected an unknown key to be rejected, got %v", err)
+	}
+
+	if _, err := signer.NewEVM(signer.RemotePrefix+identifier, signer.RemoteConfig{}); err == nil {
+		t.Error("expected a remote key without a signer URL to be refused")
+	}
+}
+
+func TestRemote_RejectsSignatureOfAnotherKey(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	other, _ := crypto.GenerateKey()
+	// A misconfigured service that signs every request with another key
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		signature, _ := crypto.Sign(crypto.Keccak256([]byte("x")), other)
+		w.Write([]byte(hexutil.Encode(signature)))
+	}))
+	defer server.Close()
+
+	remote, err := signer.NewRemote(signer.RemoteConfig{URL: server.URL}, signer.SchemeSecp256k1, hexutil.Encode(crypto.FromECDSAPub(&key.PublicKey)))
+	if err != nil {
+		t.Fatalf("new remote signer: %v", err)
+	}
+	if _, err := remote.Sign(context.Background(), []byte("x")); err == nil {
+		t.Error("expected a signature of another key to be refused")
+	}
+}
@@ -0,0 +1,126 @@
+// Package signertest runs an in-process stand-in for the remote signing service, so tests can sign
+// through signer.Remote without a real Web3Signer
+package signertest
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Server signs with the keys added to it. It speaks Web3Signer's eth1 endpoint, answering with a
+// plain-text signature whose recovery id is 27 or 28 as Web3Signer does, and the Ed25519 JSON
+// endpoint.
+type Server struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	token     string
+	secp256k1 map[string]*ecdsa.PrivateKey
+	ed25519   map[string]ed25519.PrivateKey
+	requests  int
+}
+
+// NewServer starts a stand-in signer; a non-empty token is required as a bearer token
+func NewServer(token string) *Server {
+	s := &Server{
+		token:     token,
+		secp256k1: map[string]*ecdsa.PrivateKey{},
+		ed25519:   map[string]ed25519.PrivateKey{},
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/v1/eth1/sign/{identifier}", s.signSecp256k1)
+	mux.HandleFunc("POST /api/v1/ed25519/sign/{identifier}", s.signEd25519)
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// AddSecp256k1 makes the server sign for key and returns its identifier, the 64-byte public key
+func (s *Server) AddSecp256k1(key *ecdsa.PrivateKey) string {
+	identifier := hexutil.Encode(crypto.FromECDSAPub(&key.PublicKey)[1:])
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.secp256k1[identifier] = key
+	return identifier
+}
+
+// AddEd25519 makes the server sign for key and returns its identifier, the hex public key
+func (s *Server) AddEd25519(key ed25519.PrivateKey) string {
+	identifier := hexutil.Encode(key.Public().(ed25519.PublicKey))
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ed25519[identifier] = key
+	return identifier
+}
+
+// Requests returns the number of signatures the server has made
+func (s *Server) Requests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+func (s *Server) signSecp256k1(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	key := s.secp256k1[strings.ToLower(r.PathValue("identifier"))]
+	s.mu.Unlock()
+	data, ok := s.read(w, r, key != nil)
+	if !ok {
+		return
+	}
+	signature, err := crypto.Sign(crypto.Keccak256(data), key)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	signature[crypto.RecoveryIDOffset] += 27
+	w.Header().Set("Content-Type", "text/plain")
+	w.Write([]byte(hexutil.Encode(signature)))
+}
+
+func (s *Server) signEd25519(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	key := s.ed25519[strings.ToLower(r.PathValue("identifier"))]
+	s.mu.Unlock()
+	data, ok := s.read(w, r, key != nil)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"signature": hexutil.Encode(ed25519.Sign(key, data))})
+}
+
+// read checks the token and the key, and decodes the data to sign
+func (s *Server) read(w http.ResponseWriter, r *http.Request, known bool) ([]byte, bool) {
+	if s.token != "" && r.Header.Get("Authorization") != "Bearer "+s.token {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return nil, false
+	}
+	if !known {
+		http.Error(w, "Signer not found", http.StatusNotFound)
+		return nil, false
+	}
+	var body struct {
+		Data string `json:"data"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+	data, err := hexutil.Decode(body.Data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+	s.mu.Lock()
+	s.requests++
+	s.mu.Unlock()
+	return data, true
+}
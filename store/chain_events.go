@@ -0,0 +1,203 @@
+package store
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// ChainEvent is a TinyPay contract event read from the chain by an indexer. Every event type is
+// normalized to the same fields; values that do not fit them are kept in Details.
+type ChainEvent struct {
+	ID          string            `json:"id"` // network, block or version, and log index; sorts by chain position
+	Network     string            `json:"network"`
+	Type        string            `json:"type"` // contract event name, e.g. PaymentCompleted
+	BlockNumber uint64            `json:"block_number,omitempty"`
+	BlockHash   string            `json:"block_hash,omitempty"`
+	Version     uint64            `json:"version,omitempty"` // Aptos ledger version, in place of the block
+	TxHash      string            `json:"transaction_hash"`
+	LogIndex    uint              `json:"log_index"`           // in the block; in the transaction on Aptos
+	Account     string            `json:"account,omitempty"`   // user, payer or merchant the event is about
+	Recipient   string            `json:"recipient,omitempty"` // payee of a payment
+	Token       string            `json:"token,omitempty"`
+	Amount      string            `json:"amount,omitempty"` // decimal, in the token's smallest unit
+	Fee         string            `json:"fee,omitempty"`
+	Balance     string            `json:"balance,omitempty"` // account balance after the event
+	Details     map[string]string `json:"details,omitempty"`
+	Timestamp   time.Time         `json:"timestamp"` // block time, or commit time on Aptos
+	IndexedAt   time.Time         `json:"indexed_at"`
+}
+
+// IndexedBlock is a block an indexer stored events up to, kept to detect reorgs
+type IndexedBlock struct {
+	Number uint64 `json:"number"`
+	Hash   string `json:"hash"`
+}
+
+// IndexerCheckpoint is how far the indexer of a network got
+type IndexerCheckpoint struct {
+	Network   string         `json:"network"`
+	Next      uint64         `json:"next"`                 // first block, or Aptos ledger version, not indexed yet
+	NextIndex uint64         `json:"next_index,omitempty"` // first event of the Aptos version Next not indexed yet
+	Blocks    []IndexedBlock `json:"blocks,omitempty"`     // latest indexed EVM blocks, oldest first
+	UpdatedAt time.Time      `json:"updated_at"`
+}
+
+// ChainEventFilter selects events in ListChainEvents. Empty fields other than Network match everything.
+type ChainEventFilter struct {
+	Network string
+	Type    string
+	Account string // matches the account or the recipient
+	TxHash  string
+	Cursor  string // ID of the last event of the previous page
+	Limit   int
+}
+
+// ChainEventID returns the ID of the event at log index of block (or ledger version) on network
+func ChainEventID(network string, block uint64, index uint) string {
+	return fmt.Sprintf("%s:%020d:%06d", network, block, index)
+}
+
+// position is where the event is on its chain: the block, or the ledger version on Aptos
+func (e *ChainEvent) position() uint64 {
+	if e.Version != 0 {
+		return e.Version
+	}
+	return e.BlockNumber
+}
+
+// GetIndexerCheckpoint returns the checkpoint of network, or ErrNotFound before its first pass
+func (s *Store) GetIndexerCheckpoint(network string) (*IndexerCheckpoint, error) {
+	var cp *IndexerCheckpoint
+	err := s.db.View(func(tx *bolt.Tx) error {
+		raw := tx.Bucket(bucketIndexerCheckpoints).Get([]byte(network))
+		if raw == nil {
+			return ErrNotFound
+		}
+		cp = &IndexerCheckpoint{}
+		if err := json.Unmarshal(raw, cp); err != nil {
+			return fmt.Errorf("failed to decode indexer checkpoint %s: %w", network, err)
+		}
+		return nil
+	})
+	return cp, err
+}
+
+// SaveChainEvents stores events and moves the checkpoint of their network in one transaction,
+// so a crash never leaves events the checkpoint does not cover. Events already stored are replaced.
+func (s *Store) SaveChainEvents(cp *IndexerCheckpoint, events []*ChainEvent) error {
+	now := time.Now().UTC()
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketChainEvents)
+		for _, e := range events {
+			e.Network = cp.Network
+			e.ID = ChainEventID(e.Network, e.position(), e.LogIndex)
+			e.IndexedAt = now
+			raw, err := json.Marshal(e)
+			if err != nil {
+				return fmt.Errorf("failed to encode chain event: %w", err)
+			}
+			if err := b.Put([]byte(e.ID), raw); err != nil {
+				return err
+			}
+		}
+		return putIndexerCheckpoint(tx, cp, now)
+	})
+}
+
+// RollbackChainEvents deletes the events of the checkpoint's network from block from onwards and
+// stores the checkpoint, returning how many events were removed
+func (s *Store) RollbackChainEvents(cp *IndexerCheckpoint, from uint64) (int, error) {
+	removed := 0
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		c := tx.Bucket(bucketChainEvents).Cursor()
+		prefix := []byte(cp.Network + ":")
+		var keys [][]byte
+		for k, _ := c.Seek([]byte(ChainEventID(cp.Network, from, 0))); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+			keys = append(keys, append([]byte(nil), k...))
+		}
+		b := tx.Bucket(bucketChainEvents)
+		for _, k := range keys {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+		removed = len(keys)
+		return putIndexerCheckpoint(tx, cp, time.Now().UTC())
+	})
+	return removed, err
+}
+
+// ListChainEvents returns matching events of one network, latest first, and the cursor for the next page
+func (s *Store) ListChainEvents(filter ChainEventFilter) ([]*ChainEvent, string, error) {
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultPageSize
+	}
+	if limit > maxPageSize {
+		limit = maxPageSize
+	}
+
+	events := make([]*ChainEvent, 0, limit)
+	nextCursor := ""
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(bucketChainEvents).Cursor()
+		prefix := []byte(filter.Network + ":")
+
+		// Start just before the cursor, or before the first key past the network's range
+		start := []byte(filter.Network + ";")
+		if filter.Cursor != "" {
+			start = []byte(filter.Cursor)
+		}
+		k, v := c.Seek(start)
+		if k == nil {
+			k, v = c.Last()
+		} else {
+			k, v = c.Prev()
+		}
+
+		for ; k != nil && bytes.HasPrefix(k, prefix); k, v = c.Prev() {
+			var e ChainEvent
+			if err := json.Unmarshal(v, &e); err != nil {
+				return fmt.Errorf("failed to decode chain event %s: %w", k, err)
+			}
+			if !filter.matches(&e) {
+				continue
+			}
+			if len(events) == limit {
+				nextCursor = events[len(events)-1].ID
+				break
+			}
+			events = append(events, &e)
+		}
+		return nil
+	})
+	return events, nextCursor, err
+}
+
+func (f ChainEventFilter) matches(e *ChainEvent) bool {
+	if f.Type != "" && !strings.EqualFold(f.Type, e.Type) {
+		return false
+	}
+	if f.Account != "" && !strings.EqualFold(f.Account, e.Account) && !strings.EqualFold(f.Account, e.Recipient) {
+		return false
+	}
+	if f.TxHash != "" && !strings.EqualFold(f.TxHash, e.TxHash) {
+		return false
+	}
+	return true
+}
+
+func putIndexerCheckpoint(tx *bolt.Tx, cp *IndexerCheckpoint, now time.Time) error {
+	cp.UpdatedAt = now
+	raw, err := json.Marshal(cp)
+	if err != nil {
+		return fmt.Errorf("failed to encode indexer checkpoint: %w", err)
+	}
+	return tx.Bucket(bucketIndexerCheckpoints).Put([]byte(cp.Network), raw)
+}
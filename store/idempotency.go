@@ -0,0 +1,138 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// IdempotencyState tells whether the request behind an idempotency key has finished
+type IdempotencyState string
+
+const (
+	IdempotencyInProgress IdempotencyState = "in_progress" // first request still being processed
+	IdempotencyCompleted  IdempotencyState = "completed"   // response recorded and replayable
+)
+
+// IdempotencyRecord is the remembered outcome of a request sent with an Idempotency-Key
+type IdempotencyRecord struct {
+	Key         string           `json:"key"`
+	RequestHash string           `json:"request_hash"` // fingerprint of the original request body
+	State       IdempotencyState `json:"state"`
+	StatusCode  int              `json:"status_code,omitempty"`
+	Body        []byte           `json:"body,omitempty"`
+	CreatedAt   time.Time        `json:"created_at"`
+	ExpiresAt   time.Time        `json:"expires_at"`
+}
+
+// Expired reports whether the record is past its replay window
+func (r *IdempotencyRecord) Expired(now time.Time) bool {
+	return !now.Before(r.ExpiresAt)
+}
+
+// BeginIdempotentRequest claims key for a new request. If an unexpired record already exists
+// it is returned with claimed set to false and nothing is written.
+func (s *Store) BeginIdempotentRequest(key, requestHash string, window time.Duration) (record *IdempotencyRecord, claimed bool, err error) {
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(bucketIdempotency)
+		now := time.Now().UTC()
+
+		if raw := bucket.Get([]byte(key)); raw != nil {
+			var existing IdempotencyRecord
+			if err := json.Unmarshal(raw, &existing); err != nil {
+				return fmt.Errorf("failed to decode idempotency record %s: %w", key, err)
+			}
+			if !existing.Expired(now) {
+				record = &existing
+				return nil
+			}
+		}
+
+		record = &IdempotencyRecord{
+			Key:         key,
+			RequestHash: requestHash,
+			State:       IdempotencyInProgress,
+			CreatedAt:   now,
+			ExpiresAt:   now.Add(window),
+		}
+		claimed = true
+		return putIdempotencyRecord(bucket, record)
+	})
+	return record, claimed, err
+}
+
+// CompleteIdempotentRequest stores the response of a claimed request so retries can replay it
+func (s *Store) CompleteIdempotentRequest(key string, statusCode int, body []byte) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(bucketIdempotency)
+		raw := bucket.Get([]byte(key))
+		if raw == nil {
+			return ErrNotFound
+		}
+		var record IdempotencyRecord
+		if err := json.Unmarshal(raw, &record); err != nil {
+			return fmt.Errorf("failed to decode idempotency record %s: %w", key, err)
+		}
+		record.State = IdempotencyCompleted
+		record.StatusCode = statusCode
+		record.Body = body
+		return putIdempotencyRecord(bucket, &record)
+	})
+}
+
+// ReleaseIdempotentRequest forgets a claimed key whose request did not reach a final response, so
+// a retry with the same key is processed again. Completed records are kept.
+func (s *Store) ReleaseIdempotentRequest(key string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(bucketIdempotency)
+		raw := bucket.Get([]byte(key))
+		if raw == nil {
+			return nil
+		}
+		var record IdempotencyRecord
+		if err := json.Unmarshal(raw, &record); err != nil {
+			return fmt.Errorf("failed to decode idempotency record %s: %w", key, err)
+		}
+		if record.State != IdempotencyInProgress {
+			return nil
+		}
+		return bucket.Delete([]byte(key))
+	})
+}
+
+// PurgeExpiredIdempotencyRecords deletes records past their window and returns how many were removed
+func (s *Store) PurgeExpiredIdempotencyRecords(now time.Time) (int, error) {
+	removed := 0
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(bucketIdempotency)
+
+		// Collect first; bbolt cursors must not be used while the bucket is modified
+		var expired [][]byte
+		c := bucket.Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			var record IdempotencyRecord
+			if err := json.Unmarshal(v, &record); err != nil || record.Expired(now) {
+				expired = append(expired, append([]byte(nil), k...))
+			}
+		}
+
+		for _, k := range expired {
+			if err := bucket.Delete(k); err != nil {
+				return err
+			}
+			removed++
+		}
+		return nil
+	})
+	return removed, err
+}
+
+func putIdempotencyRecord(bucket *bolt.Bucket, record *IdempotencyRecord) error {
+	raw, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to encode idempotency record: %w", err)
+	}
+	return bucket.Put([]byte(record.Key), raw)
+}
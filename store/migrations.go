@@ -0,0 +1,123 @@
+package store
+
+import (
+	"encoding/binary"
+	"fmt"
+	"log"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	bucketMeta         = []byte("meta")
+	bucketPayments     = []byte("payments")
+	bucketPaymentsByTx = []byte("payments_by_tx")
+	bucketIdempotency  = []byte("idempotency")
+	bucketPrecommits   = []byte("precommits")
+	keySchemaVersion   = []byte("schema_version")
+
+	bucketWebhooks          = []byte("webhooks")
+	bucketWebhookDeliveries = []byte("webhook_deliveries")
+	bucketWebhookQueue      = []byte("webhook_queue")  // due time and delivery ID -> delivery ID
+	bucketWebhookEvents     = []byte("webhook_events") // subscription, event and payment -> delivery ID
+
+	bucketChainEvents        = []byte("chain_events")        // network, block and log index -> event
+	bucketIndexerCheckpoints = []byte("indexer_checkpoints") // network -> checkpoint
+)
+
+// migration upgrades the database schema by one version
+type migration struct {
+	version     uint64
+	description string
+	apply       func(tx *bolt.Tx) error
+}
+
+// migrations lists every schema change in order. Append new entries; never edit applied ones.
+var migrations = []migration{
+	{
+		version:     1,
+		description: "create payment ledger buckets",
+		apply: func(tx *bolt.Tx) error {
+			return createBuckets(tx, bucketPayments, bucketPaymentsByTx)
+		},
+	},
+	{
+		version:     2,
+		description: "create idempotency key bucket",
+		apply: func(tx *bolt.Tx) error {
+			return createBuckets(tx, bucketIdempotency)
+		},
+	},
+	{
+		version:     3,
+		description: "create merchant precommit bucket",
+		apply: func(tx *bolt.Tx) error {
+			return createBuckets(tx, bucketPrecommits)
+		},
+	},
+	{
+		version:     4,
+		description: "create merchant webhook buckets",
+		apply: func(tx *bolt.Tx) error {
+			return createBuckets(tx, bucketWebhooks, bucketWebhookDeliveries, bucketWebhookQueue, bucketWebhookEvents)
+		},
+	},
+	{
+		version:     5,
+		description: "create chain event indexer buckets",
+		apply: func(tx *bolt.Tx) error {
+			return createBuckets(tx, bucketChainEvents, bucketIndexerCheckpoints)
+		},
+	},
+}
+
+// migrate applies every migration newer than the stored schema version
+func (s *Store) migrate() error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		meta, err := tx.CreateBucketIfNotExists(bucketMeta)
+		if err != nil {
+			return fmt.Errorf("failed to create meta bucket: %w", err)
+		}
+
+		current := uint64(0)
+		if raw := meta.Get(keySchemaVersion); raw != nil {
+			current = binary.BigEndian.Uint64(raw)
+		}
+
+		for _, m := range migrations {
+			if m.version <= current {
+				continue
+			}
+			if err := m.apply(tx); err != nil {
+				return fmt.Errorf("migration %d (%s) failed: %w", m.version, m.description, err)
+			}
+			log.Printf("Applied storage migration %d: %s", m.version, m.description)
+			current = m.version
+		}
+
+		version := make([]byte, 8)
+		binary.BigEndian.PutUint64(version, current)
+		return meta.Put(keySchemaVersion, version)
+	})
+}
+
+// SchemaVersion returns the schema version currently stored in the database
+func (s *Store) SchemaVersion() (uint64, error) {
+	var version uint64
+	err := s.db.View(func(tx *bolt.Tx) error {
+		if raw := tx.Bucket(bucketMeta).Get(keySchemaVersion); raw != nil {
+			version = binary.BigEndian.Uint64(raw)
+		}
+		return nil
+	})
+	return version, err
+}
+
+func createBuckets(tx *bolt.Tx, names ...[]byte) error {
+	for _, name := range names {
+		if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+			return fmt.Errorf("failed to create bucket %s: %w", name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,405 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// PaymentStatus is the lifecycle state of a ledger entry
+type PaymentStatus string
+
+const (
+	StatusReceived   PaymentStatus = "received"   // request accepted, not yet on chain
+	StatusSubmitting PaymentStatus = "submitting" // picked up by a worker, transaction being sent
+	StatusSubmitted  PaymentStatus = "submitted"  // transaction handed to the network
+	StatusConfirmed  PaymentStatus = "confirmed"  // transaction executed successfully
+	StatusFailed     PaymentStatus = "failed"     // submission or execution failed
+
+	// StatusNeedsReconciliation marks a payment whose transaction was being sent when the server
+	// stopped. It may or may not be on chain, so it is neither failed nor retried automatically.
+	StatusNeedsReconciliation PaymentStatus = "needs_reconciliation"
+)
+
+// allowedTransitions lists the statuses each status may move to
+var allowedTransitions = map[PaymentStatus][]PaymentStatus{
+	StatusReceived:   {StatusSubmitting, StatusSubmitted, StatusFailed},
+	StatusSubmitting: {StatusSubmitted, StatusFailed, StatusNeedsReconciliation},
+	StatusSubmitted:  {StatusConfirmed, StatusFailed},
+}
+
+// StatusChange records one status transition of a payment
+type StatusChange struct {
+	Status PaymentStatus `json:"status"`
+	At     time.Time     `json:"at"`
+	Error  string        `json:"error,omitempty"`
+}
+
+// Payment is a ledger entry for a payment request handled by the server
+type Payment struct {
+	ID          string         `json:"id"`
+	PayerAddr   string         `json:"payer_addr"`
+	PayeeAddr   string         `json:"payee_addr"`
+	Amount      uint64         `json:"amount"`
+	Network     string         `json:"network"`
+	Currency    string         `json:"currency"`
+	CoinType    string         `json:"coin_type"`
+	TxHash      string         `json:"transaction_hash,omitempty"`
+	Paymaster   string         `json:"paymaster,omitempty"`    // paymaster key that sent or paid for the transaction
+	Async       bool           `json:"async,omitempty"`        // submitted by a background worker
+	PrecommitID string         `json:"precommit_id,omitempty"` // merchant precommit completed by this payment
+	APIKeyID    string         `json:"api_key_id,omitempty"`   // fingerprint of the API key the payment was created with
+	Status      PaymentStatus  `json:"status"`
+	Error       string         `json:"error,omitempty"`
+	ErrorCode   int            `json:"error_code,omitempty"` // business status code of the failure, if known
+	Fee         *Fee           `json:"fee,omitempty"`        // price the transaction was sent with
+	Cancelled   bool           `json:"cancelled,omitempty"`  // the transaction is a cancellation that voids the payment
+	Replaced    []ReplacedTx   `json:"replaced_transactions,omitempty"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	History     []StatusChange `json:"history"`
+}
+
+// Fee is the price a payment's transaction was sent with, in the chain's smallest units
+type Fee struct {
+	Mode        string `json:"mode"`                   // fee policy mode: static, estimate or estimate-with-multiplier
+	Unit        string `json:"unit"`                   // unit of price and priority_fee, e.g. "wei/gas"
+	Price       uint64 `json:"price"`                  // gas unit price, max fee per gas or compute unit price
+	PriorityFee uint64 `json:"priority_fee,omitempty"` // EVM max priority fee per gas
+	Limit       uint64 `json:"limit,omitempty"`        // gas or compute unit limit, when set
+	Capped      bool   `json:"capped,omitempty"`       // the estimate was cut to the policy's cap
+}
+
+// ReplacedTx is a transaction that stood for a payment until a replacement with the same nonce was sent
+type ReplacedTx struct {
+	TxHash     string    `json:"transaction_hash"`
+	Fee        *Fee      `json:"fee,omitempty"`
+	Cancelled  bool      `json:"cancelled,omitempty"`
+	ReplacedAt time.Time `json:"replaced_at"`
+}
+
+// PaymentFilter selects payments in ListPayments. Empty fields match everything.
+type PaymentFilter struct {
+	PayerAddr string
+	PayeeAddr string
+	Network   string
+	Status    PaymentStatus
+	From      time.Time // inclusive lower bound on CreatedAt
+	To        time.Time // exclusive upper bound on CreatedAt
+	Cursor    string    // ID of the last payment of the previous page
+	Limit     int
+}
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 200
+)
+
+// CreatePayment stores a new payment in the received state and assigns its ID
+func (s *Store) CreatePayment(p *Payment) error {
+	now := time.Now().UTC()
+	p.ID = newID(now)
+	p.Status = StatusReceived
+	p.CreatedAt = now
+	p.UpdatedAt = now
+	p.History = []StatusChange{{Status: StatusReceived, At: now}}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return putPayment(tx, p)
+	})
+}
+
+// GetPayment returns the payment with the given ID
+func (s *Store) GetPayment(id string) (*Payment, error) {
+	var p *Payment
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+		p, err = getPayment(tx, id)
+		return err
+	})
+	return p, err
+}
+
+// FindPaymentByTxHash returns the payment that produced a transaction on a network
+func (s *Store) FindPaymentByTxHash(network, txHash string) (*Payment, error) {
+	var p *Payment
+	err := s.db.View(func(tx *bolt.Tx) error {
+		id := tx.Bucket(bucketPaymentsByTx).Get(txIndexKey(network, txHash))
+		if id == nil {
+			return ErrNotFound
+		}
+		var err error
+		p, err = getPayment(tx, string(id))
+		return err
+	})
+	return p, err
+}
+
+// MarkSubmitting moves a queued payment to submitting when a worker starts sending it
+func (s *Store) MarkSubmitting(id string) (*Payment, error) {
+	return s.UpdatePayment(id, func(p *Payment) error {
+		return transition(p, StatusSubmitting, "")
+	})
+}
+
+// MarkSubmitted records the transaction hash and fee, which may be nil, and moves the payment to submitted
+func (s *Store) MarkSubmitted(id, txHash, paymaster string, fee *Fee) (*Payment, error) {
+	return s.UpdatePayment(id, func(p *Payment) error {
+		p.TxHash = txHash
+		p.Paymaster = paymaster
+		p.Fee = fee
+		return transition(p, StatusSubmitted, "")
+	})
+}
+
+// ReplaceTransaction makes replacement the transaction of the submitted payment that was sent as
+// txHash, keeping the previous one in the payment's replaced transactions. Every hash the payment
+// had stays indexed, so lookups by an earlier hash still find it. Replacing with a hash the payment
+// had before, when an earlier version was mined after all, restores that version.
+func (s *Store) ReplaceTransaction(network, txHash, replacement string, fee *Fee, cancelled bool) (*Payment, error) {
+	var p *Payment
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		id := tx.Bucket(bucketPaymentsByTx).Get(txIndexKey(network, txHash))
+		if id == nil {
+			return ErrNotFound
+		}
+		var err error
+		p, err = getPayment(tx, string(id))
+		if err != nil {
+			return err
+		}
+		if p.Status != StatusSubmitted {
+			return fmt.Errorf("cannot replace the transaction of %s payment %s", p.Status, p.ID)
+		}
+		if strings.EqualFold(p.TxHash, replacement) {
+			return nil
+		}
+
+		now := time.Now().UTC()
+		replaced := []ReplacedTx{}
+		for _, r := range p.Replaced {
+			if !strings.EqualFold(r.TxHash, replacement) {
+				replaced = append(replaced, r)
+			}
+		}
+		p.Replaced = append(replaced, ReplacedTx{TxHash: p.TxHash, Fee: p.Fee, Cancelled: p.Cancelled, ReplacedAt: now})
+		p.TxHash = replacement
+		p.Fee = fee
+		p.Cancelled = cancelled
+		p.UpdatedAt = now
+		return putPayment(tx, p)
+	})
+	return p, err
+}
+
+// MarkConfirmed moves a submitted payment to confirmed
+func (s *Store) MarkConfirmed(id string) (*Payment, error) {
+	return s.UpdatePayment(id, func(p *Payment) error {
+		return transition(p, StatusConfirmed, "")
+	})
+}
+
+// MarkFailed moves a payment to failed with the given business status code and reason
+func (s *Store) MarkFailed(id string, code int, reason string) (*Payment, error) {
+	return s.UpdatePayment(id, func(p *Payment) error {
+		if err := transition(p, StatusFailed, reason); err != nil {
+			return err
+		}
+		p.ErrorCode = code
+		return nil
+	})
+}
+
+// FailInterruptedPayments fails every payment still in the received state, which after a restart
+// means it was never sent. It returns the IDs of the failed payments.
+func (s *Store) FailInterruptedPayments(code int, reason string) ([]string, error) {
+	return s.moveAll(StatusReceived, StatusFailed, code, reason)
+}
+
+// FlagUnresolvedSubmissions moves every payment still in the submitting state to
+// needs_reconciliation. After a restart its transaction may have reached the chain, so only a
+// look at the payer's on-chain state can tell whether it was paid. It returns the IDs of the
+// flagged payments.
+func (s *Store) FlagUnresolvedSubmissions(code int, reason string) ([]string, error) {
+	return s.moveAll(StatusSubmitting, StatusNeedsReconciliation, code, reason)
+}
+
+// moveAll moves every payment in status from to status to, with a business status code and reason
+func (s *Store) moveAll(from, to PaymentStatus, code int, reason string) ([]string, error) {
+	var moved []string
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		// Collect first; bbolt cursors must not be used while the bucket is modified
+		var matching []*Payment
+		c := tx.Bucket(bucketPayments).Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			var p Payment
+			if err := json.Unmarshal(v, &p); err != nil {
+				return fmt.Errorf("failed to decode payment %s: %w", k, err)
+			}
+			if p.Status == from {
+				matching = append(matching, &p)
+			}
+		}
+
+		for _, p := range matching {
+			if err := transition(p, to, reason); err != nil {
+				return err
+			}
+			p.ErrorCode = code
+			p.UpdatedAt = time.Now().UTC()
+			if err := putPayment(tx, p); err != nil {
+				return err
+			}
+			moved = append(moved, p.ID)
+		}
+		return nil
+	})
+	return moved, err
+}
+
+// UpdatePayment applies fn to the stored payment inside a single transaction
+func (s *Store) UpdatePayment(id string, fn func(p *Payment) error) (*Payment, error) {
+	var p *Payment
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		var err error
+		p, err = getPayment(tx, id)
+		if err != nil {
+			return err
+		}
+		if err := fn(p); err != nil {
+			return err
+		}
+		p.UpdatedAt = time.Now().UTC()
+		return putPayment(tx, p)
+	})
+	return p, err
+}
+
+// ListPayments returns matching payments, newest first, and the cursor for the next page
+func (s *Store) ListPayments(filter PaymentFilter) ([]*Payment, string, error) {
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultPageSize
+	}
+	if limit > maxPageSize {
+		limit = maxPageSize
+	}
+
+	payments := make([]*Payment, 0, limit)
+	nextCursor := ""
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(bucketPayments).Cursor()
+
+		// Start just before the cursor; IDs sort by creation time so Prev walks to older entries.
+		// A cursor past the newest entry did not come from a previous page and matches nothing.
+		k, v := c.Last()
+		if filter.Cursor != "" {
+			if k, _ = c.Seek([]byte(filter.Cursor)); k != nil {
+				k, v = c.Prev()
+			}
+		}
+
+		for ; k != nil; k, v = c.Prev() {
+			var p Payment
+			if err := json.Unmarshal(v, &p); err != nil {
+				return fmt.Errorf("failed to decode payment %s: %w", k, err)
+			}
+			if !filter.From.IsZero() && p.CreatedAt.Before(filter.From) {
+				// Keys are ordered by creation time, nothing older can match
+				break
+			}
+			if !filter.matches(&p) {
+				continue
+			}
+			if len(payments) == limit {
+				nextCursor = payments[len(payments)-1].ID
+				break
+			}
+			payments = append(payments, &p)
+		}
+		return nil
+	})
+	return payments, nextCursor, err
+}
+
+func (f PaymentFilter) matches(p *Payment) bool {
+	if f.PayerAddr != "" && !strings.EqualFold(f.PayerAddr, p.PayerAddr) {
+		return false
+	}
+	if f.PayeeAddr != "" && !strings.EqualFold(f.PayeeAddr, p.PayeeAddr) {
+		return false
+	}
+	if f.Network != "" && !strings.EqualFold(f.Network, p.Network) {
+		return false
+	}
+	if f.Status != "" && f.Status != p.Status {
+		return false
+	}
+	if !f.To.IsZero() && !p.CreatedAt.Before(f.To) {
+		return false
+	}
+	return true
+}
+
+// transition moves p to status, rejecting moves the lifecycle does not allow
+func transition(p *Payment, status PaymentStatus, reason string) error {
+	if p.Status == status {
+		return nil
+	}
+
+	allowed := false
+	for _, next := range allowedTransitions[p.Status] {
+		if next == status {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return fmt.Errorf("invalid payment status transition %s -> %s", p.Status, status)
+	}
+
+	now := time.Now().UTC()
+	p.Status = status
+	p.Error = reason
+	p.History = append(p.History, StatusChange{Status: status, At: now, Error: reason})
+	return nil
+}
+
+func getPayment(tx *bolt.Tx, id string) (*Payment, error) {
+	raw := tx.Bucket(bucketPayments).Get([]byte(id))
+	if raw == nil {
+		return nil, ErrNotFound
+	}
+	var p Payment
+	if err := json.Unmarshal(raw, &p); err != nil {
+		return nil, fmt.Errorf("failed to decode payment %s: %w", id, err)
+	}
+	return &p, nil
+}
+
+func putPayment(tx *bolt.Tx, p *Payment) error {
+	raw, err := json.Marshal(p)
+	if err != nil {
+		return fmt.Errorf("failed to encode payment: %w", err)
+	}
+	if err := tx.Bucket(bucketPayments).Put([]byte(p.ID), raw); err != nil {
+		return err
+	}
+	if p.TxHash != "" {
+		return tx.Bucket(bucketPaymentsByTx).Put(txIndexKey(p.Network, p.TxHash), []byte(p.ID))
+	}
+	return nil
+}
+
+// txIndexKey builds the payments_by_tx key; EVM/Aptos hashes are case-insensitive hex
+func txIndexKey(network, txHash string) []byte {
+	hash := txHash
+	if strings.HasPrefix(hash, "0x") || strings.HasPrefix(hash, "0X") {
+		hash = strings.ToLower(hash)
+	}
+	return []byte(strings.ToLower(network) + "|" + hash)
+}
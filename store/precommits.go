@@ -0,0 +1,131 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// ErrPrecommitUnavailable is returned when a precommit is already used by another payment
+var ErrPrecommitUnavailable = errors.New("precommit already used")
+
+// PrecommitStatus is the lifecycle state of a merchant precommit
+type PrecommitStatus string
+
+const (
+	PrecommitCommitted PrecommitStatus = "committed" // on chain, waiting for its payment
+	PrecommitUsed      PrecommitStatus = "used"      // claimed by a payment
+)
+
+// Precommit records a merchant_precommit made through the API. The OTP is not stored;
+// the completing payment request has to provide it again.
+type Precommit struct {
+	ID         string          `json:"id"`
+	PayerAddr  string          `json:"payer_addr"`
+	PayeeAddr  string          `json:"payee_addr"`
+	Amount     uint64          `json:"amount"`
+	Network    string          `json:"network"`
+	Currency   string          `json:"currency"`
+	CoinType   string          `json:"coin_type"`
+	CommitHash string          `json:"commit_hash"` // 0x-prefixed hex
+	TxHash     string          `json:"transaction_hash"`
+	Paymaster  string          `json:"paymaster,omitempty"` // key that made the precommit and has to complete it
+	Status     PrecommitStatus `json:"status"`
+	PaymentID  string          `json:"payment_id,omitempty"`
+	ExpiresAt  time.Time       `json:"expires_at,omitempty"`
+	CreatedAt  time.Time       `json:"created_at"`
+	UpdatedAt  time.Time       `json:"updated_at"`
+}
+
+// Expired reports whether the chain-reported expiry of the precommit has passed
+func (p *Precommit) Expired(now time.Time) bool {
+	return !p.ExpiresAt.IsZero() && !now.Before(p.ExpiresAt)
+}
+
+// CreatePrecommit stores a precommit that is already on chain and assigns its ID
+func (s *Store) CreatePrecommit(p *Precommit) error {
+	now := time.Now().UTC()
+	p.ID = newID(now)
+	p.Status = PrecommitCommitted
+	p.CreatedAt = now
+	p.UpdatedAt = now
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return putPrecommit(tx, p)
+	})
+}
+
+// GetPrecommit returns the precommit with the given ID
+func (s *Store) GetPrecommit(id string) (*Precommit, error) {
+	var p *Precommit
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+		p, err = getPrecommit(tx, id)
+		return err
+	})
+	return p, err
+}
+
+// ClaimPrecommit atomically assigns a committed precommit to a payment
+func (s *Store) ClaimPrecommit(id, paymentID string) (*Precommit, error) {
+	return s.updatePrecommit(id, func(p *Precommit) error {
+		if p.Status != PrecommitCommitted {
+			return ErrPrecommitUnavailable
+		}
+		p.Status = PrecommitUsed
+		p.PaymentID = paymentID
+		return nil
+	})
+}
+
+// ReleasePrecommit returns a precommit claimed by paymentID to committed after that payment failed
+func (s *Store) ReleasePrecommit(id, paymentID string) (*Precommit, error) {
+	return s.updatePrecommit(id, func(p *Precommit) error {
+		if p.Status != PrecommitUsed || p.PaymentID != paymentID {
+			return ErrPrecommitUnavailable
+		}
+		p.Status = PrecommitCommitted
+		p.PaymentID = ""
+		return nil
+	})
+}
+
+func (s *Store) updatePrecommit(id string, fn func(p *Precommit) error) (*Precommit, error) {
+	var p *Precommit
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		var err error
+		p, err = getPrecommit(tx, id)
+		if err != nil {
+			return err
+		}
+		if err := fn(p); err != nil {
+			return err
+		}
+		p.UpdatedAt = time.Now().UTC()
+		return putPrecommit(tx, p)
+	})
+	return p, err
+}
+
+func getPrecommit(tx *bolt.Tx, id string) (*Precommit, error) {
+	raw := tx.Bucket(bucketPrecommits).Get([]byte(id))
+	if raw == nil {
+		return nil, ErrNotFound
+	}
+	var p Precommit
+	if err := json.Unmarshal(raw, &p); err != nil {
+		return nil, fmt.Errorf("failed to decode precommit %s: %w", id, err)
+	}
+	return &p, nil
+}
+
+func putPrecommit(tx *bolt.Tx, p *Precommit) error {
+	raw, err := json.Marshal(p)
+	if err != nil {
+		return fmt.Errorf("failed to encode precommit: %w", err)
+	}
+	return tx.Bucket(bucketPrecommits).Put([]byte(p.ID), raw)
+}
@@ -0,0 +1,56 @@
+package store
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// ErrNotFound is returned when a record does not exist in the store
+var ErrNotFound = errors.New("record not found")
+
+// Store is the embedded bbolt database backing the payment ledger
+type Store struct {
+	db *bolt.DB
+}
+
+// Open opens (or creates) the database at path and applies pending migrations
+func Open(path string) (*Store, error) {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create storage directory: %w", err)
+		}
+	}
+
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open storage at %s: %w", path, err)
+	}
+
+	s := &Store{db: db}
+	if err := s.migrate(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// Close releases the underlying database file
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// newID returns a random identifier whose lexical order follows creation time
+func newID(now time.Time) string {
+	var suffix [6]byte
+	if _, err := rand.Read(suffix[:]); err != nil {
+		panic("failed to read random bytes: " + err.Error())
+	}
+	return fmt.Sprintf("%016x%s", now.UnixNano(), hex.EncodeToString(suffix[:]))
+}
@@ -0,0 +1,420 @@
+package store
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func openTestStore(t *testing.T) *Store {
+	t.Helper()
+	s, err := Open(filepath.Join(t.TempDir(), "nested", "ledger.db"))
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestOpenAppliesMigrations(t *testing.T) {
+	s := openTestStore(t)
+
+	version, err := s.SchemaVersion()
+	if err != nil {
+		t.Fatalf("schema version: %v", err)
+	}
+	if want := migrations[len(migrations)-1].version; version != want {
+		t.Errorf("expected schema version %d, got %d", want, version)
+	}
+}
+
+func TestPaymentLifecycle(t *testing.T) {
+	s := openTestStore(t)
+
+	p := &Payment{PayerAddr: "0xAA", PayeeAddr: "0xBB", Amount: 10, Network: "sepolia", Currency: "ETH"}
+	if err := s.CreatePayment(p); err != nil {
+		t.Fatalf("create payment: %v", err)
+	}
+	if p.ID == "" || p.Status != StatusReceived {
+		t.Fatalf("unexpected new payment: %+v", p)
+	}
+
+	if _, err := s.MarkConfirmed(p.ID); err == nil {
+		t.Error("expected received -> confirmed to be rejected")
+	}
+	if _, err := s.MarkSubmitted(p.ID, "0xABCDEF", "0xpaymaster", &Fee{Mode: "estimate", Unit: "wei/gas", Price: 42}); err != nil {
+		t.Fatalf("mark submitted: %v", err)
+	}
+
+	found, err := s.FindPaymentByTxHash("Sepolia", "0xabcdef")
+	if err != nil {
+		t.Fatalf("find by tx hash: %v", err)
+	}
+	if found.ID != p.ID {
+		t.Errorf("expected payment %s, got %s", p.ID, found.ID)
+	}
+	if found.Fee == nil || found.Fee.Price != 42 {
+		t.Errorf("expected the fee to be stored, got %+v", found.Fee)
+	}
+	if found.Paymaster != "0xpaymaster" {
+		t.Errorf("expected the paymaster to be stored, got %q", found.Paymaster)
+	}
+
+	confirmed, err := s.MarkConfirmed(p.ID)
+	if err != nil {
+		t.Fatalf("mark confirmed: %v", err)
+	}
+	if len(confirmed.History) != 3 {
+		t.Errorf("expected 3 history entries, got %d", len(confirmed.History))
+	}
+	if _, err := s.MarkFailed(p.ID, 0, "late failure"); err == nil {
+		t.Error("expected confirmed -> failed to be rejected")
+	}
+
+	if _, err := s.GetPayment("missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestReplaceTransaction(t *testing.T) {
+	s := openTestStore(t)
+
+	p := &Payment{PayerAddr: "0xAA", Network: "sepolia"}
+	if err := s.CreatePayment(p); err != nil {
+		t.Fatalf("create payment: %v", err)
+	}
+	if _, err := s.MarkSubmitted(p.ID, "0x01", "", &Fee{Price: 10}); err != nil {
+		t.Fatalf("mark submitted: %v", err)
+	}
+
+	replaced, err := s.ReplaceTransaction("sepolia", "0x01", "0x02", &Fee{Price: 12}, false)
+	if err != nil {
+		t.Fatalf("replace transaction: %v", err)
+	}
+	if replaced.TxHash != "0x02" || replaced.Fee.Price != 12 || len(replaced.Replaced) != 1 || replaced.Replaced[0].TxHash != "0x01" {
+		t.Errorf("unexpected payment after speed-up: %+v", replaced)
+	}
+
+	// Replacements are reported against the original hash, which still finds the payment
+	cancelled, err := s.ReplaceTransaction("sepolia", "0x01", "0x03", &Fee{Price: 15}, true)
+	if err != nil {
+		t.Fatalf("cancel transaction: %v", err)
+	}
+	if cancelled.TxHash != "0x03" || !cancelled.Cancelled || len(cancelled.Replaced) != 2 {
+		t.Errorf("unexpected payment after cancel: %+v", cancelled)
+	}
+	for _, hash := range []string{"0x01", "0x02", "0x03"} {
+		if found, err := s.FindPaymentByTxHash("sepolia", hash); err != nil || found.ID != p.ID {
+			t.Errorf("expected %s to find payment %s, got %v (%v)", hash, p.ID, found, err)
+		}
+	}
+
+	// The speed-up was mined before the cancellation
+	restored, err := s.ReplaceTransaction("sepolia", "0x01", "0x02", &Fee{Price: 12}, false)
+	if err != nil {
+		t.Fatalf("restore transaction: %v", err)
+	}
+	if restored.TxHash != "0x02" || restored.Cancelled || len(restored.Replaced) != 2 {
+		t.Errorf("unexpected payment after restoring the speed-up: %+v", restored)
+	}
+
+	if _, err := s.MarkConfirmed(p.ID); err != nil {
+		t.Fatalf("mark confirmed: %v", err)
+	}
+	if _, err := s.ReplaceTransaction("sepolia", "0x01", "0x04", nil, false); err == nil {
+		t.Error("expected replacing a confirmed payment to be rejected")
+	}
+	if _, err := s.ReplaceTransaction("sepolia", "0xff", "0x04", nil, false); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound for an unknown hash, got %v", err)
+	}
+}
+
+func TestListPaymentsFiltersAndPaginates(t *testing.T) {
+	s := openTestStore(t)
+
+	var ids []string
+	for i := 0; i < 5; i++ {
+		payee := "0xBB"
+		if i%2 == 1 {
+			payee = "0xCC"
+		}
+		p := &Payment{PayerAddr: "0xAA", PayeeAddr: payee, Amount: uint64(i), Network: "sepolia"}
+		if err := s.CreatePayment(p); err != nil {
+			t.Fatalf("create payment: %v", err)
+		}
+		ids = append(ids, p.ID)
+	}
+
+	page, cursor, err := s.ListPayments(PaymentFilter{Limit: 2})
+	if err != nil {
+		t.Fatalf("list payments: %v", err)
+	}
+	if len(page) != 2 || page[0].ID != ids[4] || page[1].ID != ids[3] || cursor != ids[3] {
+		t.Fatalf("unexpected first page: %d entries, cursor %q", len(page), cursor)
+	}
+
+	page, cursor, err = s.ListPayments(PaymentFilter{Limit: 2, Cursor: cursor})
+	if err != nil {
+		t.Fatalf("list payments: %v", err)
+	}
+	if len(page) != 2 || page[0].ID != ids[2] || page[1].ID != ids[1] {
+		t.Fatalf("unexpected second page")
+	}
+
+	page, cursor, err = s.ListPayments(PaymentFilter{Limit: 2, Cursor: cursor})
+	if err != nil {
+		t.Fatalf("list payments: %v", err)
+	}
+	if len(page) != 1 || page[0].ID != ids[0] || cursor != "" {
+		t.Fatalf("unexpected last page: %d entries, cursor %q", len(page), cursor)
+	}
+
+	// A cursor past the newest payment must not start over from the top
+	page, _, err = s.ListPayments(PaymentFilter{Limit: 2, Cursor: "zzzz"})
+	if err != nil || len(page) != 0 {
+		t.Fatalf("expected an empty page for an unknown cursor, got %d entries (%v)", len(page), err)
+	}
+
+	page, _, err = s.ListPayments(PaymentFilter{PayeeAddr: "0xcc"})
+	if err != nil {
+		t.Fatalf("list payments: %v", err)
+	}
+	if len(page) != 2 {
+		t.Errorf("expected 2 payments for payee 0xcc, got %d", len(page))
+	}
+
+	page, _, err = s.ListPayments(PaymentFilter{From: time.Now().Add(time.Hour)})
+	if err != nil {
+		t.Fatalf("list payments: %v", err)
+	}
+	if len(page) != 0 {
+		t.Errorf("expected no payments after the future bound, got %d", len(page))
+	}
+}
+
+func TestIdempotencyRecords(t *testing.T) {
+	s := openTestStore(t)
+
+	record, claimed, err := s.BeginIdempotentRequest("key-1", "hash-a", time.Hour)
+	if err != nil || !claimed || record.State != IdempotencyInProgress {
+		t.Fatalf("expected fresh claim, got %+v claimed=%v err=%v", record, claimed, err)
+	}
+	if err := s.CompleteIdempotentRequest("key-1", 200, []byte(`{"code":1001}`)); err != nil {
+		t.Fatalf("complete: %v", err)
+	}
+
+	record, claimed, err = s.BeginIdempotentRequest("key-1", "hash-b", time.Hour)
+	if err != nil || claimed {
+		t.Fatalf("expected existing record, claimed=%v err=%v", claimed, err)
+	}
+	if record.RequestHash != "hash-a" || record.State != IdempotencyCompleted || string(record.Body) != `{"code":1001}` {
+		t.Errorf("unexpected stored record: %+v", record)
+	}
+
+	// Expired keys are purged and can be claimed again
+	if _, _, err := s.BeginIdempotentRequest("key-2", "hash-c", time.Nanosecond); err != nil {
+		t.Fatalf("claim key-2: %v", err)
+	}
+	removed, err := s.PurgeExpiredIdempotencyRecords(time.Now().Add(time.Second))
+	if err != nil || removed != 1 {
+		t.Fatalf("expected 1 purged record, got %d err=%v", removed, err)
+	}
+	if _, claimed, _ := s.BeginIdempotentRequest("key-2", "hash-d", time.Hour); !claimed {
+		t.Error("expected purged key to be claimable")
+	}
+}
+
+func TestFailInterruptedPayments(t *testing.T) {
+	s := openTestStore(t)
+
+	queued := &Payment{PayerAddr: "0xAA", Network: "sepolia", Async: true}
+	sending := &Payment{PayerAddr: "0xAA", Network: "sepolia", Async: true}
+	sent := &Payment{PayerAddr: "0xAA", Network: "sepolia"}
+	for _, p := range []*Payment{queued, sending, sent} {
+		if err := s.CreatePayment(p); err != nil {
+			t.Fatalf("create payment: %v", err)
+		}
+	}
+	if _, err := s.MarkSubmitting(sending.ID); err != nil {
+		t.Fatalf("mark submitting: %v", err)
+	}
+	if _, err := s.MarkSubmitted(sent.ID, "0x01", "", nil); err != nil {
+		t.Fatalf("mark submitted: %v", err)
+	}
+
+	failed, err := s.FailInterruptedPayments(2202, "restarted")
+	if err != nil {
+		t.Fatalf("fail interrupted payments: %v", err)
+	}
+	if len(failed) != 1 || failed[0] != queued.ID {
+		t.Fatalf("expected only the queued payment to fail, got %v", failed)
+	}
+	if p, _ := s.GetPayment(queued.ID); p.Status != StatusFailed || p.ErrorCode != 2202 || p.Error != "restarted" {
+		t.Errorf("unexpected interrupted payment: %+v", p)
+	}
+
+	// The payment being sent may be on chain, so it is flagged rather than failed
+	unresolved, err := s.FlagUnresolvedSubmissions(2202, "check the chain")
+	if err != nil {
+		t.Fatalf("flag unresolved submissions: %v", err)
+	}
+	if len(unresolved) != 1 || unresolved[0] != sending.ID {
+		t.Fatalf("expected the submitting payment to be flagged, got %v", unresolved)
+	}
+	if p, _ := s.GetPayment(sending.ID); p.Status != StatusNeedsReconciliation || p.Error != "check the chain" {
+		t.Errorf("unexpected unresolved payment: %+v", p)
+	}
+	if p, _ := s.GetPayment(sent.ID); p.Status != StatusSubmitted {
+		t.Errorf("submitted payment should be untouched, got %s", p.Status)
+	}
+}
+
+func TestPrecommitClaimAndRelease(t *testing.T) {
+	s := openTestStore(t)
+
+	p := &Precommit{PayerAddr: "0xAA", PayeeAddr: "0xBB", Amount: 5, Network: "sepolia", CommitHash: "0x01"}
+	if err := s.CreatePrecommit(p); err != nil {
+		t.Fatalf("create precommit: %v", err)
+	}
+
+	if _, err := s.ClaimPrecommit(p.ID, "payment-1"); err != nil {
+		t.Fatalf("claim: %v", err)
+	}
+	if _, err := s.ClaimPrecommit(p.ID, "payment-2"); !errors.Is(err, ErrPrecommitUnavailable) {
+		t.Fatalf("expected second claim to fail, got %v", err)
+	}
+	if _, err := s.ReleasePrecommit(p.ID, "payment-2"); !errors.Is(err, ErrPrecommitUnavailable) {
+		t.Fatalf("expected release by a non-owner to fail, got %v", err)
+	}
+
+	released, err := s.ReleasePrecommit(p.ID, "payment-1")
+	if err != nil {
+		t.Fatalf("release: %v", err)
+	}
+	if released.Status != PrecommitCommitted || released.PaymentID != "" {
+		t.Errorf("unexpected released precommit: %+v", released)
+	}
+	if _, err := s.ClaimPrecommit(p.ID, "payment-2"); err != nil {
+		t.Errorf("expected released precommit to be claimable: %v", err)
+	}
+}
+
+func TestWebhookDeliveryQueue(t *testing.T) {
+	s := openTestStore(t)
+
+	sub := &WebhookSubscription{URL: "https://merchant.example/hooks", Secret: "whsec", PayeeAddr: "0xBB", Events: []string{EventPaymentConfirmed}}
+	if err := s.CreateWebhookSubscription(sub); err != nil {
+		t.Fatalf("create subscription: %v", err)
+	}
+	p := &Payment{PayerAddr: "0xAA", PayeeAddr: "0xbb", Amount: 10, Network: "sepolia"}
+	if err := s.CreatePayment(p); err != nil {
+		t.Fatalf("create payment: %v", err)
+	}
+	if !sub.Matches(EventPaymentConfirmed, p) || sub.Matches(EventPaymentFailed, p) {
+		t.Error("expected the subscription to match only confirmed events of its payee")
+	}
+
+	d, created, err := s.EnqueueWebhookDelivery(sub, EventPaymentConfirmed, p, []byte(`{"event":"payment.confirmed"}`))
+	if err != nil || !created {
+		t.Fatalf("enqueue: created=%v err=%v", created, err)
+	}
+	if again, created, _ := s.EnqueueWebhookDelivery(sub, EventPaymentConfirmed, p, nil); created || again.ID != d.ID {
+		t.Error("expected an event to be queued once per subscription")
+	}
+
+	now := time.Now()
+	claimed, err := s.ClaimWebhookDeliveries(now, time.Minute, 10)
+	if err != nil || len(claimed) != 1 || claimed[0].ID != d.ID {
+		t.Fatalf("expected to claim the delivery, got %v (%v)", claimed, err)
+	}
+	if claimed, _ := s.ClaimWebhookDeliveries(now, time.Minute, 10); len(claimed) != 0 {
+		t.Error("expected a claimed delivery to be leased")
+	}
+	if claimed, _ := s.ClaimWebhookDeliveries(now.Add(2*time.Minute), time.Minute, 10); len(claimed) != 1 {
+		t.Error("expected the delivery back once its lease ran out")
+	}
+
+	// A failed attempt schedules a retry; the last one dead-letters the delivery
+	retryAt := now.Add(time.Hour)
+	if _, err := s.RecordWebhookAttempt(d.ID, DeliveryAttempt{At: now, StatusCode: 500}, DeliveryPending, retryAt); err != nil {
+		t.Fatalf("record attempt: %v", err)
+	}
+	if claimed, _ := s.ClaimWebhookDeliveries(retryAt.Add(-time.Second), time.Minute, 10); len(claimed) != 0 {
+		t.Error("expected no delivery before its retry is due")
+	}
+	if claimed, _ := s.ClaimWebhookDeliveries(retryAt, time.Minute, 10); len(claimed) != 1 {
+		t.Fatal("expected the retry to come up")
+	}
+	dead, err := s.RecordWebhookAttempt(d.ID, DeliveryAttempt{At: retryAt, Error: "connection refused"}, DeliveryDead, time.Time{})
+	if err != nil || dead.Status != DeliveryDead || dead.Tries != 2 || len(dead.Attempts) != 2 || dead.NextAttemptAt != nil {
+		t.Fatalf("expected a dead letter after two attempts, got %+v (%v)", dead, err)
+	}
+	if claimed, _ := s.ClaimWebhookDeliveries(retryAt.Add(24*time.Hour), time.Minute, 10); len(claimed) != 0 {
+		t.Error("expected a dead letter to leave the queue")
+	}
+	letters, _, err := s.ListWebhookDeliveries(DeliveryFilter{Status: DeliveryDead})
+	if err != nil || len(letters) != 1 {
+		t.Fatalf("expected one dead letter, got %d (%v)", len(letters), err)
+	}
+	if page, _, err := s.ListWebhookDeliveries(DeliveryFilter{Cursor: "zzzz"}); err != nil || len(page) != 0 {
+		t.Fatalf("expected an empty page for an unknown cursor, got %d entries (%v)", len(page), err)
+	}
+
+	redelivered, err := s.RedeliverWebhook(d.ID)
+	if err != nil || redelivered.Status != DeliveryPending || redelivered.Tries != 0 || len(redelivered.Attempts) != 2 {
+		t.Fatalf("expected a fresh retry schedule that keeps the log, got %+v (%v)", redelivered, err)
+	}
+	if claimed, _ := s.ClaimWebhookDeliveries(time.Now(), time.Minute, 10); len(claimed) != 1 {
+		t.Error("expected the redelivery to be due right away")
+	}
+
+	if err := s.DeleteWebhookSubscription(sub.ID); err != nil {
+		t.Fatalf("delete subscription: %v", err)
+	}
+	if _, err := s.GetWebhookSubscription(sub.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound after delete, got %v", err)
+	}
+}
+
+func TestChainEventsPaginateAndRollBack(t *testing.T) {
+	s := openTestStore(t)
+
+	cp := &IndexerCheckpoint{Network: "sepolia", Next: 31}
+	var events []*ChainEvent
+	for block := uint64(8); block <= 30; block += 2 {
+		events = append(events, &ChainEvent{Type: "PaymentCompleted", BlockNumber: block, Account: "0xAA"})
+	}
+	if err := s.SaveChainEvents(cp, events); err != nil {
+		t.Fatalf("save events: %v", err)
+	}
+	// Another network's events never show up in the listing
+	if err := s.SaveChainEvents(&IndexerCheckpoint{Network: "sepolia-2", Next: 11}, []*ChainEvent{{Type: "PaymentCompleted", BlockNumber: 10}}); err != nil {
+		t.Fatalf("save events: %v", err)
+	}
+
+	page, cursor, err := s.ListChainEvents(ChainEventFilter{Network: "sepolia", Account: "0xaa", Limit: 5})
+	if err != nil || len(page) != 5 || page[0].BlockNumber != 30 || cursor != page[4].ID {
+		t.Fatalf("expected the latest five events, got %d (%v)", len(page), err)
+	}
+	rest, cursor, err := s.ListChainEvents(ChainEventFilter{Network: "sepolia", Cursor: cursor})
+	if err != nil || len(rest) != 7 || rest[0].BlockNumber != 20 || rest[6].BlockNumber != 8 || cursor != "" {
+		t.Fatalf("expected the seven older events, got %d (%v)", len(rest), err)
+	}
+
+	cp.Next = 21
+	removed, err := s.RollbackChainEvents(cp, 21)
+	if err != nil || removed != 5 {
+		t.Fatalf("expected five events after block 20 to be removed, got %d (%v)", removed, err)
+	}
+	remaining, _, _ := s.ListChainEvents(ChainEventFilter{Network: "sepolia"})
+	if len(remaining) != 7 || remaining[0].BlockNumber != 20 {
+		t.Errorf("expected the events up to block 20 to remain, got %d", len(remaining))
+	}
+	if stored, err := s.GetIndexerCheckpoint("sepolia"); err != nil || stored.Next != 21 {
+		t.Errorf("expected the checkpoint to move back, got %+v (%v)", stored, err)
+	}
+	if other, _, _ := s.ListChainEvents(ChainEventFilter{Network: "sepolia-2"}); len(other) != 1 {
+		t.Errorf("expected the other network to keep its event, got %d", len(other))
+	}
+}
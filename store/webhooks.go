@@ -0,0 +1,384 @@
+package store
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Payment lifecycle events delivered to merchant webhooks
+const (
+	EventPaymentSubmitted = "payment.submitted"
+	EventPaymentConfirmed = "payment.confirmed"
+	EventPaymentFailed    = "payment.failed"
+)
+
+// WebhookEvents lists every event a subscription can ask for
+var WebhookEvents = []string{EventPaymentSubmitted, EventPaymentConfirmed, EventPaymentFailed}
+
+// WebhookSubscription sends the lifecycle events of matching payments to a merchant URL. A
+// subscription scoped to both a payee and an API key only matches payments that have both.
+type WebhookSubscription struct {
+	ID        string    `json:"id"`
+	URL       string    `json:"url"`
+	Secret    string    `json:"secret"`               // HMAC-SHA256 key of the delivery signatures
+	PayeeAddr string    `json:"payee_addr,omitempty"` // matches payments to this payee
+	APIKeyID  string    `json:"api_key_id,omitempty"` // matches payments created with this API key
+	Events    []string  `json:"events,omitempty"`     // events to deliver; every event when empty
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Matches reports whether the subscription wants event for payment p
+func (w *WebhookSubscription) Matches(event string, p *Payment) bool {
+	if w.PayeeAddr != "" && !strings.EqualFold(w.PayeeAddr, p.PayeeAddr) {
+		return false
+	}
+	if w.APIKeyID != "" && w.APIKeyID != p.APIKeyID {
+		return false
+	}
+	if len(w.Events) == 0 {
+		return true
+	}
+	for _, e := range w.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// DeliveryStatus is the state of a webhook delivery
+type DeliveryStatus string
+
+const (
+	DeliveryPending   DeliveryStatus = "pending"   // waiting for its next attempt
+	DeliveryDelivered DeliveryStatus = "delivered" // the merchant answered 2xx
+	DeliveryDead      DeliveryStatus = "dead"      // every attempt failed; kept as a dead letter until redelivered
+)
+
+// DeliveryAttempt is one POST of a webhook delivery
+type DeliveryAttempt struct {
+	At         time.Time `json:"at"`
+	StatusCode int       `json:"status_code,omitempty"` // HTTP status of the answer, 0 when there was none
+	Error      string    `json:"error,omitempty"`
+	DurationMs int64     `json:"duration_ms"`
+}
+
+// WebhookDelivery is one event sent to one subscription, with the log of its attempts
+type WebhookDelivery struct {
+	ID             string            `json:"id"`
+	SubscriptionID string            `json:"subscription_id"`
+	Event          string            `json:"event"`
+	PaymentID      string            `json:"payment_id"`
+	Payload        json.RawMessage   `json:"payload"`
+	Status         DeliveryStatus    `json:"status"`
+	Tries          int               `json:"tries"` // attempts since the delivery was last queued
+	NextAttemptAt  *time.Time        `json:"next_attempt_at,omitempty"`
+	Attempts       []DeliveryAttempt `json:"attempts"`
+	CreatedAt      time.Time         `json:"created_at"`
+	UpdatedAt      time.Time         `json:"updated_at"`
+}
+
+// DeliveryFilter selects deliveries in ListWebhookDeliveries. Empty fields match everything.
+type DeliveryFilter struct {
+	SubscriptionID string
+	PaymentID      string
+	Status         DeliveryStatus
+	Cursor         string // ID of the last delivery of the previous page
+	Limit          int
+}
+
+// CreateWebhookSubscription stores a new subscription and assigns its ID
+func (s *Store) CreateWebhookSubscription(w *WebhookSubscription) error {
+	now := time.Now().UTC()
+	w.ID = newID(now)
+	w.CreatedAt = now
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		raw, err := json.Marshal(w)
+		if err != nil {
+			return fmt.Errorf("failed to encode webhook subscription: %w", err)
+		}
+		return tx.Bucket(bucketWebhooks).Put([]byte(w.ID), raw)
+	})
+}
+
+// GetWebhookSubscription returns the subscription with the given ID
+func (s *Store) GetWebhookSubscription(id string) (*WebhookSubscription, error) {
+	var w *WebhookSubscription
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+		w, err = getWebhookSubscription(tx, id)
+		return err
+	})
+	return w, err
+}
+
+// ListWebhookSubscriptions returns every subscription, oldest first
+func (s *Store) ListWebhookSubscriptions() ([]*WebhookSubscription, error) {
+	subscriptions := []*WebhookSubscription{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketWebhooks).ForEach(func(k, v []byte) error {
+			var w WebhookSubscription
+			if err := json.Unmarshal(v, &w); err != nil {
+				return fmt.Errorf("failed to decode webhook subscription %s: %w", k, err)
+			}
+			subscriptions = append(subscriptions, &w)
+			return nil
+		})
+	})
+	return subscriptions, err
+}
+
+// DeleteWebhookSubscription removes a subscription. Its queued deliveries are dead-lettered
+// when they come up.
+func (s *Store) DeleteWebhookSubscription(id string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(bucketWebhooks)
+		if bucket.Get([]byte(id)) == nil {
+			return ErrNotFound
+		}
+		return bucket.Delete([]byte(id))
+	})
+}
+
+// EnqueueWebhookDelivery queues the delivery of event for payment p to subscription w, due right
+// away. An event is delivered once per subscription: when it was already queued, the existing
+// delivery is returned and created is false.
+func (s *Store) EnqueueWebhookDelivery(w *WebhookSubscription, event string, p *Payment, payload []byte) (d *WebhookDelivery, created bool, err error) {
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		eventKey := []byte(w.ID + "|" + event + "|" + p.ID)
+		events := tx.Bucket(bucketWebhookEvents)
+		if id := events.Get(eventKey); id != nil {
+			var err error
+			d, err = getWebhookDelivery(tx, string(id))
+			return err
+		}
+
+		now := time.Now().UTC()
+		d = &WebhookDelivery{
+			ID:             newID(now),
+			SubscriptionID: w.ID,
+			Event:          event,
+			PaymentID:      p.ID,
+			Payload:        payload,
+			Status:         DeliveryPending,
+			NextAttemptAt:  &now,
+			Attempts:       []DeliveryAttempt{},
+			CreatedAt:      now,
+			UpdatedAt:      now,
+		}
+		created = true
+		if err := events.Put(eventKey, []byte(d.ID)); err != nil {
+			return err
+		}
+		return putWebhookDelivery(tx, d)
+	})
+	return d, created, err
+}
+
+// GetWebhookDelivery returns the delivery with the given ID
+func (s *Store) GetWebhookDelivery(id string) (*WebhookDelivery, error) {
+	var d *WebhookDelivery
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+		d, err = getWebhookDelivery(tx, id)
+		return err
+	})
+	return d, err
+}
+
+// ClaimWebhookDeliveries returns up to limit pending deliveries that are due at now, oldest due
+// first. Each is pushed back by lease, so it is attempted again should the process stop before
+// the attempt is recorded.
+func (s *Store) ClaimWebhookDeliveries(now time.Time, lease time.Duration, limit int) ([]*WebhookDelivery, error) {
+	var claimed []*WebhookDelivery
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		// Collect first; bbolt cursors must not be used while the bucket is modified
+		var due []string
+		c := tx.Bucket(bucketWebhookQueue).Cursor()
+		for k, v := c.First(); k != nil && len(due) < limit; k, v = c.Next() {
+			if int64(binary.BigEndian.Uint64(k[:8])) > now.UnixNano() {
+				break
+			}
+			due = append(due, string(v))
+		}
+
+		queue := tx.Bucket(bucketWebhookQueue)
+		retryAt := now.Add(lease).UTC()
+		for _, id := range due {
+			d, err := getWebhookDelivery(tx, id)
+			if err != nil {
+				return err
+			}
+			if err := queue.Delete(queueKey(*d.NextAttemptAt, d.ID)); err != nil {
+				return err
+			}
+			d.NextAttemptAt = &retryAt
+			if err := putWebhookDelivery(tx, d); err != nil {
+				return err
+			}
+			claimed = append(claimed, d)
+		}
+		return nil
+	})
+	return claimed, err
+}
+
+// RecordWebhookAttempt logs an attempt of a claimed delivery and moves it to status. A pending
+// delivery is attempted again at retryAt.
+func (s *Store) RecordWebhookAttempt(id string, attempt DeliveryAttempt, status DeliveryStatus, retryAt time.Time) (*WebhookDelivery, error) {
+	return s.updateWebhookDelivery(id, func(d *WebhookDelivery) error {
+		d.Attempts = append(d.Attempts, attempt)
+		d.Tries++
+		d.Status = status
+		d.NextAttemptAt = nil
+		if status == DeliveryPending {
+			at := retryAt.UTC()
+			d.NextAttemptAt = &at
+		}
+		return nil
+	})
+}
+
+// RedeliverWebhook queues a delivery again, due right away, whatever its status. The retry
+// schedule starts over; the attempts made so far stay in its log.
+func (s *Store) RedeliverWebhook(id string) (*WebhookDelivery, error) {
+	return s.updateWebhookDelivery(id, func(d *WebhookDelivery) error {
+		now := time.Now().UTC()
+		d.Status = DeliveryPending
+		d.Tries = 0
+		d.NextAttemptAt = &now
+		return nil
+	})
+}
+
+// ListWebhookDeliveries returns matching deliveries, newest first, and the cursor for the next page
+func (s *Store) ListWebhookDeliveries(filter DeliveryFilter) ([]*WebhookDelivery, string, error) {
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultPageSize
+	}
+	if limit > maxPageSize {
+		limit = maxPageSize
+	}
+
+	deliveries := make([]*WebhookDelivery, 0, limit)
+	nextCursor := ""
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(bucketWebhookDeliveries).Cursor()
+
+		// IDs sort by creation time, like payment IDs, and an unknown cursor matches nothing
+		k, v := c.Last()
+		if filter.Cursor != "" {
+			if k, _ = c.Seek([]byte(filter.Cursor)); k != nil {
+				k, v = c.Prev()
+			}
+		}
+
+		for ; k != nil; k, v = c.Prev() {
+			var d WebhookDelivery
+			if err := json.Unmarshal(v, &d); err != nil {
+				return fmt.Errorf("failed to decode webhook delivery %s: %w", k, err)
+			}
+			if !filter.matches(&d) {
+				continue
+			}
+			if len(deliveries) == limit {
+				nextCursor = deliveries[len(deliveries)-1].ID
+				break
+			}
+			deliveries = append(deliveries, &d)
+		}
+		return nil
+	})
+	return deliveries, nextCursor, err
+}
+
+func (f DeliveryFilter) matches(d *WebhookDelivery) bool {
+	if f.SubscriptionID != "" && f.SubscriptionID != d.SubscriptionID {
+		return false
+	}
+	if f.PaymentID != "" && f.PaymentID != d.PaymentID {
+		return false
+	}
+	if f.Status != "" && f.Status != d.Status {
+		return false
+	}
+	return true
+}
+
+func (s *Store) updateWebhookDelivery(id string, fn func(d *WebhookDelivery) error) (*WebhookDelivery, error) {
+	var d *WebhookDelivery
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		var err error
+		d, err = getWebhookDelivery(tx, id)
+		if err != nil {
+			return err
+		}
+		// The queue entry is keyed by the due time, so it is replaced rather than updated
+		if d.NextAttemptAt != nil {
+			if err := tx.Bucket(bucketWebhookQueue).Delete(queueKey(*d.NextAttemptAt, d.ID)); err != nil {
+				return err
+			}
+		}
+		if err := fn(d); err != nil {
+			return err
+		}
+		d.UpdatedAt = time.Now().UTC()
+		return putWebhookDelivery(tx, d)
+	})
+	return d, err
+}
+
+func getWebhookSubscription(tx *bolt.Tx, id string) (*WebhookSubscription, error) {
+	raw := tx.Bucket(bucketWebhooks).Get([]byte(id))
+	if raw == nil {
+		return nil, ErrNotFound
+	}
+	var w WebhookSubscription
+	if err := json.Unmarshal(raw, &w); err != nil {
+		return nil, fmt.Errorf("failed to decode webhook subscription %s: %w", id, err)
+	}
+	return &w, nil
+}
+
+func getWebhookDelivery(tx *bolt.Tx, id string) (*WebhookDelivery, error) {
+	raw := tx.Bucket(bucketWebhookDeliveries).Get([]byte(id))
+	if raw == nil {
+		return nil, ErrNotFound
+	}
+	var d WebhookDelivery
+	if err := json.Unmarshal(raw, &d); err != nil {
+		return nil, fmt.Errorf("failed to decode webhook delivery %s: %w", id, err)
+	}
+	return &d, nil
+}
+
+// putWebhookDelivery stores d and, while it is pending, its entry in the delivery queue. Callers
+// remove the previous queue entry first when the due time changed.
+func putWebhookDelivery(tx *bolt.Tx, d *WebhookDelivery) error {
+	raw, err := json.Marshal(d)
+	if err != nil {
+		return fmt.Errorf("failed to encode webhook delivery: %w", err)
+	}
+	if err := tx.Bucket(bucketWebhookDeliveries).Put([]byte(d.ID), raw); err != nil {
+		return err
+	}
+	if d.Status == DeliveryPending && d.NextAttemptAt != nil {
+		return tx.Bucket(bucketWebhookQueue).Put(queueKey(*d.NextAttemptAt, d.ID), []byte(d.ID))
+	}
+	return nil
+}
+
+// queueKey orders the delivery queue by due time
+func queueKey(at time.Time, id string) []byte {
+	key := make([]byte, 8, 8+len(id))
+	binary.BigEndian.PutUint64(key, uint64(at.UnixNano()))
+	return append(key, id...)
+}
@@ -368,9 +368,9 @@ func GetSolanaTokenMappingByNetwork(cfg *config.Config, network string) map[stri
 			// Add native token (SOL)
 			tokenMapping[solanaNetwork.NativeToken.Symbol] = ""
 
-			// Add SPL tokens
+			// Add SPL tokens; mint addresses are base58 and therefore case-sensitive
 			for _, token := range solanaNetwork.Tokens {
-				tokenMapping[strings.ToUpper(token.Symbol)] = strings.ToLower(token.Address)
+				tokenMapping[strings.ToUpper(token.Symbol)] = token.Address
 			}
 
 			return tokenMapping